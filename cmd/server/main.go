@@ -2,73 +2,328 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // calendar functions need zone data even in minimal images
 
 	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/action/logging"
 	"github.com/gyaneshwarpardhi/ifttt/internal/action/points"
+	"github.com/gyaneshwarpardhi/ifttt/internal/actor"
+	"github.com/gyaneshwarpardhi/ifttt/internal/actorstate"
+	"github.com/gyaneshwarpardhi/ifttt/internal/anomaly"
 	"github.com/gyaneshwarpardhi/ifttt/internal/api"
+	"github.com/gyaneshwarpardhi/ifttt/internal/auth"
 	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/counter"
 	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/drift"
 	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/inbox"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+	"github.com/gyaneshwarpardhi/ifttt/internal/monitor"
+	"github.com/gyaneshwarpardhi/ifttt/internal/retention"
+	"github.com/gyaneshwarpardhi/ifttt/internal/schedule"
+	"github.com/gyaneshwarpardhi/ifttt/internal/shutdown"
+	"github.com/gyaneshwarpardhi/ifttt/internal/streak"
+	"github.com/gyaneshwarpardhi/ifttt/internal/workflow"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "render" {
+		os.Exit(runRender(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		os.Exit(runToken(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "perf-compare" {
+		os.Exit(runPerfCompare(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "seal-key" {
+		os.Exit(runSealKey(os.Args[2:]))
+	}
+
 	addr := flag.String("addr", ":8080", "HTTP listen address")
 	cfgPath := flag.String("config", "configs/rules.yaml", "Path to rules YAML config")
+	env := flag.String("env", "", "Environment overlay name (loads <config>.<env>.yaml)")
+	overlays := flag.String("overlay", "", "Comma-separated overlay files applied after the env overlay")
+	cfgRetries := flag.Int("config-retries", 0, "Startup: times to retry loading the config before falling back to -config-cache or exiting")
+	cfgBackoff := flag.Duration("config-retry-backoff", time.Second, "Startup: wait before the first config retry, doubled each time")
+	cfgMaxBackoff := flag.Duration("config-retry-max-backoff", 30*time.Second, "Startup: longest wait between config retries")
+	cfgCache := flag.String("config-cache", "", "Last-known-good config file, served when the config cannot be loaded at startup")
+	overridesPath := flag.String("overrides", "", "File persisting runtime scenario toggles (overlay format)")
+	inboxDSN := flag.String("inbox", "", "SQLite inbox path; enables at-least-once ingestion on POST /v1/events")
+	inboxDriver := flag.String("inbox-driver", "sqlite3", "database/sql driver name for the inbox")
+	workflowDSN := flag.String("workflow-store", "", "SQLite workflow store path (default: in-memory, lost on restart)")
+	workflowDriver := flag.String("workflow-driver", "sqlite3", "database/sql driver name for the workflow store")
+	scheduleDSN := flag.String("schedule-store", "", "SQLite store for emit_later events (default: in-memory, lost on restart)")
+	scheduleDriver := flag.String("schedule-driver", "sqlite3", "database/sql driver name for the schedule store")
+	counterDSN := flag.String("counter-store", "", "Shared SQL counter store for engine.counter_strategy: central")
+	counterDriver := flag.String("counter-driver", "sqlite3", "database/sql driver name for the counter store")
+	counterPeerToken := flag.String("counter-peer-token", "", "File holding the bearer token sent to counter_peers (a peer or admin token of theirs)")
+	streakDSN := flag.String("streak-store", "", "SQLite store for streak() state (default: in-memory, lost on restart)")
+	streakDriver := flag.String("streak-driver", "sqlite3", "database/sql driver name for the streak store")
+	storeCodec := flag.String("store-codec", event.CodecJSON, "Event encoding in the inbox and schedule store: json, msgpack or proto")
+	sealKeysPath := flag.String("seal-keys", "", "Key file; when set, the inbox, schedule and workflow stores encrypt what they write")
+	resealStores := flag.Bool("reseal", false, "At startup, rewrite store rows not encrypted under the primary -seal-keys key")
+	tokensPath := flag.String("tokens", "", "API tokens file; when set, event ingestion requires a scoped bearer token")
+	sourcesTimeout := flag.Duration("shutdown-sources-timeout", 15*time.Second, "Shutdown: time for HTTP requests, the inbox and the scheduler to stop taking events")
+	queueTimeout := flag.Duration("shutdown-queue-timeout", 10*time.Second, "Shutdown: time for workers to take the events still queued")
+	actionsTimeout := flag.Duration("shutdown-actions-timeout", 15*time.Second, "Shutdown: time for in-flight events and their actions to finish before they are cancelled")
+	sinksTimeout := flag.Duration("shutdown-sinks-timeout", 5*time.Second, "Shutdown: time for background loops to stop and stores to close")
 	flag.Parse()
 
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	logLevel := new(slog.LevelVar) // info; tunable via PATCH /v1/admin/engine
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: logLevel}))
 	slog.SetDefault(logger)
 
 	// ── Load config ──────────────────────────────────────────────────────────
-	loader, err := config.NewLoader(*cfgPath)
+	loader, err := config.OpenLoader(*cfgPath, overlayPaths(*cfgPath, *env, *overlays), config.StartupPolicy{
+		Retries:    *cfgRetries,
+		Backoff:    *cfgBackoff,
+		MaxBackoff: *cfgMaxBackoff,
+		CachePath:  *cfgCache,
+		OnRetry: func(attempt int, err error, wait time.Duration) {
+			slog.Warn("config unavailable; retrying", "attempt", attempt, "retries", *cfgRetries, "wait", wait, "err", err)
+		},
+		OnWatchError: func(err error) {
+			slog.Warn("config watcher unavailable (hot-reload disabled)", "err", err)
+		},
+	})
 	if err != nil {
 		slog.Error("failed to load config", "err", err)
 		os.Exit(1)
 	}
+	if loader.FromCache() {
+		slog.Warn("config unavailable; serving the last-known-good cache until it can be loaded", "cache", *cfgCache)
+		metrics.ConfigFromCache.Set(1)
+	}
+	if *overridesPath != "" {
+		if err := loader.SetOverridesPath(*overridesPath); err != nil {
+			slog.Error("failed to load overrides", "err", err)
+			os.Exit(1)
+		}
+	}
 	cfg := loader.Config()
 	if err := config.Validate(cfg); err != nil {
 		slog.Error("config validation failed", "err", err)
 		os.Exit(1)
 	}
+	metrics.SetLatencyBuckets(cfg.Engine.LatencyBucketsMs)
+
+	// ── Action registry ───────────────────────────────────────────────────────
+	reg := action.NewRegistry()
+	reg.Use(action.Pipeline(cfg.Engine)...)
+	reg.Register(points.New())
+	reg.Register(logging.New(logger))
+	if len(cfg.Engine.SandboxActions) > 0 {
+		reg.Sandbox(cfg.Engine.SandboxActions...)
+		slog.Warn("sandbox mode: actions will not have real side effects", "action_types", cfg.Engine.SandboxActions)
+	}
+
+	codec, err := event.NewCodec(*storeCodec)
+	if err != nil {
+		slog.Error("invalid -store-codec", "err", err)
+		os.Exit(1)
+	}
+	sealer, err := openSealer(*sealKeysPath)
+	if err != nil {
+		slog.Error("failed to load -seal-keys", "err", err)
+		os.Exit(1)
+	}
+
+	// Stores are closed in the last shutdown stage, after every event that
+	// writes to them has been processed.
+	var stores []io.Closer
+
+	// ── Workflows ─────────────────────────────────────────────────────────────
+	var wfStore workflow.Store = workflow.NewMemoryStore()
+	if *workflowDSN != "" {
+		st, err := workflow.OpenSQLStore(*workflowDriver, *workflowDSN)
+		if err != nil {
+			slog.Error("failed to open workflow store (build with -tags sqlite for the bundled driver)", "err", err)
+			os.Exit(1)
+		}
+		st.SetSealer(sealer)
+		if *resealStores {
+			reseal("workflow", st)
+		}
+		stores = append(stores, st)
+		wfStore = st
+	}
+	workflows := workflow.NewManager(wfStore, reg)
+	if err := workflows.Load(cfg.Workflows); err != nil {
+		slog.Error("failed to load workflows", "err", err)
+		os.Exit(1)
+	}
+	reg.Register(workflows.Executor())
+
+	// ── Delayed events ────────────────────────────────────────────────────────
+	var schedStore schedule.Store = schedule.NewMemoryStore()
+	if *scheduleDSN != "" {
+		st, err := schedule.OpenSQLStore(*scheduleDriver, *scheduleDSN)
+		if err != nil {
+			slog.Error("failed to open schedule store (build with -tags sqlite for the bundled driver)", "err", err)
+			os.Exit(1)
+		}
+		st.SetCodec(codec)
+		st.SetSealer(sealer)
+		if *resealStores {
+			reseal("schedule", st)
+		}
+		stores = append(stores, st)
+		schedStore = st
+	}
+	scheduler := schedule.New(schedStore)
+	reg.Register(scheduler.Executor())
 
 	// ── Build initial DAG ─────────────────────────────────────────────────────
-	g, err := dag.Build(cfg)
+	// Params are migrated and validated against the registered executors first.
+	g, err := engine.BuildGraph(context.Background(), reg, cfg)
 	if err != nil {
 		slog.Error("failed to build DAG", "err", err)
 		os.Exit(1)
 	}
-	slog.Info("DAG built", "nodes", g.NodeCount(), "scenarios", len(cfg.Scenarios))
-
-	// ── Action registry ───────────────────────────────────────────────────────
-	reg := action.NewRegistry()
-	reg.Register(points.New())
+	slog.Info("DAG built", "nodes", g.NodeCount(), "scenarios", len(cfg.Scenarios), "deduplicated", g.AliasCount(), "eliminated", len(g.Pruned()))
+	if err := loader.SaveLastGood(cfg); err != nil {
+		slog.Warn("failed to write config cache", "err", err)
+	}
+	slog.Info("engine sizing",
+		"cpus", config.AvailableCPUs(),
+		"event_workers", cfg.Engine.EventWorkers,
+		"action_workers", cfg.Engine.ActionWorkers,
+		"sync_worker_fraction", cfg.Engine.SyncWorkerFraction,
+		"pin_workers", cfg.Engine.PinWorkers)
 
 	// ── Engine ────────────────────────────────────────────────────────────────
+	// Three lifetimes, ended in shutdown order: sources stop feeding events
+	// first, the engine drains next (engCtx is only cancelled to abandon what
+	// is left), and background loops and hooks (ctx) stop last.
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	srcCtx, stopSources := context.WithCancel(context.Background())
+	defer stopSources()
+	engCtx, abort := context.WithCancel(context.Background())
+	defer abort()
+	var sources, background shutdown.Group
 
-	eng := engine.New(ctx, g, reg, cfg.Engine)
+	eng := engine.New(engCtx, g, reg, cfg.Engine)
+	if cfg.Engine.ActorProfileURL != "" {
+		provider := actor.NewHTTPProvider(cfg.Engine.ActorProfileURL, &http.Client{Timeout: 2 * time.Second})
+		ttl := cfg.Engine.ActorCacheTTLMs.Duration()
+		eng.SetActorCache(actor.NewCache(provider, ttl, cfg.Engine.ActorCacheSize))
+	}
+	if cfg.Engine.TenantProfileURL != "" {
+		provider := actor.NewHTTPProviderKey(cfg.Engine.TenantProfileURL, "tenant_id", &http.Client{Timeout: 2 * time.Second})
+		ttl := cfg.Engine.TenantCacheTTLMs.Duration()
+		eng.SetTenantCache(actor.NewCache(provider, ttl, cfg.Engine.TenantCacheSize))
+	}
+	var apiOpts []api.Option
+	switch cfg.Engine.CounterStrategy {
+	case counter.StrategyCRDT:
+		gc := counter.NewGCounter(cfg.Engine.Region)
+		if *counterPeerToken != "" {
+			secret, err := os.ReadFile(*counterPeerToken)
+			if err != nil {
+				slog.Error("failed to read -counter-peer-token", "err", err)
+				os.Exit(1)
+			}
+			gc.SetPeerToken(strings.TrimSpace(string(secret)))
+		}
+		eng.SetCounters(gc)
+		background.Go(func() {
+			gc.Run(ctx, &http.Client{Timeout: 2 * time.Second}, cfg.Engine.CounterPeers, cfg.Engine.CounterSyncMs.Duration())
+		})
+		apiOpts = append(apiOpts, api.WithCounters(gc))
+		slog.Info("action limits replicated between regions", "region", cfg.Engine.Region, "peers", cfg.Engine.CounterPeers)
+	case counter.StrategyCentral:
+		if *counterDSN == "" {
+			slog.Error("engine.counter_strategy central requires -counter-store")
+			os.Exit(1)
+		}
+		st, err := counter.OpenSQLStore(*counterDriver, *counterDSN)
+		if err != nil {
+			slog.Error("failed to open counter store (build with -tags sqlite for the bundled driver)", "err", err)
+			os.Exit(1)
+		}
+		stores = append(stores, st)
+		eng.SetCounters(st)
+	}
+	if *streakDSN != "" {
+		st, err := streak.OpenSQLStore(*streakDriver, *streakDSN)
+		if err != nil {
+			slog.Error("failed to open streak store (build with -tags sqlite for the bundled driver)", "err", err)
+			os.Exit(1)
+		}
+		stores = append(stores, st)
+		eng.SetStreakStore(st)
+	}
+	eng.OnEventProcessed(func(ev *event.Event, _ *engine.EventResult) { workflows.Observe(ctx, ev) })
+	eng.OnEventProcessed(func(ev *event.Event, _ *engine.EventResult) { scheduler.Observe(ctx, ev) })
+	probes := monitor.NewRunner(eng)
+	probes.Load(cfg.Monitors)
+	purger := retention.NewPurger(cfg)
+	purger.Register("recent_results", func(_ context.Context, now time.Time, p *retention.Policy) (int, error) {
+		return eng.PurgeRecent(now, p.ResultTTL), nil
+	})
+	purger.Register("streaks", func(ctx context.Context, now time.Time, p *retention.Policy) (int, error) {
+		if p.Streaks <= 0 {
+			return 0, nil
+		}
+		return eng.Streaks().Purge(ctx, now.Add(-p.Streaks))
+	})
+	if st, ok := wfStore.(*workflow.SQLStore); ok {
+		purger.Register("workflows", func(ctx context.Context, now time.Time, p *retention.Policy) (int, error) {
+			return st.PurgeFinished(ctx, p.StateCutoffs(now))
+		})
+	}
+	eng.OnGraphSwapped(func(_, g *dag.Graph) {
+		if err := workflows.Load(g.Config().Workflows); err != nil {
+			slog.Warn("workflow reload failed; keeping previous definitions", "err", err)
+		}
+		probes.Load(g.Config().Monitors)
+		purger.SetConfig(g.Config())
+	})
+	background.Go(func() { workflows.Run(ctx) })
+	sources.Go(func() { scheduler.Run(srcCtx, eng) })
+	background.Go(func() { probes.Run(ctx) })
+	background.Go(func() { purger.Run(ctx) })
+	if cfg.Anomaly.Enabled {
+		detector := anomaly.NewDetector(cfg.Anomaly, anomaly.WebhookNotifier(cfg.Anomaly.WebhookURL))
+		eng.SetDetector(detector)
+		background.Go(func() { detector.Run(ctx) })
+	}
+	if cfg.Drift.Enabled {
+		analyzer := drift.New(cfg.Drift)
+		analyzer.SetRules(cfg)
+		eng.OnGraphSwapped(func(_, g *dag.Graph) { analyzer.SetRules(g.Config()) })
+		eng.OnEventProcessed(func(ev *event.Event, _ *engine.EventResult) { analyzer.Observe(ev) })
+		background.Go(func() { analyzer.Run(ctx) })
+		apiOpts = append(apiOpts, api.WithDrift(analyzer))
+	}
 
 	// ── Hot-reload watcher ────────────────────────────────────────────────────
 	loader.OnChange(func(newCfg *config.RuleConfig) {
-		if err := config.Validate(newCfg); err != nil {
-			slog.Warn("hot-reload skipped: config invalid", "err", err)
-			return
-		}
-		newGraph, err := dag.Build(newCfg)
+		newGraph, err := eng.BuildGraph(ctx, newCfg)
 		if err != nil {
-			slog.Warn("hot-reload skipped: DAG build failed", "err", err)
+			slog.Warn("hot-reload skipped: rules rejected", "err", err)
 			return
 		}
 		eng.SwapGraph(newGraph)
 		slog.Info("DAG hot-reloaded", "nodes", newGraph.NodeCount())
+		metrics.ConfigFromCache.Set(0)
+		if err := loader.SaveLastGood(newCfg); err != nil {
+			slog.Warn("failed to write config cache", "err", err)
+		}
 	})
 	stopWatch, err := loader.Watch()
 	if err != nil {
@@ -78,7 +333,64 @@ func main() {
 	}
 
 	// ── HTTP server ───────────────────────────────────────────────────────────
-	handler := api.New(eng, loader)
+	backends := map[string]string{"workflows": "memory", "schedule": "memory", "counters": "memory", "streaks": "memory", "inbox": "none"}
+	if *workflowDSN != "" {
+		backends["workflows"] = *workflowDriver
+	}
+	if *scheduleDSN != "" {
+		backends["schedule"] = *scheduleDriver
+	}
+	switch cfg.Engine.CounterStrategy {
+	case counter.StrategyCRDT:
+		backends["counters"] = "crdt"
+	case counter.StrategyCentral:
+		backends["counters"] = *counterDriver
+	}
+	if *streakDSN != "" {
+		backends["streaks"] = *streakDriver
+	}
+	if *inboxDSN != "" {
+		backends["inbox"] = *inboxDriver
+	}
+	apiOpts = append(apiOpts, api.WithStores(backends))
+	apiOpts = append(apiOpts, api.WithMonitors(probes), api.WithRetention(purger), api.WithLogLevel(logLevel))
+	apiOpts = append(apiOpts, api.WithActorState(actorstate.Sources{
+		Counters:  eng.Counters(),
+		Workflows: wfStore,
+		Scheduled: schedStore,
+		Streaks:   eng.Streaks(),
+		Region:    cfg.Engine.Region,
+	}))
+	if cfg.Engine.AdaptiveAsyncThreshold > 0 {
+		apiOpts = append(apiOpts, api.WithAdaptiveAsync(cfg.Engine.AdaptiveAsyncThreshold))
+	}
+	if *tokensPath != "" {
+		tokens, err := auth.Load(*tokensPath)
+		if err != nil {
+			slog.Error("failed to load API tokens", "err", err)
+			os.Exit(1)
+		}
+		apiOpts = append(apiOpts, api.WithTokens(tokens))
+		slog.Info("API tokens loaded", "tokens", tokens.Len())
+	}
+	if *inboxDSN != "" {
+		ib, err := inbox.Open(*inboxDriver, *inboxDSN)
+		if err != nil {
+			slog.Error("failed to open inbox (build with -tags sqlite for the bundled driver)", "err", err)
+			os.Exit(1)
+		}
+		ib.SetCodec(codec)
+		ib.SetSealer(sealer)
+		if *resealStores {
+			reseal("inbox", ib)
+		}
+		stores = append(stores, ib)
+		dispatcher := inbox.NewDispatcher(ib, eng)
+		sources.Go(func() { dispatcher.Run(srcCtx) })
+		apiOpts = append(apiOpts, api.WithInbox(ib))
+		slog.Info("inbox enabled", "dsn", *inboxDSN)
+	}
+	handler := api.New(eng, loader, apiOpts...)
 	srv := &http.Server{
 		Addr:         *addr,
 		Handler:      handler,
@@ -101,10 +413,49 @@ func main() {
 	<-quit
 	slog.Info("shutting down…")
 
-	shutCtx, shutCancel := context.WithTimeout(context.Background(), 15*time.Second)
-	defer shutCancel()
-	_ = srv.Shutdown(shutCtx)
-	cancel() // stop worker pools
-	eng.Shutdown()
-	slog.Info("goodbye")
+	results := shutdown.Run(
+		// Sources: stop taking events. The inbox and scheduler finish the
+		// event they hold so it is acked or deleted, not redelivered.
+		shutdown.Stage{Name: "sources", Timeout: *sourcesTimeout, Run: func(ctx context.Context) (int, error) {
+			stopSources()
+			err := srv.Shutdown(ctx)
+			if err != nil {
+				_ = srv.Close() // drop requests still open, e.g. live tails
+			}
+			return 0, errors.Join(err, sources.Wait(ctx))
+		}},
+		// Queue: refuse new events and let workers take the queued ones.
+		shutdown.Stage{Name: "queue", Timeout: *queueTimeout, Run: eng.DrainQueue},
+		// Actions: let in-flight events finish their actions; whatever is
+		// still running afterwards is cancelled.
+		shutdown.Stage{Name: "actions", Timeout: *actionsTimeout, Run: func(ctx context.Context) (int, error) {
+			left, err := eng.WaitIdle(ctx)
+			if err != nil {
+				abort()
+			}
+			return left, err
+		}},
+		// Sinks: stop background loops, then close the stores.
+		shutdown.Stage{Name: "sinks", Timeout: *sinksTimeout, Run: func(ctx context.Context) (int, error) {
+			cancel()
+			err := background.Wait(ctx)
+			left := 0
+			for _, st := range stores {
+				if cerr := st.Close(); cerr != nil {
+					left++
+					err = errors.Join(err, cerr)
+				}
+			}
+			return left, err
+		}},
+	)
+	report := make([]any, 0, 2*len(results))
+	for _, r := range results {
+		report = append(report, r.Stage+"_left", r.Left)
+	}
+	if !shutdown.Clean(results) {
+		slog.Warn("shutdown left work unfinished", report...)
+		return
+	}
+	slog.Info("goodbye", report...)
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/perf"
+)
+
+// runPerfCompare implements `fluxflow perf-compare base.txt head.txt`: it
+// diffs two `go test -bench` outputs and exits 1 when a benchmark regressed
+// by more than -threshold, so it can gate a change in CI.
+func runPerfCompare(args []string) int {
+	fs := flag.NewFlagSet("perf-compare", flag.ExitOnError)
+	threshold := fs.Float64("threshold", 0.10, "Relative change that counts as a regression (0.10 = 10%)")
+	units := fs.String("units", "", "Comma-separated units to gate on, e.g. allocs/op,B/op (default: all)")
+	all := fs.Bool("all", false, "List every metric, not only the ones that changed by more than 1%")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: fluxflow perf-compare [-threshold 0.10] [-units …] [-all] base.txt head.txt")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	base, err := parseBenchFile(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	head, err := parseBenchFile(fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	c := perf.Compare(base, head, *threshold)
+	if gated := splitList(*units); len(gated) > 0 {
+		for i := range c.Deltas {
+			c.Deltas[i].Regression = c.Deltas[i].Regression && slices.Contains(gated, c.Deltas[i].Unit)
+		}
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "benchmark\tunit\tbase\thead\tdelta\t\t")
+	for _, d := range c.Deltas {
+		if !*all && !d.Regression && d.Change > -0.01 && d.Change < 0.01 {
+			continue
+		}
+		mark := ""
+		if d.Regression {
+			mark = "REGRESSION"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f\t%.1f\t%+.1f%%\t%s\t\n", d.Benchmark, d.Unit, d.Old, d.New, d.Change*100, mark)
+	}
+	tw.Flush()
+	for _, name := range c.Added {
+		fmt.Printf("new: %s\n", name)
+	}
+	for _, name := range c.Removed {
+		fmt.Printf("removed: %s\n", name)
+	}
+
+	if n := len(c.Regressions()); n > 0 {
+		fmt.Fprintf(os.Stderr, "perf-compare: %d metric(s) regressed by more than %.0f%%\n", n, *threshold*100)
+		return 1
+	}
+	return 0
+}
+
+func parseBenchFile(path string) (perf.Run, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	run, err := perf.Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(run) == 0 {
+		return nil, fmt.Errorf("%s: no benchmark results", path)
+	}
+	return run, nil
+}
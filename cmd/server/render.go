@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+)
+
+// runRender implements `fluxflow render`: it prints the effective config
+// (base + overlays + defaults) as YAML and exits non-zero if it is invalid.
+func runRender(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	cfgPath := fs.String("config", "configs/rules.yaml", "Path to rules YAML config")
+	env := fs.String("env", "", "Environment overlay name (loads <config>.<env>.yaml)")
+	overlays := fs.String("overlay", "", "Comma-separated overlay files applied after the env overlay")
+	_ = fs.Parse(args)
+
+	loader, err := config.NewLoader(*cfgPath, overlayPaths(*cfgPath, *env, *overlays)...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	cfg := loader.Config()
+	out, err := config.Render(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	if err := config.Validate(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// overlayPaths lists overlay files in precedence order (lowest first).
+func overlayPaths(cfgPath, env, overlays string) []string {
+	var paths []string
+	if env != "" {
+		paths = append(paths, config.EnvOverlayPath(cfgPath, env))
+	}
+	for _, p := range strings.Split(overlays, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
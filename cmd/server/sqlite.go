@@ -0,0 +1,7 @@
+//go:build sqlite
+
+package main
+
+// Registers the "sqlite3" database/sql driver used by -inbox and the other
+// SQL stores. It needs cgo; see README.
+import _ "github.com/mattn/go-sqlite3"
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/auth"
+)
+
+// runToken implements `fluxflow token`: it generates a new API token, prints
+// the secret once on stderr and the entry to append to the -tokens file on
+// stdout. Only the hash is written out, so the file holds no secrets.
+func runToken(args []string) int {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	name := fs.String("name", "", "Token name, e.g. the integration it is issued to (required)")
+	sources := fs.String("sources", "", "Comma-separated event sources the token may send (default: any)")
+	types := fs.String("types", "", "Comma-separated event types the token may send (default: any)")
+	teams := fs.String("teams", "", "Comma-separated teams whose scenarios the token may change")
+	role := fs.String("role", "", "admin to let the token change every scenario")
+	_ = fs.Parse(args)
+	if *name == "" {
+		fmt.Fprintln(os.Stderr, "token: -name is required")
+		return 2
+	}
+	if *role != "" && *role != auth.RoleAdmin {
+		fmt.Fprintf(os.Stderr, "token: -role must be %s\n", auth.RoleAdmin)
+		return 2
+	}
+
+	secret, err := auth.Generate()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	out, err := yaml.Marshal([]auth.Token{{
+		Name:       *name,
+		Hash:       auth.Hash(secret),
+		Sources:    splitList(*sources),
+		EventTypes: splitList(*types),
+		Teams:      splitList(*teams),
+		Role:       *role,
+	}})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "token %s: %s\n(shown once; append the entry below under tokens: in the -tokens file)\n", *name, secret)
+	os.Stdout.Write(out)
+	return 0
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
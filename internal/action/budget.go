@@ -0,0 +1,164 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// StatusDegraded marks a result skipped because the action exhausted its error budget.
+const StatusDegraded = "degraded"
+
+// ErrDegraded is returned when an action is skipped by ErrorBudget.
+var ErrDegraded = errors.New("action degraded: error budget exhausted")
+
+const budgetBuckets = 10
+
+// ErrorBudget tracks each action ID's error rate over window and marks the
+// action degraded once the rate exceeds budget (0–1) with at least minCalls
+// calls in the window. Degraded actions are skipped with StatusDegraded; one
+// call per probeEvery is let through as a probe; a success restores the
+// action.
+func ErrorBudget(budget float64, window time.Duration, minCalls int, probeEvery time.Duration) Middleware {
+	return func(actionType string, next ExecuteFunc) ExecuteFunc {
+		if budget <= 0 || window <= 0 {
+			return next
+		}
+		var (
+			mu      sync.Mutex
+			budgets = make(map[string]*errorBudget)
+		)
+		get := func(actionID string) *errorBudget {
+			mu.Lock()
+			defer mu.Unlock()
+			b, ok := budgets[actionID]
+			if !ok {
+				b = &errorBudget{budget: budget, minCalls: minCalls, probeEvery: probeEvery, bucketLen: window / budgetBuckets}
+				budgets[actionID] = b
+			}
+			return b
+		}
+		return func(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error) {
+			b := get(actionID)
+			if rate, ok := b.allow(time.Now()); !ok {
+				err := fmt.Errorf("%s %s: %w (error rate %.0f%% > %.0f%%)", actionType, actionID, ErrDegraded, rate*100, budget*100)
+				return &ActionResult{ActionID: actionID, Type: actionType, Success: false, Status: StatusDegraded, Message: err.Error()}, err
+			}
+			res, err := next(ctx, actionID, params, evalCtx)
+			if errors.Is(err, ErrCircuitOpen) {
+				return res, err // the type-wide breaker already accounts for this failure
+			}
+			failed := err != nil || res == nil || !res.Success
+			switch b.record(time.Now(), failed) {
+			case budgetDegraded:
+				metrics.ActionDegraded.WithLabelValues(actionID).Set(1)
+				logctx.From(ctx).Warn("action degraded: error budget exhausted", "action_id", actionID, "action_type", actionType, "budget", budget)
+			case budgetRecovered:
+				metrics.ActionDegraded.WithLabelValues(actionID).Set(0)
+				logctx.From(ctx).Info("action recovered", "action_id", actionID, "action_type", actionType)
+			}
+			return res, err
+		}
+	}
+}
+
+type budgetTransition int
+
+const (
+	budgetUnchanged budgetTransition = iota
+	budgetDegraded
+	budgetRecovered
+)
+
+// errorBudget counts calls and errors in budgetBuckets rolling buckets.
+type errorBudget struct {
+	mu         sync.Mutex
+	budget     float64
+	minCalls   int
+	probeEvery time.Duration
+	bucketLen  time.Duration
+
+	calls, errs [budgetBuckets]int
+	bucketStart time.Time // start of the newest bucket
+	head        int       // index of the newest bucket
+
+	degraded  bool
+	nextProbe time.Time
+	probing   bool
+}
+
+// advance rotates buckets so head covers now.
+func (b *errorBudget) advance(now time.Time) {
+	if b.bucketStart.IsZero() {
+		b.bucketStart = now
+		return
+	}
+	for n := 0; now.Sub(b.bucketStart) >= b.bucketLen && n < budgetBuckets; n++ {
+		b.head = (b.head + 1) % budgetBuckets
+		b.calls[b.head], b.errs[b.head] = 0, 0
+		b.bucketStart = b.bucketStart.Add(b.bucketLen)
+	}
+	if now.Sub(b.bucketStart) >= b.bucketLen {
+		b.bucketStart = now // idle for longer than the window
+	}
+}
+
+func (b *errorBudget) rate() (float64, int) {
+	var calls, errs int
+	for i := range b.calls {
+		calls += b.calls[i]
+		errs += b.errs[i]
+	}
+	if calls == 0 {
+		return 0, 0
+	}
+	return float64(errs) / float64(calls), calls
+}
+
+// allow reports whether a call may proceed, and the current error rate.
+func (b *errorBudget) allow(now time.Time) (float64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	rate, _ := b.rate()
+	if !b.degraded {
+		return rate, true
+	}
+	if b.probing || now.Before(b.nextProbe) {
+		return rate, false
+	}
+	b.probing = true
+	return rate, true
+}
+
+func (b *errorBudget) record(now time.Time, failed bool) budgetTransition {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance(now)
+	if b.degraded {
+		b.probing = false
+		if failed {
+			b.nextProbe = now.Add(b.probeEvery)
+			return budgetUnchanged
+		}
+		b.degraded = false
+		b.calls, b.errs = [budgetBuckets]int{}, [budgetBuckets]int{}
+		return budgetRecovered
+	}
+	b.calls[b.head]++
+	if failed {
+		b.errs[b.head]++
+	}
+	if rate, calls := b.rate(); calls >= b.minCalls && rate > b.budget {
+		b.degraded = true
+		b.nextProbe = now.Add(b.probeEvery)
+		return budgetDegraded
+	}
+	return budgetUnchanged
+}
@@ -8,18 +8,45 @@ import (
 
 // ActionResult holds the outcome of executing a single action.
 type ActionResult struct {
-	ActionID string `json:"action_id"`
-	Type     string `json:"type"`
-	Success  bool   `json:"success"`
-	Message  string `json:"message"`
+	ActionID string  `json:"action_id"`
+	Type     string  `json:"type"`
+	Success  bool    `json:"success"`
+	Message  string  `json:"message"`
+	Sandbox  bool    `json:"sandbox,omitempty"` // produced by a sandbox executor
+	Status   string  `json:"status,omitempty"`  // set when the action was skipped, e.g. StatusDegraded
+	Points   float64 `json:"points,omitempty"`  // points awarded or deducted by reward_points
+
+	// Scenarios and Collapsed are set when engine.dedupe_actions ran this
+	// action once for several matches: every scenario that matched it, and
+	// the IDs of the identical actions that were not run.
+	Scenarios []string `json:"scenarios,omitempty"`
+	Collapsed []string `json:"collapsed,omitempty"`
 }
 
+// StatusLimited marks a result skipped because the actor reached the
+// action's limit (config.ActionLimit).
+const StatusLimited = "limited"
+
+// StatusDryRun and StatusSkipped mark matched actions that were not executed
+// because the event was sent with dry_run or listed them in skip_actions.
+const (
+	StatusDryRun  = "dry_run"
+	StatusSkipped = "skipped"
+)
+
 // Executor is the interface all action implementations must satisfy.
 type Executor interface {
 	// Type returns the string key this executor is registered under.
 	Type() string
 	// Execute runs the action and returns a result.
 	Execute(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error)
-	// Validate checks params at build time (called by dag/builder).
+	// Validate checks params at load time (called by Registry.PrepareParams).
 	Validate(params map[string]interface{}) error
 }
+
+// External is optionally implemented by executors that call other systems,
+// such as a webhook or an email provider. Scenarios running one are in the
+// expensive cost class for load shedding unless they declare a cost.
+type External interface {
+	External() bool
+}
@@ -0,0 +1,144 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/i18n"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+)
+
+// LogAction handles "log" actions. It emits one structured record per match
+// and has no side effects, which makes it suitable for staging new rules in
+// observe-only mode before attaching real actions.
+//
+// Params:
+//   - message:     text with {{field.path}} placeholders resolved against the event
+//   - message_key: catalog key rendered in the actor's locale (overrides message)
+//   - level:       debug | info | warn | error (default info)
+//   - fields:      list of field paths attached as record attributes
+type LogAction struct {
+	logger *slog.Logger
+}
+
+// New returns a LogAction writing to logger, or to slog.Default() when nil.
+func New(logger *slog.Logger) *LogAction { return &LogAction{logger: logger} }
+
+func (l *LogAction) Type() string { return "log" }
+
+// Sandbox implements action.Sandboxer. Logging has no side effects to avoid,
+// so the sandbox equivalent is the action itself.
+func (l *LogAction) Sandbox() action.Executor { return l }
+
+func (l *LogAction) Validate(params map[string]interface{}) error {
+	msg, _ := params["message"].(string)
+	key, _ := params["message_key"].(string)
+	if msg == "" && key == "" {
+		return fmt.Errorf("log: message or message_key is required")
+	}
+	if lv, ok := params["level"]; ok {
+		s, _ := lv.(string)
+		if _, ok := parseLevel(s); !ok {
+			return fmt.Errorf("log: level must be one of debug, info, warn, error, got %v", lv)
+		}
+	}
+	if _, err := fieldPaths(params); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (l *LogAction) Execute(
+	ctx context.Context,
+	actionID string,
+	params map[string]interface{},
+	evalCtx *dag.EvalContext,
+) (*action.ActionResult, error) {
+	level := slog.LevelInfo
+	if s, ok := params["level"].(string); ok {
+		level, _ = parseLevel(s)
+	}
+	tmpl, _ := params["message"].(string)
+	msg := i18n.Render(tmpl, nil, evalCtx.Resolve)
+	if key, _ := params["message_key"].(string); key != "" {
+		if localized, ok := evalCtx.Messages.Message(key, nil, evalCtx.Resolve); ok {
+			msg = localized
+		}
+	}
+
+	paths, err := fieldPaths(params)
+	if err != nil {
+		return &action.ActionResult{ActionID: actionID, Type: l.Type(), Success: false, Message: err.Error()}, err
+	}
+	attrs := []slog.Attr{slog.String("action_id", actionID)}
+	if !logctx.Has(ctx, "event_id") {
+		// Run outside an event, e.g. as a timed-out workflow's compensation.
+		attrs = append(attrs, slog.String("event_id", evalCtx.Event.ID))
+	}
+	attrs = append(attrs, slog.String("actor_id", evalCtx.Event.ActorID))
+	fields := make(map[string]interface{}, len(paths))
+	for _, p := range paths {
+		if v, ok := evalCtx.Resolve(strings.Split(p, ".")); ok {
+			fields[p] = v
+			attrs = append(attrs, slog.Any(p, v))
+		}
+	}
+
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logctx.Wrap(logger, ctx).LogAttrs(ctx, level, msg, attrs...)
+
+	evalCtx.Results.SetOutput(actionID, map[string]interface{}{
+		"level":   level.String(),
+		"message": msg,
+		"fields":  fields,
+	})
+
+	return &action.ActionResult{
+		ActionID: actionID,
+		Type:     l.Type(),
+		Success:  true,
+		Message:  msg,
+	}, nil
+}
+
+func parseLevel(s string) (slog.Level, bool) {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "", "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// fieldPaths reads the optional "fields" param. YAML decodes lists as []interface{}.
+func fieldPaths(params map[string]interface{}) ([]string, error) {
+	raw, ok := params["fields"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("log: fields must be a list of field paths, got %T", raw)
+	}
+	out := make([]string, 0, len(list))
+	for i, v := range list {
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("log: fields[%d] must be a non-empty string", i)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
@@ -0,0 +1,139 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/i18n"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+)
+
+func testContext() *dag.EvalContext {
+	return &dag.EvalContext{
+		Event: &event.Event{
+			ID:      "e1",
+			Type:    "purchase",
+			ActorID: "u1",
+			Payload: map[string]interface{}{"plan": "pro", "amount": float64(1200)},
+			Meta:    map[string]string{"locale": "fr-CA"},
+		},
+		Results: dag.NewResults(0),
+	}
+}
+
+// records decodes the JSON lines a slog.JSONHandler wrote to buf.
+func records(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("decode %q: %v", line, err)
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func TestLogAction_Validate(t *testing.T) {
+	l := New(nil)
+	for _, tc := range []struct {
+		params  map[string]interface{}
+		wantErr string
+	}{
+		{map[string]interface{}{"message": "hi"}, ""},
+		{map[string]interface{}{"message_key": "welcome", "level": "WARNING"}, ""},
+		{map[string]interface{}{"message": "hi", "fields": []interface{}{"payload.plan"}}, ""},
+		{map[string]interface{}{}, "message or message_key"},
+		{map[string]interface{}{"message": "hi", "level": "fatal"}, "level must be one of"},
+		{map[string]interface{}{"message": "hi", "fields": "payload.plan"}, "fields must be a list"},
+		{map[string]interface{}{"message": "hi", "fields": []interface{}{""}}, "fields[0]"},
+	} {
+		err := l.Validate(tc.params)
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("Validate(%v) = %v", tc.params, err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("Validate(%v) = %v, want an error containing %q", tc.params, err, tc.wantErr)
+		}
+	}
+}
+
+func TestLogAction_Execute(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	evalCtx := testContext()
+	params := map[string]interface{}{
+		"message": "{{payload.plan}} purchase of {{payload.amount}}",
+		"level":   "warn",
+		"fields":  []interface{}{"payload.amount", "payload.missing"},
+	}
+	res, err := l.Execute(context.Background(), "act_log", params, evalCtx)
+	if err != nil || !res.Success || res.Message != "pro purchase of 1200" {
+		t.Fatalf("Execute = %+v, %v", res, err)
+	}
+
+	recs := records(t, &buf)
+	if len(recs) != 1 {
+		t.Fatalf("%d records, want 1", len(recs))
+	}
+	rec := recs[0]
+	if rec["level"] != "WARN" || rec["msg"] != "pro purchase of 1200" || rec["action_id"] != "act_log" ||
+		rec["event_id"] != "e1" || rec["actor_id"] != "u1" || rec["payload.amount"] != float64(1200) {
+		t.Errorf("record = %v", rec)
+	}
+	if _, ok := rec["payload.missing"]; ok {
+		t.Error("a field the event lacks was logged")
+	}
+
+	out, ok := evalCtx.Results.Output("act_log")
+	if !ok {
+		t.Fatal("no output recorded")
+	}
+	o := out.(map[string]interface{})
+	if o["level"] != "WARN" || o["message"] != "pro purchase of 1200" || len(o["fields"].(map[string]interface{})) != 1 {
+		t.Errorf("output = %v", o)
+	}
+}
+
+func TestLogAction_EventIDFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewJSONHandler(&buf, nil)))
+	ctx := logctx.With(context.Background(), "event_id", "e1", "request_id", "r1")
+	if _, err := l.Execute(ctx, "act_log", map[string]interface{}{"message": "hi"}, testContext()); err != nil {
+		t.Fatal(err)
+	}
+	// The record carries the context's fields; event_id appears once.
+	if n := strings.Count(buf.String(), `"event_id"`); n != 1 {
+		t.Errorf("event_id appears %d times: %s", n, buf.String())
+	}
+	if rec := records(t, &buf)[0]; rec["request_id"] != "r1" {
+		t.Errorf("record = %v, want the context's request_id", rec)
+	}
+}
+
+func TestLogAction_MessageKey(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(slog.New(slog.NewJSONHandler(&buf, nil)))
+	evalCtx := testContext()
+	evalCtx.Messages = i18n.NewCatalog("en", map[string]map[string]string{
+		"en": {"bought": "{{payload.plan}} bought"},
+		"fr": {"bought": "{{payload.plan}} acheté"},
+	})
+	params := map[string]interface{}{"message": "fallback", "message_key": "bought"}
+	if res, err := l.Execute(context.Background(), "act_log", params, evalCtx); err != nil || res.Message != "pro acheté" {
+		t.Errorf("Execute = %+v, %v, want the fr-CA actor's message", res, err)
+	}
+
+	// Without a template for the key, the message param is used.
+	params["message_key"] = "unknown"
+	if res, _ := l.Execute(context.Background(), "act_log", params, evalCtx); res.Message != "fallback" {
+		t.Errorf("Message = %q, want the fallback", res.Message)
+	}
+}
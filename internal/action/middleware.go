@@ -0,0 +1,215 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// ExecuteFunc is the signature of Executor.Execute.
+type ExecuteFunc func(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error)
+
+// Middleware decorates an Executor's Execute call with cross-cutting behaviour.
+// It is applied once per executor type, so any state it closes over (e.g. a
+// circuit breaker) is scoped to that type.
+type Middleware func(actionType string, next ExecuteFunc) ExecuteFunc
+
+// ErrCircuitOpen is returned when a circuit breaker rejects a call.
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ErrTimeout is wrapped by the error Timeout returns for an abandoned call.
+var ErrTimeout = errors.New("action timed out")
+
+// Chain wraps e with mws. The first middleware is the outermost.
+func Chain(e Executor, mws ...Middleware) Executor {
+	if len(mws) == 0 {
+		return e
+	}
+	fn := ExecuteFunc(e.Execute)
+	for i := len(mws) - 1; i >= 0; i-- {
+		fn = mws[i](e.Type(), fn)
+	}
+	return &chainedExecutor{Executor: e, exec: fn}
+}
+
+// chainedExecutor overrides Execute while delegating Type and Validate.
+type chainedExecutor struct {
+	Executor
+	exec ExecuteFunc
+}
+
+func (c *chainedExecutor) Execute(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error) {
+	return c.exec(ctx, actionID, params, evalCtx)
+}
+
+// Pipeline returns the standard middleware order
+// Retry → ErrorBudget → CircuitBreaker → Timeout, configured from conf.
+// Stages whose settings are zero pass calls straight through. The Registry
+// adds Metrics → Recover inside it.
+func Pipeline(conf config.EngineConf) []Middleware {
+	return []Middleware{
+		Retry(conf.ActionRetries, conf.ActionRetryBackoffMs.Duration()),
+		ErrorBudget(conf.ActionErrorBudget, conf.ActionErrorBudgetWindowMs.Duration(), conf.ActionErrorBudgetMinCalls, conf.ActionProbeIntervalMs.Duration()),
+		CircuitBreaker(conf.BreakerThreshold, conf.BreakerCooldownMs.Duration(), conf.BreakOnPanic),
+		Timeout(conf.ActionTimeoutMs.Duration()),
+	}
+}
+
+// Retry re-invokes next up to retries additional times when it fails, with
+// an error or an unsuccessful result, sleeping backoff·attempt between
+// tries. Circuit-open and degraded errors are not retried, nor are timeouts:
+// Timeout abandons the call, which may still complete.
+func Retry(retries int, backoff time.Duration) Middleware {
+	return func(_ string, next ExecuteFunc) ExecuteFunc {
+		if retries <= 0 {
+			return next
+		}
+		return func(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error) {
+			res, err := next(ctx, actionID, params, evalCtx)
+			for attempt := 1; attempt <= retries && retryable(res, err); attempt++ {
+				select {
+				case <-time.After(backoff * time.Duration(attempt)):
+				case <-ctx.Done():
+					return res, err
+				}
+				res, err = next(ctx, actionID, params, evalCtx)
+			}
+			return res, err
+		}
+	}
+}
+
+func retryable(res *ActionResult, err error) bool {
+	if err == nil {
+		return res != nil && !res.Success
+	}
+	return !errors.Is(err, ErrCircuitOpen) && !errors.Is(err, ErrDegraded) && !errors.Is(err, ErrTimeout)
+}
+
+// CircuitBreaker opens after threshold consecutive errors and rejects calls
+// for cooldown. After the cooldown a single trial call is let through; its
+// outcome closes or re-opens the breaker. With breakOnPanic, a recovered
+// executor panic (ErrPanic) opens it at once; threshold may then be 0 to
+// break on panics only.
+func CircuitBreaker(threshold int, cooldown time.Duration, breakOnPanic bool) Middleware {
+	return func(actionType string, next ExecuteFunc) ExecuteFunc {
+		if threshold <= 0 && !breakOnPanic {
+			return next
+		}
+		b := &breaker{threshold: threshold, cooldown: cooldown, breakOnPanic: breakOnPanic}
+		return func(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error) {
+			if !b.allow() {
+				err := fmt.Errorf("%s: %w", actionType, ErrCircuitOpen)
+				return &ActionResult{ActionID: actionID, Type: actionType, Success: false, Message: err.Error()}, err
+			}
+			res, err := next(ctx, actionID, params, evalCtx)
+			b.record(err == nil, errors.Is(err, ErrPanic))
+			return res, err
+		}
+	}
+}
+
+type breaker struct {
+	mu           sync.Mutex
+	threshold    int // 0 = only panics open the breaker
+	cooldown     time.Duration
+	breakOnPanic bool
+	failures     int
+	openUntil    time.Time
+	trial        bool // a half-open trial call is in flight
+}
+
+// limit is the failure count at which the breaker is open.
+func (b *breaker) limit() int {
+	return max(b.threshold, 1)
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < b.limit() {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+func (b *breaker) record(ok, panicked bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	switch {
+	case ok:
+		b.failures = 0
+		return
+	case panicked && b.breakOnPanic:
+		b.failures = b.limit()
+	case b.threshold > 0:
+		b.failures++
+	default:
+		b.failures = 0 // panics-only breaker: ordinary failures close it
+		return
+	}
+	if b.failures >= b.limit() {
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
+
+// Timeout bounds each call to d. The executor receives a context with the
+// deadline; if it ignores the context, the call is abandoned and an error
+// wrapping ErrTimeout returned.
+func Timeout(d time.Duration) Middleware {
+	return func(actionType string, next ExecuteFunc) ExecuteFunc {
+		if d <= 0 {
+			return next
+		}
+		return func(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+
+			type outcome struct {
+				res *ActionResult
+				err error
+			}
+			done := make(chan outcome, 1)
+			go func() {
+				res, err := next(ctx, actionID, params, evalCtx)
+				done <- outcome{res, err}
+			}()
+			select {
+			case o := <-done:
+				return o.res, o.err
+			case <-ctx.Done():
+				err := fmt.Errorf("%s: action %s: %w after %v", actionType, actionID, ErrTimeout, d)
+				return &ActionResult{ActionID: actionID, Type: actionType, Success: false, Message: err.Error()}, err
+			}
+		}
+	}
+}
+
+// Metrics records ifttt_actions_executed_total and ifttt_action_duration_ms
+// for every call. The Registry installs it on every executor.
+func Metrics() Middleware {
+	return func(actionType string, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error) {
+			start := time.Now()
+			res, err := next(ctx, actionID, params, evalCtx)
+			metrics.ActionDuration().WithLabelValues(actionType).Observe(float64(time.Since(start)) / float64(time.Millisecond))
+			status := "success"
+			if err != nil || res == nil || !res.Success {
+				status = "error"
+			}
+			metrics.ActionsExecuted.WithLabelValues(actionType, status).Inc()
+			return res, err
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package action_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// flakyExec fails the first `fails` calls, then succeeds.
+type flakyExec struct {
+	fails int
+	calls int
+}
+
+func (f *flakyExec) Type() string                                 { return "flaky" }
+func (f *flakyExec) Validate(params map[string]interface{}) error { return nil }
+func (f *flakyExec) Execute(ctx context.Context, id string, params map[string]interface{}, evalCtx *dag.EvalContext) (*action.ActionResult, error) {
+	f.calls++
+	if f.calls <= f.fails {
+		return nil, errors.New("boom")
+	}
+	return &action.ActionResult{ActionID: id, Type: f.Type(), Success: true}, nil
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	mw := func(name string) action.Middleware {
+		return func(_ string, next action.ExecuteFunc) action.ExecuteFunc {
+			return func(ctx context.Context, id string, p map[string]interface{}, ec *dag.EvalContext) (*action.ActionResult, error) {
+				order = append(order, name)
+				return next(ctx, id, p, ec)
+			}
+		}
+	}
+	e := action.Chain(&flakyExec{}, mw("outer"), mw("inner"))
+	if _, err := e.Execute(context.Background(), "a", nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("expected [outer inner], got %v", order)
+	}
+	if e.Type() != "flaky" {
+		t.Errorf("chained executor should keep Type, got %q", e.Type())
+	}
+}
+
+func TestRetry_RecoversFromTransientErrors(t *testing.T) {
+	f := &flakyExec{fails: 2}
+	e := action.Chain(f, action.Retry(2, time.Millisecond))
+	res, err := e.Execute(context.Background(), "a", nil, nil)
+	if err != nil || res == nil || !res.Success {
+		t.Fatalf("expected success after retries, got res=%v err=%v", res, err)
+	}
+	if f.calls != 3 {
+		t.Errorf("expected 3 calls, got %d", f.calls)
+	}
+}
+
+// refusingExec reports an unsuccessful result without an error the first
+// `fails` calls.
+type refusingExec struct {
+	fails int
+	calls int
+}
+
+func (f *refusingExec) Type() string                                 { return "refusing" }
+func (f *refusingExec) Validate(params map[string]interface{}) error { return nil }
+func (f *refusingExec) Execute(ctx context.Context, id string, params map[string]interface{}, evalCtx *dag.EvalContext) (*action.ActionResult, error) {
+	f.calls++
+	return &action.ActionResult{ActionID: id, Type: f.Type(), Success: f.calls > f.fails}, nil
+}
+
+func TestRetry_RetriesUnsuccessfulResults(t *testing.T) {
+	f := &refusingExec{fails: 1}
+	res, err := action.Chain(f, action.Retry(2, time.Millisecond)).Execute(context.Background(), "a", nil, nil)
+	if err != nil || !res.Success || f.calls != 2 {
+		t.Fatalf("res=%+v err=%v after %d calls, want success on the second", res, err, f.calls)
+	}
+}
+
+// stuckExec ignores its context and blocks until release is closed.
+type stuckExec struct {
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *stuckExec) Type() string                                 { return "stuck" }
+func (s *stuckExec) Validate(params map[string]interface{}) error { return nil }
+func (s *stuckExec) Execute(ctx context.Context, id string, params map[string]interface{}, evalCtx *dag.EvalContext) (*action.ActionResult, error) {
+	s.calls.Add(1)
+	<-s.release
+	return &action.ActionResult{ActionID: id, Type: s.Type(), Success: true}, nil
+}
+
+func TestRetry_DoesNotRetryTimeouts(t *testing.T) {
+	s := &stuckExec{release: make(chan struct{})}
+	defer close(s.release)
+	e := action.Chain(s, action.Retry(2, time.Millisecond), action.Timeout(10*time.Millisecond))
+	_, err := e.Execute(context.Background(), "a", nil, nil)
+	if !errors.Is(err, action.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	// The abandoned call may still complete, so running it again could
+	// execute the action twice.
+	if n := s.calls.Load(); n != 1 {
+		t.Errorf("executor called %d times, want 1", n)
+	}
+}
+
+func TestRegistry_AlwaysRecordsMetrics(t *testing.T) {
+	reg := action.NewRegistry()
+	reg.Register(&refusingExec{})
+	e, _ := reg.Get("refusing")
+	before := testutil.ToFloat64(metrics.ActionsExecuted.WithLabelValues("refusing", "success"))
+	if _, err := e.Execute(context.Background(), "a", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(metrics.ActionsExecuted.WithLabelValues("refusing", "success")); got != before+1 {
+		t.Errorf("ifttt_actions_executed_total = %v, want %v without any middleware configured", got, before+1)
+	}
+}
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	f := &flakyExec{fails: 100}
+	e := action.Chain(f, action.CircuitBreaker(2, time.Hour, false))
+	for i := 0; i < 2; i++ {
+		_, _ = e.Execute(context.Background(), "a", nil, nil)
+	}
+	_, err := e.Execute(context.Background(), "a", nil, nil)
+	if !errors.Is(err, action.ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if f.calls != 2 {
+		t.Errorf("open breaker should not call executor, got %d calls", f.calls)
+	}
+}
+
+// panicExec panics on every call.
+type panicExec struct{ calls int }
+
+func (p *panicExec) Type() string                                 { return "panicky" }
+func (p *panicExec) Validate(params map[string]interface{}) error { return nil }
+func (p *panicExec) Execute(ctx context.Context, id string, params map[string]interface{}, evalCtx *dag.EvalContext) (*action.ActionResult, error) {
+	p.calls++
+	var m map[string]int
+	m["boom"]++ // nil map write
+	return nil, nil
+}
+
+func TestRecover_ConvertsPanicAndBreaks(t *testing.T) {
+	p := &panicExec{}
+	e := action.Chain(p, action.CircuitBreaker(0, time.Hour, true), action.Recover())
+	res, err := e.Execute(context.Background(), "a", nil, nil)
+	if !errors.Is(err, action.ErrPanic) || res == nil || res.Success {
+		t.Fatalf("expected failed result wrapping ErrPanic, got res=%v err=%v", res, err)
+	}
+	if _, err := e.Execute(context.Background(), "a", nil, nil); !errors.Is(err, action.ErrCircuitOpen) {
+		t.Fatalf("expected breaker to open on the first panic, got %v", err)
+	}
+	if p.calls != 1 {
+		t.Errorf("open breaker should not call executor, got %d calls", p.calls)
+	}
+}
+
+func TestRegistry_UseAppliesToRegisteredExecutors(t *testing.T) {
+	reg := action.NewRegistry()
+	f := &flakyExec{fails: 1}
+	reg.Register(f)
+	reg.Use(action.Retry(1, time.Millisecond))
+	e, err := reg.Get("flaky")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if _, err := e.Execute(context.Background(), "a", nil, nil); err != nil {
+		t.Fatalf("expected retry middleware to recover, got %v", err)
+	}
+}
+
+func TestRegistry_SandboxSkipsRealExecutor(t *testing.T) {
+	reg := action.NewRegistry()
+	f := &flakyExec{}
+	reg.Register(f)
+	reg.Sandbox("flaky")
+	e, err := reg.Get("flaky")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	ev := &dag.EvalContext{Event: &event.Event{ID: "evt"}, Results: dag.NewResults(0)}
+	res, err := e.Execute(context.Background(), "a", nil, ev)
+	if err != nil || !res.Success || !res.Sandbox {
+		t.Fatalf("expected successful sandbox result, got res=%+v err=%v", res, err)
+	}
+	if f.calls != 0 {
+		t.Errorf("sandboxed executor must not run, got %d calls", f.calls)
+	}
+}
+
+type webhookExec struct{ flakyExec }
+
+func (w *webhookExec) Type() string   { return "webhook" }
+func (w *webhookExec) External() bool { return true }
+
+func TestRegistry_IsExternal(t *testing.T) {
+	reg := action.NewRegistry()
+	reg.Register(&flakyExec{})
+	reg.Register(&webhookExec{})
+	if reg.IsExternal("flaky") || !reg.IsExternal("webhook") || reg.IsExternal("unknown") {
+		t.Errorf("IsExternal: flaky %v, webhook %v, unknown %v",
+			reg.IsExternal("flaky"), reg.IsExternal("webhook"), reg.IsExternal("unknown"))
+	}
+	// A sandboxed webhook calls nothing.
+	reg.Sandbox("webhook")
+	if reg.IsExternal("webhook") {
+		t.Error("sandboxed webhook reported as external")
+	}
+}
+
+func TestErrorBudget_DegradesAndRecovers(t *testing.T) {
+	f := &flakyExec{fails: 4}
+	e := action.Chain(f, action.ErrorBudget(0.5, time.Hour, 4, 5*time.Millisecond))
+	for i := 0; i < 4; i++ {
+		_, _ = e.Execute(context.Background(), "act_a", nil, nil)
+	}
+	res, err := e.Execute(context.Background(), "act_a", nil, nil)
+	if !errors.Is(err, action.ErrDegraded) || res.Status != action.StatusDegraded {
+		t.Fatalf("expected degraded skip, got res=%+v err=%v", res, err)
+	}
+	if f.calls != 4 {
+		t.Errorf("degraded action must not run, got %d calls", f.calls)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if res, err := e.Execute(context.Background(), "act_a", nil, nil); err != nil || !res.Success {
+		t.Fatalf("expected probe to succeed, got res=%+v err=%v", res, err)
+	}
+	if res, err := e.Execute(context.Background(), "act_a", nil, nil); err != nil || !res.Success {
+		t.Errorf("expected action restored after probe, got res=%+v err=%v", res, err)
+	}
+}
+
+// creditExec is at params version 2: v1 called the amount "points".
+type creditExec struct{}
+
+func (creditExec) Type() string       { return "credit" }
+func (creditExec) ParamsVersion() int { return 2 }
+func (creditExec) Validate(params map[string]interface{}) error {
+	if _, ok := params["amount"].(float64); !ok {
+		return errors.New("amount is required")
+	}
+	return nil
+}
+func (creditExec) MigrateParams(from int, params map[string]interface{}) (map[string]interface{}, error) {
+	out := map[string]interface{}{"amount": params["points"]}
+	return out, nil
+}
+func (creditExec) Execute(ctx context.Context, id string, params map[string]interface{}, evalCtx *dag.EvalContext) (*action.ActionResult, error) {
+	return &action.ActionResult{ActionID: id, Type: "credit", Success: true}, nil
+}
+
+func TestRegistry_PrepareParamsMigratesAndValidates(t *testing.T) {
+	reg := action.NewRegistry()
+	reg.Register(creditExec{})
+	old := &config.ActionDef{ID: "a_old", Type: "credit", Params: map[string]interface{}{"points": float64(5)}}
+	cur := &config.ActionDef{ID: "a_cur", Type: "credit", ParamsVersion: 2, Params: map[string]interface{}{"amount": float64(7)}}
+	cfg := &config.RuleConfig{Scenarios: []config.Scenario{{ID: "sc", Children: []config.NodeRef{
+		{Condition: &config.ConditionDef{ID: "c", Children: []config.NodeRef{{Action: old}}}},
+		{Action: cur},
+	}}}}
+	if err := reg.PrepareParams(cfg); err != nil {
+		t.Fatalf("PrepareParams: %v", err)
+	}
+	if old.ParamsVersion != 2 || old.Params["amount"] != float64(5) {
+		t.Errorf("expected v1 params migrated to v2, got v%d %v", old.ParamsVersion, old.Params)
+	}
+	if cur.Params["amount"] != float64(7) {
+		t.Errorf("expected current params untouched, got %v", cur.Params)
+	}
+
+	for _, bad := range []config.ActionDef{
+		{ID: "future", Type: "credit", ParamsVersion: 3, Params: map[string]interface{}{"amount": float64(1)}},
+		{ID: "invalid", Type: "credit", ParamsVersion: 2, Params: map[string]interface{}{}},
+		{ID: "unknown", Type: "nope"},
+	} {
+		cfg := &config.RuleConfig{Scenarios: []config.Scenario{{ID: "sc", Children: []config.NodeRef{{Action: &bad}}}}}
+		if err := reg.PrepareParams(cfg); err == nil {
+			t.Errorf("%s: expected PrepareParams error", bad.ID)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package action
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+)
+
+// Versioned is optionally implemented by executors whose params shape has
+// changed over time. ParamsVersion is the version Validate and Execute
+// expect; MigrateParams upgrades params written for version from to from+1.
+// Actions that omit params_version are treated as version 1.
+type Versioned interface {
+	ParamsVersion() int
+	MigrateParams(from int, params map[string]interface{}) (map[string]interface{}, error)
+}
+
+// PrepareParams migrates every action's params in cfg (scenarios and
+// workflow steps) to its executor's current version, in place, and then
+// validates them. Call it before dag.Build, so executors only ever see the
+// shape they declare and old rule files keep loading while executors evolve.
+func (r *Registry) PrepareParams(cfg *config.RuleConfig) error {
+	var errs []string
+	prepare := func(a *config.ActionDef) {
+		if err := r.prepare(a); err != nil {
+			errs = append(errs, fmt.Sprintf("action %s: %v", a.ID, err))
+		}
+	}
+	for i := range cfg.Scenarios {
+		walkActions(cfg.Scenarios[i].Children, prepare)
+	}
+	for i := range cfg.Workflows {
+		for j := range cfg.Workflows[i].Steps {
+			st := &cfg.Workflows[i].Steps[j]
+			for k := range st.Actions {
+				prepare(&st.Actions[k])
+			}
+			for k := range st.Compensate {
+				prepare(&st.Compensate[k])
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("action params errors:\n  - %s", strings.Join(errs, "\n  - "))
+	}
+	return nil
+}
+
+func walkActions(refs []config.NodeRef, fn func(*config.ActionDef)) {
+	for _, ref := range refs {
+		switch {
+		case ref.Action != nil:
+			fn(ref.Action)
+		case ref.Condition != nil:
+			walkActions(ref.Condition.Children, fn)
+		}
+	}
+}
+
+func (r *Registry) prepare(a *config.ActionDef) error {
+	r.mu.RLock()
+	e, ok := r.executors[a.Type]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no executor registered for action type %q", a.Type)
+	}
+
+	from := max(a.ParamsVersion, 1)
+	current := 1
+	v, versioned := e.(Versioned)
+	if versioned {
+		current = v.ParamsVersion()
+	}
+	if from > current {
+		return fmt.Errorf("params_version %d is newer than %s supports (%d)", from, a.Type, current)
+	}
+	if from < current {
+		params := a.Params
+		for ver := from; ver < current; ver++ {
+			next, err := v.MigrateParams(ver, params)
+			if err != nil {
+				return fmt.Errorf("migrate params v%d→v%d: %w", ver, ver+1, err)
+			}
+			params = next
+		}
+		slog.Info("migrated action params; update the rule file to silence this",
+			"action_id", a.ID, "action_type", a.Type, "from", from, "to", current)
+		a.Params = params
+	}
+	if versioned {
+		a.ParamsVersion = current
+	}
+	return e.Validate(a.Params)
+}
@@ -6,20 +6,32 @@ import (
 	"math"
 
 	"github.com/gyaneshwarpardhi/ifttt/internal/action"
-	"github.com/gyaneshwarpardhi/ifttt/internal/condition"
 	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
 )
 
 // RewardPointsAction handles "reward_points" actions.
 // It supports two param modes:
 //   - points: <fixed number>
 //   - points_formula: <expression evaluated against event context>
-type RewardPointsAction struct{}
+//
+// An optional message_key renders the result message from the config's
+// message catalog in the actor's locale, with {{operation}}, {{points}},
+// {{actor_id}} and {{reason}} available alongside event field paths.
+//
+// In sandbox mode points are computed as usual but recorded as a staging
+// entry and flagged on the result, so nothing reaches the real ledger.
+type RewardPointsAction struct {
+	sandbox bool
+}
 
 func New() *RewardPointsAction { return &RewardPointsAction{} }
 
 func (r *RewardPointsAction) Type() string { return "reward_points" }
 
+// Sandbox implements action.Sandboxer.
+func (r *RewardPointsAction) Sandbox() action.Executor { return &RewardPointsAction{sandbox: true} }
+
 func (r *RewardPointsAction) Validate(params map[string]interface{}) error {
 	op, _ := params["operation"].(string)
 	if op != "award" && op != "deduct" {
@@ -58,27 +70,45 @@ func (r *RewardPointsAction) Execute(
 	if reason != "" {
 		msg += " — " + reason
 	}
+	if key, _ := params["message_key"].(string); key != "" {
+		vars := map[string]interface{}{
+			"operation": op,
+			"points":    fmt.Sprintf("%.0f", pts),
+			"actor_id":  evalCtx.Event.ActorID,
+			"reason":    reason,
+		}
+		if localized, ok := evalCtx.Messages.Message(key, vars, evalCtx.Resolve); ok {
+			msg = localized
+		}
+	}
 
-	// In a real system, persist to a points ledger here.
-	// For now we record in EvalContext.Results.
-	evalCtx.Results[actionID] = map[string]interface{}{
+	// In a real system, persist to a points ledger (or, in sandbox mode,
+	// a staging ledger) here. For now we record in EvalContext.Results.
+	entry := map[string]interface{}{
 		"operation": op,
 		"points":    pts,
 		"actor_id":  evalCtx.Event.ActorID,
 	}
+	if r.sandbox {
+		entry["ledger"] = "staging"
+		msg = "sandbox: " + msg
+	}
+	evalCtx.Results.SetOutput(actionID, entry)
 
 	return &action.ActionResult{
 		ActionID: actionID,
 		Type:     r.Type(),
 		Success:  true,
 		Message:  msg,
+		Sandbox:  r.sandbox,
+		Points:   pts,
 	}, nil
 }
 
 // resolvePoints returns the point value from either a fixed param or a formula.
 func resolvePoints(params map[string]interface{}, evalCtx *dag.EvalContext) (float64, error) {
 	if formula, ok := params["points_formula"].(string); ok && formula != "" {
-		ast, err := condition.Parse(formula)
+		ast, err := dag.Parse(formula)
 		if err != nil {
 			return 0, fmt.Errorf("points_formula parse error: %w", err)
 		}
@@ -149,6 +179,16 @@ func resolveNumericOperand(op condition.Operand, ctx *dag.EvalContext) (float64,
 			return f, nil
 		}
 		return 0, fmt.Errorf("field %v value %v is not numeric", o.Path, val)
+	case *condition.FuncOperand:
+		// e.g. streak("login") * 10 for a streak bonus.
+		val, err := condition.ResolveOperand(o, ctx)
+		if err != nil {
+			return 0, err
+		}
+		if f, ok := toFloat64(val); ok {
+			return f, nil
+		}
+		return 0, fmt.Errorf("%s value %v is not numeric", condition.OperandString(o), val)
 	default:
 		return 0, fmt.Errorf("unknown operand type %T", op)
 	}
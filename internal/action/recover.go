@@ -0,0 +1,36 @@
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// ErrPanic wraps errors produced from a recovered executor panic.
+var ErrPanic = errors.New("executor panicked")
+
+// Recover converts a panic in the executor into a failed ActionResult and an
+// error wrapping ErrPanic, logging the stack. It belongs innermost in the
+// pipeline so every other stage sees an ordinary failure, and so a panic on
+// the goroutine Timeout starts cannot take the process down.
+func Recover() Middleware {
+	return func(actionType string, next ExecuteFunc) ExecuteFunc {
+		return func(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (res *ActionResult, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					metrics.PanicsRecovered.WithLabelValues("action", actionType).Inc()
+					logctx.From(ctx).Error("action executor panicked",
+						"action_id", actionID, "action_type", actionType, "panic", r, "stack", string(debug.Stack()))
+					err = fmt.Errorf("%s: %w: %v", actionType, ErrPanic, r)
+					res = &ActionResult{ActionID: actionID, Type: actionType, Success: false, Message: err.Error()}
+				}
+			}()
+			return next(ctx, actionID, params, evalCtx)
+		}
+	}
+}
@@ -6,15 +6,23 @@ import (
 )
 
 // Registry maps action type strings to their executors.
-// It is safe for concurrent reads; Register should only be called at startup.
+// It is safe for concurrent reads; Register and Use should only be called at
+// startup, SetMiddlewares at any time.
 type Registry struct {
-	mu        sync.RWMutex
-	executors map[string]Executor
+	mu          sync.RWMutex
+	executors   map[string]Executor // as registered
+	chained     map[string]Executor // wrapped with middlewares
+	middlewares []Middleware
+	sandboxed   map[string]bool // action types (or SandboxAll) replaced by sandbox executors
 }
 
 // NewRegistry creates an empty Registry.
 func NewRegistry() *Registry {
-	return &Registry{executors: make(map[string]Executor)}
+	return &Registry{
+		executors: make(map[string]Executor),
+		chained:   make(map[string]Executor),
+		sandboxed: make(map[string]bool),
+	}
 }
 
 // Register adds an executor. Panics on duplicate type to surface misconfiguration early.
@@ -25,13 +33,85 @@ func (r *Registry) Register(e Executor) {
 		panic(fmt.Sprintf("action registry: duplicate type %q", e.Type()))
 	}
 	r.executors[e.Type()] = e
+	r.chained[e.Type()] = r.chain(e)
 }
 
-// Get returns the executor for the given type.
-func (r *Registry) Get(actionType string) (Executor, error) {
+// Use appends middlewares to the execution pipeline of every executor,
+// including ones registered later. Earlier middlewares wrap later ones.
+func (r *Registry) Use(mws ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append(r.middlewares, mws...)
+	r.rechain()
+}
+
+// SetMiddlewares replaces the whole pipeline, e.g. with a retuned Pipeline.
+// State kept by the old middlewares (breakers, error budgets) is dropped.
+func (r *Registry) SetMiddlewares(mws ...Middleware) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.middlewares = append([]Middleware(nil), mws...)
+	r.rechain()
+}
+
+// Sandbox swaps the given action types (or SandboxAll) for their sandbox
+// executors, including types registered later. Middlewares still apply, so
+// the full pipeline is exercised without side effects.
+func (r *Registry) Sandbox(actionTypes ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range actionTypes {
+		r.sandboxed[t] = true
+	}
+	r.rechain()
+}
+
+// IsSandboxed reports whether actionType runs in sandbox mode.
+func (r *Registry) IsSandboxed(actionType string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sandboxed[SandboxAll] || r.sandboxed[actionType]
+}
+
+// IsExternal reports whether actionType's executor calls other systems
+// (see External). A sandboxed type is judged by its sandbox executor.
+func (r *Registry) IsExternal(actionType string) bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	e, ok := r.executors[actionType]
+	if !ok {
+		return false
+	}
+	if r.sandboxed[SandboxAll] || r.sandboxed[actionType] {
+		e = sandboxOf(e)
+	}
+	x, ok := e.(External)
+	return ok && x.External()
+}
+
+// chain must be called with r.mu held. Metrics and Recover are always
+// innermost, so every call is counted and a panic in any executor is
+// contained whatever the configured pipeline.
+func (r *Registry) chain(e Executor) Executor {
+	if r.sandboxed[SandboxAll] || r.sandboxed[e.Type()] {
+		e = sandboxOf(e)
+	}
+	mws := append(append([]Middleware(nil), r.middlewares...), Metrics(), Recover())
+	return Chain(e, mws...)
+}
+
+// rechain must be called with r.mu held.
+func (r *Registry) rechain() {
+	for typ, e := range r.executors {
+		r.chained[typ] = r.chain(e)
+	}
+}
+
+// Get returns the executor for the given type, wrapped in the middleware pipeline.
+func (r *Registry) Get(actionType string) (Executor, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.chained[actionType]
 	if !ok {
 		return nil, fmt.Errorf("no executor registered for action type %q", actionType)
 	}
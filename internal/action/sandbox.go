@@ -0,0 +1,50 @@
+package action
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+)
+
+// SandboxAll, passed to Registry.Sandbox, sandboxes every action type.
+const SandboxAll = "*"
+
+// Sandboxer is implemented by executors that provide a side-effect-free
+// equivalent of themselves for pre-production use (e.g. a webhook that logs
+// instead of sending). Executors without one fall back to a dry run.
+type Sandboxer interface {
+	Sandbox() Executor
+}
+
+// sandboxOf returns e's sandbox equivalent.
+func sandboxOf(e Executor) Executor {
+	if s, ok := e.(Sandboxer); ok {
+		return s.Sandbox()
+	}
+	return &dryRunExecutor{Executor: e}
+}
+
+// dryRunExecutor validates params and reports success without executing.
+type dryRunExecutor struct {
+	Executor
+}
+
+func (d *dryRunExecutor) Execute(ctx context.Context, actionID string, params map[string]interface{}, evalCtx *dag.EvalContext) (*ActionResult, error) {
+	if err := d.Validate(params); err != nil {
+		return &ActionResult{ActionID: actionID, Type: d.Type(), Success: false, Message: err.Error(), Sandbox: true}, err
+	}
+	args := []any{"action_id", actionID, "action_type", d.Type(), "params", params}
+	if !logctx.Has(ctx, "event_id") {
+		args = append(args, "event_id", evalCtx.Event.ID)
+	}
+	logctx.From(ctx).Info("sandbox: action not executed", args...)
+	return &ActionResult{
+		ActionID: actionID,
+		Type:     d.Type(),
+		Success:  true,
+		Message:  fmt.Sprintf("sandbox: %s not executed", d.Type()),
+		Sandbox:  true,
+	}, nil
+}
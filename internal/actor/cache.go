@@ -0,0 +1,133 @@
+package actor
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Provider fetches actor-scoped data (profile, tier, counters) for one actor.
+type Provider interface {
+	Fetch(ctx context.Context, actorID string) (map[string]interface{}, error)
+}
+
+// ProviderFunc adapts a plain function to Provider.
+type ProviderFunc func(ctx context.Context, actorID string) (map[string]interface{}, error)
+
+func (f ProviderFunc) Fetch(ctx context.Context, actorID string) (map[string]interface{}, error) {
+	return f(ctx, actorID)
+}
+
+// Cache wraps a Provider with a short-TTL cache keyed by actor ID, so bursts
+// of events from the same actor share one lookup. Concurrent misses for the
+// same actor are coalesced into a single Fetch. Errors are never cached.
+type Cache struct {
+	provider Provider
+	ttl      time.Duration
+	maxSize  int
+
+	mu       sync.Mutex
+	order    *list.List // front = most recently used
+	entries  map[string]*list.Element
+	inflight map[string]*call
+}
+
+type entry struct {
+	id      string
+	data    map[string]interface{}
+	expires time.Time
+}
+
+type call struct {
+	done chan struct{}
+	data map[string]interface{}
+	err  error
+}
+
+// NewCache creates a Cache. maxSize bounds the number of cached actors;
+// when full, the least recently used entry is evicted.
+func NewCache(p Provider, ttl time.Duration, maxSize int) *Cache {
+	return &Cache{
+		provider: p,
+		ttl:      ttl,
+		maxSize:  maxSize,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*call),
+	}
+}
+
+// Get returns cached data for actorID, fetching it on a miss.
+//
+// A fetch is shared by every caller waiting on the same actor, so it runs
+// without the first caller's cancellation: one event timing out does not
+// fail the others. The Provider bounds its own fetches (HTTPProvider through
+// its client's timeout). A caller whose ctx ends stops waiting.
+func (c *Cache) Get(ctx context.Context, actorID string) (map[string]interface{}, error) {
+	now := time.Now()
+	c.mu.Lock()
+	if el, ok := c.entries[actorID]; ok {
+		if e := el.Value.(*entry); now.Before(e.expires) {
+			c.order.MoveToFront(el)
+			c.mu.Unlock()
+			return e.data, nil
+		}
+		c.remove(el)
+	}
+	cl, ok := c.inflight[actorID]
+	if !ok {
+		cl = &call{done: make(chan struct{})}
+		c.inflight[actorID] = cl
+		go c.fetch(context.WithoutCancel(ctx), actorID, cl)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.data, cl.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Cache) fetch(ctx context.Context, actorID string, cl *call) {
+	cl.data, cl.err = c.provider.Fetch(ctx, actorID)
+
+	c.mu.Lock()
+	delete(c.inflight, actorID)
+	if cl.err == nil {
+		c.store(actorID, cl.data, time.Now())
+	}
+	c.mu.Unlock()
+	close(cl.done)
+}
+
+// Invalidate drops the cached entry for actorID (e.g. after a profile update).
+func (c *Cache) Invalidate(actorID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[actorID]; ok {
+		c.remove(el)
+	}
+}
+
+// store must be called with c.mu held.
+func (c *Cache) store(actorID string, data map[string]interface{}, now time.Time) {
+	e := &entry{id: actorID, data: data, expires: now.Add(c.ttl)}
+	if el, ok := c.entries[actorID]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return
+	}
+	if c.maxSize > 0 && c.order.Len() >= c.maxSize {
+		c.remove(c.order.Back())
+	}
+	c.entries[actorID] = c.order.PushFront(e)
+}
+
+// remove must be called with c.mu held.
+func (c *Cache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).id)
+}
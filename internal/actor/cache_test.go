@@ -0,0 +1,107 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCache_HitsAndInvalidate(t *testing.T) {
+	var fetches int32
+	c := NewCache(ProviderFunc(func(ctx context.Context, id string) (map[string]interface{}, error) {
+		atomic.AddInt32(&fetches, 1)
+		return map[string]interface{}{"tier": "gold"}, nil
+	}), time.Minute, 10)
+
+	for i := 0; i < 3; i++ {
+		data, err := c.Get(context.Background(), "user_42")
+		if err != nil || data["tier"] != "gold" {
+			t.Fatalf("Get = %v, %v", data, err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("expected 1 fetch for a burst, got %d", fetches)
+	}
+	c.Invalidate("user_42")
+	if _, err := c.Get(context.Background(), "user_42"); err != nil {
+		t.Fatal(err)
+	}
+	if fetches != 2 {
+		t.Errorf("expected re-fetch after Invalidate, got %d fetches", fetches)
+	}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	fetched := map[string]int{}
+	c := NewCache(ProviderFunc(func(ctx context.Context, id string) (map[string]interface{}, error) {
+		fetched[id]++
+		return map[string]interface{}{"id": id}, nil
+	}), time.Minute, 2)
+	get := func(id string) {
+		t.Helper()
+		if _, err := c.Get(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get("a")
+	get("b")
+	get("a") // b is now the least recently used
+	get("c")
+	get("a")
+	if fetched["a"] != 1 {
+		t.Errorf("a fetched %d times; a full cache must evict one entry, not reset", fetched["a"])
+	}
+	get("b")
+	if fetched["b"] != 2 {
+		t.Errorf("b fetched %d times, want 2 after being evicted", fetched["b"])
+	}
+}
+
+func TestCache_CoalescedFetchOutlivesFirstCaller(t *testing.T) {
+	release := make(chan struct{})
+	var fetches int32
+	c := NewCache(ProviderFunc(func(ctx context.Context, id string) (map[string]interface{}, error) {
+		atomic.AddInt32(&fetches, 1)
+		select {
+		case <-release:
+			return map[string]interface{}{"tier": "gold"}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}), time.Minute, 10)
+
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.Get(first, "user_42")
+		firstErr <- err
+	}()
+	for atomic.LoadInt32(&fetches) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	second := make(chan map[string]interface{}, 1)
+	go func() {
+		data, _ := c.Get(context.Background(), "user_42")
+		second <- data
+	}()
+
+	// The first caller gives up; the shared fetch carries on for the second.
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("first Get = %v, want context.Canceled", err)
+	}
+	close(release)
+	select {
+	case data := <-second:
+		if data["tier"] != "gold" {
+			t.Errorf("second Get = %v", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("second Get did not return")
+	}
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("fetches = %d, want 1", n)
+	}
+}
@@ -0,0 +1,55 @@
+package actor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTPProvider fetches actor data as a JSON object from a URL template in
+// which "{actor_id}" is replaced by the (escaped) actor ID.
+type HTTPProvider struct {
+	urlTemplate string
+	key         string // placeholder name, e.g. "actor_id"
+	client      *http.Client
+}
+
+// NewHTTPProvider returns an HTTPProvider. A nil client uses http.DefaultClient.
+func NewHTTPProvider(urlTemplate string, client *http.Client) *HTTPProvider {
+	return NewHTTPProviderKey(urlTemplate, "actor_id", client)
+}
+
+// NewHTTPProviderKey returns an HTTPProvider whose template placeholder is
+// "{key}", for other keyed profiles served the same way, such as tenants
+// ("{tenant_id}").
+func NewHTTPProviderKey(urlTemplate, key string, client *http.Client) *HTTPProvider {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPProvider{urlTemplate: urlTemplate, key: key, client: client}
+}
+
+func (p *HTTPProvider) Fetch(ctx context.Context, id string) (map[string]interface{}, error) {
+	kind := strings.TrimSuffix(p.key, "_id")
+	u := strings.ReplaceAll(p.urlTemplate, "{"+p.key+"}", url.PathEscape(id))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", kind, id, err)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", kind, id, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s: profile lookup returned %s", kind, id, resp.Status)
+	}
+	var data map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("%s %s: decode profile: %w", kind, id, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,283 @@
+// Package actorstate exports and imports the state fluxflow keeps per actor
+// (action limit and cooldown windows, running workflows, scheduled events,
+// streaks) as one JSON document, for moving users between environments and
+// for disaster recovery drills.
+//
+// Points balances are not part of it: reward_points reports each grant in
+// the event result and the ledger lives downstream.
+package actorstate
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/counter"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/schedule"
+	"github.com/gyaneshwarpardhi/ifttt/internal/streak"
+	"github.com/gyaneshwarpardhi/ifttt/internal/workflow"
+)
+
+// Format and Version identify the document; Import rejects anything else.
+const (
+	Format  = "fluxflow.actor_state"
+	Version = 2
+)
+
+// Snapshot is the export document.
+type Snapshot struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Region     string    `json:"region,omitempty"`
+	Actors     []Actor   `json:"actors"`
+}
+
+// Actor is one actor's state.
+type Actor struct {
+	ActorID   string               `json:"actor_id"`
+	Limits    []Limit              `json:"limits,omitempty"`
+	Workflows []*workflow.Instance `json:"workflows,omitempty"`
+	Scheduled []*schedule.Entry    `json:"scheduled,omitempty"`
+	Streaks   []*streak.Streak     `json:"streaks,omitempty"`
+}
+
+// Limit is the count of one action's limit (or cooldown) in its current
+// window.
+type Limit struct {
+	ScenarioID  string    `json:"scenario_id"`
+	ActionID    string    `json:"action_id"`
+	WindowStart time.Time `json:"window_start"`
+	Expires     time.Time `json:"expires"`
+	Count       int64     `json:"count"`
+}
+
+// Summary counts what Import restored.
+type Summary struct {
+	Actors    int `json:"actors"`
+	Limits    int `json:"limits"`
+	Workflows int `json:"workflows"`
+	Scheduled int `json:"scheduled"`
+	Streaks   int `json:"streaks"`
+	Expired   int `json:"expired"` // limit windows already over, skipped
+}
+
+// Sources are the stores holding per-actor state. A nil store, or a counter
+// store without counter.Snapshotter, is left out of exports and imports.
+type Sources struct {
+	Counters  counter.Store
+	Workflows workflow.Store
+	Scheduled schedule.Store
+	Streaks   streak.Store
+	Region    string
+}
+
+// Export returns the state of actorIDs, or of every actor with state when
+// actorIDs is empty. Actors without state are omitted.
+func (s Sources) Export(ctx context.Context, actorIDs []string, now time.Time) (*Snapshot, error) {
+	byActor := make(map[string]*Actor)
+	get := func(id string) *Actor {
+		a, ok := byActor[id]
+		if !ok {
+			a = &Actor{ActorID: id}
+			byActor[id] = a
+		}
+		return a
+	}
+	want := func(id string) bool { return len(actorIDs) == 0 || slices.Contains(actorIDs, id) }
+
+	if snap, ok := s.Counters.(counter.Snapshotter); ok {
+		ws, err := snap.Windows(ctx, now, func(key string) bool {
+			_, _, actorID, ok := engine.SplitLimitKey(key)
+			return ok && want(actorID)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range ws {
+			scenarioID, actionID, actorID, _ := engine.SplitLimitKey(w.Key)
+			a := get(actorID)
+			a.Limits = append(a.Limits, Limit{ScenarioID: scenarioID, ActionID: actionID, WindowStart: w.Start, Expires: w.Expires, Count: w.Count})
+		}
+	}
+
+	ids := actorIDs
+	if len(ids) == 0 {
+		all, err := s.actors(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids = all
+	}
+	for _, id := range ids {
+		if s.Workflows != nil {
+			insts, err := s.Workflows.Running(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if len(insts) > 0 {
+				get(id).Workflows = insts
+			}
+		}
+		if s.Scheduled != nil {
+			entries, err := s.Scheduled.ForActor(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if len(entries) > 0 {
+				get(id).Scheduled = entries
+			}
+		}
+		if s.Streaks != nil {
+			streaks, err := s.Streaks.ForActor(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			if len(streaks) > 0 {
+				get(id).Streaks = streaks
+			}
+		}
+	}
+
+	out := &Snapshot{Format: Format, Version: Version, ExportedAt: now.UTC(), Region: s.Region, Actors: []Actor{}}
+	for _, a := range byActor {
+		sort.Slice(a.Limits, func(i, j int) bool {
+			if a.Limits[i].ScenarioID != a.Limits[j].ScenarioID {
+				return a.Limits[i].ScenarioID < a.Limits[j].ScenarioID
+			}
+			return a.Limits[i].ActionID < a.Limits[j].ActionID
+		})
+		out.Actors = append(out.Actors, *a)
+	}
+	sort.Slice(out.Actors, func(i, j int) bool { return out.Actors[i].ActorID < out.Actors[j].ActorID })
+	return out, nil
+}
+
+// actors lists actors with workflow, scheduled or streak state.
+func (s Sources) actors(ctx context.Context) ([]string, error) {
+	var ids []string
+	if s.Workflows != nil {
+		w, err := s.Workflows.Actors(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, w...)
+	}
+	if s.Scheduled != nil {
+		sc, err := s.Scheduled.Actors(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, sc...)
+	}
+	if s.Streaks != nil {
+		st, err := s.Streaks.Actors(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, st...)
+	}
+	slices.Sort(ids)
+	return slices.Compact(ids), nil
+}
+
+// Check reports the first problem that would stop snap from importing.
+func Check(snap *Snapshot) error {
+	if snap.Format != Format || snap.Version != Version {
+		return fmt.Errorf("unsupported document %q version %d (want %q version %d)", snap.Format, snap.Version, Format, Version)
+	}
+	for i, a := range snap.Actors {
+		if a.ActorID == "" {
+			return fmt.Errorf("actors[%d]: actor_id is required", i)
+		}
+		for _, l := range a.Limits {
+			if l.ScenarioID == "" || l.ActionID == "" || l.Count < 0 || !l.WindowStart.Before(l.Expires) {
+				return fmt.Errorf("actor %s: invalid limit %+v", a.ActorID, l)
+			}
+		}
+		for _, inst := range a.Workflows {
+			if inst == nil || inst.ID == "" || inst.ActorID != a.ActorID {
+				return fmt.Errorf("actor %s: workflow instances need an id and this actor_id", a.ActorID)
+			}
+		}
+		for _, e := range a.Scheduled {
+			if e == nil || e.ID == "" || e.ActorID != a.ActorID || e.Event == nil {
+				return fmt.Errorf("actor %s: scheduled entries need an id, this actor_id and an event", a.ActorID)
+			}
+		}
+		for _, st := range a.Streaks {
+			if st == nil || st.EventType == "" || st.ActorID != a.ActorID || st.Current < 0 || st.Best < st.Current {
+				return fmt.Errorf("actor %s: streaks need an event_type, this actor_id and 0 <= current <= best", a.ActorID)
+			}
+			if _, err := time.Parse(streak.DayLayout, st.LastDay); err != nil {
+				return fmt.Errorf("actor %s: streak %s: last_day %q is not YYYY-MM-DD", a.ActorID, st.EventType, st.LastDay)
+			}
+		}
+	}
+	return nil
+}
+
+// Import merges snap into the stores. It is safe to repeat: limit counts are
+// only ever raised, workflow instances are saved by ID, scheduled entries
+// already pending are kept and a streak only replaces one with an earlier
+// last day. Call Check first; Import stops at the first
+// store error, leaving earlier actors imported.
+func (s Sources) Import(ctx context.Context, snap *Snapshot, now time.Time) (*Summary, error) {
+	if err := Check(snap); err != nil {
+		return nil, err
+	}
+	sum := &Summary{}
+	snapper, _ := s.Counters.(counter.Snapshotter)
+	for _, a := range snap.Actors {
+		if snapper != nil && len(a.Limits) > 0 {
+			ws := make([]counter.Window, 0, len(a.Limits))
+			for _, l := range a.Limits {
+				if !now.Before(l.Expires) {
+					sum.Expired++
+					continue
+				}
+				ws = append(ws, counter.Window{Key: engine.LimitKey(l.ScenarioID, l.ActionID, a.ActorID), Start: l.WindowStart, Expires: l.Expires, Count: l.Count})
+			}
+			if err := snapper.Restore(ctx, ws, now); err != nil {
+				return sum, fmt.Errorf("actor %s: %w", a.ActorID, err)
+			}
+			sum.Limits += len(ws)
+		}
+		if s.Workflows != nil {
+			for _, inst := range a.Workflows {
+				if err := s.Workflows.Save(ctx, inst); err != nil {
+					return sum, fmt.Errorf("actor %s: %w", a.ActorID, err)
+				}
+				sum.Workflows++
+			}
+		}
+		if s.Scheduled != nil {
+			for _, e := range a.Scheduled {
+				if err := s.Scheduled.Put(ctx, e); err != nil {
+					return sum, fmt.Errorf("actor %s: %w", a.ActorID, err)
+				}
+				sum.Scheduled++
+			}
+		}
+		if s.Streaks != nil {
+			for _, st := range a.Streaks {
+				cur, err := s.Streaks.Get(ctx, a.ActorID, st.EventType)
+				if err != nil {
+					return sum, fmt.Errorf("actor %s: %w", a.ActorID, err)
+				}
+				if cur != nil && cur.LastDay >= st.LastDay {
+					continue
+				}
+				if err := s.Streaks.Save(ctx, st); err != nil {
+					return sum, fmt.Errorf("actor %s: %w", a.ActorID, err)
+				}
+				sum.Streaks++
+			}
+		}
+		sum.Actors++
+	}
+	return sum, nil
+}
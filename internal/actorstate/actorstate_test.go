@@ -0,0 +1,104 @@
+package actorstate
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/counter"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/schedule"
+	"github.com/gyaneshwarpardhi/ifttt/internal/streak"
+	"github.com/gyaneshwarpardhi/ifttt/internal/workflow"
+)
+
+func newSources() Sources {
+	return Sources{
+		Counters:  counter.NewMemory(),
+		Workflows: workflow.NewMemoryStore(),
+		Scheduled: schedule.NewMemoryStore(),
+		Streaks:   streak.NewMemoryStore(),
+	}
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	src := newSources()
+	src.Counters.Claim(ctx, "sc_promo|act_bonus|u1", 3, time.Hour, now)
+	src.Counters.Claim(ctx, "sc_promo|act_bonus|u1", 3, time.Hour, now)
+	src.Counters.Claim(ctx, "sc_promo|act_bonus|u2", 3, time.Hour, now)
+	src.Workflows.Save(ctx, &workflow.Instance{ID: "wf-1", WorkflowID: "wf_checkout", ActorID: "u1", Status: workflow.StatusRunning})
+	src.Scheduled.Put(ctx, &schedule.Entry{ID: "e1:act_later", ActorID: "u3", Due: now.Add(time.Hour), Event: &event.Event{ID: "e1:act_later", Type: "churn_risk", ActorID: "u3"}})
+	src.Streaks.Save(ctx, &streak.Streak{ActorID: "u2", EventType: "login", Current: 4, Best: 6, LastDay: "2026-03-01"})
+	src.Streaks.Save(ctx, &streak.Streak{ActorID: "u2", EventType: "checkin", Current: 4, Best: 6, LastDay: "2026-03-01"})
+
+	all, err := src.Export(ctx, nil, now)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(all.Actors) != 3 {
+		t.Fatalf("exported %d actors, want 3: %+v", len(all.Actors), all.Actors)
+	}
+	one, err := src.Export(ctx, []string{"u1"}, now)
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	if len(one.Actors) != 1 || len(one.Actors[0].Limits) != 1 || one.Actors[0].Limits[0].Count != 2 ||
+		one.Actors[0].Limits[0].ScenarioID != "sc_promo" || len(one.Actors[0].Workflows) != 1 {
+		t.Fatalf("unexpected u1 export: %+v", one.Actors)
+	}
+
+	// The document survives JSON, as it does through the API.
+	body, _ := json.Marshal(all)
+	var doc Snapshot
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	dst := newSources()
+	// A streak already stored with a later day is newer and kept.
+	dst.Streaks.Save(ctx, &streak.Streak{ActorID: "u2", EventType: "login", Current: 1, Best: 1, LastDay: "2026-03-02"})
+	for range 2 { // importing twice changes nothing
+		sum, err := dst.Import(ctx, &doc, now.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		if sum.Actors != 3 || sum.Limits != 2 || sum.Workflows != 1 || sum.Scheduled != 1 {
+			t.Fatalf("unexpected summary %+v", sum)
+		}
+	}
+	if ok, _ := dst.Counters.Claim(ctx, "sc_promo|act_bonus|u1", 3, time.Hour, now.Add(time.Minute)); !ok {
+		t.Error("u1 should have one slot left")
+	}
+	if ok, _ := dst.Counters.Claim(ctx, "sc_promo|act_bonus|u1", 3, time.Hour, now.Add(time.Minute)); ok {
+		t.Error("u1's imported count was not applied")
+	}
+	if n, _ := dst.Scheduled.Pending(ctx); n != 1 {
+		t.Errorf("scheduled entries = %d, want 1", n)
+	}
+	if insts, _ := dst.Workflows.Running(ctx, "u1"); len(insts) != 1 {
+		t.Errorf("running workflows for u1 = %d, want 1", len(insts))
+	}
+	if st, _ := dst.Streaks.Get(ctx, "u2", "checkin"); st == nil || st.Current != 4 || st.Best != 6 {
+		t.Errorf("u2 checkin streak = %+v, want current 4 best 6", st)
+	}
+	if st, _ := dst.Streaks.Get(ctx, "u2", "login"); st == nil || st.LastDay != "2026-03-02" {
+		t.Errorf("u2 login streak = %+v, want the newer local one kept", st)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	for name, snap := range map[string]*Snapshot{
+		"wrong format":     {Format: "other", Version: Version},
+		"wrong version":    {Format: Format, Version: 1},
+		"no actor id":      {Format: Format, Version: Version, Actors: []Actor{{}}},
+		"bad limit":        {Format: Format, Version: Version, Actors: []Actor{{ActorID: "u1", Limits: []Limit{{ActionID: "a", Count: 1}}}}},
+		"bad streak day":   {Format: Format, Version: Version, Actors: []Actor{{ActorID: "u1", Streaks: []*streak.Streak{{ActorID: "u1", EventType: "login", Current: 1, Best: 1, LastDay: "yesterday"}}}}},
+		"foreign workflow": {Format: Format, Version: Version, Actors: []Actor{{ActorID: "u1", Workflows: []*workflow.Instance{{ID: "w", ActorID: "u2"}}}}},
+	} {
+		if err := Check(snap); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package anomaly
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// minDelta is the smallest absolute change in match ratio that can count as
+// an anomaly; it keeps near-zero baselines from alerting on single matches.
+const minDelta = 0.01
+
+// Direction of an anomaly relative to baseline.
+const (
+	Spike = "spike" // scenario suddenly matches far more events than usual
+	Drop  = "drop"  // scenario suddenly matches far fewer (or none)
+)
+
+// Alert describes one scenario whose match ratio left its baseline.
+type Alert struct {
+	ScenarioID string    `json:"scenario_id"`
+	Direction  string    `json:"direction"`
+	Ratio      float64   `json:"ratio"`    // matches / events in the window
+	Baseline   float64   `json:"baseline"` // EWMA of previous windows
+	Events     int       `json:"events"`
+	WindowEnd  time.Time `json:"window_end"`
+}
+
+// Notifier receives alerts (e.g. a webhook). It is called outside the detector lock.
+type Notifier func(Alert)
+
+// Detector tracks per-scenario match ratios over fixed windows and compares
+// each window against an exponentially weighted baseline of earlier windows.
+type Detector struct {
+	conf   config.AnomalyConf
+	notify Notifier
+
+	mu        sync.Mutex
+	events    int
+	counts    map[string]int
+	baselines map[string]*baseline
+}
+
+type baseline struct {
+	ewma    float64
+	windows int
+}
+
+// NewDetector creates a Detector. notify may be nil (metrics only).
+func NewDetector(conf config.AnomalyConf, notify Notifier) *Detector {
+	return &Detector{
+		conf:      conf,
+		notify:    notify,
+		counts:    make(map[string]int),
+		baselines: make(map[string]*baseline),
+	}
+}
+
+// Observe records one processed event and the scenarios it matched.
+func (d *Detector) Observe(scenariosMatched []string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events++
+	for _, id := range scenariosMatched {
+		d.counts[id]++
+	}
+}
+
+// Run closes a window every conf.WindowSec until ctx is cancelled.
+func (d *Detector) Run(ctx context.Context) {
+	t := time.NewTicker(d.conf.WindowSec.Duration())
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			for _, a := range d.Roll(now) {
+				if d.notify != nil {
+					d.notify(a)
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Roll closes the current window, updates baselines and returns any alerts.
+// Windows with fewer than conf.MinEvents events are discarded.
+func (d *Detector) Roll(now time.Time) []Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	events, counts := d.events, d.counts
+	d.events, d.counts = 0, make(map[string]int)
+	if events < d.conf.MinEvents || events == 0 {
+		return nil
+	}
+
+	// Scenarios with a baseline but no matches this window have ratio 0.
+	for id := range d.baselines {
+		if _, ok := counts[id]; !ok {
+			counts[id] = 0
+		}
+	}
+
+	var alerts []Alert
+	for id, n := range counts {
+		ratio := float64(n) / float64(events)
+		metrics.ScenarioMatchRatio.WithLabelValues(id).Set(ratio)
+
+		b, ok := d.baselines[id]
+		if !ok {
+			d.baselines[id] = &baseline{ewma: ratio, windows: 1}
+			continue
+		}
+		if b.windows >= d.conf.WarmupWindows {
+			if dir := deviation(ratio, b.ewma, d.conf.Factor); dir != "" {
+				metrics.ScenarioMatchAnomalies.WithLabelValues(id, dir).Inc()
+				alerts = append(alerts, Alert{
+					ScenarioID: id,
+					Direction:  dir,
+					Ratio:      ratio,
+					Baseline:   b.ewma,
+					Events:     events,
+					WindowEnd:  now,
+				})
+			}
+		}
+		b.ewma = d.conf.Alpha*ratio + (1-d.conf.Alpha)*b.ewma
+		b.windows++
+	}
+	return alerts
+}
+
+// Forget drops the baseline for scenarios not in ids, e.g. after a reload
+// removed them, so they do not alert as a permanent drop.
+func (d *Detector) Forget(ids map[string]struct{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for id := range d.baselines {
+		if _, ok := ids[id]; !ok {
+			delete(d.baselines, id)
+			metrics.ScenarioMatchRatio.DeleteLabelValues(id)
+		}
+	}
+}
+
+func deviation(ratio, base, factor float64) string {
+	switch {
+	case ratio >= base*factor && ratio-base >= minDelta:
+		return Spike
+	case base >= minDelta && ratio <= base/factor:
+		return Drop
+	}
+	return ""
+}
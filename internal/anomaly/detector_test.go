@@ -0,0 +1,54 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+)
+
+func feed(d *Detector, events, matches int) {
+	for i := 0; i < events; i++ {
+		if i < matches {
+			d.Observe([]string{"sc_a"})
+		} else {
+			d.Observe(nil)
+		}
+	}
+}
+
+func TestDetector_SpikeAndDrop(t *testing.T) {
+	d := NewDetector(config.AnomalyConf{Factor: 5, Alpha: 0.2, MinEvents: 10, WarmupWindows: 3}, nil)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		feed(d, 100, 5) // steady 5% baseline
+		if alerts := d.Roll(now); len(alerts) != 0 {
+			t.Fatalf("unexpected alert during warmup: %+v", alerts)
+		}
+	}
+
+	feed(d, 100, 100) // rule suddenly matches everything
+	alerts := d.Roll(now)
+	if len(alerts) != 1 || alerts[0].Direction != Spike {
+		t.Fatalf("expected one spike alert, got %+v", alerts)
+	}
+
+	d = NewDetector(config.AnomalyConf{Factor: 5, Alpha: 0.2, MinEvents: 10, WarmupWindows: 3}, nil)
+	for i := 0; i < 3; i++ {
+		feed(d, 100, 20)
+		d.Roll(now)
+	}
+	feed(d, 100, 0) // rule suddenly matches nothing
+	alerts = d.Roll(now)
+	if len(alerts) != 1 || alerts[0].Direction != Drop {
+		t.Fatalf("expected one drop alert, got %+v", alerts)
+	}
+}
+
+func TestDetector_IgnoresQuietWindows(t *testing.T) {
+	d := NewDetector(config.AnomalyConf{Factor: 5, Alpha: 0.2, MinEvents: 50, WarmupWindows: 1}, nil)
+	feed(d, 10, 10)
+	if alerts := d.Roll(time.Now()); alerts != nil {
+		t.Errorf("expected low-traffic window to be ignored, got %+v", alerts)
+	}
+}
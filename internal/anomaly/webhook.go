@@ -0,0 +1,39 @@
+package anomaly
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// WebhookNotifier returns a Notifier that POSTs each alert as JSON to url.
+// Delivery is best-effort: failures are logged, never retried.
+func WebhookNotifier(url string) Notifier {
+	client := &http.Client{Timeout: 5 * time.Second}
+	return func(a Alert) {
+		slog.Warn("scenario match anomaly",
+			"scenario_id", a.ScenarioID,
+			"direction", a.Direction,
+			"ratio", a.Ratio,
+			"baseline", a.Baseline,
+		)
+		if url == "" {
+			return
+		}
+		body, err := json.Marshal(a)
+		if err != nil {
+			return
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			slog.Warn("anomaly webhook failed", "url", url, "err", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			slog.Warn("anomaly webhook rejected", "url", url, "status", resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/duration"
+)
+
+// WithLogLevel makes log_level tunable through PATCH /v1/admin/engine.
+func WithLogLevel(lv *slog.LevelVar) Option {
+	return func(h *Handler) { h.logLvl = lv }
+}
+
+// engineSettings are the engine settings that can change without a restart.
+// The rest (worker counts, queue depth, pinning, counter strategy) size
+// structures built at startup.
+type engineSettings struct {
+	EventTimeoutMs         config.Millis `json:"event_timeout_ms"`
+	AdaptiveAsyncThreshold float64       `json:"adaptive_async_threshold"`
+	ShedExpensiveAt        float64       `json:"shed_expensive_at"`
+	ShedMode               string        `json:"shed_mode"`
+	OverflowPolicy         string        `json:"overflow_policy"`
+	IngestRateLimit        float64       `json:"ingest_rate_limit"`
+	IngestBurst            int           `json:"ingest_burst"`
+	RequestLogSampleEvery  int           `json:"request_log_sample_every"`
+	MaxPayloadBytes        int           `json:"max_payload_bytes"`
+	MaxPayloadDepth        int           `json:"max_payload_depth"`
+	MaxMatchInput          int           `json:"max_match_input"`
+	MaxResults             int           `json:"max_results"`
+	ActionTimeoutMs        config.Millis `json:"action_timeout_ms"`
+	ActionRetries          int           `json:"action_retries"`
+	ActionRetryBackoffMs   config.Millis `json:"action_retry_backoff_ms"`
+	LogLevel               string        `json:"log_level,omitempty"`
+}
+
+// engineTuning is a PATCH body; nil fields are left unchanged.
+type engineTuning struct {
+	EventTimeoutMs         *millis  `json:"event_timeout_ms"`
+	AdaptiveAsyncThreshold *float64 `json:"adaptive_async_threshold"`
+	ShedExpensiveAt        *float64 `json:"shed_expensive_at"`
+	ShedMode               *string  `json:"shed_mode"`
+	OverflowPolicy         *string  `json:"overflow_policy"`
+	IngestRateLimit        *float64 `json:"ingest_rate_limit"`
+	IngestBurst            *int     `json:"ingest_burst"`
+	RequestLogSampleEvery  *int     `json:"request_log_sample_every"`
+	MaxPayloadBytes        *int     `json:"max_payload_bytes"`
+	MaxPayloadDepth        *int     `json:"max_payload_depth"`
+	MaxMatchInput          *int     `json:"max_match_input"`
+	MaxResults             *int     `json:"max_results"`
+	ActionTimeoutMs        *millis  `json:"action_timeout_ms"`
+	ActionRetries          *int     `json:"action_retries"`
+	ActionRetryBackoffMs   *millis  `json:"action_retry_backoff_ms"`
+	LogLevel               *string  `json:"log_level"`
+	Reason                 string   `json:"reason"`
+}
+
+// millis accepts milliseconds (1500) or a duration string ("1.5s", "2m").
+type millis config.Millis
+
+func (m *millis) UnmarshalJSON(b []byte) error {
+	var n float64
+	if err := json.Unmarshal(b, &n); err == nil {
+		*m = millis(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("want milliseconds or a duration string, got %s", b)
+	}
+	d, err := duration.Parse(s)
+	if err != nil {
+		return err
+	}
+	*m = millis(d / time.Millisecond)
+	return nil
+}
+
+// validate checks every field before any is applied, so a bad request
+// changes nothing.
+func (t *engineTuning) validate(logTunable bool) []string {
+	var errs []string
+	nonNeg := func(name string, v *int) {
+		if v != nil && *v < 0 {
+			errs = append(errs, fmt.Sprintf("%s must be >= 0", name))
+		}
+	}
+	if t.EventTimeoutMs != nil && *t.EventTimeoutMs <= 0 {
+		errs = append(errs, "event_timeout_ms must be > 0")
+	}
+	if v := t.AdaptiveAsyncThreshold; v != nil && (*v < 0 || *v > 1) {
+		errs = append(errs, "adaptive_async_threshold must be in [0, 1]")
+	}
+	if v := t.ShedExpensiveAt; v != nil && (*v < 0 || *v > 1) {
+		errs = append(errs, "shed_expensive_at must be in [0, 1]")
+	}
+	if v := t.ShedMode; v != nil && *v != config.ShedSkip && *v != config.ShedDefer {
+		errs = append(errs, fmt.Sprintf("shed_mode must be %q or %q", config.ShedSkip, config.ShedDefer))
+	}
+	if v := t.OverflowPolicy; v != nil && *v != config.OverflowReject && *v != config.OverflowDefer {
+		errs = append(errs, fmt.Sprintf("overflow_policy must be %q or %q", config.OverflowReject, config.OverflowDefer))
+	}
+	if v := t.IngestRateLimit; v != nil && *v < 0 {
+		errs = append(errs, "ingest_rate_limit must be >= 0")
+	}
+	nonNeg("ingest_burst", t.IngestBurst)
+	nonNeg("request_log_sample_every", t.RequestLogSampleEvery)
+	nonNeg("max_payload_bytes", t.MaxPayloadBytes)
+	nonNeg("max_payload_depth", t.MaxPayloadDepth)
+	nonNeg("max_match_input", t.MaxMatchInput)
+	nonNeg("max_results", t.MaxResults)
+	nonNeg("action_retries", t.ActionRetries)
+	for name, v := range map[string]*millis{"action_timeout_ms": t.ActionTimeoutMs, "action_retry_backoff_ms": t.ActionRetryBackoffMs} {
+		if v != nil && *v < 0 {
+			errs = append(errs, fmt.Sprintf("%s must be >= 0", name))
+		}
+	}
+	if t.LogLevel != nil {
+		var lvl slog.Level
+		if !logTunable {
+			errs = append(errs, "log_level is not tunable on this server")
+		} else if err := lvl.UnmarshalText([]byte(*t.LogLevel)); err != nil {
+			errs = append(errs, fmt.Sprintf("log_level %q must be debug, info, warn or error", *t.LogLevel))
+		}
+	}
+	return errs
+}
+
+func (h *Handler) engineSettings() engineSettings {
+	c := h.eng.Settings()
+	s := engineSettings{
+		EventTimeoutMs:         c.EventTimeoutMs,
+		AdaptiveAsyncThreshold: math.Float64frombits(h.adaptiveAsync.Load()),
+		ShedExpensiveAt:        c.ShedExpensiveAt,
+		ShedMode:               c.ShedMode,
+		OverflowPolicy:         c.OverflowPolicy,
+		IngestRateLimit:        c.IngestRateLimit,
+		IngestBurst:            c.IngestBurst,
+		RequestLogSampleEvery:  c.RequestLogSampleEvery,
+		MaxPayloadBytes:        c.MaxPayloadBytes,
+		MaxPayloadDepth:        c.MaxPayloadDepth,
+		MaxMatchInput:          c.MaxMatchInput,
+		MaxResults:             c.MaxResults,
+		ActionTimeoutMs:        c.ActionTimeoutMs,
+		ActionRetries:          c.ActionRetries,
+		ActionRetryBackoffMs:   c.ActionRetryBackoffMs,
+	}
+	if h.logLvl != nil {
+		s.LogLevel = strings.ToLower(h.logLvl.Level().String())
+	}
+	return s
+}
+
+// GET /v1/admin/engine — the runtime-tunable engine settings in effect.
+func (h *Handler) getEngineSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.engineSettings())
+}
+
+// PATCH /v1/admin/engine — change tunable engine settings without a restart.
+// Changes are not written to the rules file and last until the process exits.
+func (h *Handler) tuneEngine(w http.ResponseWriter, r *http.Request) {
+	var req engineTuning
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		msg := fmt.Sprintf("invalid JSON: %s", err)
+		if strings.Contains(err.Error(), "unknown field") {
+			msg += " (only runtime-tunable settings are accepted; see GET /v1/admin/engine — others need a restart)"
+		}
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	if errs := req.validate(h.logLvl != nil); len(errs) > 0 {
+		writeError(w, http.StatusUnprocessableEntity, strings.Join(errs, "; "))
+		return
+	}
+
+	changes := make(map[string]interface{})
+	setInt := func(name string, src *int, dst *int) {
+		if src != nil {
+			*dst = *src
+			changes[name] = *src
+		}
+	}
+	setMillis := func(name string, src *millis, dst *config.Millis) {
+		if src != nil {
+			*dst = config.Millis(*src)
+			changes[name] = *src
+		}
+	}
+	h.eng.Tune(func(c *config.EngineConf) {
+		setMillis("event_timeout_ms", req.EventTimeoutMs, &c.EventTimeoutMs)
+		setInt("ingest_burst", req.IngestBurst, &c.IngestBurst)
+		setInt("request_log_sample_every", req.RequestLogSampleEvery, &c.RequestLogSampleEvery)
+		setInt("max_payload_bytes", req.MaxPayloadBytes, &c.MaxPayloadBytes)
+		setInt("max_payload_depth", req.MaxPayloadDepth, &c.MaxPayloadDepth)
+		setInt("max_match_input", req.MaxMatchInput, &c.MaxMatchInput)
+		setInt("max_results", req.MaxResults, &c.MaxResults)
+		setMillis("action_timeout_ms", req.ActionTimeoutMs, &c.ActionTimeoutMs)
+		setInt("action_retries", req.ActionRetries, &c.ActionRetries)
+		setMillis("action_retry_backoff_ms", req.ActionRetryBackoffMs, &c.ActionRetryBackoffMs)
+		if v := req.AdaptiveAsyncThreshold; v != nil {
+			c.AdaptiveAsyncThreshold = *v
+			h.adaptiveAsync.Store(math.Float64bits(*v))
+			changes["adaptive_async_threshold"] = *v
+		}
+		if v := req.ShedExpensiveAt; v != nil {
+			c.ShedExpensiveAt = *v
+			changes["shed_expensive_at"] = *v
+		}
+		if v := req.ShedMode; v != nil {
+			c.ShedMode = *v
+			changes["shed_mode"] = *v
+		}
+		if v := req.IngestRateLimit; v != nil {
+			c.IngestRateLimit = *v
+			changes["ingest_rate_limit"] = *v
+		}
+		if v := req.OverflowPolicy; v != nil {
+			c.OverflowPolicy = *v
+			changes["overflow_policy"] = *v
+		}
+	})
+	if req.LogLevel != nil {
+		var lvl slog.Level
+		_ = lvl.UnmarshalText([]byte(*req.LogLevel)) // validated above
+		h.logLvl.Set(lvl)
+		changes["log_level"] = *req.LogLevel
+	}
+
+	h.audit.record(r.Context(), auditEntry{
+		Time:    time.Now(),
+		Action:  "engine.tune",
+		Actor:   h.auditActor(r),
+		Token:   h.tokenName(r),
+		Reason:  req.Reason,
+		Changes: changes,
+	})
+	writeJSON(w, http.StatusOK, h.engineSettings())
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+)
+
+// archiveRequest is the optional body of the archive and restore routes.
+type archiveRequest struct {
+	Persist bool   `json:"persist"`
+	Reason  string `json:"reason"`
+}
+
+// archivedScenario is one entry of GET /v1/rules/archive.
+type archivedScenario struct {
+	ID          string       `json:"id"`
+	Description string       `json:"description,omitempty"`
+	Enabled     bool         `json:"enabled"` // the state a restore returns to
+	ArchivedAt  time.Time    `json:"archived_at,omitempty"`
+	ArchivedBy  string       `json:"archived_by,omitempty"`
+	Reason      string       `json:"reason,omitempty"`
+	Scenario    interface{}  `json:"scenario"` // the retained definition
+	History     []auditEntry `json:"history"`  // runtime changes still in the audit log, newest first
+
+	// ArchiveHistory is the recent archives and restores made through the API,
+	// oldest first. Unlike History it survives restarts once persisted.
+	ArchiveHistory []config.ArchiveEvent `json:"archive_history"`
+}
+
+// GET /v1/rules/archive — archived scenarios with their definitions and
+// recent history.
+func (h *Handler) listArchive(w http.ResponseWriter, r *http.Request) {
+	audit := h.audit.list()
+	out := []archivedScenario{}
+	for _, sc := range h.loader.Config().Scenarios {
+		if sc.Archived == nil {
+			continue
+		}
+		a := archivedScenario{
+			ID:          sc.ID,
+			Description: sc.Description,
+			Enabled:     sc.Enabled,
+			ArchivedAt:  sc.Archived.At,
+			ArchivedBy:  sc.Archived.By,
+			Reason:      sc.Archived.Reason,
+			Scenario:    sc,
+			History:     []auditEntry{},
+		}
+		a.ArchiveHistory = append([]config.ArchiveEvent{}, h.loader.ArchiveHistory(sc.ID)...)
+		for _, e := range audit {
+			if e.ScenarioID == sc.ID {
+				a.History = append(a.History, e)
+			}
+		}
+		out = append(out, a)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"count": len(out), "scenarios": out})
+}
+
+// POST /v1/rules/scenarios/{id}/archive — take a scenario out of the live
+// graph, keeping its definition and ID for a later restore.
+func (h *Handler) archiveScenario(w http.ResponseWriter, r *http.Request) {
+	h.changeArchive(w, r, "scenario.archive", func(id, actor string, req archiveRequest, check func(*config.RuleConfig) error) (*config.RuleConfig, error) {
+		info := config.ArchiveInfo{At: time.Now().UTC().Truncate(time.Second), By: actor, Reason: req.Reason}
+		return h.loader.ArchiveScenario(id, info, req.Persist, check)
+	})
+}
+
+// POST /v1/rules/scenarios/{id}/restore — return an archived scenario to the
+// live graph as it was.
+func (h *Handler) restoreScenario(w http.ResponseWriter, r *http.Request) {
+	h.changeArchive(w, r, "scenario.restore", func(id, actor string, req archiveRequest, check func(*config.RuleConfig) error) (*config.RuleConfig, error) {
+		info := config.ArchiveInfo{At: time.Now().UTC().Truncate(time.Second), By: actor, Reason: req.Reason}
+		return h.loader.RestoreScenario(id, info, req.Persist, check)
+	})
+}
+
+// changeArchive applies change to scenario {id}, swaps in the rebuilt graph
+// and records action in the audit log. change passes check to the loader so
+// a config whose graph does not build is never committed.
+func (h *Handler) changeArchive(w http.ResponseWriter, r *http.Request, action string,
+	change func(id, actor string, req archiveRequest, check func(*config.RuleConfig) error) (*config.RuleConfig, error)) {
+	id := r.PathValue("id")
+	var req archiveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err))
+		return
+	}
+	actor := h.auditActor(r)
+	var g *dag.Graph
+	cfg, err := change(id, actor, req, func(cfg *config.RuleConfig) (err error) {
+		g, err = h.eng.BuildGraph(r.Context(), cfg)
+		return err
+	})
+	if err != nil {
+		writeError(w, scenarioChangeStatus(err, req.Persist), err.Error())
+		return
+	}
+	h.eng.SwapGraph(g)
+
+	var sc config.Scenario
+	for _, s := range cfg.Scenarios {
+		if s.ID == id {
+			sc = s
+		}
+	}
+	h.audit.record(r.Context(), auditEntry{
+		Time:       time.Now(),
+		Action:     action,
+		ScenarioID: id,
+		Enabled:    sc.Enabled,
+		Persisted:  req.Persist,
+		Actor:      actor,
+		Token:      h.tokenName(r),
+		Reason:     req.Reason,
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"scenario_id": id,
+		"archived":    sc.Archived != nil,
+		"enabled":     sc.Enabled,
+		"persisted":   req.Persist,
+		"hash":        g.Hash(),
+	})
+}
+
+// scenarioChangeStatus maps a loader error from a scenario toggle, archive
+// or restore to an HTTP status.
+func scenarioChangeStatus(err error, persist bool) int {
+	switch {
+	case errors.Is(err, config.ErrUnknownScenario):
+		return http.StatusNotFound
+	case errors.Is(err, config.ErrScenarioArchived), errors.Is(err, config.ErrScenarioNotArchived):
+		return http.StatusConflict
+	case errors.Is(err, config.ErrChangeRejected):
+		return http.StatusUnprocessableEntity
+	case persist:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+)
+
+const auditCapacity = 200
+
+// auditEntry records one runtime change made through the API.
+type auditEntry struct {
+	Time       time.Time `json:"time"`
+	Action     string    `json:"action"`
+	ScenarioID string    `json:"scenario_id"`
+	Enabled    bool      `json:"enabled"`
+	Persisted  bool      `json:"persisted"`
+	Actor      string    `json:"actor"`
+	Token      string    `json:"token,omitempty"` // API token that made the change
+	Reason     string    `json:"reason,omitempty"`
+
+	Changes map[string]interface{} `json:"changes,omitempty"` // engine.tune: setting → new value
+}
+
+// auditLog keeps the most recent entries in memory and mirrors every entry
+// to the log, with the request's logctx fields.
+type auditLog struct {
+	mu      sync.Mutex
+	entries []auditEntry
+}
+
+func (a *auditLog) record(ctx context.Context, e auditEntry) {
+	logctx.From(ctx).Info("audit",
+		"action", e.Action,
+		"scenario_id", e.ScenarioID,
+		"enabled", e.Enabled,
+		"persisted", e.Persisted,
+		"actor", e.Actor,
+		"token", e.Token,
+		"reason", e.Reason,
+		"changes", e.Changes,
+	)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.entries = append(a.entries, e)
+	if len(a.entries) > auditCapacity {
+		a.entries = a.entries[len(a.entries)-auditCapacity:]
+	}
+}
+
+// purge drops entries older than ttl(ScenarioID) and returns how many.
+func (a *auditLog) purge(now time.Time, ttl func(scenarioID string) time.Duration) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	kept := a.entries[:0]
+	for _, e := range a.entries {
+		if d := ttl(e.ScenarioID); d > 0 && now.Sub(e.Time) >= d {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	n := len(a.entries) - len(kept)
+	clear(a.entries[len(kept):])
+	a.entries = kept
+	return n
+}
+
+// list returns entries newest first.
+func (a *auditLog) list() []auditEntry {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]auditEntry, len(a.entries))
+	for i, e := range a.entries {
+		out[len(a.entries)-1-i] = e
+	}
+	return out
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/schedule"
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
+)
+
+// apiVersions are the route prefixes this build serves.
+var apiVersions = []string{"v1"}
+
+// WithStores names the backend of each storage subsystem (e.g. "workflows":
+// "sqlite", "counters": "crdt") for GET /v1/capabilities.
+func WithStores(stores map[string]string) Option {
+	return func(h *Handler) { h.stores = stores }
+}
+
+// capabilities is the GET /v1/capabilities document.
+type capabilities struct {
+	APIVersions  []string          `json:"api_versions"`
+	Region       string            `json:"region,omitempty"`
+	RulesVersion string            `json:"rules_version"`
+	Sources      []string          `json:"sources"`   // ways events enter the engine
+	Streaming    []string          `json:"streaming"` // POST /v1/events stream formats
+	Stores       map[string]string `json:"stores"`
+	Executors    []executorInfo    `json:"executors"`
+	Expression   expressionInfo    `json:"expression"`
+	Features     map[string]bool   `json:"features"`
+}
+
+type executorInfo struct {
+	Type      string `json:"type"`
+	Sandboxed bool   `json:"sandboxed,omitempty"`
+}
+
+type expressionInfo struct {
+	Operators  []condition.Operator `json:"operators"`
+	Functions  []string             `json:"functions"`
+	Namespaces []string             `json:"namespaces"`
+}
+
+// GET /v1/capabilities — what this deployment supports, so clients and the
+// UI can adapt: enabled subsystems, store backends, registered action types
+// and the expression functions and namespaces rules may use.
+func (h *Handler) capabilities(w http.ResponseWriter, r *http.Request) {
+	conf := h.eng.Settings()
+	reg := h.eng.Registry()
+
+	sources := []string{"http", "batch"}
+	if h.inbox != nil {
+		sources = append(sources, "inbox")
+	}
+	if _, err := reg.Get(schedule.ActionType); err == nil {
+		sources = append(sources, "scheduler")
+	}
+
+	types := reg.Types()
+	sort.Strings(types)
+	execs := make([]executorInfo, len(types))
+	for i, t := range types {
+		execs[i] = executorInfo{Type: t, Sandboxed: reg.IsSandboxed(t)}
+	}
+
+	namespaces := []string{"payload", "meta", "event"}
+	if conf.ActorProfileURL != "" {
+		namespaces = append(namespaces, "actor")
+	}
+	if conf.TenantProfileURL != "" {
+		namespaces = append(namespaces, "tenant")
+	}
+
+	stores := h.stores
+	if stores == nil {
+		stores = map[string]string{}
+	}
+	writeJSON(w, http.StatusOK, capabilities{
+		APIVersions:  apiVersions,
+		Region:       conf.Region,
+		RulesVersion: h.eng.Graph().Version(),
+		Sources:      sources,
+		Streaming:    []string{streamSSE, streamNDJSON},
+		Stores:       stores,
+		Executors:    execs,
+		Expression: expressionInfo{
+			Operators:  condition.Operators(),
+			Functions:  dag.Functions(),
+			Namespaces: namespaces,
+		},
+		Features: map[string]bool{
+			"auth_tokens":         h.tokens != nil,
+			"adaptive_async":      h.adaptiveAsync.Load() != 0,
+			"actor_state":         h.state != nil,
+			"counter_replication": h.crdt != nil,
+			"payload_analytics":   h.drift != nil,
+			"monitors":            h.probes != nil,
+			"log_level_tuning":    h.logLvl != nil,
+			"dedupe":              conf.DedupeWindowMs > 0,
+			"load_shedding":       conf.ShedExpensiveAt > 0,
+			"evaluation_options":  true,
+			"scenario_archive":    true,
+			"problem_json":        true,
+			"response_projection": true,
+			"scenario_ownership":  h.tokens != nil,
+		},
+	})
+}
@@ -1,50 +1,193 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
+	"github.com/gyaneshwarpardhi/ifttt/internal/actorstate"
+	"github.com/gyaneshwarpardhi/ifttt/internal/auth"
 	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/counter"
 	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/drift"
+	"github.com/gyaneshwarpardhi/ifttt/internal/duration"
 	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
 	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/inbox"
 	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+	"github.com/gyaneshwarpardhi/ifttt/internal/monitor"
+	"github.com/gyaneshwarpardhi/ifttt/internal/retention"
 )
 
-const maxBatchSize = 100
+const (
+	maxBatchSize       = 100
+	defaultRecentLimit = 100
+
+	// maxEventBodyBytes bounds an event body when max_payload_bytes is 0.
+	maxEventBodyBytes = 8 << 20
+	// eventEnvelopeBytes is the room an event body gets on top of
+	// max_payload_bytes for its other fields, meta and options.
+	eventEnvelopeBytes = 64 << 10
+)
 
 // Handler holds all HTTP handler dependencies.
 type Handler struct {
 	eng    *engine.Engine
 	loader *config.Loader
 	mux    *http.ServeMux
+	audit  *auditLog
+	sims   *simCache
+	inbox  *inbox.Inbox // nil = process /v1/events synchronously
+	jobs   *jobStore
+	tokens *auth.Tokens // nil = ingestion is unauthenticated
+	tails  *tailHub
+	probes *monitor.Runner     // nil = no synthetic monitors
+	crdt   *counter.GCounter   // nil unless engine.counter_strategy is crdt
+	logLvl *slog.LevelVar      // nil = log_level not tunable
+	state  *actorstate.Sources // nil = no actor state export/import
+	drift  *drift.Analyzer     // nil = payload analytics disabled
+	stores map[string]string   // subsystem → backend, for GET /v1/capabilities
+
+	// adaptiveAsync holds math.Float64bits of the queue utilization (0–1)
+	// above which POST /v1/events defers to async processing and answers 202
+	// with a job handle. 0 = off. Tunable through PATCH /v1/admin/engine.
+	adaptiveAsync atomic.Uint64
+
+	logSeq atomic.Uint64 // successful requests seen, for request_log_sample_every
+}
+
+// Option configures optional Handler features.
+type Option func(*Handler)
+
+// WithInbox makes POST /v1/events acknowledge once the event is persisted to
+// ib instead of waiting for processing (at-least-once ingestion).
+func WithInbox(ib *inbox.Inbox) Option {
+	return func(h *Handler) { h.inbox = ib }
+}
+
+// WithAdaptiveAsync makes POST /v1/events switch to async processing (202
+// with a job handle) when queue utilization reaches threshold or the sync
+// queue is full, instead of risking a timeout or 429.
+func WithAdaptiveAsync(threshold float64) Option {
+	return func(h *Handler) { h.adaptiveAsync.Store(math.Float64bits(threshold)) }
+}
+
+// WithMonitors serves the latest synthetic probe results at GET /v1/monitors.
+func WithMonitors(m *monitor.Runner) Option {
+	return func(h *Handler) { h.probes = m }
+}
+
+// WithDrift serves a's payload profiles and drift at
+// GET /v1/analytics/payloads.
+func WithDrift(a *drift.Analyzer) Option {
+	return func(h *Handler) { h.drift = a }
+}
+
+// WithCounters serves gc's state at GET /v1/counters/state for peer regions
+// to merge.
+func WithCounters(gc *counter.GCounter) Option {
+	return func(h *Handler) { h.crdt = gc }
+}
+
+// WithRetention registers the audit log and async job results with p, so
+// entries for scenarios with a retention period are purged.
+func WithRetention(p *retention.Purger) Option {
+	return func(h *Handler) {
+		p.Register("audit", func(_ context.Context, now time.Time, pol *retention.Policy) (int, error) {
+			return h.audit.purge(now, pol.AuditTTL), nil
+		})
+		p.Register("jobs", func(_ context.Context, now time.Time, pol *retention.Policy) (int, error) {
+			return h.jobs.purge(now, pol.ResultTTL), nil
+		})
+	}
 }
 
 // New creates an HTTP handler and registers all routes.
-func New(eng *engine.Engine, loader *config.Loader) http.Handler {
-	h := &Handler{eng: eng, loader: loader, mux: http.NewServeMux()}
+func New(eng *engine.Engine, loader *config.Loader, opts ...Option) http.Handler {
+	h := &Handler{eng: eng, loader: loader, mux: http.NewServeMux(), audit: &auditLog{}, sims: newSimCache(simCacheSize), jobs: newJobStore(jobStoreSize), tails: newTailHub()}
+	for _, opt := range opts {
+		opt(h)
+	}
 
-	h.mux.HandleFunc("POST /v1/events", h.ingestEvent)
-	h.mux.HandleFunc("POST /v1/events/batch", h.ingestBatch)
-	h.mux.HandleFunc("GET /v1/rules", h.listRules)
-	h.mux.HandleFunc("POST /v1/rules/reload", h.reloadRules)
+	h.mux.HandleFunc("POST /v1/events", h.authenticate(h.ingestEvent))
+	h.mux.HandleFunc("POST /v1/events/batch", h.authenticate(h.ingestBatch))
+	h.mux.HandleFunc("GET /v1/jobs/{id}", h.authenticate(h.getJob))
+	h.mux.HandleFunc("POST /v1/simulate", h.authenticate(h.simulate))
+	h.mux.HandleFunc("GET /v1/rules", h.authenticate(h.listRules))
+	h.mux.HandleFunc("GET /v1/rules/rendered", h.authenticate(h.renderedRules))
+	h.mux.HandleFunc("GET /v1/rules/search", h.authenticate(h.searchRules))
+	h.mux.HandleFunc("POST /v1/rules/reload", h.authorizeAdmin(h.reloadRules))
+	h.mux.HandleFunc("PATCH /v1/rules/scenarios/{id}/enabled", h.authorizeScenario(h.toggleScenario))
+	h.mux.HandleFunc("POST /v1/rules/scenarios/{id}/archive", h.authorizeScenario(h.archiveScenario))
+	h.mux.HandleFunc("POST /v1/rules/scenarios/{id}/restore", h.authorizeScenario(h.restoreScenario))
+	h.mux.HandleFunc("GET /v1/rules/archive", h.authenticate(h.listArchive))
+	h.mux.HandleFunc("GET /v1/rules/audit", h.authenticate(h.listAudit))
+	h.mux.HandleFunc("GET /v1/quarantine", h.authenticate(h.listQuarantine))
+	h.mux.HandleFunc("GET /v1/results/recent", h.authenticate(h.recentResults))
+	h.mux.HandleFunc("GET /v1/tail", h.authorizeAdmin(h.tail))
+	h.mux.HandleFunc("GET /v1/monitors", h.authenticate(h.listMonitors))
+	h.mux.HandleFunc("GET /v1/analytics/payloads", h.authenticate(h.payloadAnalytics))
+	h.mux.HandleFunc("GET /v1/admin/engine", h.authorizeAdmin(h.getEngineSettings))
+	h.mux.HandleFunc("PATCH /v1/admin/engine", h.authorizeAdmin(h.tuneEngine))
+	h.mux.HandleFunc("GET /v1/admin/actors/state", h.authorizeAdmin(h.exportActorState))
+	h.mux.HandleFunc("POST /v1/admin/actors/state", h.authorizeAdmin(h.importActorState))
+	h.mux.HandleFunc("GET /v1/counters/state", h.authorizePeer(h.counterState))
+	h.mux.HandleFunc("DELETE /v1/actors/{actor_id}/cache", h.authorizeAdmin(h.invalidateActor))
+	h.mux.HandleFunc("DELETE /v1/tenants/{tenant_id}/cache", h.authorizeAdmin(h.invalidateTenant))
+	h.mux.HandleFunc("GET /v1/capabilities", h.authenticate(h.capabilities))
 	h.mux.HandleFunc("GET /healthz", h.healthz)
 	h.mux.HandleFunc("GET /readyz", h.readyz)
 	h.mux.Handle("GET /metrics", promhttp.Handler())
 
-	return loggingMiddleware(h.mux)
+	return h.logRequests(routeErrors(h.mux))
 }
 
-// POST /v1/events — synchronous single-event ingestion.
+// POST /v1/events — synchronous single-event ingestion. The body is an
+// event, or an envelope carrying the event and per-request options (see
+// decodeEventBody). Events with evaluation options are always answered
+// synchronously: they bypass the inbox and adaptive async mode.
 func (h *Handler) ingestEvent(w http.ResponseWriter, r *http.Request) {
-	var ev event.Event
-	if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err))
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.eventBodyLimit()))
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	ev, opts, err := decodeEventBody(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	mode, err := responseMode(r, opts)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	format := streamFormat(r)
+	if opts != nil && opts.Stream != "" {
+		format = opts.Stream
+	}
+	if format != "" && projectionRequested(r, opts) {
+		writeError(w, http.StatusBadRequest, "a stream cannot be combined with response minimal or headers")
+		return
+	}
+	evalOpts := opts.evalOptions()
+	if err := h.eng.CheckOptions(evalOpts); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
 		return
 	}
 	if ev.ID == "" {
@@ -54,63 +197,427 @@ func (h *Handler) ingestEvent(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, "event type is required")
 		return
 	}
+	if reason := checkScope(r, ev); reason != "" {
+		writeError(w, http.StatusForbidden, reason)
+		return
+	}
 	ev.ReceivedAt = time.Now()
+	// Oversized events are answered 413 whatever the response mode, before
+	// they reach the inbox, a stream or the async queue.
+	if res := h.eng.Quarantine(ev); res != nil {
+		writeResult(w, r, http.StatusRequestEntityTooLarge, res, mode)
+		return
+	}
+
+	if h.inbox != nil && evalOpts == nil {
+		if err := h.inbox.Put(r.Context(), ev); err != nil {
+			if errors.Is(err, inbox.ErrDuplicate) {
+				writeDuplicate(w, ev)
+				return
+			}
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusAccepted, map[string]interface{}{
+			"event_id": ev.ID,
+			"status":   "accepted",
+		})
+		return
+	}
+
+	if format != "" {
+		h.streamEvent(w, r, ev, evalOpts, format)
+		return
+	}
+
+	threshold := math.Float64frombits(h.adaptiveAsync.Load())
+	if evalOpts != nil {
+		threshold = 0
+	}
+	if threshold > 0 && h.eng.QueueUtilization() >= threshold {
+		h.deferEvent(w, r, ev)
+		return
+	}
 
-	res, err := h.eng.ProcessSync(r.Context(), &ev)
+	res, err := h.eng.ProcessOptions(r.Context(), ev, evalOpts, nil)
 	if err != nil {
-		writeError(w, http.StatusTooManyRequests, err.Error())
+		overflow := threshold > 0 || (evalOpts == nil && h.eng.Settings().OverflowPolicy == config.OverflowDefer)
+		if overflow && errors.Is(err, engine.ErrQueueFull) {
+			h.deferEvent(w, r, ev)
+			return
+		}
+		writeEngineError(w, err)
 		return
 	}
-	metrics.EventProcessingDuration.Observe(float64(res.DurationMs))
-	writeJSON(w, http.StatusOK, res)
+	if res.Quarantined {
+		writeResult(w, r, http.StatusRequestEntityTooLarge, res, mode)
+		return
+	}
+	writeResult(w, r, http.StatusOK, res, mode)
+}
+
+// eventBodyLimit is the largest event body read: max_payload_bytes plus
+// eventEnvelopeBytes, so an oversized payload is cut off while reading
+// rather than decoded first.
+func (h *Handler) eventBodyLimit() int64 {
+	if n := h.eng.Settings().MaxPayloadBytes; n > 0 {
+		return int64(n) + eventEnvelopeBytes
+	}
+	return maxEventBodyBytes
+}
+
+// writeBodyError answers a failed body read: 413 past the size limit, 400
+// otherwise.
+func writeBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
+		return
+	}
+	writeError(w, http.StatusBadRequest, err.Error())
+}
+
+// deferEvent queues ev for async processing and answers 202 with a job
+// handle the client can poll. It still answers 429 if the async queue is full.
+func (h *Handler) deferEvent(w http.ResponseWriter, r *http.Request, ev *event.Event) {
+	j := &job{ID: uuid.New().String(), EventID: ev.ID, Status: "queued", EnqueuedAt: time.Now()}
+	h.jobs.add(j)
+	if err := h.eng.Enqueue(r.Context(), ev, func(res *engine.EventResult) { h.jobs.finish(j.ID, res) }); err != nil {
+		h.jobs.remove(j.ID)
+		if errors.Is(err, engine.ErrDuplicate) {
+			writeDuplicate(w, ev)
+			return
+		}
+		writeEngineError(w, err)
+		return
+	}
+	metrics.EventsDeferred.Inc()
+	w.Header().Set("Location", "/v1/jobs/"+j.ID)
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{
+		"job_id":   j.ID,
+		"event_id": ev.ID,
+		"status":   j.Status,
+	})
+}
+
+// writeDuplicate answers an event that was not queued because its ID is
+// already pending or was processed within the dedupe window.
+func writeDuplicate(w http.ResponseWriter, ev *event.Event) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"event_id":  ev.ID,
+		"status":    "duplicate",
+		"duplicate": true,
+	})
+}
+
+// GET /v1/jobs/{id} — status and result of an event deferred by adaptive async mode.
+func (h *Handler) getJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := h.jobs.get(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "job not found (unknown or evicted)")
+		return
+	}
+	writeJSON(w, http.StatusOK, j)
+}
+
+// batchItem reports the outcome for one element of a batch.
+type batchItem struct {
+	Index   int    `json:"index"`
+	EventID string `json:"event_id,omitempty"`
+	Status  string `json:"status"` // queued | duplicate | rejected
+	Reason  string `json:"reason,omitempty"`
 }
 
 // POST /v1/events/batch — async batch ingestion (up to 100 events).
+// Each event is validated on its own; the response reports every index so
+// clients can resend only the rejected ones. An ID repeated within the batch,
+// or already accepted within engine.dedupe_window_ms on any path, is reported
+// as duplicate and not processed again.
 func (h *Handler) ingestBatch(w http.ResponseWriter, r *http.Request) {
-	var events []*event.Event
-	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+	var raw []json.RawMessage
+	body := http.MaxBytesReader(w, r.Body, maxBatchSize*h.eventBodyLimit())
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyError(w, err)
+			return
+		}
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err))
 		return
 	}
-	if len(events) == 0 {
+	if len(raw) == 0 {
 		writeError(w, http.StatusBadRequest, "batch must contain at least one event")
 		return
 	}
-	if len(events) > maxBatchSize {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf("batch size %d exceeds max %d", len(events), maxBatchSize))
+	if len(raw) > maxBatchSize {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("batch size %d exceeds max %d", len(raw), maxBatchSize))
 		return
 	}
 
 	now := time.Now()
 	jobID := uuid.New().String()
-	queued := 0
-	for _, ev := range events {
-		if ev.ID == "" {
-			ev.ID = uuid.New().String()
+	items := make([]batchItem, len(raw))
+	seen := make(map[string]int, len(raw))
+	queued, duplicates, invalid := 0, 0, 0
+	for i, msg := range raw {
+		item := &items[i]
+		item.Index = i
+		ev, reason := h.validateBatchEvent(r, msg)
+		if ev != nil {
+			item.EventID = ev.ID
 		}
+		if reason != "" {
+			item.Status, item.Reason = "rejected", reason
+			invalid++
+			continue
+		}
+		if first, dup := seen[ev.ID]; dup {
+			item.Status, item.Reason = "duplicate", fmt.Sprintf("same event id as index %d", first)
+			duplicates++
+			continue
+		}
+		seen[ev.ID] = i
 		ev.ReceivedAt = now
-		if h.eng.ProcessAsync(ev) {
+		switch err := h.eng.Enqueue(r.Context(), ev, nil); {
+		case errors.Is(err, engine.ErrDuplicate):
+			item.Status, item.Reason = "duplicate", "event id already accepted within the dedupe window"
+			duplicates++
+		case err != nil:
+			item.Status, item.Reason = "rejected", err.Error()
+		default:
+			item.Status = "queued"
 			queued++
 		}
 	}
 
-	writeJSON(w, http.StatusAccepted, map[string]interface{}{
-		"job_id":   jobID,
-		"total":    len(events),
-		"queued":   queued,
-		"rejected": len(events) - queued,
+	status := http.StatusAccepted
+	if invalid == len(raw) {
+		status = http.StatusUnprocessableEntity
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"job_id":     jobID,
+		"total":      len(raw),
+		"queued":     queued,
+		"duplicates": duplicates,
+		"rejected":   len(raw) - queued - duplicates,
+		"results":    items,
 	})
 }
 
-// GET /v1/rules — list loaded scenarios.
+// validateBatchEvent decodes and checks one batch element. It returns the
+// event (when it decoded) and a rejection reason, empty if the event is valid.
+func (h *Handler) validateBatchEvent(r *http.Request, msg json.RawMessage) (*event.Event, string) {
+	var ev event.Event
+	if reason := decodeBatchEvent(msg, &ev); reason != "" {
+		return nil, reason
+	}
+	if ev.Type == "" {
+		return &ev, "event type is required"
+	}
+	if reason := checkScope(r, &ev); reason != "" {
+		return &ev, reason
+	}
+	if err := h.eng.CheckSize(&ev); err != nil {
+		return &ev, err.Error()
+	}
+	if ev.ID == "" {
+		ev.ID = uuid.New().String()
+	}
+	return &ev, ""
+}
+
+// decodeBatchEvent checks a batch element against the event schema while
+// decoding it: an object with only event fields, each of its type. A field
+// the schema does not know, such as a misspelt "actorId", would otherwise
+// be dropped silently. It returns the rejection reason, empty if it decoded.
+func decodeBatchEvent(msg json.RawMessage, ev *event.Event) string {
+	dec := json.NewDecoder(bytes.NewReader(msg))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(ev)
+	if err == nil && dec.More() {
+		err = errors.New("trailing data after the event")
+	}
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &typeErr) && typeErr.Field == "":
+		return "event must be a JSON object"
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("schema: %s must be %s", typeErr.Field, jsonKind(typeErr.Type.Kind()))
+	case errors.As(err, &timeErr):
+		return "schema: occurred_at must be an RFC 3339 timestamp"
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "schema: " + strings.TrimPrefix(err.Error(), "json: ")
+	}
+	return fmt.Sprintf("invalid event: %s", err)
+}
+
+// jsonKind names the JSON type that decodes into a Go value of kind k.
+func jsonKind(k reflect.Kind) string {
+	switch k {
+	case reflect.String:
+		return "a string"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Bool:
+		return "a boolean"
+	}
+	return "a number"
+}
+
+// POST /v1/simulate — evaluate an event without executing actions.
+// Results are cached per (graph hash, event) because rule-builder UIs resend
+// the same simulation repeatedly while users tweak payload fields.
+func (h *Handler) simulate(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, h.eventBodyLimit()))
+	if err != nil {
+		writeBodyError(w, err)
+		return
+	}
+	var ev event.Event
+	if err := json.Unmarshal(body, &ev); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err))
+		return
+	}
+	if ev.Type == "" {
+		writeError(w, http.StatusBadRequest, "event type is required")
+		return
+	}
+	// A simulated event is held to the same guards as an ingested one, but
+	// not quarantined.
+	if err := h.eng.CheckSize(&ev); err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+	// The key covers only the event, so results that depend on actor or
+	// tenant data, the clock, or other state, are never cached.
+	g := h.eng.Graph()
+	cacheable := !g.ReadsState()
+	key := simKey(g.Hash(), &ev)
+	if res, ok := h.sims.get(key); ok && cacheable {
+		w.Header().Set("X-Cache", "HIT")
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	if ev.ID == "" {
+		ev.ID = uuid.New().String()
+	}
+	ev.ReceivedAt = time.Now()
+	res := h.eng.Simulate(r.Context(), &ev)
+	if !cacheable {
+		w.Header().Set("X-Cache", "BYPASS")
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	if res.GraphHash == g.Hash() {
+		h.sims.put(key, res)
+	}
+	w.Header().Set("X-Cache", "MISS")
+	writeJSON(w, http.StatusOK, res)
+}
+
+// GET /v1/rules — list scenarios of the active graph.
+// The listing, version and ETag all come from the same graph snapshot, so a
+// concurrent reload can never mix revisions. Honours If-None-Match.
 func (h *Handler) listRules(w http.ResponseWriter, r *http.Request) {
-	cfg := h.loader.Config()
+	g := h.eng.Graph()
+	cfg := g.Config()
+	if cfg == nil {
+		cfg = h.loader.Config()
+	}
+	if notModified(w, r, g.Hash(), cfg.Version) {
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"version":   cfg.Version,
+		"hash":      g.Hash(),
 		"scenarios": cfg.Scenarios,
 	})
 }
 
+// GET /v1/rules/search?q=… — scenario descriptions, condition expressions,
+// action params and workflow texts of the active rules containing every term
+// of q, e.g. to find each rule reading a payload field before removing it.
+func (h *Handler) searchRules(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+	if strings.TrimSpace(q) == "" {
+		writeError(w, http.StatusBadRequest, "q is required")
+		return
+	}
+	g := h.eng.Graph()
+	cfg := g.Config()
+	if cfg == nil {
+		cfg = h.loader.Config()
+	}
+	hits := config.Search(cfg, q)
+	if hits == nil {
+		hits = []config.SearchHit{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"query": q,
+		"hash":  g.Hash(),
+		"count": len(hits),
+		"hits":  hits,
+	})
+}
+
+// GET /v1/rules/rendered — the active config as YAML, with overlays applied
+// and anchors, aliases and merge keys expanded.
+func (h *Handler) renderedRules(w http.ResponseWriter, r *http.Request) {
+	g := h.eng.Graph()
+	cfg := g.Config()
+	if cfg == nil {
+		cfg = h.loader.Config()
+	}
+	if notModified(w, r, g.Hash(), cfg.Version) {
+		return
+	}
+	out, err := config.Render(cfg)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(out)
+}
+
+// notModified sets the revision headers of a rules response and answers 304
+// when If-None-Match names the revision. Without a hash (the config could not
+// be hashed) there is no ETag, so the full response is always sent.
+func notModified(w http.ResponseWriter, r *http.Request, hash, version string) bool {
+	w.Header().Set("X-Rules-Version", version)
+	if hash == "" {
+		return false
+	}
+	etag := `"` + hash + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("X-Rules-Hash", hash)
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+// etagMatch reports whether an If-None-Match header value matches etag.
+func etagMatch(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // POST /v1/rules/reload — hot-reload rules from disk.
 func (h *Handler) reloadRules(w http.ResponseWriter, r *http.Request) {
 	cfg, err := h.loader.Reload()
@@ -119,7 +626,7 @@ func (h *Handler) reloadRules(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Rebuild and swap the DAG.
-	g, err := dag.Build(cfg)
+	g, err := h.eng.BuildGraph(r.Context(), cfg)
 	if err != nil {
 		writeError(w, http.StatusUnprocessableEntity, err.Error())
 		return
@@ -127,10 +634,151 @@ func (h *Handler) reloadRules(w http.ResponseWriter, r *http.Request) {
 	h.eng.SwapGraph(g)
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"reloaded":        true,
+		"version":         g.Version(),
+		"hash":            g.Hash(),
 		"scenarios_count": len(cfg.Scenarios),
 	})
 }
 
+// toggleRequest is the body of PATCH /v1/rules/scenarios/{id}/enabled.
+type toggleRequest struct {
+	Enabled *bool  `json:"enabled"`
+	Persist bool   `json:"persist"`
+	Reason  string `json:"reason"`
+}
+
+// PATCH /v1/rules/scenarios/{id}/enabled — switch one scenario on/off in the live graph.
+func (h *Handler) toggleScenario(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req toggleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err))
+		return
+	}
+	if req.Enabled == nil {
+		writeError(w, http.StatusBadRequest, "enabled is required")
+		return
+	}
+	var g *dag.Graph
+	_, err := h.loader.SetScenarioEnabled(id, *req.Enabled, req.Persist, func(cfg *config.RuleConfig) (err error) {
+		g, err = h.eng.BuildGraph(r.Context(), cfg)
+		return err
+	})
+	if err != nil {
+		writeError(w, scenarioChangeStatus(err, req.Persist), err.Error())
+		return
+	}
+	h.eng.SwapGraph(g)
+
+	h.audit.record(r.Context(), auditEntry{
+		Time:       time.Now(),
+		Action:     "scenario.toggle",
+		ScenarioID: id,
+		Enabled:    *req.Enabled,
+		Persisted:  req.Persist,
+		Actor:      h.auditActor(r),
+		Token:      h.tokenName(r),
+		Reason:     req.Reason,
+	})
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"scenario_id": id,
+		"enabled":     *req.Enabled,
+		"persisted":   req.Persist,
+		"hash":        g.Hash(),
+	})
+}
+
+// GET /v1/rules/audit — recent runtime rule changes, newest first.
+func (h *Handler) listAudit(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"entries": h.audit.list()})
+}
+
+// GET /v1/quarantine — recent events rejected by payload size guards.
+func (h *Handler) listQuarantine(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"events": h.eng.Quarantined()})
+}
+
+// GET /v1/counters/state — this region's action-limit counters, pulled by
+// peer regions when engine.counter_strategy is crdt.
+func (h *Handler) counterState(w http.ResponseWriter, r *http.Request) {
+	if h.crdt == nil {
+		writeError(w, http.StatusNotFound, "counter replication is not enabled (engine.counter_strategy: crdt)")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.crdt.State())
+}
+
+// GET /v1/monitors — the latest result of each synthetic monitor probe.
+func (h *Handler) listMonitors(w http.ResponseWriter, r *http.Request) {
+	results := []monitor.Result{}
+	if h.probes != nil {
+		results = h.probes.Results()
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"probes": results})
+}
+
+// GET /v1/analytics/payloads — per event type payload field presence, value
+// types and sizes from the last drift window, and recent drift. Optional
+// filter: event_type.
+func (h *Handler) payloadAnalytics(w http.ResponseWriter, r *http.Request) {
+	if h.drift == nil {
+		writeError(w, http.StatusNotFound, "payload analytics are disabled (drift.enabled)")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.drift.Report(r.URL.Query().Get("event_type")))
+}
+
+// GET /v1/results/recent — recently processed events and their results,
+// newest first. Filters: actor_id, scenario_id, type, status
+// (matched|unmatched|failed), since (duration like 10m, or RFC 3339), limit.
+func (h *Handler) recentResults(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	f := engine.RecentFilter{
+		ActorID:    q.Get("actor_id"),
+		ScenarioID: q.Get("scenario_id"),
+		Type:       q.Get("type"),
+		Status:     q.Get("status"),
+		Limit:      defaultRecentLimit,
+	}
+	switch f.Status {
+	case "", "matched", "unmatched", "failed":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("status must be matched, unmatched or failed, got %q", f.Status))
+		return
+	}
+	if s := q.Get("since"); s != "" {
+		if d, err := duration.Parse(s); err == nil {
+			f.Since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, s); err == nil {
+			f.Since = t
+		} else {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("since must be a duration or RFC 3339 time, got %q", s))
+			return
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("limit must be a positive integer, got %q", s))
+			return
+		}
+		f.Limit = n
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"results": h.eng.Recent(f)})
+}
+
+// DELETE /v1/actors/{actor_id}/cache — drop cached actor data after a profile update.
+func (h *Handler) invalidateActor(w http.ResponseWriter, r *http.Request) {
+	h.eng.InvalidateActor(r.PathValue("actor_id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /v1/tenants/{tenant_id}/cache — drop cached tenant data after a plan change.
+func (h *Handler) invalidateTenant(w http.ResponseWriter, r *http.Request) {
+	h.eng.InvalidateTenant(r.PathValue("tenant_id"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // GET /healthz — always 200 (liveness probe).
 func (h *Handler) healthz(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
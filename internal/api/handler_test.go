@@ -0,0 +1,1040 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/action/points"
+	"github.com/gyaneshwarpardhi/ifttt/internal/auth"
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/counter"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/schedule"
+)
+
+const testRules = `version: v1
+engine:
+  event_workers: 2
+  action_workers: 2
+  queue_depth: 16
+  event_timeout_ms: 1000
+scenarios:
+  - id: sc_login
+    enabled: true
+    owner: growth
+    event_types: [login]
+    children:
+      - action:
+          id: act_welcome
+          type: reward_points
+          params: {operation: award, points: 50}
+`
+
+// Secrets of the tokens testTokens issues.
+const (
+	adminSecret   = "ff_admin"
+	growthSecret  = "ff_growth"
+	billingSecret = "ff_billing"
+	peerSecret    = "ff_peer"
+)
+
+// testTokens returns an admin token, a token of team growth (which owns
+// sc_login), an ingestion token scoped to billing transactions and a peer
+// region's token.
+func testTokens(t *testing.T) *auth.Tokens {
+	t.Helper()
+	body := "tokens:\n" +
+		"  - {name: oncall, sha256: " + auth.Hash(adminSecret) + ", role: admin}\n" +
+		"  - {name: growth-team, sha256: " + auth.Hash(growthSecret) + ", teams: [growth]}\n" +
+		"  - {name: billing, sha256: " + auth.Hash(billingSecret) + ", sources: [billing-service], event_types: [transaction]}\n" +
+		"  - {name: region-us, sha256: " + auth.Hash(peerSecret) + ", role: peer}\n"
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := auth.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+// newTestHandler serves testRules (or rules, if given) through a Handler
+// built with opts.
+func newTestHandler(t *testing.T, rules string, opts ...Option) (http.Handler, *engine.Engine) {
+	t.Helper()
+	if rules == "" {
+		rules = testRules
+	}
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loader, err := config.NewLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newLoaderHandler(t, loader, opts...)
+}
+
+// newLoaderHandler is newTestHandler for a loader the test set up itself.
+func newLoaderHandler(t *testing.T, loader *config.Loader, opts ...Option) (http.Handler, *engine.Engine) {
+	t.Helper()
+	cfg := loader.Config()
+	reg := action.NewRegistry()
+	reg.Register(points.New())
+	if err := reg.PrepareParams(cfg); err != nil {
+		t.Fatal(err)
+	}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	eng := engine.New(ctx, g, reg, cfg.Engine)
+	t.Cleanup(func() {
+		cancel()
+		eng.Shutdown()
+	})
+	return New(eng, loader, opts...), eng
+}
+
+// do serves a request with an optional bearer secret and returns the recorder.
+func do(h http.Handler, method, target, secret string, body io.Reader) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, body)
+	if secret != "" {
+		r.Header.Set("Authorization", "Bearer "+secret)
+	}
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAdminRoutes_RequireAdminToken(t *testing.T) {
+	h, _ := newTestHandler(t, "", WithTokens(testTokens(t)))
+	for _, tc := range []struct {
+		method, target, body string
+		admin                int // status for an admin token
+	}{
+		// Export and import are not enabled here, so an admin gets past
+		// authorization to the route's own 404.
+		{"GET", "/v1/admin/actors/state", "", http.StatusNotFound},
+		{"POST", "/v1/admin/actors/state", "{}", http.StatusNotFound},
+		{"GET", "/v1/admin/engine", "", http.StatusOK},
+		{"PATCH", "/v1/admin/engine", "{}", http.StatusOK},
+		{"GET", "/v1/tail", "", http.StatusBadRequest}, // no filter
+		{"POST", "/v1/rules/reload", "", http.StatusOK},
+	} {
+		for secret, want := range map[string]int{
+			"":           http.StatusUnauthorized,
+			growthSecret: http.StatusForbidden,
+			adminSecret:  tc.admin,
+		} {
+			var body io.Reader
+			if tc.body != "" {
+				body = strings.NewReader(tc.body)
+			}
+			if w := do(h, tc.method, tc.target, secret, body); w.Code != want {
+				t.Errorf("%s %s with %q: status %d, want %d", tc.method, tc.target, secret, w.Code, want)
+			}
+		}
+	}
+}
+
+func TestTuneEngine(t *testing.T) {
+	h, eng := newTestHandler(t, "")
+	w := do(h, "PATCH", "/v1/admin/engine", "", strings.NewReader(`{"ingest_rate_limit": 0.001, "ingest_burst": 1, "overflow_policy": "defer", "reason": "test"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH: status %d: %s", w.Code, w.Body)
+	}
+	if c := eng.Settings(); c.IngestRateLimit != 0.001 || c.IngestBurst != 1 || c.OverflowPolicy != config.OverflowDefer {
+		t.Errorf("settings after PATCH: %+v", c)
+	}
+
+	// The burst admits one event; the next is over the limit.
+	login := `{"type": "login", "actor_id": "u1"}`
+	if w := do(h, "POST", "/v1/events", "", strings.NewReader(login)); w.Code != http.StatusOK {
+		t.Fatalf("first event: status %d: %s", w.Code, w.Body)
+	}
+	w = do(h, "POST", "/v1/events", "", strings.NewReader(login))
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), "rate limit") {
+		t.Errorf("second event: status %d: %s", w.Code, w.Body)
+	}
+
+	for _, body := range []string{
+		`{"overflow_policy": "drop"}`,
+		`{"ingest_rate_limit": -1}`,
+		`{"request_log_sample_every": -2}`,
+	} {
+		if w := do(h, "PATCH", "/v1/admin/engine", "", strings.NewReader(body)); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("PATCH %s: status %d, want 422", body, w.Code)
+		}
+	}
+	if c := eng.Settings(); c.OverflowPolicy != config.OverflowDefer {
+		t.Errorf("rejected PATCH changed overflow_policy to %q", c.OverflowPolicy)
+	}
+}
+
+func TestIngestEvent_Options(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+	for name, tc := range map[string]struct {
+		body string
+		want int
+	}{
+		"forced dry run":         {`{"event": {"type": "purchase", "actor_id": "u1"}, "options": {"force_scenario": "sc_login", "dry_run": true}}`, http.StatusOK},
+		"forced real run":        {`{"event": {"type": "purchase", "actor_id": "u1"}, "options": {"force_scenario": "sc_login"}}`, http.StatusUnprocessableEntity},
+		"unknown skipped action": {`{"event": {"type": "login", "actor_id": "u1"}, "options": {"skip_actions": ["act_nope"]}}`, http.StatusUnprocessableEntity},
+	} {
+		if w := do(h, "POST", "/v1/events", "", strings.NewReader(tc.body)); w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d: %s", name, w.Code, tc.want, w.Body)
+		}
+	}
+}
+
+func TestIngest_RejectsOversizedEvents(t *testing.T) {
+	rules := strings.Replace(testRules, "  event_timeout_ms: 1000\n", "  event_timeout_ms: 1000\n  max_payload_bytes: 256\n", 1)
+	h, eng := newTestHandler(t, rules)
+
+	// Over max_payload_bytes: quarantined and answered 413 in every mode.
+	event := `{"type": "login", "actor_id": "u1", "payload": {"blob": "` + strings.Repeat("x", 512) + `"}}`
+	for _, target := range []string{
+		"/v1/events",
+		"/v1/events?response=minimal",
+		"/v1/events?response=headers",
+		"/v1/events?stream=ndjson",
+		"/v1/events?stream=sse",
+	} {
+		if w := do(h, "POST", target, "", strings.NewReader(event)); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("POST %s: status %d, want 413: %s", target, w.Code, w.Body)
+		}
+	}
+	if n := len(eng.Quarantined()); n != 5 {
+		t.Errorf("%d events quarantined, want 5", n)
+	}
+
+	// Simulations are held to the guards without being quarantined.
+	if w := do(h, "POST", "/v1/simulate", "", strings.NewReader(event)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("POST /v1/simulate: status %d, want 413: %s", w.Code, w.Body)
+	}
+	if n := len(eng.Quarantined()); n != 5 {
+		t.Errorf("%d events quarantined after a simulation, want 5", n)
+	}
+
+	// Past the body limit: cut off while reading.
+	huge := `{"type": "login", "payload": {"blob": "` + strings.Repeat("x", 256+eventEnvelopeBytes) + `"}}`
+	for _, target := range []string{"/v1/events", "/v1/simulate"} {
+		if w := do(h, "POST", target, "", strings.NewReader(huge)); w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("oversized body to %s: status %d, want 413", target, w.Code)
+		}
+	}
+	batch := "[" + strings.Repeat(huge+",", maxBatchSize) + huge + "]"
+	if w := do(h, "POST", "/v1/events/batch", "", strings.NewReader(batch)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized batch: status %d, want 413", w.Code)
+	}
+}
+
+func TestTail_RedactsPayloadByDefault(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for query, want := range map[string]string{"": redactedValue, "&payload=full": "pro"} {
+		resp, err := http.Get(srv.URL + "/v1/tail?stream=ndjson&duration=5s&actor_id=u1" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := bufio.NewScanner(resp.Body)
+		if !lines.Scan() || !strings.Contains(lines.Text(), `"start"`) {
+			t.Fatalf("first record %q, want start", lines.Text())
+		}
+		event := `{"type": "login", "actor_id": "u1", "payload": {"plan": "pro"}}`
+		if w := do(h, "POST", "/v1/events", "", strings.NewReader(event)); w.Code != http.StatusOK {
+			t.Fatalf("event: status %d: %s", w.Code, w.Body)
+		}
+		if !lines.Scan() {
+			t.Fatalf("tail%s ended without a decision: %v", query, lines.Err())
+		}
+		var rec struct {
+			Type string     `json:"type"`
+			Data tailRecord `json:"data"`
+		}
+		if err := json.Unmarshal(lines.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Type != "decision" || rec.Data.Event.Payload["plan"] != want {
+			t.Errorf("tail%s: %s, want payload.plan %q", query, lines.Text(), want)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestCounterState_RequiresPeerToken(t *testing.T) {
+	h, _ := newTestHandler(t, "", WithTokens(testTokens(t)), WithCounters(counter.NewGCounter("eu")))
+	for secret, want := range map[string]int{
+		"":           http.StatusUnauthorized,
+		growthSecret: http.StatusForbidden,
+		peerSecret:   http.StatusOK,
+		adminSecret:  http.StatusOK,
+	} {
+		if w := do(h, "GET", "/v1/counters/state", secret, nil); w.Code != want {
+			t.Errorf("with %q: status %d, want %d", secret, w.Code, want)
+		}
+	}
+}
+
+func TestScenarioRoutes_RequireOwningTeam(t *testing.T) {
+	h, _ := newTestHandler(t, "", WithTokens(testTokens(t)))
+	for _, target := range []string{
+		"/v1/rules/scenarios/sc_login/archive",
+		"/v1/rules/scenarios/sc_login/restore",
+	} {
+		// The archive or restore itself succeeds or conflicts; only
+		// authorization is checked here.
+		for secret, want := range map[string]int{
+			"":            http.StatusUnauthorized,
+			billingSecret: http.StatusForbidden,
+			peerSecret:    http.StatusForbidden,
+		} {
+			if w := do(h, "POST", target, secret, strings.NewReader(`{"reason": "test"}`)); w.Code != want {
+				t.Errorf("POST %s with %q: status %d, want %d", target, secret, w.Code, want)
+			}
+		}
+	}
+	for secret, enabled := range map[string]string{growthSecret: "false", adminSecret: "true"} {
+		if w := do(h, "PATCH", "/v1/rules/scenarios/sc_login/enabled", secret, strings.NewReader(`{"enabled": `+enabled+`}`)); w.Code != http.StatusOK {
+			t.Errorf("toggle with %q: status %d, want 200: %s", secret, w.Code, w.Body)
+		}
+	}
+}
+
+func TestToggleScenario(t *testing.T) {
+	h, eng := newTestHandler(t, "", WithTokens(testTokens(t)))
+	swaps := 0
+	eng.OnGraphSwapped(func(_, _ *dag.Graph) { swaps++ })
+
+	r := httptest.NewRequest("PATCH", "/v1/rules/scenarios/sc_login/enabled", strings.NewReader(`{"enabled": false, "reason": "test"}`))
+	r.Header.Set("Authorization", "Bearer "+growthSecret)
+	r.Header.Set("X-Actor", "someone-else")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("toggle: status %d: %s", w.Code, w.Body)
+	}
+	if swaps != 1 {
+		t.Errorf("graph swapped %d times, want 1", swaps)
+	}
+	res, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+	if err != nil || len(res.ScenariosMatched) != 0 {
+		t.Errorf("disabled scenario still matched: %+v, %v", res, err)
+	}
+
+	// Persisting needs an overrides file; without one nothing changes.
+	w = do(h, "PATCH", "/v1/rules/scenarios/sc_login/enabled", growthSecret, strings.NewReader(`{"enabled": true, "persist": true}`))
+	if w.Code != http.StatusConflict {
+		t.Errorf("persisted toggle without overrides file: status %d, want 409", w.Code)
+	}
+
+	var audit struct {
+		Entries []auditEntry `json:"entries"`
+	}
+	w = do(h, "GET", "/v1/rules/audit", growthSecret, nil)
+	if err := json.Unmarshal(w.Body.Bytes(), &audit); err != nil {
+		t.Fatal(err)
+	}
+	if len(audit.Entries) != 1 || audit.Entries[0].Actor != "growth-team" {
+		t.Errorf("audit = %+v, want one entry by the token, not X-Actor", audit.Entries)
+	}
+}
+
+// A toggle whose graph does not build is rejected before it reaches the
+// loader's config or the overrides file, so hot-reloads and restarts keep
+// working.
+func TestToggleScenario_RejectsBrokenGraph(t *testing.T) {
+	dir := t.TempDir()
+	rules := testRules + `  - id: sc_broken
+    enabled: false
+    owner: growth
+    event_types: [login]
+    children:
+      - condition:
+          id: cond_big
+          expression: payload.amount > 100
+          tests:
+            - {payload: {amount: 5}, expect: true}
+`
+	path := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loader, err := config.NewLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides := filepath.Join(dir, "overrides.yaml")
+	if err := loader.SetOverridesPath(overrides); err != nil {
+		t.Fatal(err)
+	}
+	h, _ := newLoaderHandler(t, loader, WithTokens(testTokens(t)))
+	before := loader.Config()
+
+	w := do(h, "PATCH", "/v1/rules/scenarios/sc_broken/enabled", growthSecret, strings.NewReader(`{"enabled": true, "persist": true}`))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("toggle: status %d, want 422: %s", w.Code, w.Body)
+	}
+	if cfg := loader.Config(); cfg != before || cfg.Scenarios[1].Enabled {
+		t.Errorf("loader config changed by a rejected toggle")
+	}
+	if _, err := os.Stat(overrides); !os.IsNotExist(err) {
+		t.Errorf("overrides file written by a rejected toggle: %v", err)
+	}
+}
+
+// Restoring a scenario that no longer builds is rejected and leaves it
+// archived, in the loader and in the overrides file.
+func TestRestoreScenario_RejectsBrokenGraph(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(path, []byte(testRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	loader, err := config.NewLoader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides := filepath.Join(dir, "overrides.yaml")
+	if err := loader.SetOverridesPath(overrides); err != nil {
+		t.Fatal(err)
+	}
+	h, _ := newLoaderHandler(t, loader, WithTokens(testTokens(t)))
+	if w := do(h, "POST", "/v1/rules/scenarios/sc_login/archive", growthSecret, strings.NewReader(`{"persist": true}`)); w.Code != http.StatusOK {
+		t.Fatalf("archive: status %d: %s", w.Code, w.Body)
+	}
+	saved, err := os.ReadFile(overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The archived scenario stops building: its condition's inline test fails.
+	broken := strings.Replace(testRules, `      - action:
+          id: act_welcome`, `      - condition:
+          id: cond_big
+          expression: payload.amount > 100
+          tests:
+            - {payload: {amount: 5}, expect: true}
+      - action:
+          id: act_welcome`, 1)
+	if err := os.WriteFile(path, []byte(broken), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	before := loader.Config()
+
+	w := do(h, "POST", "/v1/rules/scenarios/sc_login/restore", adminSecret, strings.NewReader(`{"persist": true}`))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("restore: status %d, want 422: %s", w.Code, w.Body)
+	}
+	if cfg := loader.Config(); cfg != before || cfg.Scenarios[0].Archived == nil {
+		t.Errorf("loader config changed by a rejected restore")
+	}
+	if got, err := os.ReadFile(overrides); err != nil || string(got) != string(saved) {
+		t.Errorf("overrides file changed by a rejected restore: %v\n%s", err, got)
+	}
+}
+
+func TestArchiveAndRestoreScenario(t *testing.T) {
+	h, eng := newTestHandler(t, "", WithTokens(testTokens(t)))
+	login := func() int {
+		res, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(res.ScenariosMatched)
+	}
+	var archive struct {
+		Count     int                `json:"count"`
+		Scenarios []archivedScenario `json:"scenarios"`
+	}
+	list := func() {
+		t.Helper()
+		w := do(h, "GET", "/v1/rules/archive", growthSecret, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("list archive: status %d: %s", w.Code, w.Body)
+		}
+		archive.Scenarios = nil
+		if err := json.Unmarshal(w.Body.Bytes(), &archive); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if w := do(h, "POST", "/v1/rules/scenarios/sc_login/archive", growthSecret, strings.NewReader(`{"reason": "promo over"}`)); w.Code != http.StatusOK {
+		t.Fatalf("archive: status %d: %s", w.Code, w.Body)
+	}
+	if n := login(); n != 0 {
+		t.Errorf("archived scenario still matched")
+	}
+	list()
+	if archive.Count != 1 {
+		t.Fatalf("archive lists %d scenarios, want 1", archive.Count)
+	}
+	a := archive.Scenarios[0]
+	if a.ID != "sc_login" || a.ArchivedBy != "growth-team" || a.Reason != "promo over" || !a.Enabled {
+		t.Errorf("archived entry = %+v", a)
+	}
+	if len(a.History) != 1 || a.History[0].Action != "scenario.archive" {
+		t.Errorf("history = %+v, want the archive", a.History)
+	}
+	if len(a.ArchiveHistory) != 1 || a.ArchiveHistory[0].Action != "archive" || a.ArchiveHistory[0].By != "growth-team" {
+		t.Errorf("archive history = %+v", a.ArchiveHistory)
+	}
+
+	// An archived scenario can be neither archived again nor toggled.
+	if w := do(h, "POST", "/v1/rules/scenarios/sc_login/archive", growthSecret, nil); w.Code != http.StatusConflict {
+		t.Errorf("archiving twice: status %d, want 409", w.Code)
+	}
+	if w := do(h, "PATCH", "/v1/rules/scenarios/sc_login/enabled", growthSecret, strings.NewReader(`{"enabled": false}`)); w.Code != http.StatusConflict {
+		t.Errorf("toggling an archived scenario: status %d, want 409", w.Code)
+	}
+
+	if w := do(h, "POST", "/v1/rules/scenarios/sc_login/restore", adminSecret, nil); w.Code != http.StatusOK {
+		t.Fatalf("restore: status %d: %s", w.Code, w.Body)
+	}
+	if n := login(); n != 1 {
+		t.Errorf("restored scenario matched %d times, want 1", n)
+	}
+	list()
+	if archive.Count != 0 {
+		t.Errorf("archive lists %d scenarios after the restore", archive.Count)
+	}
+	if w := do(h, "POST", "/v1/rules/scenarios/sc_login/restore", adminSecret, nil); w.Code != http.StatusConflict {
+		t.Errorf("restoring a live scenario: status %d, want 409", w.Code)
+	}
+	if w := do(h, "POST", "/v1/rules/scenarios/sc_missing/archive", adminSecret, nil); w.Code != http.StatusNotFound {
+		t.Errorf("archiving an unknown scenario: status %d, want 404", w.Code)
+	}
+	// Persisting needs an overrides file, as for toggles.
+	if w := do(h, "POST", "/v1/rules/scenarios/sc_login/archive", adminSecret, strings.NewReader(`{"persist": true}`)); w.Code != http.StatusConflict {
+		t.Errorf("persisted archive without overrides file: status %d, want 409", w.Code)
+	}
+	if n := login(); n != 1 {
+		t.Errorf("a failed persisted archive took the scenario out of the graph")
+	}
+}
+
+func TestJobStore_EvictsOnlyFinishedJobs(t *testing.T) {
+	s := newJobStore(2)
+	for _, id := range []string{"j1", "j2", "j3"} {
+		s.add(&job{ID: id, Status: "queued"})
+	}
+	// Over size, but nothing has finished: every job is kept.
+	for _, id := range []string{"j1", "j2", "j3"} {
+		if _, ok := s.get(id); !ok {
+			t.Fatalf("queued job %s evicted", id)
+		}
+	}
+	s.finish("j2", &engine.EventResult{})
+	if _, ok := s.get("j2"); ok {
+		t.Error("finished job j2 kept past the size")
+	}
+	s.finish("j1", &engine.EventResult{})
+	s.finish("j3", &engine.EventResult{})
+	s.add(&job{ID: "j4", Status: "queued"})
+	if _, ok := s.get("j1"); ok {
+		t.Error("the longest-finished job j1 was kept")
+	}
+	if j, ok := s.get("j3"); !ok || j.Status != "done" {
+		t.Errorf("j3 = %+v, %v; want the newest finished job kept", j, ok)
+	}
+}
+
+func TestGetJob(t *testing.T) {
+	rules := strings.Replace(testRules, "  event_workers: 2\n", "  event_workers: 1\n", 1)
+	rules = strings.Replace(rules, "  queue_depth: 16\n", "  queue_depth: 2\n", 1)
+	h, eng := newTestHandler(t, rules, WithAdaptiveAsync(0.5))
+
+	// Hold the only worker in a streamed event and fill half the queue.
+	started, release := make(chan struct{}), make(chan struct{})
+	go eng.ProcessStream(context.Background(), &event.Event{ID: "s1", Type: "login", ActorID: "u1"}, func(*action.ActionResult) {
+		close(started)
+		<-release
+	})
+	<-started
+	if !eng.ProcessAsync(&event.Event{ID: "a1", Type: "login", ActorID: "u1"}) {
+		t.Fatal("ProcessAsync rejected event")
+	}
+
+	w := do(h, "POST", "/v1/events", "", strings.NewReader(`{"id": "e1", "type": "login", "actor_id": "u1"}`))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("busy engine: status %d, want 202: %s", w.Code, w.Body)
+	}
+	var deferred job
+	if err := json.Unmarshal(w.Body.Bytes(), &deferred); err != nil {
+		t.Fatal(err)
+	}
+	if loc := w.Header().Get("Location"); loc != "/v1/jobs/"+deferred.ID {
+		t.Errorf("Location = %q, want the job URL", loc)
+	}
+	get := func() job {
+		t.Helper()
+		w := do(h, "GET", "/v1/jobs/"+deferred.ID, "", nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET job: status %d: %s", w.Code, w.Body)
+		}
+		var j job
+		if err := json.Unmarshal(w.Body.Bytes(), &j); err != nil {
+			t.Fatal(err)
+		}
+		return j
+	}
+	if j := get(); j.Status != "queued" || j.EventID != "e1" || j.Result != nil {
+		t.Errorf("job before processing = %+v", j)
+	}
+
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for get().Status != "done" {
+		if time.Now().After(deadline) {
+			t.Fatal("job not done")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if j := get(); j.Result == nil || j.Result.EventID != "e1" || len(j.Result.ActionsExecuted) != 1 {
+		t.Errorf("finished job = %+v", j)
+	}
+
+	if w := do(h, "GET", "/v1/jobs/unknown", "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown job: status %d, want 404", w.Code)
+	}
+}
+
+func TestErrors_AcceptNegotiation(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+	for name, tc := range map[string]struct {
+		method, target, body, accept string
+		status                       int
+		problemType                  string // "" = errorResponse
+	}{
+		"default":          {"POST", "/v1/events", `{"actor_id": "u1"}`, "", http.StatusBadRequest, ""},
+		"problem":          {"POST", "/v1/events", `{"actor_id": "u1"}`, problemMediaType, http.StatusBadRequest, problemValidation},
+		"problem in list":  {"POST", "/v1/events", `{"actor_id": "u1"}`, "application/json, application/problem+json;q=0.5", http.StatusBadRequest, problemValidation},
+		"problem refused":  {"POST", "/v1/events", `{"actor_id": "u1"}`, "application/problem+json;q=0", http.StatusBadRequest, ""},
+		"unknown route":    {"GET", "/v1/nope", "", "", http.StatusNotFound, ""},
+		"unknown route pd": {"GET", "/v1/nope", "", problemMediaType, http.StatusNotFound, "about:blank"},
+		"wrong method pd":  {"GET", "/v1/events", "", problemMediaType, http.StatusMethodNotAllowed, "about:blank"},
+	} {
+		var body io.Reader
+		if tc.body != "" {
+			body = strings.NewReader(tc.body)
+		}
+		r := httptest.NewRequest(tc.method, tc.target, body)
+		if tc.accept != "" {
+			r.Header.Set("Accept", tc.accept)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tc.status {
+			t.Errorf("%s: status %d, want %d: %s", name, w.Code, tc.status, w.Body)
+			continue
+		}
+		var got map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: body %q is not JSON: %v", name, w.Body, err)
+			continue
+		}
+		ct := w.Header().Get("Content-Type")
+		if tc.problemType == "" {
+			if ct != "application/json" || got["error"] == nil {
+				t.Errorf("%s: %s %v, want an error response", name, ct, got)
+			}
+			continue
+		}
+		if ct != problemMediaType || got["type"] != tc.problemType || got["status"] != float64(tc.status) || got["detail"] == "" {
+			t.Errorf("%s: %s %v, want a %s problem", name, ct, got, tc.problemType)
+		}
+	}
+	r := httptest.NewRequest("GET", "/v1/events", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if allow := w.Header().Get("Allow"); !strings.Contains(allow, "POST") {
+		t.Errorf("405 Allow = %q, want POST listed", allow)
+	}
+}
+
+func TestEngineErrorStatus(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		typ    string
+	}{
+		{fmt.Errorf("%w (capacity 16)", engine.ErrQueueFull), http.StatusTooManyRequests, problemQueueFull},
+		{engine.ErrRateLimited, http.StatusTooManyRequests, problemRateLimit},
+		{fmt.Errorf("%w after 1s", engine.ErrTimeout), http.StatusGatewayTimeout, problemTimeout},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout, problemTimeout},
+		{engine.ErrShuttingDown, http.StatusServiceUnavailable, ""},
+	} {
+		if status, typ := engineErrorStatus(tc.err); status != tc.status || typ != tc.typ {
+			t.Errorf("%v: %d %q, want %d %q", tc.err, status, typ, tc.status, tc.typ)
+		}
+	}
+}
+
+func TestIngestEvent_ResponseModes(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+	event := `{"type": "login", "actor_id": "u1"}`
+	post := func(target, body string, header ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", target, strings.NewReader(body))
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	checkHeaders := func(name string, w *httptest.ResponseRecorder, actions string) {
+		t.Helper()
+		if w.Header().Get("X-Decision") != decisionMatched || w.Header().Get("X-Scenarios-Matched") != "sc_login" ||
+			w.Header().Get("X-Actions") != actions || w.Header().Get("X-Actions-Failed") != "0" || w.Header().Get("X-Event-ID") == "" {
+			t.Errorf("%s: decision headers %v, want matched sc_login with %s actions", name, w.Header(), actions)
+		}
+	}
+
+	// full: the engine result, plus the decision headers.
+	w := post("/v1/events", event)
+	var full engine.EventResult
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &full) != nil || len(full.ActionsExecuted) != 1 {
+		t.Fatalf("full: status %d: %s", w.Code, w.Body)
+	}
+	checkHeaders("full", w, "1")
+
+	// minimal, requested three ways.
+	for name, w := range map[string]*httptest.ResponseRecorder{
+		"?response=minimal": post("/v1/events?response=minimal", event),
+		"options.response":  post("/v1/events", `{"event": `+event+`, "options": {"response": "minimal"}}`),
+		"Prefer":            post("/v1/events", event, "Prefer", "return=minimal"),
+	} {
+		var s decisionSummary
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &s) != nil {
+			t.Errorf("%s: status %d: %s", name, w.Code, w.Body)
+			continue
+		}
+		if s.Decision != decisionMatched || s.Actions != 1 || strings.Contains(w.Body.String(), "actions_executed") {
+			t.Errorf("%s: body %s, want only the summary", name, w.Body)
+		}
+		checkHeaders(name, w, "1")
+		if applied := w.Header().Get("Preference-Applied"); (name == "Prefer") != (applied == "return=minimal") {
+			t.Errorf("%s: Preference-Applied = %q", name, applied)
+		}
+	}
+
+	// headers: 204 and no body.
+	w = post("/v1/events?response=headers", event)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("headers: status %d, body %q; want 204 and none", w.Code, w.Body)
+	}
+	checkHeaders("headers", w, "1")
+
+	// Held-back actions are reported but not counted as executed.
+	w = post("/v1/events?response=headers", `{"event": `+event+`, "options": {"dry_run": true}}`)
+	checkHeaders("dry run", w, "0")
+
+	// A stream cannot be projected; Prefer is only a preference.
+	for _, target := range []string{"/v1/events?stream=ndjson&response=minimal", "/v1/events?stream=sse&response=headers"} {
+		if w := post(target, event); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: status %d, want 400", target, w.Code)
+		}
+	}
+	if w := post("/v1/events?response=minimal", `{"event": `+event+`, "options": {"stream": "ndjson"}}`); w.Code != http.StatusBadRequest {
+		t.Errorf("options.stream with ?response=minimal: status %d, want 400", w.Code)
+	}
+	w = post("/v1/events?stream=ndjson", event, "Prefer", "return=minimal")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Errorf("stream with Prefer: status %d, %s", w.Code, w.Header().Get("Content-Type"))
+	}
+}
+
+func TestSimulate_Cache(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+	body := `{"type": "login", "actor_id": "u1", "occurred_at": "2026-03-01T10:00:00Z"}`
+	for i, want := range []string{"MISS", "HIT"} {
+		w := do(h, "POST", "/v1/simulate", adminSecret, strings.NewReader(body))
+		if w.Code != http.StatusOK || w.Header().Get("X-Cache") != want {
+			t.Fatalf("call %d: %d, X-Cache %q, want %s: %s", i+1, w.Code, w.Header().Get("X-Cache"), want, w.Body)
+		}
+	}
+
+	// The key holds only the event, so a graph that reads actor data or the
+	// clock must not answer from the cache.
+	for _, expr := range []string{`actor.tier == "gold"`, `age(event.occurred_at) < 1h`} {
+		h, _ = newTestHandler(t, `version: v1
+scenarios:
+  - id: sc_login
+    enabled: true
+    event_types: [login]
+    children:
+      - condition:
+          id: cond_login
+          expression: '`+expr+`'
+          children:
+            - action:
+                id: act_welcome
+                type: reward_points
+                params: {operation: award, points: 50}
+`)
+		for i := 0; i < 2; i++ {
+			w := do(h, "POST", "/v1/simulate", adminSecret, strings.NewReader(body))
+			if w.Code != http.StatusOK || w.Header().Get("X-Cache") != "BYPASS" {
+				t.Fatalf("%s, call %d: %d, X-Cache %q, want BYPASS: %s", expr, i+1, w.Code, w.Header().Get("X-Cache"), w.Body)
+			}
+		}
+	}
+}
+
+func TestRules_ETag(t *testing.T) {
+	h, _ := newTestHandler(t, "")
+	for _, target := range []string{"/v1/rules", "/v1/rules/rendered"} {
+		w := do(h, "GET", target, adminSecret, nil)
+		etag := w.Header().Get("ETag")
+		if w.Code != http.StatusOK || etag == "" || w.Header().Get("X-Rules-Version") != "v1" {
+			t.Fatalf("%s: %d, ETag %q, X-Rules-Version %q", target, w.Code, etag, w.Header().Get("X-Rules-Version"))
+		}
+		if etag != `"`+w.Header().Get("X-Rules-Hash")+`"` {
+			t.Errorf("%s: ETag %s does not quote X-Rules-Hash %s", target, etag, w.Header().Get("X-Rules-Hash"))
+		}
+		for _, inm := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+			r := httptest.NewRequest("GET", target, nil)
+			r.Header.Set("If-None-Match", inm)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, r)
+			if rw.Code != http.StatusNotModified || rw.Body.Len() != 0 {
+				t.Errorf("%s If-None-Match %s: %d with %d body bytes, want an empty 304", target, inm, rw.Code, rw.Body.Len())
+			}
+		}
+	}
+
+	w := do(h, "GET", "/v1/rules", adminSecret, nil)
+	var body struct {
+		Version string `json:"version"`
+		Hash    string `json:"hash"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Version != "v1" || `"`+body.Hash+`"` != w.Header().Get("ETag") {
+		t.Errorf("body version %q hash %q, ETag %s", body.Version, body.Hash, w.Header().Get("ETag"))
+	}
+
+	// A change to the active rules changes the ETag, so the old one misses.
+	old := w.Header().Get("ETag")
+	if w := do(h, "PATCH", "/v1/rules/scenarios/sc_login/enabled", adminSecret, strings.NewReader(`{"enabled": false}`)); w.Code != http.StatusOK {
+		t.Fatalf("toggle: %d %s", w.Code, w.Body)
+	}
+	r := httptest.NewRequest("GET", "/v1/rules", nil)
+	r.Header.Set("If-None-Match", old)
+	rw := httptest.NewRecorder()
+	h.ServeHTTP(rw, r)
+	if rw.Code != http.StatusOK || rw.Header().Get("ETag") == old {
+		t.Errorf("after a change: %d, ETag %s (was %s)", rw.Code, rw.Header().Get("ETag"), old)
+	}
+}
+
+func TestNotModified_WithoutHash(t *testing.T) {
+	r := httptest.NewRequest("GET", "/v1/rules", nil)
+	r.Header.Set("If-None-Match", "*")
+	w := httptest.NewRecorder()
+	if notModified(w, r, "", "v1") {
+		t.Error("notModified = true without a hash")
+	}
+	if _, ok := w.Header()["Etag"]; ok {
+		t.Errorf("ETag %q set without a hash", w.Header().Get("ETag"))
+	}
+}
+
+func TestIngestBatch_PerEventReport(t *testing.T) {
+	rules := strings.Replace(testRules, "  queue_depth: 16\n", "  queue_depth: 16\n  max_payload_bytes: 256\n", 1)
+	h, _ := newTestHandler(t, rules, WithTokens(testTokens(t)))
+	batch := `[
+		{"id": "e1", "type": "transaction", "source": "billing-service", "actor_id": "u1"},
+		{"source": "billing-service", "actor_id": "u2"},
+		{"type": "transaction", "source": "billing-service", "actorId": "u3"},
+		{"type": "transaction", "source": "billing-service", "actor_id": 42},
+		{"type": "transaction", "source": "billing-service", "occurred_at": "yesterday"},
+		"login",
+		{"type": "login", "source": "billing-service"},
+		{"type": "transaction", "source": "billing-service", "payload": {"blob": "` + strings.Repeat("x", 300) + `"}},
+		{"id": "e1", "type": "transaction", "source": "billing-service"}
+	]`
+	w := do(h, "POST", "/v1/events/batch", billingSecret, strings.NewReader(batch))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Total      int         `json:"total"`
+		Queued     int         `json:"queued"`
+		Duplicates int         `json:"duplicates"`
+		Rejected   int         `json:"rejected"`
+		Results    []batchItem `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Total != 9 || resp.Queued != 1 || resp.Duplicates != 1 || resp.Rejected != 7 || len(resp.Results) != 9 {
+		t.Fatalf("counts = %+v", resp)
+	}
+	want := []struct{ status, reason string }{
+		{"queued", ""},
+		{"rejected", "event type is required"},
+		{"rejected", `schema: unknown field "actorId"`},
+		{"rejected", "schema: actor_id must be a string"},
+		{"rejected", "schema: occurred_at must be an RFC 3339 timestamp"},
+		{"rejected", "event must be a JSON object"},
+		{"rejected", "may not send"},
+		{"rejected", "payload"},
+		{"duplicate", "same event id as index 0"},
+	}
+	for i, item := range resp.Results {
+		if item.Index != i || item.Status != want[i].status || !strings.Contains(item.Reason, want[i].reason) {
+			t.Errorf("results[%d] = %+v, want %s containing %q", i, item, want[i].status, want[i].reason)
+		}
+	}
+
+	// A batch with no valid event is a 422.
+	if w := do(h, "POST", "/v1/events/batch", billingSecret, strings.NewReader(`[{"actor_id": "u1"}]`)); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("all invalid: status %d, want 422", w.Code)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	get := func(h http.Handler) capabilities {
+		t.Helper()
+		w := do(h, "GET", "/v1/capabilities", billingSecret, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var c capabilities
+		if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	h, _ := newTestHandler(t, "")
+	c := get(h)
+	if c.RulesVersion != "v1" || len(c.APIVersions) != 1 || c.APIVersions[0] != "v1" {
+		t.Errorf("versions: rules %q, api %v", c.RulesVersion, c.APIVersions)
+	}
+	if strings.Join(c.Sources, ",") != "http,batch" {
+		t.Errorf("sources = %v, want http and batch only", c.Sources)
+	}
+	if len(c.Executors) != 1 || c.Executors[0].Type != "reward_points" {
+		t.Errorf("executors = %+v", c.Executors)
+	}
+	if strings.Join(c.Expression.Namespaces, ",") != "payload,meta,event" || len(c.Expression.Functions) == 0 {
+		t.Errorf("expression = %+v", c.Expression)
+	}
+	if c.Features["auth_tokens"] || !c.Features["evaluation_options"] || len(c.Stores) != 0 {
+		t.Errorf("features %v, stores %v", c.Features, c.Stores)
+	}
+
+	// A deployment with tokens, stores and the scheduler's action says so.
+	h, eng := newTestHandler(t, "", WithTokens(testTokens(t)), WithStores(map[string]string{"schedule": "sqlite3"}))
+	eng.Registry().Register(schedule.New(schedule.NewMemoryStore()).Executor())
+	c = get(h)
+	if strings.Join(c.Sources, ",") != "http,batch,scheduler" {
+		t.Errorf("sources = %v, want the scheduler listed", c.Sources)
+	}
+	if len(c.Executors) != 2 || c.Executors[0].Type != schedule.ActionType {
+		t.Errorf("executors = %+v", c.Executors)
+	}
+	if !c.Features["auth_tokens"] || !c.Features["scenario_ownership"] || c.Stores["schedule"] != "sqlite3" {
+		t.Errorf("features %v, stores %v", c.Features, c.Stores)
+	}
+}
+
+func TestTokens_ProtectEveryRoute(t *testing.T) {
+	h, _ := newTestHandler(t, "", WithTokens(testTokens(t)))
+	for _, route := range []struct{ method, target, body string }{
+		{"GET", "/v1/jobs/unknown", ""},
+		{"POST", "/v1/simulate", `{"type": "login"}`},
+		{"GET", "/v1/rules", ""},
+		{"GET", "/v1/rules/rendered", ""},
+		{"GET", "/v1/rules/search?q=login", ""},
+		{"GET", "/v1/rules/archive", ""},
+		{"GET", "/v1/rules/audit", ""},
+		{"GET", "/v1/quarantine", ""},
+		{"GET", "/v1/results/recent", ""},
+		{"GET", "/v1/monitors", ""},
+		{"GET", "/v1/analytics/payloads", ""},
+		{"GET", "/v1/capabilities", ""},
+	} {
+		body := func() io.Reader {
+			if route.body == "" {
+				return nil
+			}
+			return strings.NewReader(route.body)
+		}
+		w := do(h, route.method, route.target, "", body())
+		if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s without a token: status %d, want 401", route.method, route.target, w.Code)
+		}
+		if w := do(h, route.method, route.target, "ff_unknown", body()); w.Code != http.StatusUnauthorized {
+			t.Errorf("%s %s with an unknown token: status %d, want 401", route.method, route.target, w.Code)
+		}
+		// Any known token will do; the route decides what comes next.
+		if w := do(h, route.method, route.target, billingSecret, body()); w.Code == http.StatusUnauthorized || w.Code == http.StatusForbidden {
+			t.Errorf("%s %s with an ingestion token: status %d", route.method, route.target, w.Code)
+		}
+	}
+	for _, target := range []string{"/healthz", "/readyz", "/metrics"} {
+		if w := do(h, "GET", target, "", nil); w.Code == http.StatusUnauthorized {
+			t.Errorf("GET %s needs a token", target)
+		}
+	}
+}
+
+func TestCacheInvalidation_NeedsAdmin(t *testing.T) {
+	h, _ := newTestHandler(t, "", WithTokens(testTokens(t)))
+	for _, target := range []string{"/v1/actors/u1/cache", "/v1/tenants/t1/cache"} {
+		if w := do(h, "DELETE", target, billingSecret, nil); w.Code != http.StatusForbidden {
+			t.Errorf("DELETE %s with an ingestion token: status %d, want 403", target, w.Code)
+		}
+		if w := do(h, "DELETE", target, adminSecret, nil); w.Code != http.StatusNoContent {
+			t.Errorf("DELETE %s with an admin token: status %d, want 204", target, w.Code)
+		}
+	}
+}
+
+func TestIngestEvent_TokenScope(t *testing.T) {
+	h, _ := newTestHandler(t, "", WithTokens(testTokens(t)))
+	for _, tc := range []struct {
+		name, secret, body string
+		want               int
+	}{
+		{"no token", "", `{"type": "transaction", "source": "billing-service"}`, http.StatusUnauthorized},
+		{"in scope", billingSecret, `{"type": "transaction", "source": "billing-service"}`, http.StatusOK},
+		{"source defaulted", billingSecret, `{"type": "transaction"}`, http.StatusOK},
+		{"other source", billingSecret, `{"type": "transaction", "source": "growth-service"}`, http.StatusForbidden},
+		{"other type", billingSecret, `{"type": "login", "source": "billing-service"}`, http.StatusForbidden},
+		{"unscoped token", growthSecret, `{"type": "login", "source": "web"}`, http.StatusOK},
+	} {
+		if w := do(h, "POST", "/v1/events", tc.secret, strings.NewReader(tc.body)); w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d: %s", tc.name, w.Code, tc.want, w.Body)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+//go:build cgo
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/inbox"
+)
+
+func TestIngestEvent_InboxDuplicate(t *testing.T) {
+	ib, err := inbox.Open("sqlite3", filepath.Join(t.TempDir(), "inbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ib.Close() })
+	h, _ := newTestHandler(t, "", WithInbox(ib))
+
+	body := `{"id": "e1", "type": "login", "actor_id": "u1"}`
+	if w := do(h, "POST", "/v1/events", "", strings.NewReader(body)); w.Code != http.StatusAccepted {
+		t.Fatalf("first copy: status %d, want 202: %s", w.Code, w.Body)
+	}
+	w := do(h, "POST", "/v1/events", "", strings.NewReader(body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("second copy: status %d, want 200: %s", w.Code, w.Body)
+	}
+	var res struct {
+		Status    string `json:"status"`
+		Duplicate bool   `json:"duplicate"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != "duplicate" || !res.Duplicate {
+		t.Errorf("second copy = %s, want a duplicate", w.Body)
+	}
+}
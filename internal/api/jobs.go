@@ -0,0 +1,105 @@
+package api
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+)
+
+const jobStoreSize = 10000
+
+// job tracks an event that POST /v1/events deferred to async processing.
+type job struct {
+	ID         string              `json:"job_id"`
+	EventID    string              `json:"event_id"`
+	Status     string              `json:"status"` // queued | done
+	EnqueuedAt time.Time           `json:"enqueued_at"`
+	Result     *engine.EventResult `json:"result,omitempty"`
+}
+
+// jobStore keeps deferred jobs until they are polled out of it: once it
+// holds more than size jobs, the longest-finished ones are evicted, so
+// clients should poll promptly. Queued jobs are never evicted; their number
+// is bounded by the engine queue.
+type jobStore struct {
+	mu     sync.Mutex
+	size   int
+	queued *list.List // unordered
+	done   *list.List // front = most recently finished
+	items  map[string]*list.Element
+}
+
+func newJobStore(size int) *jobStore {
+	return &jobStore{size: size, queued: list.New(), done: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (s *jobStore) add(j *job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items[j.ID] = s.queued.PushFront(j)
+	s.evict()
+}
+
+func (s *jobStore) finish(id string, res *engine.EventResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[id]
+	if !ok {
+		return
+	}
+	j := s.queued.Remove(el).(*job)
+	j.Status, j.Result = "done", res
+	s.items[id] = s.done.PushFront(j)
+	s.evict()
+}
+
+// evict drops the longest-finished jobs while the store is over size.
+func (s *jobStore) evict() {
+	for len(s.items) > s.size && s.done.Len() > 0 {
+		oldest := s.done.Back()
+		s.done.Remove(oldest)
+		delete(s.items, oldest.Value.(*job).ID)
+	}
+}
+
+func (s *jobStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[id]; ok {
+		s.queued.Remove(el)
+		delete(s.items, id)
+	}
+}
+
+// purge removes finished jobs whose result has outlived ttl(ScenariosMatched).
+func (s *jobStore) purge(now time.Time, ttl func(scenarios []string) time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for el := s.done.Front(); el != nil; {
+		next := el.Next()
+		j := el.Value.(*job)
+		if j.Result != nil {
+			if d := ttl(j.Result.ScenariosMatched); d > 0 && now.Sub(j.EnqueuedAt) >= d {
+				s.done.Remove(el)
+				delete(s.items, j.ID)
+				n++
+			}
+		}
+		el = next
+	}
+	return n
+}
+
+// get returns a copy of the job so callers can encode it without the lock.
+func (s *jobStore) get(id string) (job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[id]
+	if !ok {
+		return job{}, false
+	}
+	return *el.Value.(*job), true
+}
@@ -1,18 +1,40 @@
 package api
 
 import (
-	"log/slog"
 	"net/http"
 	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
 )
 
-// loggingMiddleware logs method, path, status, and duration for every request.
-func loggingMiddleware(next http.Handler) http.Handler {
+// maxRequestIDLen bounds a client-supplied X-Request-ID.
+const maxRequestIDLen = 128
+
+// logRequests tags every request with a request ID (the client's
+// X-Request-ID, or a new one), echoes it in the response and attaches it to
+// the request context, so log lines from handlers, the engine and actions
+// carry it. It then logs method, path, status, and duration: every error
+// response, and one in engine.request_log_sample_every successful ones.
+func (h *Handler) logRequests(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > maxRequestIDLen {
+			id = uuid.New().String()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(logctx.With(r.Context(), "request_id", id))
+
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK, problem: acceptsProblem(r)}
 		next.ServeHTTP(rw, r)
-		slog.Info("request",
+		if rw.status < 400 {
+			if every := h.eng.Settings().RequestLogSampleEvery; every > 1 && h.logSeq.Add(1)%uint64(every) != 0 {
+				return
+			}
+		}
+		logctx.From(r.Context()).Info("request",
 			"method", r.Method,
 			"path", r.URL.Path,
 			"status", rw.status,
@@ -22,13 +44,28 @@ func loggingMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// responseWriter captures the status code written by the handler.
+// responseWriter captures the status code written by the handler. It also
+// carries whether the client takes errors as problem details, so writeError
+// needs no request.
 type responseWriter struct {
 	http.ResponseWriter
-	status int
+	status  int
+	problem bool
 }
 
 func (rw *responseWriter) WriteHeader(code int) {
 	rw.status = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Flush lets streaming handlers flush through the logging wrapper.
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// eventOptions are the per-request options of a POST /v1/events envelope:
+// the engine's evaluation options plus how the response is delivered.
+type eventOptions struct {
+	engine.EvalOptions
+	Stream   string `json:"stream,omitempty"`   // sse | ndjson; overrides ?stream= and Accept
+	Response string `json:"response,omitempty"` // full | minimal | headers; overrides ?response= and Prefer
+}
+
+// decodeEventBody reads a POST /v1/events body, either a bare event or an
+// envelope {"event": {...}, "options": {...}}. Options are decoded strictly
+// so a misspelt flag is an error instead of being ignored; opts is nil for a
+// bare event.
+func decodeEventBody(body []byte) (*event.Event, *eventOptions, error) {
+	var env struct {
+		Event   json.RawMessage `json:"event"`
+		Options json.RawMessage `json:"options"`
+	}
+	if err := json.Unmarshal(body, &env); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON: %s", err)
+	}
+	var ev event.Event
+	if env.Event == nil {
+		if env.Options != nil {
+			return nil, nil, fmt.Errorf("options need the envelope form {\"event\": {...}, \"options\": {...}}")
+		}
+		if err := json.Unmarshal(body, &ev); err != nil {
+			return nil, nil, fmt.Errorf("invalid JSON: %s", err)
+		}
+		return &ev, nil, nil
+	}
+	if err := json.Unmarshal(env.Event, &ev); err != nil {
+		return nil, nil, fmt.Errorf("invalid event: %s", err)
+	}
+	if env.Options == nil {
+		return &ev, nil, nil
+	}
+	opts := &eventOptions{}
+	dec := json.NewDecoder(bytes.NewReader(env.Options))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(opts); err != nil {
+		return nil, nil, fmt.Errorf("invalid options: %s", err)
+	}
+	switch opts.Stream = strings.ToLower(opts.Stream); opts.Stream {
+	case "", streamSSE, streamNDJSON:
+	default:
+		return nil, nil, fmt.Errorf("invalid options: stream must be %s or %s", streamSSE, streamNDJSON)
+	}
+	switch opts.Response = strings.ToLower(opts.Response); opts.Response {
+	case "", responseFull, responseMinimal, responseHeaders:
+	default:
+		return nil, nil, fmt.Errorf("invalid options: response must be %s, %s or %s", responseFull, responseMinimal, responseHeaders)
+	}
+	if opts.Stream != "" && opts.Response != "" && opts.Response != responseFull {
+		return nil, nil, fmt.Errorf("invalid options: a streamed response cannot also be %s", opts.Response)
+	}
+	return &ev, opts, nil
+}
+
+// evalOptions returns the engine options of o, or nil when o is nil or
+// only chooses a stream format.
+func (o *eventOptions) evalOptions() *engine.EvalOptions {
+	if o == nil {
+		return nil
+	}
+	e := o.EvalOptions
+	if !e.Explain && !e.DryRun && len(e.SkipActions) == 0 && e.ForceScenario == "" {
+		return nil
+	}
+	return &e
+}
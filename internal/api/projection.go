@@ -0,0 +1,143 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+)
+
+// Response projections of POST /v1/events, for callers that only need the
+// decision: minimal answers with a decisionSummary instead of the full
+// result, headers with the summary in headers and no body.
+const (
+	responseFull    = "full"
+	responseMinimal = "minimal"
+	responseHeaders = "headers"
+)
+
+// Decision values of a summary.
+const (
+	decisionMatched     = "matched"
+	decisionNoMatch     = "no_match"
+	decisionDuplicate   = "duplicate"
+	decisionQuarantined = "quarantined"
+)
+
+// decisionSummary is the minimal projection of an engine.EventResult.
+type decisionSummary struct {
+	EventID          string   `json:"event_id"`
+	Decision         string   `json:"decision"`
+	ScenariosMatched []string `json:"scenarios_matched"`
+	Actions          int      `json:"actions"`        // actions executed, not held back or skipped
+	ActionsFailed    int      `json:"actions_failed"` // of those, how many failed
+	DurationMs       int64    `json:"duration_ms"`
+}
+
+// responseMode picks the projection from options.response, then ?response=,
+// then `Prefer: return=minimal`. An unknown value is an error.
+func responseMode(r *http.Request, opts *eventOptions) (string, error) {
+	mode := ""
+	if opts != nil {
+		mode = opts.Response
+	}
+	if mode == "" {
+		mode = strings.ToLower(r.URL.Query().Get("response"))
+	}
+	if mode == "" && prefersMinimal(r) {
+		mode = responseMinimal
+	}
+	switch mode {
+	case "":
+		return responseFull, nil
+	case responseFull, responseMinimal, responseHeaders:
+		return mode, nil
+	}
+	return "", fmt.Errorf("response must be %s, %s or %s", responseFull, responseMinimal, responseHeaders)
+}
+
+// projectionRequested reports whether options.response or ?response= asks
+// for minimal or headers, which a stream cannot honour. Prefer is only a
+// preference, so a stream simply ignores it.
+func projectionRequested(r *http.Request, opts *eventOptions) bool {
+	mode := strings.ToLower(r.URL.Query().Get("response"))
+	if opts != nil && opts.Response != "" {
+		mode = opts.Response
+	}
+	return mode == responseMinimal || mode == responseHeaders
+}
+
+// prefersMinimal reports whether r carries the RFC 7240 return=minimal preference.
+func prefersMinimal(r *http.Request) bool {
+	for _, h := range r.Header.Values("Prefer") {
+		for _, p := range strings.Split(h, ",") {
+			if strings.EqualFold(strings.TrimSpace(p), "return=minimal") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func summarize(res *engine.EventResult) decisionSummary {
+	s := decisionSummary{
+		EventID:          res.EventID,
+		Decision:         decisionNoMatch,
+		ScenariosMatched: res.ScenariosMatched,
+		DurationMs:       res.DurationMs,
+	}
+	switch {
+	case res.Duplicate:
+		s.Decision = decisionDuplicate
+	case res.Quarantined:
+		s.Decision = decisionQuarantined
+	case len(res.ScenariosMatched) > 0:
+		s.Decision = decisionMatched
+	}
+	if s.ScenariosMatched == nil {
+		s.ScenariosMatched = []string{}
+	}
+	for _, ar := range res.ActionsExecuted {
+		// A status marks an action held back (dry_run, skip_actions) or
+		// skipped (limited, degraded) instead of executed.
+		if ar.Status != "" {
+			continue
+		}
+		s.Actions++
+		if !ar.Success {
+			s.ActionsFailed++
+		}
+	}
+	return s
+}
+
+// writeResult answers with res projected by mode. The decision headers are
+// set in every mode, so a client can switch modes without changing how it
+// reads them.
+func writeResult(w http.ResponseWriter, r *http.Request, status int, res *engine.EventResult, mode string) {
+	s := summarize(res)
+	hdr := w.Header()
+	hdr.Set("X-Event-ID", s.EventID)
+	hdr.Set("X-Decision", s.Decision)
+	if len(s.ScenariosMatched) > 0 {
+		hdr.Set("X-Scenarios-Matched", strings.Join(s.ScenariosMatched, ","))
+	}
+	hdr.Set("X-Actions", strconv.Itoa(s.Actions))
+	hdr.Set("X-Actions-Failed", strconv.Itoa(s.ActionsFailed))
+	if mode == responseMinimal && prefersMinimal(r) {
+		hdr.Set("Preference-Applied", "return=minimal")
+	}
+	switch mode {
+	case responseHeaders:
+		if status == http.StatusOK {
+			status = http.StatusNoContent
+		}
+		w.WriteHeader(status)
+	case responseMinimal:
+		writeJSON(w, status, s)
+	default:
+		writeJSON(w, status, res)
+	}
+}
@@ -1,8 +1,15 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
 )
 
 // writeJSON encodes v as JSON and writes it with the given status code.
@@ -17,6 +24,183 @@ type errorResponse struct {
 	Error string `json:"error"`
 }
 
+// problemMediaType is the RFC 7807 media type. Clients that list it in
+// Accept get errors as problem details instead of errorResponse.
+const problemMediaType = "application/problem+json"
+
+// Problem types for the failures clients most often handle by type. Other
+// errors use "about:blank", for which the title is the HTTP status text.
+const (
+	problemQueueFull  = "urn:fluxflow:problem:queue-full"
+	problemValidation = "urn:fluxflow:problem:validation"
+	problemAuth       = "urn:fluxflow:problem:auth"
+	problemTimeout    = "urn:fluxflow:problem:timeout"
+	problemRateLimit  = "urn:fluxflow:problem:rate-limited"
+)
+
+var problemTitles = map[string]string{
+	problemQueueFull:  "Event queue full",
+	problemValidation: "Invalid request",
+	problemAuth:       "Not authorized",
+	problemTimeout:    "Processing timed out",
+	problemRateLimit:  "Event rate limit exceeded",
+}
+
 func writeError(w http.ResponseWriter, status int, msg string) {
-	writeJSON(w, status, errorResponse{Error: msg})
+	writeProblem(w, status, "", msg, nil)
+}
+
+// writeEngineError answers an event the engine did not accept or finish,
+// typing queue-full, rate-limit and timeout failures.
+func writeEngineError(w http.ResponseWriter, err error) {
+	status, typ := engineErrorStatus(err)
+	writeProblem(w, status, typ, err.Error(), nil)
+}
+
+// engineErrorStatus maps an engine error to a status and problem type: 429
+// for a full queue or the rate limit, which clients retry with backoff, 504
+// for a timeout and 503 while shutting down.
+func engineErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, engine.ErrQueueFull):
+		return http.StatusTooManyRequests, problemQueueFull
+	case errors.Is(err, engine.ErrRateLimited):
+		return http.StatusTooManyRequests, problemRateLimit
+	case errors.Is(err, engine.ErrTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, problemTimeout
+	case errors.Is(err, engine.ErrShuttingDown):
+		return http.StatusServiceUnavailable, ""
+	}
+	return http.StatusInternalServerError, ""
+}
+
+// writeProblem writes an error as errorResponse, or as a problem detail if
+// the client asked for one. typ defaults to a type derived from status; ext
+// members are added to either body.
+func writeProblem(w http.ResponseWriter, status int, typ, msg string, ext map[string]interface{}) {
+	body := errorBody(w, status, typ, msg, ext)
+	if !wantsProblem(w) {
+		writeJSON(w, status, body)
+		return
+	}
+	w.Header().Set("Content-Type", problemMediaType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// errorBody is the body writeProblem sends, for writers that frame it
+// themselves, such as a stream that has already started.
+func errorBody(w http.ResponseWriter, status int, typ, msg string, ext map[string]interface{}) interface{} {
+	if !wantsProblem(w) {
+		if ext == nil {
+			return errorResponse{Error: msg}
+		}
+		body := map[string]interface{}{"error": msg}
+		for k, v := range ext {
+			body[k] = v
+		}
+		return body
+	}
+	if typ == "" {
+		typ = statusProblem(status)
+	}
+	title := problemTitles[typ]
+	if title == "" {
+		title = http.StatusText(status)
+	}
+	body := map[string]interface{}{
+		"type":   typ,
+		"title":  title,
+		"status": status,
+		"detail": msg,
+	}
+	for k, v := range ext {
+		body[k] = v
+	}
+	return body
+}
+
+// statusProblem is the problem type of an error with no more specific one.
+func statusProblem(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return problemValidation
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return problemAuth
+	case http.StatusGatewayTimeout:
+		return problemTimeout
+	}
+	return "about:blank"
+}
+
+// acceptsProblem reports whether r's Accept header lists problem+json with
+// a non-zero quality.
+func acceptsProblem(r *http.Request) bool {
+	for _, h := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(h, ",") {
+			mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil || mt != problemMediaType {
+				continue
+			}
+			if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// wantsProblem finds the request's preference recorded on w by
+// logRequests.
+func wantsProblem(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case *responseWriter:
+			return rw.problem
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
+
+// routeErrors answers requests that mux has no route for (404, or 405 with
+// an Allow header) in the client's error format instead of ServeMux's
+// plain text.
+func routeErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern == "" {
+			w = &routeErrorWriter{ResponseWriter: w}
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// routeErrorWriter holds back an error status written by ServeMux and
+// replaces its plain-text body with writeError.
+type routeErrorWriter struct {
+	http.ResponseWriter
+	status  int
+	written bool
+}
+
+func (rw *routeErrorWriter) WriteHeader(code int) {
+	if code < 400 {
+		rw.ResponseWriter.WriteHeader(code)
+		return
+	}
+	rw.status = code
+}
+
+func (rw *routeErrorWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		return rw.ResponseWriter.Write(b)
+	}
+	if !rw.written {
+		writeError(rw.ResponseWriter, rw.status, strings.TrimSpace(string(b)))
+		rw.written = true
+	}
+	return len(b), nil
 }
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+const simCacheSize = 256
+
+// simCache is a small LRU of simulation results keyed by graph hash and
+// normalized event hash. Entries for older graphs simply age out.
+type simCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List // front = most recently used
+	items map[string]*list.Element
+}
+
+type simEntry struct {
+	key string
+	res *engine.SimulationResult
+}
+
+func newSimCache(size int) *simCache {
+	return &simCache{size: size, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (c *simCache) get(key string) (*engine.SimulationResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*simEntry).res, true
+}
+
+func (c *simCache) put(key string, res *engine.SimulationResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		el.Value.(*simEntry).res = res
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&simEntry{key: key, res: res})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*simEntry).key)
+	}
+}
+
+// simKey hashes the event as submitted (before an ID is generated) together
+// with the graph hash. encoding/json sorts map keys, so payload field order
+// does not affect the key.
+func simKey(graphHash string, ev *event.Event) string {
+	data, _ := json.Marshal(ev)
+	h := sha256.New()
+	h.Write([]byte(graphHash))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
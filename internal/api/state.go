@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/actorstate"
+)
+
+// maxStateImportBytes bounds POST /v1/admin/actors/state bodies.
+const maxStateImportBytes = 64 << 20
+
+// WithActorState serves per-actor state export and import from src at
+// /v1/admin/actors/state.
+func WithActorState(src actorstate.Sources) Option {
+	return func(h *Handler) { h.state = &src }
+}
+
+// GET /v1/admin/actors/state?actor_id=… — export per-actor state (limits,
+// workflows, scheduled events, streaks). Repeat actor_id for several actors; omit it
+// to export every actor with state.
+func (h *Handler) exportActorState(w http.ResponseWriter, r *http.Request) {
+	if h.state == nil {
+		writeError(w, http.StatusNotFound, "actor state export is not enabled")
+		return
+	}
+	snap, err := h.state.Export(r.Context(), r.URL.Query()["actor_id"], time.Now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
+// POST /v1/admin/actors/state — import a document produced by the export.
+// Repeating an import is harmless; see actorstate.Sources.Import.
+func (h *Handler) importActorState(w http.ResponseWriter, r *http.Request) {
+	if h.state == nil {
+		writeError(w, http.StatusNotFound, "actor state import is not enabled")
+		return
+	}
+	var snap actorstate.Snapshot
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxStateImportBytes)).Decode(&snap); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid JSON: %s", err))
+		return
+	}
+	if err := actorstate.Check(&snap); err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	sum, err := h.state.Import(r.Context(), &snap, time.Now())
+	if sum != nil {
+		h.audit.record(r.Context(), auditEntry{
+			Time:   time.Now(),
+			Action: "actors.import",
+			Actor:  h.auditActor(r),
+			Token:  h.tokenName(r),
+			Reason: r.URL.Query().Get("reason"),
+			Changes: map[string]interface{}{
+				"actors":    sum.Actors,
+				"limits":    sum.Limits,
+				"workflows": sum.Workflows,
+				"scheduled": sum.Scheduled,
+				"streaks":   sum.Streaks,
+			},
+		})
+	}
+	if err != nil {
+		writeProblem(w, http.StatusInternalServerError, "", err.Error(), map[string]interface{}{"imported": sum})
+		return
+	}
+	writeJSON(w, http.StatusOK, sum)
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+const (
+	streamSSE    = "sse"
+	streamNDJSON = "ndjson"
+)
+
+// streamFormat picks a streaming encoding from ?stream= or the Accept header.
+// It returns "" for a regular buffered JSON response.
+func streamFormat(r *http.Request) string {
+	switch strings.ToLower(r.URL.Query().Get("stream")) {
+	case streamSSE:
+		return streamSSE
+	case streamNDJSON:
+		return streamNDJSON
+	}
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/event-stream"):
+		return streamSSE
+	case strings.Contains(accept, "application/x-ndjson"):
+		return streamNDJSON
+	}
+	return ""
+}
+
+// streamEvent processes ev and writes one "action" record per completed action
+// followed by a final "result" record (or "error" if processing failed after
+// the stream started). Headers are deferred until the first record so that
+// queue-full errors can still be reported with a proper status code.
+func (h *Handler) streamEvent(w http.ResponseWriter, r *http.Request, ev *event.Event, opts *engine.EvalOptions, format string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusNotAcceptable, "streaming not supported by this connection")
+		return
+	}
+	sw := &streamWriter{w: w, flusher: flusher, format: format}
+
+	res, err := h.eng.ProcessOptions(r.Context(), ev, opts, func(ar *action.ActionResult) {
+		sw.send("action", ar)
+	})
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.closed = true // late action callbacks must not touch w after we return
+	if err != nil {
+		if !sw.started {
+			writeEngineError(w, err)
+			return
+		}
+		status, typ := engineErrorStatus(err)
+		sw.write("error", errorBody(w, status, typ, err.Error(), nil))
+		return
+	}
+	metrics.EventProcessingDuration().Observe(float64(res.DurationMs))
+	sw.write("result", res)
+}
+
+// streamWriter serialises records from the event worker's action callback
+// with the request goroutine.
+type streamWriter struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	format  string
+	started bool
+	closed  bool
+}
+
+func (s *streamWriter) send(kind string, v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.write(kind, v)
+}
+
+// write must be called with s.mu held.
+func (s *streamWriter) write(kind string, v interface{}) {
+	if !s.started {
+		if s.format == streamSSE {
+			s.w.Header().Set("Content-Type", "text/event-stream")
+		} else {
+			s.w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if s.format == streamSSE {
+		fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", kind, data)
+	} else {
+		fmt.Fprintf(s.w, "{\"type\":%q,\"data\":%s}\n", kind, data)
+	}
+	s.flusher.Flush()
+}
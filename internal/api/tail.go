@@ -0,0 +1,173 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/duration"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+const (
+	maxTails            = 16  // concurrent tail sessions
+	tailBuffer          = 256 // records buffered per session before dropping
+	defaultTailDuration = 5 * time.Minute
+	maxTailDuration     = 30 * time.Minute
+	tailHeartbeat       = 15 * time.Second
+)
+
+// redactedValue replaces payload values in tail records unless the session
+// asked for ?payload=full.
+const redactedValue = "[redacted]"
+
+// tailRecord is one decision streamed to a tail session.
+type tailRecord struct {
+	Event  *event.Event        `json:"event"`
+	Result *engine.EventResult `json:"result"`
+}
+
+// tailSub is one open tail session. Empty filter fields match anything.
+type tailSub struct {
+	actorID     string
+	scenarioID  string
+	fullPayload bool
+	ch          chan tailRecord
+	dropped     atomic.Int64
+}
+
+func (s *tailSub) matches(ev *event.Event, res *engine.EventResult) bool {
+	return (s.actorID == "" || ev.ActorID == s.actorID) &&
+		(s.scenarioID == "" || slices.Contains(res.ScenariosMatched, s.scenarioID))
+}
+
+// publish is the session's engine.EventHook. It runs on event workers, so it
+// never blocks: a session that falls behind loses records and is told how
+// many in its final "end" record.
+func (s *tailSub) publish(ev *event.Event, res *engine.EventResult) {
+	if !s.matches(ev, res) {
+		return
+	}
+	if !s.fullPayload {
+		ev = redactPayload(ev)
+	}
+	select {
+	case s.ch <- tailRecord{Event: ev, Result: res}:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// redactPayload returns a copy of ev whose payload keeps its field names but
+// not their values, which may carry personal data.
+func redactPayload(ev *event.Event) *event.Event {
+	cp := *ev
+	cp.Payload = make(map[string]interface{}, len(ev.Payload))
+	for k := range ev.Payload {
+		cp.Payload[k] = redactedValue
+	}
+	return &cp
+}
+
+// tailHub counts open tail sessions against maxTails.
+type tailHub struct {
+	n atomic.Int32
+}
+
+func newTailHub() *tailHub {
+	return &tailHub{}
+}
+
+// open claims a session slot, or reports false when maxTails are open.
+func (t *tailHub) open() bool {
+	if t.n.Add(1) > maxTails {
+		t.n.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (t *tailHub) close() {
+	t.n.Add(-1)
+}
+
+// GET /v1/tail?actor_id=X&scenario_id=Y&duration=10m — stream every decision
+// for an actor and/or scenario as it happens, for a bounded time. SSE by
+// default; ?stream=ndjson is also accepted. Payload values are redacted
+// unless ?payload=full.
+func (h *Handler) tail(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sub := &tailSub{actorID: q.Get("actor_id"), scenarioID: q.Get("scenario_id"), ch: make(chan tailRecord, tailBuffer)}
+	switch p := q.Get("payload"); p {
+	case "", "redacted":
+	case "full":
+		sub.fullPayload = true
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid payload %q (want redacted or full)", p))
+		return
+	}
+	if sub.actorID == "" && sub.scenarioID == "" {
+		writeError(w, http.StatusBadRequest, "actor_id or scenario_id is required")
+		return
+	}
+	d := defaultTailDuration
+	if s := q.Get("duration"); s != "" {
+		var err error
+		if d, err = duration.Parse(s); err != nil || d <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid duration %q", s))
+			return
+		}
+		d = min(d, maxTailDuration)
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusNotAcceptable, "streaming not supported by this connection")
+		return
+	}
+	if !h.tails.open() {
+		writeError(w, http.StatusTooManyRequests, fmt.Sprintf("too many tail sessions (max %d)", maxTails))
+		return
+	}
+	defer h.tails.close()
+	defer h.eng.WatchEvents(sub.publish)()
+
+	// The server's WriteTimeout would cut the stream short.
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + tailHeartbeat))
+
+	format := streamFormat(r)
+	if format == "" {
+		format = streamSSE
+	}
+	sw := &streamWriter{w: w, flusher: flusher, format: format}
+	payload := "redacted"
+	if sub.fullPayload {
+		payload = "full"
+	}
+	sw.send("start", map[string]interface{}{
+		"actor_id":    sub.actorID,
+		"scenario_id": sub.scenarioID,
+		"until":       time.Now().Add(d).UTC(),
+		"payload":     payload,
+	})
+
+	deadline := time.NewTimer(d)
+	defer deadline.Stop()
+	heartbeat := time.NewTicker(tailHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case rec := <-sub.ch:
+			sw.send("decision", rec)
+		case <-heartbeat.C:
+			sw.send("heartbeat", map[string]interface{}{"dropped": sub.dropped.Load()})
+		case <-deadline.C:
+			sw.send("end", map[string]interface{}{"reason": "duration elapsed", "dropped": sub.dropped.Load()})
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
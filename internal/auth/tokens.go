@@ -0,0 +1,143 @@
+// Package auth holds the API tokens that ingestion clients present. Each
+// token is scoped to the event sources and types one integration may send,
+// so a leaked or misconfigured client cannot pass itself off as another
+// system. Tokens can also belong to teams, which may change the scenarios
+// they own. Only SHA-256 hashes of the secrets are stored.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// tokenPrefix marks secrets issued by Generate so they are easy to spot in
+// logs and secret scanners.
+const tokenPrefix = "ff_"
+
+// Token roles. RoleAdmin lets a token change every scenario, owned or not,
+// and use the admin routes. RolePeer lets another region of an active-active
+// deployment read this region's action-limit counters.
+const (
+	RoleAdmin = "admin"
+	RolePeer  = "peer"
+)
+
+// Token is one issued API token and its scope. Empty Sources or EventTypes
+// allow any value. Teams and Role govern rule changes: a token may change
+// the scenarios owned by one of its teams, or any scenario with RoleAdmin.
+type Token struct {
+	Name       string   `yaml:"name"`
+	Hash       string   `yaml:"sha256"` // hex SHA-256 of the secret
+	Sources    []string `yaml:"sources,omitempty"`
+	EventTypes []string `yaml:"event_types,omitempty"`
+	Teams      []string `yaml:"teams,omitempty"`
+	Role       string   `yaml:"role,omitempty"`
+}
+
+// Allows reports whether the token may send an event with source and typ.
+func (t *Token) Allows(source, typ string) bool {
+	return (len(t.Sources) == 0 || slices.Contains(t.Sources, source)) &&
+		(len(t.EventTypes) == 0 || slices.Contains(t.EventTypes, typ))
+}
+
+// MayChange reports whether the token may change a scenario owned by owner.
+// Scenarios without an owner can only be changed by admins.
+func (t *Token) MayChange(owner string) bool {
+	return t.Role == RoleAdmin || (owner != "" && slices.Contains(t.Teams, owner))
+}
+
+// DefaultSource returns the token's only source, which events that omit
+// their source are attributed to, or "" if the token allows several.
+func (t *Token) DefaultSource() string {
+	if len(t.Sources) == 1 {
+		return t.Sources[0]
+	}
+	return ""
+}
+
+// Tokens is the set of accepted tokens, keyed by secret hash.
+type Tokens struct {
+	byHash map[string]*Token
+}
+
+type tokensFile struct {
+	Tokens []Token `yaml:"tokens"`
+}
+
+// Load reads a tokens file:
+//
+//	tokens:
+//	  - name: billing
+//	    sha256: 9f86d0…
+//	    sources: [billing-service]
+//	    event_types: [transaction, refund]
+//	  - name: growth-team
+//	    sha256: 2c26b4…
+//	    teams: [growth]
+//	  - name: oncall
+//	    sha256: fcde2b…
+//	    role: admin
+func Load(path string) (*Tokens, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read tokens %s: %w", path, err)
+	}
+	var f tokensFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse tokens %s: %w", path, err)
+	}
+	ts := &Tokens{byHash: make(map[string]*Token, len(f.Tokens))}
+	names := make(map[string]bool, len(f.Tokens))
+	for i := range f.Tokens {
+		t := &f.Tokens[i]
+		switch {
+		case t.Name == "":
+			return nil, fmt.Errorf("tokens %s: tokens[%d]: name is required", path, i)
+		case names[t.Name]:
+			return nil, fmt.Errorf("tokens %s: duplicate token name %q", path, t.Name)
+		case len(t.Hash) != sha256.Size*2:
+			return nil, fmt.Errorf("tokens %s: token %s: sha256 must be %d hex characters", path, t.Name, sha256.Size*2)
+		case t.Role != "" && t.Role != RoleAdmin && t.Role != RolePeer:
+			return nil, fmt.Errorf("tokens %s: token %s: unknown role %q (expected %s or %s)", path, t.Name, t.Role, RoleAdmin, RolePeer)
+		}
+		if _, err := hex.DecodeString(t.Hash); err != nil {
+			return nil, fmt.Errorf("tokens %s: token %s: sha256 is not hex", path, t.Name)
+		}
+		names[t.Name] = true
+		ts.byHash[t.Hash] = t
+	}
+	return ts, nil
+}
+
+// Lookup returns the token whose hash matches secret.
+func (ts *Tokens) Lookup(secret string) (*Token, bool) {
+	if secret == "" {
+		return nil, false
+	}
+	t, ok := ts.byHash[Hash(secret)]
+	return t, ok
+}
+
+// Len returns the number of tokens.
+func (ts *Tokens) Len() int { return len(ts.byHash) }
+
+// Hash returns the hex SHA-256 of secret, the form stored in the tokens file.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Generate returns a new random secret.
+func Generate() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	return tokenPrefix + hex.EncodeToString(b), nil
+}
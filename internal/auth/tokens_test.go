@@ -0,0 +1,87 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/auth"
+)
+
+func TestTokens_LookupAndScope(t *testing.T) {
+	secret, err := auth.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	body := "tokens:\n" +
+		"  - name: billing\n" +
+		"    sha256: " + auth.Hash(secret) + "\n" +
+		"    sources: [billing-service]\n" +
+		"    event_types: [transaction]\n"
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ts, err := auth.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if _, ok := ts.Lookup("ff_wrong"); ok {
+		t.Error("expected unknown secret to be rejected")
+	}
+	tok, ok := ts.Lookup(secret)
+	if !ok || tok.Name != "billing" {
+		t.Fatalf("expected billing token, got %v %v", tok, ok)
+	}
+	if !tok.Allows("billing-service", "transaction") {
+		t.Error("expected in-scope event to be allowed")
+	}
+	if tok.Allows("auth-service", "transaction") || tok.Allows("billing-service", "login") {
+		t.Error("expected other sources and types to be rejected")
+	}
+	if tok.DefaultSource() != "billing-service" {
+		t.Errorf("expected single source as default, got %q", tok.DefaultSource())
+	}
+}
+
+func TestLoad_RejectsBadEntries(t *testing.T) {
+	dir := t.TempDir()
+	for name, body := range map[string]string{
+		"no_name":  "tokens:\n  - sha256: " + auth.Hash("x") + "\n",
+		"bad_hash": "tokens:\n  - name: a\n    sha256: abc\n",
+		"dup_name": "tokens:\n  - name: a\n    sha256: " + auth.Hash("x") + "\n  - name: a\n    sha256: " + auth.Hash("y") + "\n",
+		"bad_role": "tokens:\n  - name: a\n    sha256: " + auth.Hash("x") + "\n    role: owner\n",
+	} {
+		path := filepath.Join(dir, name+".yaml")
+		if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := auth.Load(path); err == nil {
+			t.Errorf("%s: expected load error", name)
+		}
+	}
+}
+
+func TestToken_MayChange(t *testing.T) {
+	growth := &auth.Token{Name: "growth", Teams: []string{"growth"}}
+	admin := &auth.Token{Name: "oncall", Role: auth.RoleAdmin}
+	ingest := &auth.Token{Name: "billing", Sources: []string{"billing-service"}}
+
+	for _, c := range []struct {
+		tok   *auth.Token
+		owner string
+		want  bool
+	}{
+		{growth, "growth", true},
+		{growth, "payments", false},
+		{growth, "", false},
+		{admin, "payments", true},
+		{admin, "", true},
+		{ingest, "growth", false},
+	} {
+		if got := c.tok.MayChange(c.owner); got != c.want {
+			t.Errorf("%s.MayChange(%q) = %v, want %v", c.tok.Name, c.owner, got, c.want)
+		}
+	}
+}
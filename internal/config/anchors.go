@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// aliasedIDs records, for every id that data reaches only through a YAML
+// alias (*name) or merge key (<<: *name), the alias that copied it. Anchors
+// are expanded by the decoder, so a shared block that carries an id turns
+// into a duplicate id; Validate uses these origins to say where the copy
+// came from instead of reporting the same location twice.
+//
+// A merged block whose id is overridden next to the merge key is recorded
+// too, but the override leaves no duplicate to annotate.
+func aliasedIDs(file string, data []byte, into map[string]string) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parse config %s: %w", file, err)
+	}
+	expanding := make(map[*yaml.Node]bool) // guards against self-referencing anchors
+	var walk func(n *yaml.Node, via string)
+	walk = func(n *yaml.Node, via string) {
+		switch n.Kind {
+		case yaml.DocumentNode, yaml.SequenceNode:
+			for _, c := range n.Content {
+				walk(c, via)
+			}
+		case yaml.AliasNode:
+			if n.Alias == nil || expanding[n.Alias] {
+				return
+			}
+			if via == "" {
+				via = fmt.Sprintf("alias *%s at %s:%d (anchor at line %d)", n.Value, file, n.Line, n.Alias.Line)
+			}
+			expanding[n.Alias] = true
+			walk(n.Alias, via)
+			delete(expanding, n.Alias)
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k, v := n.Content[i], n.Content[i+1]
+				if via != "" && k.Value == "id" && v.Kind == yaml.ScalarNode {
+					if _, ok := into[v.Value]; !ok {
+						into[v.Value] = via
+					}
+				}
+				walk(v, via)
+			}
+		}
+	}
+	walk(&doc, "")
+	return nil
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// AvailableCPUs returns the number of CPUs this process can actually use:
+// GOMAXPROCS, further limited by a container CPU quota (cgroup v2 or v1).
+func AvailableCPUs() int {
+	n := runtime.GOMAXPROCS(0)
+	if q := cgroupCPUs(); q > 0 && q < n {
+		n = q
+	}
+	return max(n, 1)
+}
+
+// cgroupCPUs returns the CPU quota rounded up to whole CPUs, or 0 if unlimited/unknown.
+func cgroupCPUs() int {
+	if b, err := os.ReadFile("/sys/fs/cgroup/cpu.max"); err == nil {
+		return parseCPUMax(string(b))
+	}
+	quota, err1 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	period, err2 := os.ReadFile("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return parseCPUMax(strings.TrimSpace(string(quota)) + " " + strings.TrimSpace(string(period)))
+}
+
+// parseCPUMax parses "<quota> <period>" as found in cgroup v2 cpu.max.
+// A quota of "max" or -1 means unlimited.
+func parseCPUMax(s string) int {
+	fields := strings.Fields(s)
+	if len(fields) != 2 || fields[0] == "max" {
+		return 0
+	}
+	quota, err1 := strconv.ParseFloat(fields[0], 64)
+	period, err2 := strconv.ParseFloat(fields[1], 64)
+	if err1 != nil || err2 != nil || quota <= 0 || period <= 0 {
+		return 0
+	}
+	return int(math.Ceil(quota / period))
+}
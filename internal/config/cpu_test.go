@@ -0,0 +1,18 @@
+package config
+
+import "testing"
+
+func TestParseCPUMax(t *testing.T) {
+	for in, want := range map[string]int{
+		"max 100000":    0,
+		"200000 100000": 2,
+		"150000 100000": 2,
+		"50000 100000":  1,
+		"-1 100000":     0,
+		"garbage":       0,
+	} {
+		if got := parseCPUMax(in); got != want {
+			t.Errorf("parseCPUMax(%q) = %d, want %d", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/duration"
+)
+
+// Millis is a millisecond setting. In YAML it may be a plain number of
+// milliseconds or a duration string such as "250ms", "5m" or "7d".
+type Millis int
+
+// Duration returns m as a time.Duration.
+func (m Millis) Duration() time.Duration { return time.Duration(m) * time.Millisecond }
+
+func (m *Millis) UnmarshalYAML(n *yaml.Node) error {
+	d, err := decodeDuration(n, time.Millisecond)
+	if err != nil {
+		return err
+	}
+	*m = Millis(d / time.Millisecond)
+	return nil
+}
+
+// Seconds is a second setting. In YAML it may be a plain number of seconds
+// or a duration string such as "90s", "15m" or "1d".
+type Seconds int
+
+// Duration returns s as a time.Duration.
+func (s Seconds) Duration() time.Duration { return time.Duration(s) * time.Second }
+
+func (s *Seconds) UnmarshalYAML(n *yaml.Node) error {
+	d, err := decodeDuration(n, time.Second)
+	if err != nil {
+		return err
+	}
+	*s = Seconds(d / time.Second)
+	return nil
+}
+
+// decodeDuration reads a bare number (in unit) or a duration string.
+func decodeDuration(n *yaml.Node, unit time.Duration) (time.Duration, error) {
+	if n.Kind != yaml.ScalarNode {
+		return 0, fmt.Errorf("line %d: expected a duration, got %s", n.Line, n.Tag)
+	}
+	if n.Tag == "!!int" {
+		var v int64
+		if err := n.Decode(&v); err != nil {
+			return 0, err
+		}
+		return time.Duration(v) * unit, nil
+	}
+	d, err := duration.Parse(n.Value)
+	if err != nil {
+		return 0, fmt.Errorf("line %d: %w", n.Line, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("line %d: duration %q must not be negative", n.Line, n.Value)
+	}
+	if d%unit != 0 {
+		return 0, fmt.Errorf("line %d: duration %q is finer than %v", n.Line, n.Value, unit)
+	}
+	return d, nil
+}
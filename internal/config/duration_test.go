@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestDurationFields(t *testing.T) {
+	var conf struct {
+		Engine  EngineConf  `yaml:"engine"`
+		Anomaly AnomalyConf `yaml:"anomaly"`
+	}
+	err := yaml.Unmarshal([]byte(`
+engine:
+  event_timeout_ms: 5s
+  breaker_cooldown_ms: 2m
+  action_timeout_ms: 750
+anomaly:
+  window_sec: 1d
+`), &conf)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if conf.Engine.EventTimeoutMs != 5000 || conf.Engine.BreakerCooldownMs.Duration() != 2*time.Minute || conf.Engine.ActionTimeoutMs != 750 {
+		t.Errorf("unexpected engine durations: %+v", conf.Engine)
+	}
+	if conf.Anomaly.WindowSec != 86400 {
+		t.Errorf("expected window_sec 1d = 86400, got %d", conf.Anomaly.WindowSec)
+	}
+
+	for _, bad := range []string{"engine: {event_timeout_ms: 5 minutes}", "engine: {event_timeout_ms: -1s}", "anomaly: {window_sec: 1500ms}"} {
+		if err := yaml.Unmarshal([]byte(bad), &conf); err == nil {
+			t.Errorf("expected error for %q", bad)
+		}
+	}
+}
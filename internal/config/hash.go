@@ -0,0 +1,19 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Hash returns a stable content hash of cfg. Map keys are sorted by
+// encoding/json, so two semantically identical configs hash the same
+// regardless of YAML key order.
+func Hash(cfg *RuleConfig) string {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -2,25 +2,38 @@ package config
 
 import (
 	"fmt"
-	"os"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"gopkg.in/yaml.v3"
 )
 
-// Loader reads a YAML config file and watches it for changes.
+// Loader reads a YAML config file, applies any overlay files on top of it,
+// and watches all of them for changes.
 type Loader struct {
 	path     string
+	overlays []string // applied in order; later overlays win
 	mu       sync.RWMutex
 	current  *RuleConfig
 	onChange []func(*RuleConfig)
 	watcher  *fsnotify.Watcher
+
+	overrides     map[string]override // scenario id → enabled flag and archive, set at runtime
+	overridesPath string              // "" = overrides are not persisted
+
+	cachePath     string        // last-known-good copy (StartupPolicy.CachePath); "" = none
+	fromCache     bool          // current config came from cachePath, not the files
+	recoverEvery  time.Duration // retry interval after a start from the cache (Watch)
+	onWatchError  func(error)   // StartupPolicy.OnWatchError
+	recovered     chan struct{} // closed once the files load again after a start from the cache
+	recoveredOnce sync.Once     // guards close(recovered)
 }
 
 // NewLoader creates a Loader and performs the initial load.
-func NewLoader(path string) (*Loader, error) {
-	l := &Loader{path: path}
+// Overlays patch the base file in the given order (see mergeNode).
+func NewLoader(path string, overlays ...string) (*Loader, error) {
+	l := &Loader{path: path, overlays: overlays}
 	cfg, err := l.load()
 	if err != nil {
 		return nil, err
@@ -44,51 +57,77 @@ func (l *Loader) OnChange(fn func(*RuleConfig)) {
 }
 
 // Watch starts a background goroutine that hot-reloads the config on file changes.
-// Call the returned stop function to clean up.
+// Call the returned stop function to clean up. After a start from the
+// last-known-good cache, Watch also starts retrying the files, so register
+// OnChange callbacks first; watching begins once the files load again.
 func (l *Loader) Watch() (stop func(), err error) {
+	done := make(chan struct{})
+	if l.recovered != nil && l.FromCache() {
+		go l.recover(l.recoverEvery, done)
+		go func() {
+			select {
+			case <-l.recovered:
+			case <-done:
+				return
+			}
+			w, err := l.newWatcher()
+			if err != nil {
+				if l.onWatchError != nil {
+					l.onWatchError(err)
+				}
+				return
+			}
+			l.watch(w, done)
+		}()
+		return func() { close(done) }, nil
+	}
+	w, err := l.newWatcher()
+	if err != nil {
+		return nil, err
+	}
+	go l.watch(w, done)
+	return func() { close(done) }, nil
+}
+
+func (l *Loader) newWatcher() (*fsnotify.Watcher, error) {
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, fmt.Errorf("config watcher: %w", err)
 	}
-	if err := w.Add(l.path); err != nil {
-		w.Close()
-		return nil, fmt.Errorf("config watcher add %s: %w", l.path, err)
+	for _, p := range append([]string{l.path}, l.overlays...) {
+		if err := w.Add(p); err != nil {
+			w.Close()
+			return nil, fmt.Errorf("config watcher add %s: %w", p, err)
+		}
 	}
-	l.watcher = w
+	return w, nil
+}
 
-	done := make(chan struct{})
-	go func() {
-		defer w.Close()
-		for {
-			select {
-			case ev, ok := <-w.Events:
-				if !ok {
-					return
-				}
-				if ev.Has(fsnotify.Write) || ev.Has(fsnotify.Create) {
-					cfg, err := l.load()
-					if err != nil {
-						// Log and continue with old config.
-						continue
-					}
-					l.mu.Lock()
-					l.current = cfg
-					callbacks := make([]func(*RuleConfig), len(l.onChange))
-					copy(callbacks, l.onChange)
-					l.mu.Unlock()
-					for _, fn := range callbacks {
-						fn(cfg)
-					}
-				}
-			case <-w.Errors:
-				// Ignore watcher errors.
-			case <-done:
+func (l *Loader) watch(w *fsnotify.Watcher, done <-chan struct{}) {
+	l.mu.Lock()
+	l.watcher = w
+	l.mu.Unlock()
+	defer w.Close()
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
 				return
 			}
+			if ev.Has(fsnotify.Write) || ev.Has(fsnotify.Create) {
+				cfg, err := l.load()
+				if err != nil {
+					// Log and continue with old config.
+					continue
+				}
+				l.apply(cfg)
+			}
+		case <-w.Errors:
+			// Ignore watcher errors.
+		case <-done:
+			return
 		}
-	}()
-
-	return func() { close(done) }, nil
+	}
 }
 
 // Reload forces an immediate re-read of the config file.
@@ -97,32 +136,56 @@ func (l *Loader) Reload() (*RuleConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	return l.apply(cfg), nil
+}
+
+// apply makes cfg, freshly loaded from the files, current and runs the
+// OnChange callbacks.
+func (l *Loader) apply(cfg *RuleConfig) *RuleConfig {
 	l.mu.Lock()
+	cfg = applyOverrides(cfg, l.overrides)
 	l.current = cfg
+	l.fromCache = false
 	callbacks := make([]func(*RuleConfig), len(l.onChange))
 	copy(callbacks, l.onChange)
 	l.mu.Unlock()
+	if l.recovered != nil {
+		l.recoveredOnce.Do(func() { close(l.recovered) })
+	}
 	for _, fn := range callbacks {
 		fn(cfg)
 	}
-	return cfg, nil
+	return cfg
 }
 
 func (l *Loader) load() (*RuleConfig, error) {
-	data, err := os.ReadFile(l.path)
+	data, aliased, err := readLayers(l.path, l.overlays)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parse(l.path, data)
 	if err != nil {
-		return nil, fmt.Errorf("read config %s: %w", l.path, err)
+		return nil, err
 	}
+	cfg.aliased = aliased
+	return cfg, nil
+}
+
+// parse decodes a config read from source and applies defaults.
+func parse(source string, data []byte) (*RuleConfig, error) {
 	var cfg RuleConfig
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, fmt.Errorf("parse config %s: %w", l.path, err)
+		return nil, fmt.Errorf("parse config %s: %w", source, err)
 	}
-	// Apply defaults.
+	cfg.raw = data
+	// Apply defaults. Worker counts scale with usable CPUs (4× and 2×, which
+	// gives 32/16 on an 8-CPU host) so small pods are not oversubscribed.
+	cpus := AvailableCPUs()
 	if cfg.Engine.EventWorkers == 0 {
-		cfg.Engine.EventWorkers = 32
+		cfg.Engine.EventWorkers = max(4, 4*cpus)
 	}
 	if cfg.Engine.ActionWorkers == 0 {
-		cfg.Engine.ActionWorkers = 16
+		cfg.Engine.ActionWorkers = max(2, 2*cpus)
 	}
 	if cfg.Engine.QueueDepth == 0 {
 		cfg.Engine.QueueDepth = 10000
@@ -130,5 +193,99 @@ func (l *Loader) load() (*RuleConfig, error) {
 	if cfg.Engine.EventTimeoutMs == 0 {
 		cfg.Engine.EventTimeoutMs = 5000
 	}
+	if cfg.Engine.OverflowPolicy == "" {
+		cfg.Engine.OverflowPolicy = OverflowReject
+	}
+	if cfg.Engine.ShedMode == "" {
+		cfg.Engine.ShedMode = ShedSkip
+	}
+	if cfg.Engine.MaxPayloadBytes == 0 {
+		cfg.Engine.MaxPayloadBytes = 1 << 20
+	}
+	if cfg.Engine.MaxPayloadDepth == 0 {
+		cfg.Engine.MaxPayloadDepth = 32
+	}
+	if cfg.Engine.MaxMatchInput == 0 {
+		cfg.Engine.MaxMatchInput = 64 << 10
+	}
+	if cfg.Engine.MaxResults == 0 {
+		cfg.Engine.MaxResults = 1000
+	}
+	if cfg.Engine.RecentResults == 0 {
+		cfg.Engine.RecentResults = 1000
+	}
+	if cfg.Engine.StreakRetentionMs == 0 {
+		cfg.Engine.StreakRetentionMs = Millis(90 * 24 * time.Hour / time.Millisecond)
+	}
+	if cfg.Engine.CounterStrategy == "" {
+		cfg.Engine.CounterStrategy = "local"
+	}
+	if cfg.Engine.CounterSyncMs == 0 {
+		cfg.Engine.CounterSyncMs = 1000
+	}
+	if cfg.Engine.ActorCacheTTLMs == 0 {
+		cfg.Engine.ActorCacheTTLMs = 5000
+	}
+	if cfg.Engine.ActorCacheSize == 0 {
+		cfg.Engine.ActorCacheSize = 100000
+	}
+	if cfg.Engine.TenantCacheTTLMs == 0 {
+		cfg.Engine.TenantCacheTTLMs = 60000
+	}
+	if cfg.Engine.TenantCacheSize == 0 {
+		cfg.Engine.TenantCacheSize = 10000
+	}
+	if cfg.Messages.DefaultLocale == "" {
+		cfg.Messages.DefaultLocale = "en"
+	}
+	for i := range cfg.Monitors {
+		if cfg.Monitors[i].IntervalMs == 0 {
+			cfg.Monitors[i].IntervalMs = 60000
+		}
+	}
+	if cfg.Anomaly.WindowSec == 0 {
+		cfg.Anomaly.WindowSec = 60
+	}
+	if cfg.Anomaly.Factor == 0 {
+		cfg.Anomaly.Factor = 5
+	}
+	if cfg.Anomaly.Alpha == 0 {
+		cfg.Anomaly.Alpha = 0.2
+	}
+	if cfg.Anomaly.MinEvents == 0 {
+		cfg.Anomaly.MinEvents = 100
+	}
+	if cfg.Anomaly.WarmupWindows == 0 {
+		cfg.Anomaly.WarmupWindows = 5
+	}
+	if cfg.Drift.SampleRate == 0 {
+		cfg.Drift.SampleRate = 0.1
+	}
+	if cfg.Drift.WindowSec == 0 {
+		cfg.Drift.WindowSec = 300
+	}
+	if cfg.Drift.MinSamples == 0 {
+		cfg.Drift.MinSamples = 50
+	}
+	if cfg.Drift.Threshold == 0 {
+		cfg.Drift.Threshold = 0.2
+	}
+	if cfg.Drift.Alpha == 0 {
+		cfg.Drift.Alpha = 0.3
+	}
+	if (cfg.Engine.BreakerThreshold > 0 || cfg.Engine.BreakOnPanic) && cfg.Engine.BreakerCooldownMs == 0 {
+		cfg.Engine.BreakerCooldownMs = 30000
+	}
+	if cfg.Engine.ActionErrorBudget > 0 {
+		if cfg.Engine.ActionErrorBudgetWindowMs == 0 {
+			cfg.Engine.ActionErrorBudgetWindowMs = 60000
+		}
+		if cfg.Engine.ActionErrorBudgetMinCalls == 0 {
+			cfg.Engine.ActionErrorBudgetMinCalls = 20
+		}
+		if cfg.Engine.ActionProbeIntervalMs == 0 {
+			cfg.Engine.ActionProbeIntervalMs = 30000
+		}
+	}
 	return &cfg, nil
 }
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvOverlayPath returns the conventional overlay file for env next to base,
+// e.g. configs/rules.yaml + "staging" → configs/rules.staging.yaml.
+func EnvOverlayPath(base, env string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + env + ext
+}
+
+// Render marshals cfg back to YAML, e.g. to show the effective merged config.
+// Anchors, aliases and merge keys come out expanded, so the result shows
+// exactly what the engine and Validate see.
+func Render(cfg *RuleConfig) ([]byte, error) {
+	return yaml.Marshal(cfg)
+}
+
+// readLayers reads base followed by each overlay and merges them in order;
+// later files take precedence. The merged document is returned as YAML,
+// together with the ids that any layer copied through an alias (see aliasedIDs).
+// Anchors are resolved per file, so an overlay cannot alias a block in base.
+func readLayers(base string, overlays []string) ([]byte, map[string]string, error) {
+	data, err := os.ReadFile(base)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read config %s: %w", base, err)
+	}
+	aliased := make(map[string]string)
+	if err := aliasedIDs(base, data, aliased); err != nil {
+		return nil, nil, err
+	}
+	if len(overlays) == 0 {
+		return data, aliased, nil
+	}
+	var merged interface{}
+	if err := yaml.Unmarshal(data, &merged); err != nil {
+		return nil, nil, fmt.Errorf("parse config %s: %w", base, err)
+	}
+	for _, path := range overlays {
+		od, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read overlay %s: %w", path, err)
+		}
+		if err := aliasedIDs(path, od, aliased); err != nil {
+			return nil, nil, err
+		}
+		var overlay interface{}
+		if err := yaml.Unmarshal(od, &overlay); err != nil {
+			return nil, nil, fmt.Errorf("parse overlay %s: %w", path, err)
+		}
+		merged = mergeNode(merged, overlay)
+	}
+	out, err := yaml.Marshal(merged)
+	return out, aliased, err
+}
+
+// mergeNode patches base with overlay:
+//   - mappings merge key by key, recursively
+//   - sequences whose items all carry an id (scenarios, children) merge by id;
+//     unmatched overlay items are appended
+//   - anything else in the overlay replaces the base value
+func mergeNode(base, overlay interface{}) interface{} {
+	switch o := overlay.(type) {
+	case map[string]interface{}:
+		b, ok := base.(map[string]interface{})
+		if !ok {
+			return o
+		}
+		for k, v := range o {
+			b[k] = mergeNode(b[k], v)
+		}
+		return b
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok || !allKeyed(b) || !allKeyed(o) {
+			return o
+		}
+		index := make(map[string]int, len(b))
+		for i, item := range b {
+			index[itemID(item)] = i
+		}
+		for _, item := range o {
+			if i, ok := index[itemID(item)]; ok {
+				b[i] = mergeNode(b[i], item)
+			} else {
+				b = append(b, item)
+			}
+		}
+		return b
+	case nil:
+		return base
+	default:
+		return o
+	}
+}
+
+func allKeyed(items []interface{}) bool {
+	for _, item := range items {
+		if itemID(item) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// itemID returns the id of a scenario ({id: …}) or a node ref
+// ({condition: {id: …}} / {action: {id: …}}), or "" if it has none.
+func itemID(item interface{}) string {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if id, ok := m["id"].(string); ok {
+		return id
+	}
+	for _, kind := range []string{"condition", "action"} {
+		if inner, ok := m[kind].(map[string]interface{}); ok {
+			if id, ok := inner["id"].(string); ok {
+				return kind + ":" + id
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoader_OverlayPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "rules.yaml", `
+version: v1
+engine:
+  event_workers: 8
+scenarios:
+  - id: sc_a
+    enabled: true
+    event_types: [login]
+    children:
+      - condition:
+          id: cond_a
+          expression: "payload.amount > 1000"
+  - id: sc_b
+    enabled: true
+    event_types: [login]
+`)
+	writeFile(t, dir, "rules.staging.yaml", `
+engine:
+  event_workers: 2
+scenarios:
+  - id: sc_b
+    enabled: false
+  - id: sc_a
+    children:
+      - condition:
+          id: cond_a
+          expression: "payload.amount > 10"
+`)
+	extra := writeFile(t, dir, "extra.yaml", `
+engine:
+  event_workers: 3
+`)
+
+	l, err := NewLoader(base, EnvOverlayPath(base, "staging"), extra)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	cfg := l.Config()
+	if cfg.Engine.EventWorkers != 3 {
+		t.Errorf("expected last overlay to win (3 workers), got %d", cfg.Engine.EventWorkers)
+	}
+	if len(cfg.Scenarios) != 2 || cfg.Scenarios[0].ID != "sc_a" {
+		t.Fatalf("expected scenarios merged by id in base order, got %+v", cfg.Scenarios)
+	}
+	if cfg.Scenarios[1].Enabled {
+		t.Errorf("expected sc_b disabled by overlay")
+	}
+	if got := cfg.Scenarios[0].Children[0].Condition.Expression; got != "payload.amount > 10" {
+		t.Errorf("expected patched expression, got %q", got)
+	}
+	if len(cfg.Scenarios[0].EventTypes) != 1 {
+		t.Errorf("expected untouched fields to survive the merge, got %v", cfg.Scenarios[0].EventTypes)
+	}
+}
+
+func TestLoader_AnchorsAndMergeKeys(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "rules.yaml", `
+version: v1
+templates:
+  bonus: &bonus
+    type: reward_points
+    params: {operation: award, points: 10}
+scenarios:
+  - id: sc_a
+    enabled: true
+    event_types: [purchase]
+    children:
+      - action:
+          <<: *bonus
+          id: act_bonus_a
+      - action: &notify {id: act_notify, type: log, params: {message: hi}}
+  - id: sc_b
+    enabled: true
+    event_types: [purchase]
+    children:
+      - action:
+          <<: *bonus
+          id: act_bonus_b
+      - action: *notify
+`)
+	l, err := NewLoader(base)
+	if err != nil {
+		t.Fatalf("NewLoader: %v", err)
+	}
+	cfg := l.Config()
+	a := cfg.Scenarios[1].Children[0].Action
+	if a.ID != "act_bonus_b" || a.Type != "reward_points" || a.Params["points"] != 10 {
+		t.Errorf("expected merge key to fill type and params, got %+v", a)
+	}
+
+	err = Validate(cfg)
+	if err == nil {
+		t.Fatal("expected duplicate id from the aliased block")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, `duplicate id "act_notify"`) || !strings.Contains(msg, "alias *notify at "+base+":23") {
+		t.Errorf("expected duplicate to name the alias, got %v", msg)
+	}
+	if strings.Contains(msg, "act_bonus") {
+		t.Errorf("expected overridden ids to validate, got %v", msg)
+	}
+}
@@ -0,0 +1,274 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrUnknownScenario is returned when toggling a scenario that is not in the config.
+var ErrUnknownScenario = errors.New("unknown scenario")
+
+// ErrScenarioArchived is returned when toggling or archiving a scenario that
+// is already archived, and ErrScenarioNotArchived when restoring one that is
+// not archived through the API.
+var (
+	ErrScenarioArchived    = errors.New("scenario is archived")
+	ErrScenarioNotArchived = errors.New("scenario is not archived")
+)
+
+// ErrChangeRejected wraps the error of a check that refused a scenario
+// toggle, archive or restore; nothing was changed.
+var ErrChangeRejected = errors.New("change rejected")
+
+// maxArchiveHistory bounds the archive and restore events kept per scenario.
+const maxArchiveHistory = 50
+
+// override is the runtime state of one scenario set on top of the files.
+type override struct {
+	enabled  *bool
+	archived *ArchiveInfo
+	history  []ArchiveEvent // oldest first
+}
+
+// empty reports whether o changes nothing and records nothing.
+func (o override) empty() bool {
+	return o.enabled == nil && o.archived == nil && len(o.history) == 0
+}
+
+// record appends ev to o's history, dropping the oldest events past
+// maxArchiveHistory. The slice is copied, so clones of the overrides map
+// never share a backing array.
+func (o *override) record(ev ArchiveEvent) {
+	h := append(append(make([]ArchiveEvent, 0, len(o.history)+1), o.history...), ev)
+	if len(h) > maxArchiveHistory {
+		h = h[len(h)-maxArchiveHistory:]
+	}
+	o.history = h
+}
+
+// SetOverridesPath makes runtime overrides persistable to path. Existing
+// overrides in the file are loaded immediately and applied to the current config.
+// The file uses the overlay format, so it can also be passed to -overlay.
+func (l *Loader) SetOverridesPath(path string) error {
+	overrides := make(map[string]override)
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("read overrides %s: %w", path, err)
+	default:
+		var doc overrideDoc
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parse overrides %s: %w", path, err)
+		}
+		for _, e := range doc.Scenarios {
+			overrides[e.ID] = override{enabled: e.Enabled, archived: e.Archived, history: e.History}
+		}
+	}
+	l.mu.Lock()
+	l.overridesPath = path
+	l.overrides = overrides
+	l.current = applyOverrides(l.current, overrides)
+	l.mu.Unlock()
+	return nil
+}
+
+// SetScenarioEnabled overrides one scenario's enabled flag on top of the
+// files. The override survives hot-reloads; with persist it is also written
+// to the overrides file. OnChange callbacks receive the new config. An
+// archived scenario must be restored first.
+//
+// check, if not nil, is called with the new config before anything is
+// committed, typically to build its graph. If it fails, the current config
+// and the overrides file are left as they were and the error is returned
+// wrapped in ErrChangeRejected. The same goes for ArchiveScenario and
+// RestoreScenario.
+func (l *Loader) SetScenarioEnabled(id string, enabled, persist bool, check func(*RuleConfig) error) (*RuleConfig, error) {
+	return l.setOverride(id, persist, check, func(sc *Scenario, o *override) error {
+		if sc.Archived != nil {
+			return fmt.Errorf("%w %q; restore it first", ErrScenarioArchived, id)
+		}
+		o.enabled = &enabled
+		return nil
+	})
+}
+
+// ArchiveScenario takes a scenario out of the live graph, keeping its
+// definition and enabled flag for RestoreScenario. Like SetScenarioEnabled
+// it survives hot-reloads and, with persist, restarts; so does the event
+// recorded in ArchiveHistory.
+func (l *Loader) ArchiveScenario(id string, info ArchiveInfo, persist bool, check func(*RuleConfig) error) (*RuleConfig, error) {
+	return l.setOverride(id, persist, check, func(sc *Scenario, o *override) error {
+		if sc.Archived != nil {
+			return fmt.Errorf("%w %q", ErrScenarioArchived, id)
+		}
+		o.archived = &info
+		o.record(ArchiveEvent{Action: "archive", At: info.At, By: info.By, Reason: info.Reason})
+		return nil
+	})
+}
+
+// RestoreScenario undoes ArchiveScenario, returning the scenario to the live
+// graph as it was, and records info in ArchiveHistory. A scenario archived
+// in the rules files is restored by editing them.
+func (l *Loader) RestoreScenario(id string, info ArchiveInfo, persist bool, check func(*RuleConfig) error) (*RuleConfig, error) {
+	return l.setOverride(id, persist, check, func(sc *Scenario, o *override) error {
+		switch {
+		case sc.Archived == nil:
+			return fmt.Errorf("%w %q", ErrScenarioNotArchived, id)
+		case o.archived == nil:
+			return fmt.Errorf("%w %q through the API; it is archived in the rules file", ErrScenarioNotArchived, id)
+		}
+		o.archived = nil
+		o.record(ArchiveEvent{Action: "restore", At: info.At, By: info.By, Reason: info.Reason})
+		return nil
+	})
+}
+
+// ArchiveHistory returns the archive and restore events of scenario id made
+// through the Loader, oldest first. Events are kept after a restore, and
+// those persisted to the overrides file are reloaded at start-up.
+func (l *Loader) ArchiveHistory(id string) []ArchiveEvent {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.overrides[id].history
+}
+
+// setOverride changes scenario id's override with fn, checks the resulting
+// config, then persists and applies it and notifies OnChange callbacks. fn
+// sees the scenario as currently configured. check runs under l.mu, so it
+// must not call back into the Loader.
+func (l *Loader) setOverride(id string, persist bool, check func(*RuleConfig) error, fn func(*Scenario, *override) error) (*RuleConfig, error) {
+	l.mu.Lock()
+	var sc *Scenario
+	for i := range l.current.Scenarios {
+		if l.current.Scenarios[i].ID == id {
+			sc = &l.current.Scenarios[i]
+			break
+		}
+	}
+	if sc == nil {
+		l.mu.Unlock()
+		return nil, fmt.Errorf("%w %q", ErrUnknownScenario, id)
+	}
+	if persist && l.overridesPath == "" {
+		l.mu.Unlock()
+		return nil, fmt.Errorf("persistence requested but no overrides file is configured")
+	}
+	o := l.overrides[id]
+	if err := fn(sc, &o); err != nil {
+		l.mu.Unlock()
+		return nil, err
+	}
+	// The new overrides become current only once checked and persisted, so
+	// a config that does not build or a failed write leaves the running
+	// config and the file in agreement.
+	overrides := maps.Clone(l.overrides)
+	if overrides == nil {
+		overrides = make(map[string]override)
+	}
+	if o.empty() {
+		delete(overrides, id)
+	} else {
+		overrides[id] = o
+	}
+	cfg := applyOverrides(l.current, overrides)
+	if o.archived == nil && sc.Archived != nil {
+		// Restored: the archive came from the override, not the files.
+		cfg = withoutArchive(cfg, id)
+	}
+	if check != nil {
+		if err := check(cfg); err != nil {
+			l.mu.Unlock()
+			return nil, fmt.Errorf("%w: %w", ErrChangeRejected, err)
+		}
+	}
+	if persist {
+		if err := writeOverrides(l.overridesPath, overrides); err != nil {
+			l.mu.Unlock()
+			return nil, err
+		}
+	}
+	l.overrides = overrides
+	l.current = cfg
+	callbacks := make([]func(*RuleConfig), len(l.onChange))
+	copy(callbacks, l.onChange)
+	l.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(cfg)
+	}
+	return cfg, nil
+}
+
+// applyOverrides returns a copy of cfg with enabled flags and archives
+// replaced. Scenario children are shared with cfg; they are never mutated.
+func applyOverrides(cfg *RuleConfig, overrides map[string]override) *RuleConfig {
+	if cfg == nil || len(overrides) == 0 {
+		return cfg
+	}
+	out := *cfg
+	out.Scenarios = make([]Scenario, len(cfg.Scenarios))
+	copy(out.Scenarios, cfg.Scenarios)
+	for i := range out.Scenarios {
+		o, ok := overrides[out.Scenarios[i].ID]
+		if !ok {
+			continue
+		}
+		if o.enabled != nil {
+			out.Scenarios[i].Enabled = *o.enabled
+		}
+		if o.archived != nil {
+			out.Scenarios[i].Archived = o.archived
+		}
+	}
+	return &out
+}
+
+// withoutArchive returns a copy of cfg with scenario id's archive cleared.
+func withoutArchive(cfg *RuleConfig, id string) *RuleConfig {
+	out := *cfg
+	out.Scenarios = make([]Scenario, len(cfg.Scenarios))
+	copy(out.Scenarios, cfg.Scenarios)
+	for i := range out.Scenarios {
+		if out.Scenarios[i].ID == id {
+			out.Scenarios[i].Archived = nil
+		}
+	}
+	return &out
+}
+
+type overrideDoc struct {
+	Scenarios []overrideEntry `yaml:"scenarios"`
+}
+
+type overrideEntry struct {
+	ID       string         `yaml:"id"`
+	Enabled  *bool          `yaml:"enabled,omitempty"`
+	Archived *ArchiveInfo   `yaml:"archived,omitempty"`
+	History  []ArchiveEvent `yaml:"history,omitempty"` // ignored when the file is used as an overlay
+}
+
+func writeOverrides(path string, overrides map[string]override) error {
+	doc := overrideDoc{Scenarios: make([]overrideEntry, 0, len(overrides))}
+	for id, o := range overrides {
+		doc.Scenarios = append(doc.Scenarios, overrideEntry{ID: id, Enabled: o.enabled, Archived: o.archived, History: o.history})
+	}
+	sort.Slice(doc.Scenarios, func(i, j int) bool { return doc.Scenarios[i].ID < doc.Scenarios[j].ID })
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("encode overrides: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("write overrides %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write overrides %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoader_ArchiveAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "rules.yaml", `
+version: v1
+scenarios:
+  - id: sc_diwali
+    enabled: true
+    event_types: [purchase]
+  - id: sc_holi
+    enabled: false
+    event_types: [purchase]
+    archived:
+      at: 2025-03-20T00:00:00Z
+      reason: promo over
+`)
+	l, err := NewLoader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides := filepath.Join(dir, "overrides.yaml")
+	if err := l.SetOverridesPath(overrides); err != nil {
+		t.Fatal(err)
+	}
+	scenario := func(cfg *RuleConfig, id string) Scenario {
+		for _, sc := range cfg.Scenarios {
+			if sc.ID == id {
+				return sc
+			}
+		}
+		t.Fatalf("scenario %s missing", id)
+		return Scenario{}
+	}
+
+	if sc := scenario(l.Config(), "sc_holi"); sc.Archived == nil || sc.Archived.Reason != "promo over" {
+		t.Fatalf("archive from the file not loaded: %+v", sc.Archived)
+	}
+	if _, err := l.RestoreScenario("sc_holi", ArchiveInfo{}, false, nil); !errors.Is(err, ErrScenarioNotArchived) {
+		t.Errorf("restoring a file archive: %v, want ErrScenarioNotArchived", err)
+	}
+
+	at := time.Date(2026, 11, 5, 0, 0, 0, 0, time.UTC)
+	cfg, err := l.ArchiveScenario("sc_diwali", ArchiveInfo{At: at, By: "ops", Reason: "season over"}, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc := scenario(cfg, "sc_diwali"); sc.Archived == nil || !sc.Enabled {
+		t.Fatalf("archived scenario = %+v, want archived and still enabled", sc)
+	}
+	if _, err := l.SetScenarioEnabled("sc_diwali", false, false, nil); !errors.Is(err, ErrScenarioArchived) {
+		t.Errorf("toggling an archived scenario: %v, want ErrScenarioArchived", err)
+	}
+
+	// The archive survives a restart through the overrides file.
+	l2, err := NewLoader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.SetOverridesPath(overrides); err != nil {
+		t.Fatal(err)
+	}
+	if sc := scenario(l2.Config(), "sc_diwali"); sc.Archived == nil || !sc.Archived.At.Equal(at) || sc.Archived.By != "ops" {
+		t.Fatalf("persisted archive = %+v", sc.Archived)
+	}
+
+	cfg, err = l2.RestoreScenario("sc_diwali", ArchiveInfo{At: at.Add(time.Hour), By: "growth"}, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc := scenario(cfg, "sc_diwali"); sc.Archived != nil || !sc.Enabled {
+		t.Fatalf("restored scenario = %+v, want live and enabled", sc)
+	}
+	if cfg, err = l2.Reload(); err != nil || scenario(cfg, "sc_diwali").Archived != nil {
+		t.Fatalf("restore lost on reload: %v", err)
+	}
+	if _, err := l2.RestoreScenario("sc_diwali", ArchiveInfo{}, false, nil); !errors.Is(err, ErrScenarioNotArchived) {
+		t.Errorf("restoring twice: %v, want ErrScenarioNotArchived", err)
+	}
+
+	// The archive and the restore stay on record across another restart.
+	l3, err := NewLoader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l3.SetOverridesPath(overrides); err != nil {
+		t.Fatal(err)
+	}
+	h := l3.ArchiveHistory("sc_diwali")
+	if len(h) != 2 || h[0].Action != "archive" || h[0].By != "ops" || h[0].Reason != "season over" ||
+		h[1].Action != "restore" || h[1].By != "growth" || !h[1].At.Equal(at.Add(time.Hour)) {
+		t.Fatalf("persisted history = %+v", h)
+	}
+	if sc := scenario(l3.Config(), "sc_diwali"); sc.Archived != nil || !sc.Enabled {
+		t.Fatalf("scenario with history only = %+v, want live and enabled", sc)
+	}
+}
+
+func TestLoader_FailedPersistChangesNothing(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "rules.yaml", `
+version: v1
+scenarios:
+  - id: sc_diwali
+    enabled: true
+    event_types: [purchase]
+`)
+	l, err := NewLoader(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The overrides file cannot be written: its directory does not exist.
+	if err := l.SetOverridesPath(filepath.Join(dir, "missing", "overrides.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.SetScenarioEnabled("sc_diwali", false, true, nil); err == nil {
+		t.Fatal("expected the persist to fail")
+	}
+	if !l.Config().Scenarios[0].Enabled {
+		t.Error("a failed persist disabled the scenario")
+	}
+	// Nor is the change picked up later, e.g. by a hot-reload.
+	cfg, err := l.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.Scenarios[0].Enabled {
+		t.Error("a failed persist left its override behind")
+	}
+
+	// The same holds for archives.
+	if _, err := l.ArchiveScenario("sc_diwali", ArchiveInfo{By: "ops"}, true, nil); err == nil {
+		t.Fatal("expected the persisted archive to fail")
+	}
+	if l.Config().Scenarios[0].Archived != nil || len(l.ArchiveHistory("sc_diwali")) != 0 {
+		t.Error("a failed persist archived the scenario")
+	}
+	if cfg, err = l.Reload(); err != nil || cfg.Scenarios[0].Archived != nil {
+		t.Errorf("a failed persist left its archive behind: %v", err)
+	}
+}
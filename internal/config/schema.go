@@ -1,21 +1,201 @@
 package config
 
+import "time"
+
 // RuleConfig is the top-level YAML structure.
 type RuleConfig struct {
-	Version   string     `yaml:"version"`
-	Engine    EngineConf `yaml:"engine"`
-	Scenarios []Scenario `yaml:"scenarios"`
+	Version   string        `yaml:"version"`
+	Engine    EngineConf    `yaml:"engine"`
+	Anomaly   AnomalyConf   `yaml:"anomaly"`
+	Drift     DriftConf     `yaml:"drift"`
+	Messages  MessageConf   `yaml:"messages"`
+	Scenarios []Scenario    `yaml:"scenarios"`
+	Workflows []WorkflowDef `yaml:"workflows"`
+	Monitors  []MonitorDef  `yaml:"monitors"`
+
+	// EventTypes maps the raw types producers send to the canonical types
+	// scenarios are written against.
+	EventTypes []EventTypeDef `yaml:"event_types"`
+
+	// aliased maps ids copied by a YAML alias or merge key to the alias
+	// site, for duplicate-id errors. Set by the Loader; nil otherwise.
+	aliased map[string]string
+
+	// raw is the YAML it was parsed from, overlays merged, which
+	// Loader.SaveLastGood caches. Set by the Loader; nil otherwise.
+	raw []byte
 }
 
-// EngineConf holds tunable concurrency settings.
+// MessageConf holds localized action message templates.
+type MessageConf struct {
+	DefaultLocale string                       `yaml:"default_locale"`
+	Catalogs      map[string]map[string]string `yaml:"catalogs"` // locale → key → template
+}
+
+// EngineConf holds tunable concurrency settings. Millis fields accept
+// duration strings ("5s", "2m") as well as plain milliseconds.
 type EngineConf struct {
-	EventWorkers   int  `yaml:"event_workers"`
-	ActionWorkers  int  `yaml:"action_workers"`
-	QueueDepth     int  `yaml:"queue_depth"`
-	EventTimeoutMs int  `yaml:"event_timeout_ms"`
-	FailOpen       bool `yaml:"fail_open"`
+	EventWorkers   int    `yaml:"event_workers"`
+	ActionWorkers  int    `yaml:"action_workers"`
+	QueueDepth     int    `yaml:"queue_depth"`
+	EventTimeoutMs Millis `yaml:"event_timeout_ms"`
+	FailOpen       bool   `yaml:"fail_open"`
+
+	// SyncWorkerFraction reserves that share of EventWorkers (and QueueDepth)
+	// for synchronous requests; the rest serve async and batch traffic.
+	// 0 = one shared pool.
+	SyncWorkerFraction float64 `yaml:"sync_worker_fraction"`
+	// PinWorkers locks each event worker to an OS thread pinned to one CPU (Linux only).
+	PinWorkers bool `yaml:"pin_workers"`
+	// AdaptiveAsyncThreshold is the queue utilization (0–1) at which
+	// POST /v1/events answers 202 with a job handle instead of waiting. 0 = off.
+	AdaptiveAsyncThreshold float64 `yaml:"adaptive_async_threshold"`
+	// ShedExpensiveAt is the queue utilization (0–1) at which scenarios of
+	// the expensive cost class are shed so cheap ones keep up. 0 = off.
+	ShedExpensiveAt float64 `yaml:"shed_expensive_at"`
+	// ShedMode is what happens to an event's shed scenarios: "skip"
+	// (default) drops them for that event; "defer" evaluates them once queue
+	// utilization is back under ShedExpensiveAt.
+	ShedMode string `yaml:"shed_mode"`
+	// OverflowPolicy is what POST /v1/events does when the sync queue is
+	// full: "reject" (default, 429) or "defer" (202 with a job handle, as
+	// adaptive async mode does).
+	OverflowPolicy string `yaml:"overflow_policy"`
+	// IngestRateLimit caps events accepted per second across sync and async
+	// submission, with bursts of up to IngestBurst (default: one second's
+	// worth). Events over the limit get 429. 0 = unlimited.
+	IngestRateLimit float64 `yaml:"ingest_rate_limit"`
+	IngestBurst     int     `yaml:"ingest_burst"`
+	// RequestLogSampleEvery logs one in that many successful requests;
+	// requests answered 4xx or 5xx are always logged. 0 or 1 logs every one.
+	RequestLogSampleEvery int `yaml:"request_log_sample_every"`
+
+	// DefaultTimezone is the IANA zone for hour(), weekday() etc. when neither
+	// meta.timezone nor the actor profile's timezone is set. Default UTC.
+	DefaultTimezone string `yaml:"default_timezone"`
+
+	// Action middleware pipeline; zero disables the stage.
+	ActionTimeoutMs      Millis `yaml:"action_timeout_ms"`
+	ActionRetries        int    `yaml:"action_retries"`
+	ActionRetryBackoffMs Millis `yaml:"action_retry_backoff_ms"`
+	BreakerThreshold     int    `yaml:"breaker_threshold"`
+	BreakerCooldownMs    Millis `yaml:"breaker_cooldown_ms"`
+	// BreakOnPanic opens an action type's breaker on its first executor
+	// panic, even when BreakerThreshold is 0.
+	BreakOnPanic bool `yaml:"break_on_panic"`
+
+	// Per-action error budget: an action whose error rate over the window
+	// exceeds ActionErrorBudget (0–1) is skipped as degraded and probed every
+	// ActionProbeIntervalMs until a call succeeds. 0 disables.
+	ActionErrorBudget         float64 `yaml:"action_error_budget"`
+	ActionErrorBudgetWindowMs Millis  `yaml:"action_error_budget_window_ms"`
+	ActionErrorBudgetMinCalls int     `yaml:"action_error_budget_min_calls"`
+	ActionProbeIntervalMs     Millis  `yaml:"action_probe_interval_ms"`
+
+	// DedupeNodes collapses structurally identical subtrees into shared DAG nodes.
+	DedupeNodes bool `yaml:"dedupe_nodes"`
+
+	// DedupeActions runs an event's matched actions with the same type,
+	// params and limit once, however many scenarios matched them, so
+	// overlapping promos do not award twice or call a webhook twice.
+	DedupeActions bool `yaml:"dedupe_actions"`
+
+	// Actor data for the actor.* namespace; empty URL disables it.
+	// "{actor_id}" in the URL is replaced per lookup.
+	ActorProfileURL string `yaml:"actor_profile_url"`
+	ActorCacheTTLMs Millis `yaml:"actor_cache_ttl_ms"`
+	ActorCacheSize  int    `yaml:"actor_cache_size"`
+
+	// Tenant data for the tenant.* namespace, keyed by meta.tenant; empty
+	// URL disables it. "{tenant_id}" in the URL is replaced per lookup.
+	TenantProfileURL string `yaml:"tenant_profile_url"`
+	TenantCacheTTLMs Millis `yaml:"tenant_cache_ttl_ms"`
+	TenantCacheSize  int    `yaml:"tenant_cache_size"`
+
+	// SandboxActions lists action types replaced by sandbox executors
+	// ("*" = all), typically set from a pre-prod environment overlay.
+	SandboxActions []string `yaml:"sandbox_actions"`
+
+	// Size guards; events over the payload limits are quarantined unevaluated.
+	MaxPayloadBytes int `yaml:"max_payload_bytes"`
+	MaxPayloadDepth int `yaml:"max_payload_depth"`
+	MaxMatchInput   int `yaml:"max_match_input"` // longest string fed to a matches regex
+	MaxResults      int `yaml:"max_results"`     // cap on action outputs in EvalContext.Results per event
+
+	// Region tags results from this instance in active-active deployments.
+	Region string `yaml:"region"`
+	// CounterStrategy is how action limits are counted across regions:
+	// "local" (default, this process only), "crdt" (replicated with
+	// CounterPeers every CounterSyncMs) or "central" (shared -counter-store).
+	CounterStrategy string   `yaml:"counter_strategy"`
+	CounterPeers    []string `yaml:"counter_peers"` // base URLs of the other regions
+	CounterSyncMs   Millis   `yaml:"counter_sync_ms"`
+
+	// DedupeWindowMs skips events whose ID was already accepted within the
+	// window, whichever path (/v1/events, batch, inbox) each copy arrived on.
+	// 0 disables.
+	DedupeWindowMs Millis `yaml:"dedupe_window_ms"`
+
+	// LatencyBucketsMs replaces the classic buckets (in milliseconds) of the
+	// event and action latency histograms; empty keeps the defaults. Read at
+	// startup only.
+	LatencyBucketsMs []float64 `yaml:"latency_buckets_ms"`
+
+	// RecentResults is how many processed events GET /v1/results/recent keeps
+	// in memory (default 1000; negative disables the buffer).
+	RecentResults int `yaml:"recent_results"`
+
+	// StreakRetentionMs is how long a streak is kept after its last update
+	// (default 90d). A streak still alive was updated within the last two
+	// days, so it must be at least MinStreakRetention.
+	StreakRetentionMs Millis `yaml:"streak_retention_ms"`
 }
 
+// MinStreakRetention is the shortest engine.streak_retention_ms: a streak
+// alive in any time zone was updated less than three days ago.
+const MinStreakRetention = 3 * 24 * time.Hour
+
+// AnomalyConf configures scenario match-rate anomaly detection.
+type AnomalyConf struct {
+	Enabled       bool    `yaml:"enabled"`
+	WindowSec     Seconds `yaml:"window_sec"`     // length of one observation window
+	Factor        float64 `yaml:"factor"`         // ratio change (×/÷) that counts as anomalous
+	Alpha         float64 `yaml:"alpha"`          // EWMA weight of the newest window
+	MinEvents     int     `yaml:"min_events"`     // windows with fewer events are ignored
+	WarmupWindows int     `yaml:"warmup_windows"` // windows before a baseline may alert
+	WebhookURL    string  `yaml:"webhook_url"`    // optional alert sink
+}
+
+// DriftConf configures payload schema drift analytics: field presence and
+// types are profiled per event type from a sample of processed events and
+// each window is compared with a baseline of earlier ones.
+type DriftConf struct {
+	Enabled    bool    `yaml:"enabled"`
+	SampleRate float64 `yaml:"sample_rate"` // fraction of processed events profiled
+	WindowSec  Seconds `yaml:"window_sec"`  // length of one observation window
+	MinSamples int     `yaml:"min_samples"` // per event type; smaller windows are not compared
+	Threshold  float64 `yaml:"threshold"`   // change in a field's presence ratio that counts as drift
+	Alpha      float64 `yaml:"alpha"`       // EWMA weight of the newest window
+}
+
+// Overflow policies (EngineConf.OverflowPolicy).
+const (
+	OverflowReject = "reject"
+	OverflowDefer  = "defer"
+)
+
+// Load shedding modes (EngineConf.ShedMode).
+const (
+	ShedSkip  = "skip"
+	ShedDefer = "defer"
+)
+
+// Scenario cost classes (Scenario.Cost).
+const (
+	CostCheap     = "cheap"
+	CostExpensive = "expensive"
+)
+
 // Scenario is an entry point that filters events by type and source.
 type Scenario struct {
 	ID          string    `yaml:"id"`
@@ -24,6 +204,59 @@ type Scenario struct {
 	EventTypes  []string  `yaml:"event_types"`
 	Sources     []string  `yaml:"sources"` // empty = all sources
 	Children    []NodeRef `yaml:"children"`
+
+	// Owner is the team that owns the scenario. With API tokens, only tokens
+	// of that team (or admin tokens) may toggle, archive or restore it.
+	Owner string `yaml:"owner"`
+
+	// RelatedActors maps an alias usable in expressions to the field path
+	// holding that actor's ID, e.g. referrer: payload.referrer_id.
+	RelatedActors map[string]string `yaml:"related_actors"`
+
+	// RequiresFields lists field paths (payload.amount, meta.channel,
+	// event.source) every matching event must carry. Events of the scenario's
+	// types missing any of them skip the scenario with a missing_fields
+	// outcome instead of failing conditions one by one.
+	RequiresFields []string `yaml:"requires_fields"`
+
+	// Cost is the scenario's cost class for load shedding: CostCheap or
+	// CostExpensive. Empty classifies it from what it does: reading actor,
+	// tenant or related-actor fields (external lookups) or running an action
+	// whose executor calls other systems makes it expensive.
+	Cost string `yaml:"cost"`
+
+	Retention RetentionConf `yaml:"retention"`
+
+	// Archived takes the scenario out of the live graph while keeping its
+	// definition, so its ID stays reserved and it can be restored as it was.
+	// Set it here or with POST /v1/rules/scenarios/{id}/archive.
+	Archived *ArchiveInfo `yaml:"archived,omitempty"`
+}
+
+// ArchiveInfo records when, by whom and why a scenario was archived.
+type ArchiveInfo struct {
+	At     time.Time `yaml:"at,omitempty"`
+	By     string    `yaml:"by,omitempty"`
+	Reason string    `yaml:"reason,omitempty"`
+}
+
+// ArchiveEvent is one archive or restore made through the Loader. The
+// events are kept with the scenario's runtime override, so with persist they
+// survive restarts along with the archive itself.
+type ArchiveEvent struct {
+	Action string    `yaml:"action" json:"action"` // "archive" or "restore"
+	At     time.Time `yaml:"at" json:"at"`
+	By     string    `yaml:"by,omitempty" json:"by,omitempty"`
+	Reason string    `yaml:"reason,omitempty" json:"reason,omitempty"`
+}
+
+// RetentionConf bounds how long data tied to a scenario is kept, for use
+// cases with stricter data-retention rules. Zero keeps data until capacity
+// evicts it.
+type RetentionConf struct {
+	ResultsMs Millis `yaml:"results_ms"` // buffered event and job results that matched the scenario
+	AuditMs   Millis `yaml:"audit_ms"`   // runtime rule-change audit entries for the scenario
+	StateMs   Millis `yaml:"state_ms"`   // finished instances of workflows the scenario starts
 }
 
 // NodeRef is a discriminated union: exactly one of Condition or Action is set.
@@ -37,6 +270,24 @@ type ConditionDef struct {
 	ID         string    `yaml:"id"`
 	Expression string    `yaml:"expression"`
 	Children   []NodeRef `yaml:"children"`
+	// Tests are sample inputs with the expected outcome, run when the rules
+	// are built; a mismatch rejects the rule file.
+	Tests []ConditionTest `yaml:"tests,omitempty"`
+}
+
+// ConditionTest is an inline assertion on a condition's expression: for an
+// event of the scenario's first type carrying these fields, the expression
+// must evaluate to Expect. An evaluation error (e.g. a missing field) counts
+// as false, as it does at runtime.
+type ConditionTest struct {
+	Name    string                            `yaml:"name,omitempty"`
+	Payload map[string]interface{}            `yaml:"payload,omitempty"`
+	Meta    map[string]string                 `yaml:"meta,omitempty"`
+	Actor   map[string]interface{}            `yaml:"actor,omitempty"`   // actor.* profile
+	Tenant  map[string]interface{}            `yaml:"tenant,omitempty"`  // tenant.* profile
+	Related map[string]map[string]interface{} `yaml:"related,omitempty"` // related_actors alias → profile
+	Streaks map[string]int                    `yaml:"streaks,omitempty"` // event type → streak("type")
+	Expect  *bool                             `yaml:"expect"`
 }
 
 // ActionDef is a leaf node that specifies an action to execute.
@@ -44,4 +295,85 @@ type ActionDef struct {
 	ID     string                 `yaml:"id"`
 	Type   string                 `yaml:"type"`
 	Params map[string]interface{} `yaml:"params"`
+	// ParamsVersion is the executor params shape Params is written for;
+	// 0 means 1. Older shapes are migrated on load (see action.Versioned).
+	ParamsVersion int `yaml:"params_version,omitempty"`
+	// Limit caps how often the action fires per actor; nil = unlimited.
+	Limit *ActionLimit `yaml:"limit,omitempty"`
+}
+
+// ActionLimit allows at most Max executions of an action per actor in each
+// fixed window of WindowMs (aligned to the epoch, so all regions agree).
+// With Max 1 it is a per-actor cooldown.
+type ActionLimit struct {
+	Max      int    `yaml:"max"` // 0 means 1
+	WindowMs Millis `yaml:"window_ms"`
+}
+
+// StartWorkflowType is the type of the action that starts a workflow; its
+// workflow param names a WorkflowDef.
+const StartWorkflowType = "start_workflow"
+
+// WorkflowDef is a multi-step saga, started by a start_workflow action and
+// advanced by later events from the same actor.
+type WorkflowDef struct {
+	ID          string         `yaml:"id"`
+	Description string         `yaml:"description"`
+	Steps       []WorkflowStep `yaml:"steps"`
+}
+
+// WorkflowStep waits for a matching event, then runs its actions. When a
+// later step fails or times out, Compensate of every completed step runs in
+// reverse order.
+type WorkflowStep struct {
+	ID         string      `yaml:"id"`
+	Await      []string    `yaml:"await"`      // event types; empty = run as soon as the step is reached
+	Condition  string      `yaml:"condition"`  // optional expression the awaited event must satisfy
+	TimeoutMs  Millis      `yaml:"timeout_ms"` // how long to wait for the event; 0 = no limit
+	Actions    []ActionDef `yaml:"actions"`
+	Compensate []ActionDef `yaml:"compensate"`
+}
+
+// EventTypeDef declares a canonical event type. Events sent as Type or as one
+// of Aliases are processed as Type; Derive then picks a more specific type
+// from the event itself. Matching is case-insensitive, like event_types.
+type EventTypeDef struct {
+	Type    string       `yaml:"type"`
+	Aliases []string     `yaml:"aliases"`
+	Derive  []DeriveRule `yaml:"derive"` // first rule that holds wins
+}
+
+// DeriveRule replaces the canonical type with Type when When holds. When may
+// read only the event: payload, meta and event fields.
+type DeriveRule struct {
+	When string `yaml:"when"`
+	Type string `yaml:"type"`
+}
+
+// MonitorDef is a synthetic probe: Event is simulated (dry run, no actions
+// executed) against the active rules every IntervalMs and after every
+// reload, and the outcome must satisfy Expect.
+type MonitorDef struct {
+	ID         string        `yaml:"id"`
+	IntervalMs Millis        `yaml:"interval_ms"` // default 1m
+	Event      MonitorEvent  `yaml:"event"`
+	Expect     MonitorExpect `yaml:"expect"`
+}
+
+// MonitorEvent is the event a probe injects. Source defaults to "monitor"
+// and ActorID to "synthetic-monitor".
+type MonitorEvent struct {
+	Type    string                 `yaml:"type"`
+	Source  string                 `yaml:"source"`
+	ActorID string                 `yaml:"actor_id"`
+	Payload map[string]interface{} `yaml:"payload"`
+	Meta    map[string]string      `yaml:"meta"`
+}
+
+// MonitorExpect lists what a probe's event must (and must not) trigger.
+type MonitorExpect struct {
+	Scenarios    []string `yaml:"scenarios"`     // must all match
+	NotScenarios []string `yaml:"not_scenarios"` // must not match
+	Actions      []string `yaml:"actions"`       // action ids that must fire
+	NoErrors     bool     `yaml:"no_errors"`     // evaluation must report no errors
 }
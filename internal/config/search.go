@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SearchHit is one rule text matching a Search query.
+type SearchHit struct {
+	ScenarioID string `json:"scenario_id,omitempty"`
+	WorkflowID string `json:"workflow_id,omitempty"`
+	NodeID     string `json:"node_id,omitempty"` // condition, action or workflow step
+	Field      string `json:"field"`             // e.g. expression, params.url, requires_fields
+	Text       string `json:"text"`
+}
+
+// Search finds the scenario descriptions, condition expressions, action
+// params and workflow texts in cfg that contain every whitespace-separated
+// term of q, ignoring case. A term also matches a param's name, so
+// "coupon_code" finds both {{payload.coupon_code}} and a coupon_code param.
+// Hits are in config order.
+func Search(cfg *RuleConfig, q string) []SearchHit {
+	terms := strings.Fields(strings.ToLower(q))
+	if len(terms) == 0 {
+		return nil
+	}
+	var hits []SearchHit
+	add := func(h SearchHit) {
+		hay := strings.ToLower(h.Field + " " + h.Text)
+		for _, t := range terms {
+			if !strings.Contains(hay, t) {
+				return
+			}
+		}
+		hits = append(hits, h)
+	}
+	action := func(scenarioID, workflowID string, a *ActionDef) {
+		add(SearchHit{ScenarioID: scenarioID, WorkflowID: workflowID, NodeID: a.ID, Field: "type", Text: a.Type})
+		for _, p := range flattenParams("params", a.Params) {
+			add(SearchHit{ScenarioID: scenarioID, WorkflowID: workflowID, NodeID: a.ID, Field: p[0], Text: p[1]})
+		}
+	}
+	var walk func(scenarioID string, refs []NodeRef)
+	walk = func(scenarioID string, refs []NodeRef) {
+		for _, ref := range refs {
+			switch {
+			case ref.Condition != nil:
+				add(SearchHit{ScenarioID: scenarioID, NodeID: ref.Condition.ID, Field: "expression", Text: ref.Condition.Expression})
+				walk(scenarioID, ref.Condition.Children)
+			case ref.Action != nil:
+				action(scenarioID, "", ref.Action)
+			}
+		}
+	}
+
+	for i := range cfg.Scenarios {
+		sc := &cfg.Scenarios[i]
+		if sc.Description != "" {
+			add(SearchHit{ScenarioID: sc.ID, Field: "description", Text: sc.Description})
+		}
+		if len(sc.RequiresFields) > 0 {
+			add(SearchHit{ScenarioID: sc.ID, Field: "requires_fields", Text: strings.Join(sc.RequiresFields, ", ")})
+		}
+		aliases := make([]string, 0, len(sc.RelatedActors))
+		for alias := range sc.RelatedActors {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+		for _, alias := range aliases {
+			add(SearchHit{ScenarioID: sc.ID, Field: "related_actors." + alias, Text: sc.RelatedActors[alias]})
+		}
+		walk(sc.ID, sc.Children)
+	}
+	for i := range cfg.Workflows {
+		wf := &cfg.Workflows[i]
+		if wf.Description != "" {
+			add(SearchHit{WorkflowID: wf.ID, Field: "description", Text: wf.Description})
+		}
+		for j := range wf.Steps {
+			st := &wf.Steps[j]
+			if st.Condition != "" {
+				add(SearchHit{WorkflowID: wf.ID, NodeID: st.ID, Field: "condition", Text: st.Condition})
+			}
+			for k := range st.Actions {
+				action("", wf.ID, &st.Actions[k])
+			}
+			for k := range st.Compensate {
+				action("", wf.ID, &st.Compensate[k])
+			}
+		}
+	}
+	return hits
+}
+
+// flattenParams returns [path, value] pairs for every scalar in v, with map
+// keys sorted, e.g. ["params.headers.x-token", "{{meta.token}}"].
+func flattenParams(path string, v interface{}) [][2]string {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var out [][2]string
+		for _, k := range keys {
+			out = append(out, flattenParams(path+"."+k, t[k])...)
+		}
+		return out
+	case []interface{}:
+		var out [][2]string
+		for i, e := range t {
+			out = append(out, flattenParams(fmt.Sprintf("%s[%d]", path, i), e)...)
+		}
+		return out
+	case nil:
+		return nil
+	}
+	return [][2]string{{path, fmt.Sprint(v)}}
+}
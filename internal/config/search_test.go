@@ -0,0 +1,57 @@
+package config
+
+import "testing"
+
+func TestSearch(t *testing.T) {
+	cfg := &RuleConfig{
+		Scenarios: []Scenario{{
+			ID:          "sc_coupon",
+			Description: "Bonus for coupon redemptions",
+			Children: []NodeRef{{Condition: &ConditionDef{
+				ID:         "cond_coupon",
+				Expression: `payload.coupon_code != "" AND payload.amount > 10`,
+				Children: []NodeRef{{Action: &ActionDef{
+					ID:   "act_log",
+					Type: "log",
+					Params: map[string]interface{}{
+						"message": "coupon {{payload.coupon_code}} used",
+						"fields":  []interface{}{"payload.amount"},
+					},
+				}}},
+			}}},
+		}},
+		Workflows: []WorkflowDef{{
+			ID: "wf_refund",
+			Steps: []WorkflowStep{{
+				ID:        "await_refund",
+				Condition: "payload.coupon_code == \"\"",
+			}},
+		}},
+	}
+
+	hits := Search(cfg, "PAYLOAD.coupon_code")
+	want := []SearchHit{
+		{ScenarioID: "sc_coupon", NodeID: "cond_coupon", Field: "expression", Text: `payload.coupon_code != "" AND payload.amount > 10`},
+		{ScenarioID: "sc_coupon", NodeID: "act_log", Field: "params.message", Text: "coupon {{payload.coupon_code}} used"},
+		{WorkflowID: "wf_refund", NodeID: "await_refund", Field: "condition", Text: "payload.coupon_code == \"\""},
+	}
+	if len(hits) != len(want) {
+		t.Fatalf("got %d hits, want %d: %+v", len(hits), len(want), hits)
+	}
+	for i := range want {
+		if hits[i] != want[i] {
+			t.Errorf("hit %d = %+v, want %+v", i, hits[i], want[i])
+		}
+	}
+
+	// Every term must appear in the same text; param paths count.
+	if hits := Search(cfg, "fields amount"); len(hits) != 1 || hits[0].Field != "params.fields[0]" {
+		t.Errorf("param path search: %+v", hits)
+	}
+	if hits := Search(cfg, "coupon redemptions bonus"); len(hits) != 1 || hits[0].Field != "description" {
+		t.Errorf("description search: %+v", hits)
+	}
+	if hits := Search(cfg, "   "); hits != nil {
+		t.Errorf("blank query matched %+v", hits)
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+)
+
+// defaultRecoverEvery is how often a Loader started from the cache retries
+// the files when StartupPolicy.MaxBackoff is zero.
+const defaultRecoverEvery = 30 * time.Second
+
+// StartupPolicy lets OpenLoader ride out a config source that is unavailable
+// when the server starts, e.g. a config volume that a sidecar has not synced
+// yet after a pod restart.
+type StartupPolicy struct {
+	// Retries is how many more times a failed load is attempted. Backoff is
+	// the wait before the first retry, doubled each time up to MaxBackoff
+	// (0 = no cap).
+	Retries    int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// CachePath is a last-known-good copy of the config, written by
+	// SaveLastGood. When every attempt fails, the Loader starts from it and,
+	// once Watch is called, keeps retrying the files in the background; once
+	// they load, OnChange callbacks receive the new config.
+	CachePath string
+
+	// OnRetry, if set, is called before each retry.
+	OnRetry func(attempt int, err error, wait time.Duration)
+
+	// OnWatchError, if set, is called when the files load again after a
+	// start from the cache but cannot be watched, which leaves hot reload
+	// off.
+	OnWatchError func(error)
+}
+
+func (p StartupPolicy) next(wait time.Duration) time.Duration {
+	wait *= 2
+	if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+		wait = p.MaxBackoff
+	}
+	return wait
+}
+
+// OpenLoader is NewLoader with a StartupPolicy. It returns the last error
+// when every attempt fails and there is no usable cache.
+func OpenLoader(path string, overlays []string, p StartupPolicy) (*Loader, error) {
+	l := &Loader{path: path, overlays: overlays, cachePath: p.CachePath, onWatchError: p.OnWatchError}
+	wait := p.Backoff
+	var err error
+	for attempt := 0; ; attempt++ {
+		var cfg *RuleConfig
+		if cfg, err = l.load(); err == nil {
+			l.current = cfg
+			return l, nil
+		}
+		if attempt >= p.Retries {
+			break
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt+1, err, wait)
+		}
+		time.Sleep(wait)
+		wait = p.next(wait)
+	}
+	if p.CachePath == "" {
+		return nil, err
+	}
+	data, cerr := os.ReadFile(p.CachePath)
+	if cerr != nil {
+		return nil, fmt.Errorf("%w (last-known-good cache: %v)", err, cerr)
+	}
+	cfg, cerr := parse(p.CachePath, data)
+	if cerr != nil {
+		return nil, fmt.Errorf("%w (last-known-good cache: %v)", err, cerr)
+	}
+	l.current = cfg
+	l.fromCache = true
+	l.recovered = make(chan struct{})
+	l.recoverEvery = p.MaxBackoff
+	if l.recoverEvery <= 0 {
+		l.recoverEvery = defaultRecoverEvery
+	}
+	return l, nil
+}
+
+// recover retries the files until they load, until Reload loads them, or
+// until done is closed.
+func (l *Loader) recover(every time.Duration, done <-chan struct{}) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.recovered:
+			return
+		case <-done:
+			return
+		case <-t.C:
+		}
+		if cfg, err := l.load(); err == nil {
+			l.apply(cfg)
+			return
+		}
+	}
+}
+
+// FromCache reports whether the current config is the last-known-good cache,
+// loaded because the files could not be.
+func (l *Loader) FromCache() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.fromCache
+}
+
+// SaveLastGood writes cfg to the StartupPolicy cache. Call it once cfg has
+// passed validation and its graph has built, so a start from the cache
+// serves rules that worked. Without a cache path it does nothing.
+func (l *Loader) SaveLastGood(cfg *RuleConfig) error {
+	if l.cachePath == "" || cfg == nil || cfg.raw == nil {
+		return nil
+	}
+	if old, err := os.ReadFile(l.cachePath); err == nil && bytes.Equal(old, cfg.raw) {
+		return nil
+	}
+	tmp := l.cachePath + ".tmp"
+	if err := os.WriteFile(tmp, cfg.raw, 0o644); err != nil {
+		return fmt.Errorf("write config cache %s: %w", l.cachePath, err)
+	}
+	if err := os.Rename(tmp, l.cachePath); err != nil {
+		return fmt.Errorf("write config cache %s: %w", l.cachePath, err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenLoader_FallsBackToLastKnownGood(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "rules.yaml", `
+version: v1
+scenarios:
+  - id: sc_welcome
+    enabled: true
+    event_types: [signup]
+`)
+	policy := StartupPolicy{Retries: 2, Backoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, CachePath: filepath.Join(dir, "rules.cache.yaml")}
+
+	l, err := OpenLoader(base, nil, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SaveLastGood(l.Config()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The config source is gone when the server restarts.
+	if err := os.Remove(base); err != nil {
+		t.Fatal(err)
+	}
+	retries := 0
+	policy.OnRetry = func(int, error, time.Duration) { retries++ }
+	l, err = OpenLoader(base, nil, policy)
+	if err != nil {
+		t.Fatalf("OpenLoader with a cache: %v", err)
+	}
+	if !l.FromCache() || l.Config().Version != "v1" || len(l.Config().Scenarios) != 1 {
+		t.Fatalf("expected the cached v1 config, got %+v (from cache: %v)", l.Config(), l.FromCache())
+	}
+	if retries != 2 {
+		t.Errorf("retries = %d, want 2", retries)
+	}
+	changed := make(chan *RuleConfig, 1)
+	l.OnChange(func(cfg *RuleConfig) { changed <- cfg })
+	stop, err := l.Watch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	// Once the source is back, the loader switches to it.
+	writeFile(t, dir, "rules.yaml", "version: v2\n")
+	select {
+	case cfg := <-changed:
+		if cfg.Version != "v2" || l.FromCache() {
+			t.Errorf("after recovery: version %q, from cache %v", cfg.Version, l.FromCache())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("loader did not pick up the restored config")
+	}
+}
+
+func TestOpenLoader_FailsWithoutCache(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "rules.yaml")
+	if _, err := OpenLoader(missing, nil, StartupPolicy{Retries: 1, Backoff: time.Millisecond}); err == nil {
+		t.Fatal("expected an error without a cache")
+	}
+	policy := StartupPolicy{CachePath: filepath.Join(dir, "missing.cache.yaml")}
+	if _, err := OpenLoader(missing, nil, policy); err == nil {
+		t.Fatal("expected an error when the cache is missing too")
+	}
+}
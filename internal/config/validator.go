@@ -2,9 +2,16 @@ package config
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 )
 
+// reservedNamespaces are field path roots that related-actor aliases may not shadow.
+var reservedNamespaces = map[string]struct{}{
+	"payload": {}, "meta": {}, "event": {}, "actor": {}, "tenant": {},
+}
+
 // Validate checks the config for:
 //   - Duplicate IDs across scenarios, conditions, and actions
 //   - Cycle detection within the DAG (impossible in YAML tree, but guards against future formats)
@@ -13,7 +20,7 @@ func Validate(cfg *RuleConfig) error {
 	if cfg.Version == "" {
 		return fmt.Errorf("config: version is required")
 	}
-	ids := make(map[string]string) // id → location
+	ids := idSet{seen: make(map[string]string), aliased: cfg.aliased}
 	var errs []string
 
 	for i, sc := range cfg.Scenarios {
@@ -22,24 +29,163 @@ func Validate(cfg *RuleConfig) error {
 			continue
 		}
 		loc := fmt.Sprintf("scenario %s", sc.ID)
-		if prev, ok := ids[sc.ID]; ok {
-			errs = append(errs, fmt.Sprintf("duplicate id %q (first seen at %s, again at %s)", sc.ID, prev, loc))
-		} else {
-			ids[sc.ID] = loc
-		}
+		ids.claim(sc.ID, loc, &errs)
 		if len(sc.EventTypes) == 0 {
 			errs = append(errs, fmt.Sprintf("scenario %s: event_types must not be empty", sc.ID))
 		}
+		for alias, path := range sc.RelatedActors {
+			if _, reserved := reservedNamespaces[alias]; reserved || alias == "" || strings.Contains(alias, ".") {
+				errs = append(errs, fmt.Sprintf("scenario %s: related_actors alias %q is not allowed", sc.ID, alias))
+			}
+			if ns, _, _ := strings.Cut(path, "."); !strings.Contains(path, ".") || ns == "actor" || ns == alias {
+				errs = append(errs, fmt.Sprintf("scenario %s: related_actors.%s path %q must be a field path like payload.referrer_id", sc.ID, alias, path))
+			}
+		}
+		for _, path := range sc.RequiresFields {
+			ns, rest, _ := strings.Cut(path, ".")
+			if (ns != "payload" && ns != "meta" && ns != "event") || rest == "" {
+				errs = append(errs, fmt.Sprintf("scenario %s: requires_fields path %q must start with payload., meta. or event.", sc.ID, path))
+			}
+		}
+		if sc.Cost != "" && sc.Cost != CostCheap && sc.Cost != CostExpensive {
+			errs = append(errs, fmt.Sprintf("scenario %s: cost must be %s or %s, got %q", sc.ID, CostCheap, CostExpensive, sc.Cost))
+		}
 		validateNodeRefs(sc.Children, loc, ids, &errs)
 	}
 
+	if f := cfg.Engine.SyncWorkerFraction; f < 0 || f >= 1 {
+		errs = append(errs, fmt.Sprintf("engine: sync_worker_fraction must be in [0, 1), got %v", f))
+	}
+	if tz := cfg.Engine.DefaultTimezone; tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			errs = append(errs, fmt.Sprintf("engine: default_timezone: %v", err))
+		}
+	}
+	if t := cfg.Engine.AdaptiveAsyncThreshold; t < 0 || t > 1 {
+		errs = append(errs, fmt.Sprintf("engine: adaptive_async_threshold must be in [0, 1], got %v", t))
+	}
+	if t := cfg.Engine.ShedExpensiveAt; t < 0 || t > 1 {
+		errs = append(errs, fmt.Sprintf("engine: shed_expensive_at must be in [0, 1], got %v", t))
+	}
+	if m := cfg.Engine.ShedMode; m != "" && m != ShedSkip && m != ShedDefer {
+		errs = append(errs, fmt.Sprintf("engine: shed_mode must be %q or %q, got %q", ShedSkip, ShedDefer, m))
+	}
+	if p := cfg.Engine.OverflowPolicy; p != "" && p != OverflowReject && p != OverflowDefer {
+		errs = append(errs, fmt.Sprintf("engine: overflow_policy must be %q or %q, got %q", OverflowReject, OverflowDefer, p))
+	}
+	if cfg.Engine.IngestRateLimit < 0 || cfg.Engine.IngestBurst < 0 || cfg.Engine.RequestLogSampleEvery < 0 {
+		errs = append(errs, "engine: ingest_rate_limit, ingest_burst and request_log_sample_every must be >= 0")
+	}
+	for _, f := range []struct {
+		name string
+		v    float64
+	}{{"sample_rate", cfg.Drift.SampleRate}, {"threshold", cfg.Drift.Threshold}, {"alpha", cfg.Drift.Alpha}} {
+		if f.v < 0 || f.v > 1 {
+			errs = append(errs, fmt.Sprintf("drift: %s must be in [0, 1], got %v", f.name, f.v))
+		}
+	}
+	if cfg.Drift.Enabled && cfg.Drift.WindowSec <= 0 {
+		errs = append(errs, fmt.Sprintf("drift: window_sec must be > 0, got %d", cfg.Drift.WindowSec))
+	}
+	for i, b := range cfg.Engine.LatencyBucketsMs {
+		if b <= 0 || (i > 0 && b <= cfg.Engine.LatencyBucketsMs[i-1]) {
+			errs = append(errs, fmt.Sprintf("engine: latency_buckets_ms must be positive and increasing, got %v", cfg.Engine.LatencyBucketsMs))
+			break
+		}
+	}
+	if cfg.Engine.DedupeWindowMs < 0 {
+		errs = append(errs, fmt.Sprintf("engine: dedupe_window_ms must be >= 0, got %v", cfg.Engine.DedupeWindowMs))
+	}
+	if r := cfg.Engine.StreakRetentionMs; r != 0 && r.Duration() < MinStreakRetention {
+		errs = append(errs, fmt.Sprintf("engine: streak_retention_ms must be at least 3d, got %v", r.Duration()))
+	}
+	if b := cfg.Engine.ActionErrorBudget; b < 0 || b >= 1 {
+		errs = append(errs, fmt.Sprintf("engine: action_error_budget must be in [0, 1), got %v", b))
+	}
+
+	switch cfg.Engine.CounterStrategy {
+	case "", "local", "central":
+	case "crdt":
+		if cfg.Engine.Region == "" || len(cfg.Engine.CounterPeers) == 0 {
+			errs = append(errs, "engine: counter_strategy crdt requires region and counter_peers")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("engine: counter_strategy must be local, crdt or central, got %q", cfg.Engine.CounterStrategy))
+	}
+
+	workflows := make(map[string]bool, len(cfg.Workflows))
+	for i, wf := range cfg.Workflows {
+		validateWorkflow(i, wf, ids, &errs)
+		workflows[wf.ID] = true
+	}
+	for _, sc := range cfg.Scenarios {
+		validateWorkflowRefs(sc.Children, workflows, &errs)
+	}
+
+	scenarios := make(map[string]bool, len(cfg.Scenarios))
+	for _, sc := range cfg.Scenarios {
+		scenarios[sc.ID] = true
+	}
+	validateEventTypes(cfg, &errs)
+
+	probes := make(map[string]bool, len(cfg.Monitors))
+	for i, m := range cfg.Monitors {
+		validateMonitor(i, m, scenarios, ids, probes, &errs)
+	}
+
+	if len(cfg.Messages.Catalogs) > 0 {
+		if _, ok := cfg.Messages.Catalogs[cfg.Messages.DefaultLocale]; !ok {
+			errs = append(errs, fmt.Sprintf("messages: default_locale %q has no catalog", cfg.Messages.DefaultLocale))
+		}
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("config validation errors:\n  - %s", strings.Join(errs, "\n  - "))
 	}
 	return nil
 }
 
-func validateNodeRefs(refs []NodeRef, parent string, ids map[string]string, errs *[]string) {
+// idSet tracks the ids seen so far across scenarios, nodes and workflows.
+type idSet struct {
+	seen    map[string]string // id → location
+	aliased map[string]string // id → alias that copied it (RuleConfig.aliased)
+}
+
+// claim records id at loc, or reports it as a duplicate. Duplicates copied by
+// a YAML alias say so, since both locations usually read the same.
+func (s idSet) claim(id, loc string, errs *[]string) {
+	// Scenario and action IDs are joined with "|" into action limit keys.
+	if strings.Contains(id, "|") {
+		*errs = append(*errs, fmt.Sprintf("%s: id %q must not contain \"|\"", loc, id))
+	}
+	prev, ok := s.seen[id]
+	if !ok {
+		s.seen[id] = loc
+		return
+	}
+	msg := fmt.Sprintf("duplicate id %q (first seen at %s, again at %s)", id, prev, loc)
+	if via, ok := s.aliased[id]; ok {
+		msg += fmt.Sprintf("; copied by %s, to reuse the block merge it (<<: *anchor) and set a new id", via)
+	}
+	*errs = append(*errs, msg)
+}
+
+// validateWorkflowRefs checks that every start_workflow action under refs
+// names a workflow of the config.
+func validateWorkflowRefs(refs []NodeRef, workflows map[string]bool, errs *[]string) {
+	for _, ref := range refs {
+		switch {
+		case ref.Condition != nil:
+			validateWorkflowRefs(ref.Condition.Children, workflows, errs)
+		case ref.Action != nil && ref.Action.Type == StartWorkflowType:
+			if id, _ := ref.Action.Params["workflow"].(string); id != "" && !workflows[id] {
+				*errs = append(*errs, fmt.Sprintf("action %s: unknown workflow %q", ref.Action.ID, id))
+			}
+		}
+	}
+}
+
+func validateNodeRefs(refs []NodeRef, parent string, ids idSet, errs *[]string) {
 	for j, ref := range refs {
 		switch {
 		case ref.Condition != nil && ref.Action != nil:
@@ -53,14 +199,15 @@ func validateNodeRefs(refs []NodeRef, parent string, ids map[string]string, errs
 				continue
 			}
 			loc := fmt.Sprintf("condition %s", c.ID)
-			if prev, ok := ids[c.ID]; ok {
-				*errs = append(*errs, fmt.Sprintf("duplicate id %q (first seen at %s, again at %s)", c.ID, prev, loc))
-			} else {
-				ids[c.ID] = loc
-			}
+			ids.claim(c.ID, loc+" under "+parent, errs)
 			if c.Expression == "" {
 				*errs = append(*errs, fmt.Sprintf("condition %s: expression is required", c.ID))
 			}
+			for k, t := range c.Tests {
+				if t.Expect == nil {
+					*errs = append(*errs, fmt.Sprintf("condition %s: tests[%d]: expect is required (true or false)", c.ID, k))
+				}
+			}
 			validateNodeRefs(c.Children, loc, ids, errs)
 		case ref.Action != nil:
 			a := ref.Action
@@ -68,15 +215,148 @@ func validateNodeRefs(refs []NodeRef, parent string, ids map[string]string, errs
 				*errs = append(*errs, fmt.Sprintf("%s.children[%d].action: id is required", parent, j))
 				continue
 			}
-			loc := fmt.Sprintf("action %s", a.ID)
-			if prev, ok := ids[a.ID]; ok {
-				*errs = append(*errs, fmt.Sprintf("duplicate id %q (first seen at %s, again at %s)", a.ID, prev, loc))
-			} else {
-				ids[a.ID] = loc
-			}
+			ids.claim(a.ID, fmt.Sprintf("action %s under %s", a.ID, parent), errs)
 			if a.Type == "" {
 				*errs = append(*errs, fmt.Sprintf("action %s: type is required", a.ID))
 			}
+			if l := a.Limit; l != nil && (l.Max < 0 || l.WindowMs <= 0) {
+				*errs = append(*errs, fmt.Sprintf("action %s: limit needs window_ms > 0 and max >= 0", a.ID))
+			}
+		}
+	}
+}
+
+func validateWorkflow(i int, wf WorkflowDef, ids idSet, errs *[]string) {
+	if wf.ID == "" {
+		*errs = append(*errs, fmt.Sprintf("workflows[%d]: id is required", i))
+		return
+	}
+	ids.claim(wf.ID, fmt.Sprintf("workflow %s", wf.ID), errs)
+	if len(wf.Steps) == 0 {
+		*errs = append(*errs, fmt.Sprintf("workflow %s: steps must not be empty", wf.ID))
+	}
+	steps := make(map[string]bool, len(wf.Steps))
+	for j, st := range wf.Steps {
+		if st.ID == "" {
+			*errs = append(*errs, fmt.Sprintf("workflow %s.steps[%d]: id is required", wf.ID, j))
+			continue
+		}
+		if steps[st.ID] {
+			*errs = append(*errs, fmt.Sprintf("workflow %s: duplicate step id %q", wf.ID, st.ID))
+		}
+		steps[st.ID] = true
+		stepLoc := fmt.Sprintf("workflow %s step %s", wf.ID, st.ID)
+		if len(st.Await) == 0 && (st.Condition != "" || st.TimeoutMs != 0) {
+			*errs = append(*errs, fmt.Sprintf("%s: condition and timeout_ms require await", stepLoc))
+		}
+		for _, list := range [][]ActionDef{st.Actions, st.Compensate} {
+			for k, a := range list {
+				switch {
+				case a.ID == "":
+					*errs = append(*errs, fmt.Sprintf("%s: actions[%d]: id is required", stepLoc, k))
+				case a.Type == "":
+					*errs = append(*errs, fmt.Sprintf("action %s: type is required", a.ID))
+				case a.Type == StartWorkflowType:
+					// Steps run with their actor's instances locked.
+					*errs = append(*errs, fmt.Sprintf("%s: action %s: start_workflow is not allowed in a workflow step", stepLoc, a.ID))
+				}
+				if a.ID == "" {
+					continue
+				}
+				ids.claim(a.ID, stepLoc, errs)
+			}
+		}
+	}
+}
+
+// validateEventTypes checks that every raw type maps to one canonical type,
+// that no canonical type is also an alias, so an alias never chains, and
+// that scenarios and workflows match canonical types, not aliases.
+func validateEventTypes(cfg *RuleConfig, errs *[]string) {
+	defs := cfg.EventTypes
+	canonical := make(map[string]bool, len(defs))
+	for i, d := range defs {
+		t := strings.ToLower(d.Type)
+		switch {
+		case t == "":
+			*errs = append(*errs, fmt.Sprintf("event_types[%d]: type is required", i))
+		case canonical[t]:
+			*errs = append(*errs, fmt.Sprintf("event type %s: declared twice", d.Type))
+		}
+		canonical[t] = true
+	}
+	aliases := make(map[string]string)
+	for _, d := range defs {
+		for _, a := range d.Aliases {
+			l := strings.ToLower(a)
+			switch prev, dup := aliases[l]; {
+			case l == "":
+				*errs = append(*errs, fmt.Sprintf("event type %s: empty alias", d.Type))
+			case canonical[l]:
+				*errs = append(*errs, fmt.Sprintf("event type %s: alias %q is itself a declared event type", d.Type, a))
+			case dup:
+				*errs = append(*errs, fmt.Sprintf("event type %s: alias %q already maps to %s", d.Type, a, prev))
+			}
+			aliases[l] = d.Type
+		}
+	}
+	for _, d := range defs {
+		for j, r := range d.Derive {
+			if r.When == "" || r.Type == "" {
+				*errs = append(*errs, fmt.Sprintf("event type %s: derive[%d] needs when and type", d.Type, j))
+			} else if to, ok := aliases[strings.ToLower(r.Type)]; ok {
+				*errs = append(*errs, fmt.Sprintf("event type %s: derive[%d] type %q is an alias of %s", d.Type, j, r.Type, to))
+			}
+		}
+	}
+	if len(aliases) == 0 {
+		return
+	}
+	for _, sc := range cfg.Scenarios {
+		for _, t := range sc.EventTypes {
+			if to, ok := aliases[strings.ToLower(t)]; ok {
+				*errs = append(*errs, fmt.Sprintf("scenario %s: event type %q is an alias of %s; use %s", sc.ID, t, to, to))
+			}
+		}
+	}
+	for _, wf := range cfg.Workflows {
+		for _, st := range wf.Steps {
+			for _, t := range st.Await {
+				if to, ok := aliases[strings.ToLower(t)]; ok {
+					*errs = append(*errs, fmt.Sprintf("workflow %s: step %s awaits %q, an alias of %s; use %s", wf.ID, st.ID, t, to, to))
+				}
+			}
+		}
+	}
+}
+
+func validateMonitor(i int, m MonitorDef, scenarios map[string]bool, ids idSet, probes map[string]bool, errs *[]string) {
+	if m.ID == "" {
+		*errs = append(*errs, fmt.Sprintf("monitors[%d]: id is required", i))
+		return
+	}
+	if probes[m.ID] {
+		*errs = append(*errs, fmt.Sprintf("monitor %s: duplicate monitor id", m.ID))
+	}
+	probes[m.ID] = true
+	if m.IntervalMs < 1000 {
+		*errs = append(*errs, fmt.Sprintf("monitor %s: interval_ms must be at least 1s", m.ID))
+	}
+	if m.Event.Type == "" {
+		*errs = append(*errs, fmt.Sprintf("monitor %s: event.type is required", m.ID))
+	}
+	x := m.Expect
+	if len(x.Scenarios) == 0 && len(x.NotScenarios) == 0 && len(x.Actions) == 0 && !x.NoErrors {
+		*errs = append(*errs, fmt.Sprintf("monitor %s: expect must assert something", m.ID))
+	}
+	for _, id := range append(slices.Clone(x.Scenarios), x.NotScenarios...) {
+		if !scenarios[id] {
+			*errs = append(*errs, fmt.Sprintf("monitor %s: unknown scenario %q", m.ID, id))
+		}
+	}
+	for _, id := range x.Actions {
+		if !strings.HasPrefix(ids.seen[id], "action ") {
+			*errs = append(*errs, fmt.Sprintf("monitor %s: unknown scenario action %q", m.ID, id))
 		}
 	}
 }
@@ -0,0 +1,27 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate_DriftWindow(t *testing.T) {
+	dir := t.TempDir()
+	for window, ok := range map[string]bool{"": true, "5m": true, "-5": false} {
+		body := "version: v1\ndrift:\n  enabled: true\n"
+		if window != "" {
+			body += "  window_sec: " + window + "\n"
+		}
+		l, err := NewLoader(writeFile(t, dir, "rules.yaml", body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Validate(l.Config())
+		if ok && err != nil {
+			t.Errorf("window_sec %q: %v", window, err)
+		}
+		if !ok && (err == nil || !strings.Contains(err.Error(), "window_sec")) {
+			t.Errorf("window_sec %q: err = %v, want a window_sec error", window, err)
+		}
+	}
+}
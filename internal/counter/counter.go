@@ -0,0 +1,82 @@
+// Package counter counts executions per key in fixed time windows. The engine
+// uses it to enforce action limits (at most max per actor per window). The
+// strategies differ in how the regions of an active-active deployment agree on
+// a count:
+//
+//   - Memory: one process only.
+//   - GCounter: a CRDT replicated between regions. Cheap and partition-tolerant,
+//     but two regions can both claim the last slot between syncs.
+//   - SQLStore: one shared table. Claims are exact but need the database on
+//     every limited action.
+package counter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Strategy names accepted in engine.counter_strategy.
+const (
+	StrategyLocal   = "local"
+	StrategyCRDT    = "crdt"
+	StrategyCentral = "central"
+)
+
+// Store claims slots in per-key windows.
+type Store interface {
+	// Claim increments key's count in the window containing now if the
+	// count is below limit, and reports whether it did.
+	Claim(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error)
+}
+
+// windowStart aligns windows to fixed boundaries, so every region places a
+// claim in the same window regardless of when it started counting.
+func windowStart(window time.Duration, now time.Time) time.Time {
+	return now.UTC().Truncate(window)
+}
+
+// gcEvery bounds how often stores sweep expired windows.
+const gcEvery = time.Minute
+
+// Memory is a process-local Store.
+type Memory struct {
+	mu     sync.Mutex
+	slots  map[string]*slot
+	lastGC time.Time
+}
+
+type slot struct {
+	start   time.Time
+	expires time.Time
+	n       int
+}
+
+// NewMemory creates an empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{slots: make(map[string]*slot)}
+}
+
+func (m *Memory) Claim(_ context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error) {
+	start := windowStart(window, now)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if now.Sub(m.lastGC) >= gcEvery {
+		for k, s := range m.slots {
+			if !now.Before(s.expires) {
+				delete(m.slots, k)
+			}
+		}
+		m.lastGC = now
+	}
+	s, ok := m.slots[key]
+	if !ok || !s.start.Equal(start) {
+		s = &slot{start: start, expires: start.Add(window)}
+		m.slots[key] = s
+	}
+	if s.n >= limit {
+		return false, nil
+	}
+	s.n++
+	return true, nil
+}
@@ -0,0 +1,103 @@
+package counter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemory_FixedWindows(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i, want := range []bool{true, true, false} {
+		if ok, _ := m.Claim(ctx, "act|u1", 2, time.Hour, now.Add(time.Duration(i)*time.Minute)); ok != want {
+			t.Fatalf("claim %d = %v, want %v", i, ok, want)
+		}
+	}
+	if ok, _ := m.Claim(ctx, "act|u1", 2, time.Hour, now.Add(time.Hour)); !ok {
+		t.Error("expected a fresh slot in the next window")
+	}
+}
+
+func TestGCounter_ReplicasConverge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	eu, us := NewGCounter("eu"), NewGCounter("us")
+
+	if ok, _ := eu.Claim(ctx, "act|u1", 1, 24*time.Hour, now); !ok {
+		t.Fatal("eu claim rejected")
+	}
+
+	// us pulls eu's state over HTTP before the actor shows up there.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/counters/state" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer ff_peer" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(eu.State())
+	}))
+	defer srv.Close()
+	us.SetPeerToken("ff_peer")
+	if err := us.Sync(ctx, srv.Client(), []string{srv.URL}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if ok, _ := us.Claim(ctx, "act|u1", 1, 24*time.Hour, now.Add(time.Minute)); ok {
+		t.Error("us claimed a slot eu already used")
+	}
+
+	// Merging is idempotent and order-independent.
+	us.Merge(eu.State())
+	eu.Merge(us.State())
+	for _, g := range []*GCounter{eu, us} {
+		for id, e := range g.State() {
+			if e.total() != 1 {
+				t.Errorf("%s: %s total = %d, want 1", g.region, id, e.total())
+			}
+		}
+	}
+}
+
+func TestSnapshot_RestoreRaisesCounts(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	src := NewMemory()
+	for range 2 {
+		src.Claim(ctx, "act|u1", 5, time.Hour, now)
+	}
+	src.Claim(ctx, "act|u2", 5, time.Hour, now)
+
+	ws, err := src.Windows(ctx, now, func(key string) bool { return key == "act|u1" })
+	if err != nil || len(ws) != 1 || ws[0].Count != 2 {
+		t.Fatalf("Windows = %+v, %v; want one window with count 2", ws, err)
+	}
+
+	for _, dst := range []interface {
+		Store
+		Snapshotter
+	}{NewMemory(), NewGCounter("eu")} {
+		dst.Claim(ctx, "act|u1", 5, time.Hour, now)
+		if err := dst.Restore(ctx, ws, now); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+		if err := dst.Restore(ctx, ws, now); err != nil { // idempotent
+			t.Fatalf("Restore: %v", err)
+		}
+		var claims int
+		for range 5 {
+			if ok, _ := dst.Claim(ctx, "act|u1", 5, time.Hour, now.Add(time.Minute)); ok {
+				claims++
+			}
+		}
+		if claims != 3 {
+			t.Errorf("%T: %d claims left after restoring count 2, want 3", dst, claims)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package counter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is one key's window in a GCounter: a grow-only count per region.
+type Entry struct {
+	Expires time.Time        `json:"expires"`
+	Counts  map[string]int64 `json:"counts"` // region → claims made there
+}
+
+func (e *Entry) total() int64 {
+	var n int64
+	for _, c := range e.Counts {
+		n += c
+	}
+	return n
+}
+
+// GCounter is a G-Counter CRDT: each region only increments its own count,
+// a claim checks the sum over all regions, and merging takes the per-region
+// maximum, so replicas converge however often and in whatever order they
+// sync. Claims are decided locally and never block on a peer.
+//
+// Because claims are local, a limit of max N can be exceeded while regions
+// have not synced: in the worst case each region grants up to N claims
+// within one sync interval. Use the central strategy where a limit must be
+// exact.
+type GCounter struct {
+	region    string
+	peerToken string // bearer token sent to peers; empty = none
+
+	mu      sync.Mutex
+	entries map[string]*Entry // key + "@" + window start
+	lastGC  time.Time
+}
+
+// NewGCounter creates a replica that counts its own claims under region.
+func NewGCounter(region string) *GCounter {
+	return &GCounter{region: region, entries: make(map[string]*Entry)}
+}
+
+func (g *GCounter) Claim(_ context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error) {
+	start := windowStart(window, now)
+	id := key + "@" + strconv.FormatInt(start.Unix(), 10)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gc(now)
+	e, ok := g.entries[id]
+	if !ok {
+		e = &Entry{Expires: start.Add(window), Counts: make(map[string]int64)}
+		g.entries[id] = e
+	}
+	if e.total() >= int64(limit) {
+		return false, nil
+	}
+	e.Counts[g.region]++
+	return true, nil
+}
+
+// gc drops expired windows; g.mu must be held.
+func (g *GCounter) gc(now time.Time) {
+	if now.Sub(g.lastGC) < gcEvery {
+		return
+	}
+	for id, e := range g.entries {
+		if !now.Before(e.Expires) {
+			delete(g.entries, id)
+		}
+	}
+	g.lastGC = now
+}
+
+// State returns a copy of every live entry, for peers to merge.
+func (g *GCounter) State() map[string]Entry {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	out := make(map[string]Entry, len(g.entries))
+	for id, e := range g.entries {
+		out[id] = Entry{Expires: e.Expires, Counts: maps.Clone(e.Counts)}
+	}
+	return out
+}
+
+// Merge folds a peer's state into g.
+func (g *GCounter) Merge(state map[string]Entry) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for id, in := range state {
+		e, ok := g.entries[id]
+		if !ok {
+			e = &Entry{Expires: in.Expires, Counts: make(map[string]int64, len(in.Counts))}
+			g.entries[id] = e
+		}
+		for r, n := range in.Counts {
+			e.Counts[r] = max(e.Counts[r], n)
+		}
+	}
+}
+
+// SetPeerToken makes Sync authenticate to peers with a bearer token: a peer
+// or admin token of their API tokens file. Call before Run.
+func (g *GCounter) SetPeerToken(secret string) {
+	g.peerToken = secret
+}
+
+// Sync pulls the state of each peer (a base URL serving GET
+// /v1/counters/state) and merges it. It returns the first error but still
+// merges every peer that answered.
+func (g *GCounter) Sync(ctx context.Context, client *http.Client, peers []string) error {
+	var first error
+	for _, peer := range peers {
+		if err := g.pull(ctx, client, peer); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+func (g *GCounter) pull(ctx context.Context, client *http.Client, peer string) error {
+	url := strings.TrimRight(peer, "/") + "/v1/counters/state"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("counter sync %s: %w", peer, err)
+	}
+	if g.peerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+g.peerToken)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("counter sync %s: %w", peer, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("counter sync %s: status %d", peer, resp.StatusCode)
+	}
+	var state map[string]Entry
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return fmt.Errorf("counter sync %s: decode: %w", peer, err)
+	}
+	g.Merge(state)
+	return nil
+}
+
+// Run syncs with peers every interval until ctx is cancelled.
+func (g *GCounter) Run(ctx context.Context, client *http.Client, peers []string, every time.Duration) {
+	t := time.NewTicker(every)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := g.Sync(ctx, client, peers); err != nil {
+				slog.Warn("counter sync failed", "region", g.region, "err", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
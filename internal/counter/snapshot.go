@@ -0,0 +1,142 @@
+package counter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Window is one key's count in one window: the portable form of a store's
+// state, used to export and import actor state between deployments.
+type Window struct {
+	Key     string    `json:"key"`
+	Start   time.Time `json:"start"`
+	Expires time.Time `json:"expires"`
+	Count   int64     `json:"count"`
+}
+
+// Snapshotter is implemented by stores whose windows can be listed and
+// restored. Memory, GCounter and SQLStore all implement it.
+type Snapshotter interface {
+	// Windows returns the unexpired windows of the keys match accepts.
+	Windows(ctx context.Context, now time.Time, match func(key string) bool) ([]Window, error)
+	// Restore raises each window's count to at least w.Count, so importing
+	// a snapshot twice, or into a store that has counted since, never lowers
+	// a count. Expired windows are skipped.
+	Restore(ctx context.Context, ws []Window, now time.Time) error
+}
+
+func (m *Memory) Windows(_ context.Context, now time.Time, match func(string) bool) ([]Window, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Window
+	for k, s := range m.slots {
+		if now.Before(s.expires) && match(k) {
+			out = append(out, Window{Key: k, Start: s.start, Expires: s.expires, Count: int64(s.n)})
+		}
+	}
+	return out, nil
+}
+
+func (m *Memory) Restore(_ context.Context, ws []Window, now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, w := range ws {
+		if !now.Before(w.Expires) {
+			continue
+		}
+		s, ok := m.slots[w.Key]
+		switch {
+		case !ok || s.start.Before(w.Start):
+			m.slots[w.Key] = &slot{start: w.Start, expires: w.Expires, n: int(w.Count)}
+		case s.start.Equal(w.Start):
+			s.n = max(s.n, int(w.Count))
+		}
+		// A newer window already in the store wins over an older import.
+	}
+	return nil
+}
+
+func (g *GCounter) Windows(_ context.Context, now time.Time, match func(string) bool) ([]Window, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var out []Window
+	for id, e := range g.entries {
+		i := strings.LastIndexByte(id, '@')
+		if i < 0 || !now.Before(e.Expires) || !match(id[:i]) {
+			continue
+		}
+		sec, err := strconv.ParseInt(id[i+1:], 10, 64)
+		if err != nil {
+			continue
+		}
+		out = append(out, Window{Key: id[:i], Start: time.Unix(sec, 0).UTC(), Expires: e.Expires, Count: e.total()})
+	}
+	return out, nil
+}
+
+// Restore credits any shortfall to this replica's region, so the imported
+// count reaches peers through the normal sync.
+func (g *GCounter) Restore(_ context.Context, ws []Window, now time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, w := range ws {
+		if !now.Before(w.Expires) {
+			continue
+		}
+		id := w.Key + "@" + strconv.FormatInt(w.Start.Unix(), 10)
+		e, ok := g.entries[id]
+		if !ok {
+			e = &Entry{Expires: w.Expires, Counts: make(map[string]int64)}
+			g.entries[id] = e
+		}
+		if short := w.Count - e.total(); short > 0 {
+			e.Counts[g.region] += short
+		}
+	}
+	return nil
+}
+
+func (s *SQLStore) Windows(ctx context.Context, now time.Time, match func(string) bool) ([]Window, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT key, window_start, count, expires FROM counters WHERE expires > ?`, now.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("counter windows: %w", err)
+	}
+	defer rows.Close()
+	var out []Window
+	for rows.Next() {
+		var (
+			key                   string
+			start, count, expires int64
+		)
+		if err := rows.Scan(&key, &start, &count, &expires); err != nil {
+			return nil, fmt.Errorf("counter windows: %w", err)
+		}
+		if match(key) {
+			out = append(out, Window{Key: key, Start: time.Unix(0, start).UTC(), Expires: time.Unix(0, expires).UTC(), Count: count})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("counter windows: %w", err)
+	}
+	return out, nil
+}
+
+func (s *SQLStore) Restore(ctx context.Context, ws []Window, now time.Time) error {
+	for _, w := range ws {
+		if !now.Before(w.Expires) {
+			continue
+		}
+		_, err := s.db.ExecContext(ctx,
+			`INSERT INTO counters (key, window_start, count, expires) VALUES (?, ?, ?, ?)
+			 ON CONFLICT (key, window_start) DO UPDATE SET count = CASE WHEN excluded.count > counters.count THEN excluded.count ELSE counters.count END`,
+			w.Key, w.Start.UnixNano(), w.Count, w.Expires.UnixNano())
+		if err != nil {
+			return fmt.Errorf("counter restore %s: %w", w.Key, err)
+		}
+	}
+	return nil
+}
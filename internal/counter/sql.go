@@ -0,0 +1,73 @@
+package counter
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS counters (
+	key          TEXT    NOT NULL,
+	window_start INTEGER NOT NULL,
+	count        INTEGER NOT NULL,
+	expires      INTEGER NOT NULL,
+	PRIMARY KEY (key, window_start)
+)`,
+}
+
+// SQLStore coordinates counts through one table shared by every region. A
+// claim is a single conditional upsert, so the database decides the last slot.
+type SQLStore struct {
+	db     *sql.DB
+	lastGC atomic.Int64 // unix nanos
+}
+
+// OpenSQLStore opens (creating if needed) a store at dsn using a database/sql
+// driver registered under driverName.
+func OpenSQLStore(driverName, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("counter store open: %w", err)
+	}
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("counter store schema: %w", err)
+		}
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Claim(ctx context.Context, key string, limit int, window time.Duration, now time.Time) (bool, error) {
+	start := windowStart(window, now)
+	s.gc(ctx, now)
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO counters (key, window_start, count, expires) VALUES (?, ?, 1, ?)
+		 ON CONFLICT (key, window_start) DO UPDATE SET count = counters.count + 1 WHERE counters.count < ?`,
+		key, start.UnixNano(), start.Add(window).UnixNano(), limit)
+	if err != nil {
+		return false, fmt.Errorf("counter claim %s: %w", key, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("counter claim %s: %w", key, err)
+	}
+	return n == 1, nil
+}
+
+// gc deletes expired windows at most once per gcEvery per process.
+func (s *SQLStore) gc(ctx context.Context, now time.Time) {
+	last := s.lastGC.Load()
+	if now.UnixNano()-last < int64(gcEvery) || !s.lastGC.CompareAndSwap(last, now.UnixNano()) {
+		return
+	}
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM counters WHERE expires <= ?`, now.UnixNano())
+}
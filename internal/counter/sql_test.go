@@ -0,0 +1,92 @@
+//go:build cgo
+
+package counter
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openSQLTest(t *testing.T, path string) *SQLStore {
+	t.Helper()
+	s, err := OpenSQLStore("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLStore_SharedBetweenRegions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "counters.db")
+	eu, us := openSQLTest(t, path), openSQLTest(t, path)
+
+	var mu sync.Mutex
+	granted := 0
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := eu
+			if i%2 == 1 {
+				s = us
+			}
+			ok, err := s.Claim(ctx, "sc|act|u1", 3, time.Hour, now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if ok {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if granted != 3 {
+		t.Errorf("granted %d claims across regions, want exactly 3", granted)
+	}
+	if ok, err := us.Claim(ctx, "sc|act|u1", 3, time.Hour, now.Add(time.Hour)); err != nil || !ok {
+		t.Errorf("claim in the next window = %v, %v; want a fresh slot", ok, err)
+	}
+}
+
+func TestSQLStore_WindowsAndRestore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	src := openSQLTest(t, filepath.Join(t.TempDir(), "src.db"))
+	for range 2 {
+		src.Claim(ctx, "sc|act|u1", 5, time.Hour, now)
+	}
+	src.Claim(ctx, "sc|act|u2", 5, time.Hour, now)
+
+	ws, err := src.Windows(ctx, now, func(key string) bool { return key == "sc|act|u1" })
+	if err != nil || len(ws) != 1 || ws[0].Count != 2 {
+		t.Fatalf("Windows = %+v, %v; want one window with count 2", ws, err)
+	}
+
+	dst := openSQLTest(t, filepath.Join(t.TempDir(), "dst.db"))
+	for range 2 { // idempotent
+		if err := dst.Restore(ctx, ws, now); err != nil {
+			t.Fatalf("Restore: %v", err)
+		}
+	}
+	var claims int
+	for range 5 {
+		if ok, _ := dst.Claim(ctx, "sc|act|u1", 5, time.Hour, now.Add(time.Minute)); ok {
+			claims++
+		}
+	}
+	if claims != 3 {
+		t.Errorf("%d claims left after restoring count 2, want 3", claims)
+	}
+}
@@ -1,51 +1,356 @@
 package dag
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"slices"
+	"strings"
 
-	"github.com/gyaneshwarpardhi/ifttt/internal/condition"
 	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/i18n"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
 )
 
 // Build constructs a DAG from a validated RuleConfig.
 // All expressions are compiled into ASTs here; zero parsing happens at evaluation time.
+//
+// Identical expressions always share one compiled AST. When engine.dedupe_nodes
+// is set, structurally identical subtrees (same expression, same action type
+// and params, same children) are additionally collapsed into one shared node;
+// the duplicate IDs become aliases of the first occurrence. Only evaluation
+// is shared: matches still carry each scenario's own action IDs.
+//
+// Expressions are constant-folded (condition.Fold). A condition that folds to
+// false is left out together with its subtree, and each elimination is logged.
 func Build(cfg *config.RuleConfig) (*Graph, error) {
+	return BuildContext(context.Background(), cfg, BuildOptions{})
+}
+
+// BuildOptions carry what the builder cannot tell from the config alone.
+type BuildOptions struct {
+	// ExternalAction reports whether executors of an action type call other
+	// systems, such as a webhook. A scenario running one is in the expensive
+	// cost class unless it declares a cost. Nil = none do.
+	ExternalAction func(actionType string) bool
+}
+
+// BuildContext is Build with opts, logging eliminations with ctx's logctx
+// fields, e.g. the request that changed the rules.
+func BuildContext(ctx context.Context, cfg *config.RuleConfig, opts BuildOptions) (*Graph, error) {
 	g := NewGraph()
+	g.source = cfg
+	g.hash = config.Hash(cfg)
+	g.msgs = i18n.NewCatalog(cfg.Messages.DefaultLocale, cfg.Messages.Catalogs)
+	b := &builder{
+		ctx:    ctx,
+		g:      g,
+		dedupe: cfg.Engine.DedupeNodes,
+		asts:   make(map[string]condition.Expr),
+		folded: make(map[string]condition.Expr),
+		shared: make(map[string]Node),
+		keys:   make(map[interface{}]string),
+	}
+	if err := b.buildEventTypes(cfg.EventTypes); err != nil {
+		return nil, err
+	}
 	for _, sc := range cfg.Scenarios {
-		if !sc.Enabled {
+		if !sc.Enabled || sc.Archived != nil {
 			continue
 		}
+		schema := &lookupRecorder{fieldSchema: fieldSchema{related: sc.RelatedActors}}
+		if err := b.checkExpressions(&sc, sc.Children, schema); err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", sc.ID, err)
+		}
 		sn := NewScenarioNode(sc.ID, sc.EventTypes, sc.Sources)
+		sn.expensive = sc.Cost == config.CostExpensive || (sc.Cost == "" && (schema.external || callsOut(sc.Children, opts.ExternalAction)))
+		g.external = g.external || schema.external
+		if len(sc.RelatedActors) > 0 {
+			sn.SetRelatedActors(sc.RelatedActors)
+		}
+		if len(sc.RequiresFields) > 0 {
+			sn.SetRequiredFields(sc.RequiresFields)
+		}
 		g.AddNode(sn)
-		if err := buildChildren(g, sc.ID, sc.Children); err != nil {
+		if err := b.buildChildren(sc.ID, sc.Children); err != nil {
 			return nil, fmt.Errorf("scenario %s: %w", sc.ID, err)
 		}
 	}
 	return g, nil
 }
 
-func buildChildren(g *Graph, parentID string, refs []config.NodeRef) error {
+type builder struct {
+	ctx    context.Context // for logctx fields
+	g      *Graph
+	dedupe bool
+	asts   map[string]condition.Expr // expression → compiled AST
+	folded map[string]condition.Expr // expression → constant-folded AST
+	shared map[string]Node           // subtree key → first node built for it
+	keys   map[interface{}]string    // *ConditionDef / *ActionDef → subtree key
+}
+
+func (b *builder) buildChildren(parentID string, refs []config.NodeRef) error {
 	for _, ref := range refs {
+		if b.deadBranch(ref) {
+			ids := subtreeIDs(ref)
+			b.g.pruned = append(b.g.pruned, ids...)
+			logctx.From(b.ctx).Info("eliminated statically false branch",
+				"condition_id", ref.Condition.ID, "expression", ref.Condition.Expression, "nodes", len(ids))
+			continue
+		}
+		if b.dedupe {
+			// A parent reaches a shared node once, so that each edge maps
+			// back to one config ID.
+			if n, ok := b.shared[b.key(ref)]; ok && !slices.Contains(b.g.Children(parentID), n) {
+				b.g.AddEdge(parentID, n)
+				b.aliasSubtree(parentID, ref, n)
+				continue
+			}
+		}
 		switch {
 		case ref.Condition != nil:
 			c := ref.Condition
-			ast, err := condition.Parse(c.Expression)
+			ast, err := b.fold(c.Expression)
 			if err != nil {
 				return fmt.Errorf("condition %s: parse %q: %w", c.ID, c.Expression, err)
 			}
 			cn := NewConditionNode(c.ID, ast)
-			g.AddNode(cn)
-			g.AddEdge(parentID, cn)
-			if err := buildChildren(g, c.ID, c.Children); err != nil {
+			b.g.AddNode(cn)
+			b.g.AddEdge(parentID, cn)
+			if b.dedupe {
+				b.shared[b.key(ref)] = cn
+			}
+			if err := b.buildChildren(c.ID, c.Children); err != nil {
 				return fmt.Errorf("condition %s: %w", c.ID, err)
 			}
 		case ref.Action != nil:
 			a := ref.Action
 			an := NewActionNode(a.ID, a.Type, a.Params)
-			g.AddNode(an)
-			g.AddEdge(parentID, an)
+			an.limit = a.Limit
+			an.signature = b.key(ref)
+			b.g.AddNode(an)
+			b.g.AddEdge(parentID, an)
+			if b.dedupe {
+				b.shared[b.key(ref)] = an
+			}
 			// Actions are leaves; they have no children.
 		}
 	}
 	return nil
 }
+
+// callsOut reports whether any action under refs is of an external type.
+func callsOut(refs []config.NodeRef, external func(string) bool) bool {
+	if external == nil {
+		return false
+	}
+	for _, ref := range refs {
+		if a := ref.Action; a != nil && external(a.Type) {
+			return true
+		}
+		if c := ref.Condition; c != nil && callsOut(c.Children, external) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkExpressions compiles every condition under refs, validates its field
+// paths and operand types against the scenario's schema and runs its inline
+// tests.
+func (b *builder) checkExpressions(sc *config.Scenario, refs []config.NodeRef, schema condition.Schema) error {
+	for _, ref := range refs {
+		if a := ref.Action; a != nil {
+			if err := b.formulaStreaks(a); err != nil {
+				return fmt.Errorf("action %s: %w", a.ID, err)
+			}
+			continue
+		}
+		c := ref.Condition
+		if c == nil {
+			continue
+		}
+		ast, err := b.compile(c.Expression)
+		if err != nil {
+			return fmt.Errorf("condition %s: parse %q: %w", c.ID, c.Expression, err)
+		}
+		if err := condition.Check(ast, schema); err != nil {
+			return fmt.Errorf("condition %s: %w", c.ID, err)
+		}
+		if err := streakRefs(ast, b.g.streaks); err != nil {
+			return fmt.Errorf("condition %s: %w", c.ID, err)
+		}
+		if callsAny(ast, calendarFuncs) {
+			b.g.zoned = true
+		}
+		if callsAny(ast, clockFuncs) {
+			b.g.clocked = true
+		}
+		if err := runConditionTests(sc, c, ast); err != nil {
+			return err
+		}
+		if err := b.checkExpressions(sc, c.Children, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formulaStreaks records the streak() calls in a's points_formula, the one
+// action param evaluated as an expression. A formula that does not parse is
+// left for the action to report when it runs.
+func (b *builder) formulaStreaks(a *config.ActionDef) error {
+	formula, _ := a.Params["points_formula"].(string)
+	if formula == "" {
+		return nil
+	}
+	ast, err := b.compile(formula)
+	if err != nil {
+		return nil
+	}
+	return streakRefs(ast, b.g.streaks)
+}
+
+func (b *builder) compile(expr string) (condition.Expr, error) {
+	if ast, ok := b.asts[expr]; ok {
+		return ast, nil
+	}
+	ast, err := Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	b.asts[expr] = ast
+	return ast, nil
+}
+
+// fold returns the constant-folded AST of expr. Check runs on the unfolded
+// AST, so folding never hides a type error.
+func (b *builder) fold(expr string) (condition.Expr, error) {
+	if ast, ok := b.folded[expr]; ok {
+		return ast, nil
+	}
+	ast, err := b.compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	ast = condition.Fold(ast)
+	b.folded[expr] = ast
+	return ast, nil
+}
+
+// deadBranch reports whether ref is a condition that folds to false.
+func (b *builder) deadBranch(ref config.NodeRef) bool {
+	if ref.Condition == nil {
+		return false
+	}
+	ast, err := b.fold(ref.Condition.Expression)
+	c, ok := ast.(*condition.ConstExpr)
+	return err == nil && ok && !c.Value
+}
+
+// subtreeIDs lists the IDs of ref and every node below it.
+func subtreeIDs(ref config.NodeRef) []string {
+	switch {
+	case ref.Condition != nil:
+		ids := []string{ref.Condition.ID}
+		for _, child := range ref.Condition.Children {
+			ids = append(ids, subtreeIDs(child)...)
+		}
+		return ids
+	case ref.Action != nil:
+		return []string{ref.Action.ID}
+	}
+	return nil
+}
+
+// key returns a structural hash of the subtree rooted at ref. IDs are
+// deliberately excluded so that renamed copies of a branch collide.
+func (b *builder) key(ref config.NodeRef) string {
+	var ptr interface{}
+	var raw string
+	switch {
+	case ref.Condition != nil:
+		ptr = ref.Condition
+		if k, ok := b.keys[ptr]; ok {
+			return k
+		}
+		parts := make([]string, 0, len(ref.Condition.Children)+1)
+		parts = append(parts, "C:"+ref.Condition.Expression)
+		for _, child := range ref.Condition.Children {
+			parts = append(parts, b.key(child))
+		}
+		raw = strings.Join(parts, "\x00")
+	case ref.Action != nil:
+		ptr = ref.Action
+		if k, ok := b.keys[ptr]; ok {
+			return k
+		}
+		params, _ := json.Marshal(ref.Action.Params)
+		raw = "A:" + ref.Action.Type + "\x00" + string(params)
+		if l := ref.Action.Limit; l != nil {
+			raw += fmt.Sprintf("\x00L:%d/%d", l.Max, l.WindowMs)
+		}
+	default:
+		return ""
+	}
+	sum := sha256.Sum256([]byte(raw))
+	k := hex.EncodeToString(sum[:])
+	b.keys[ptr] = k
+	return k
+}
+
+// aliasSubtree maps every ID in the duplicate subtree ref, a child of
+// parentID, onto the matching node of the shared subtree rooted at n. Both
+// have identical shape by construction.
+func (b *builder) aliasSubtree(parentID string, ref config.NodeRef, n Node) {
+	switch {
+	case ref.Condition != nil:
+		b.g.addAlias(parentID, ref.Condition.ID, n.ID())
+		shared := b.g.Children(n.ID())
+		i := 0
+		for _, child := range ref.Condition.Children {
+			if b.deadBranch(child) {
+				// Pruned from the shared subtree too; nothing to alias.
+				b.g.pruned = append(b.g.pruned, subtreeIDs(child)...)
+				continue
+			}
+			if i < len(shared) {
+				b.aliasSubtree(ref.Condition.ID, child, shared[i])
+			}
+			i++
+		}
+	case ref.Action != nil:
+		b.g.addAlias(parentID, ref.Action.ID, n.ID())
+	}
+}
+
+// calendarFuncs are the functions whose result depends on the zone
+// EvalContext.Location picks.
+var calendarFuncs = map[string]bool{"hour": true, "weekday": true, "day": true, "month": true}
+
+// clockFuncs are the functions whose result depends on when they run.
+var clockFuncs = map[string]bool{"age": true}
+
+// callsAny reports whether expr calls one of funcs.
+func callsAny(expr condition.Expr, funcs map[string]bool) bool {
+	switch e := expr.(type) {
+	case *condition.BinaryExpr:
+		return callsAny(e.Left, funcs) || callsAny(e.Right, funcs)
+	case *condition.NotExpr:
+		return callsAny(e.Expr, funcs)
+	case *condition.ComparisonExpr:
+		return operandCalls(e.Left, funcs) || operandCalls(e.Right, funcs)
+	}
+	return false
+}
+
+func operandCalls(op condition.Operand, funcs map[string]bool) bool {
+	f, ok := op.(*condition.FuncOperand)
+	if !ok {
+		return false
+	}
+	return funcs[f.Name] || operandCalls(f.Arg, funcs)
+}
@@ -0,0 +1,302 @@
+package dag_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
+)
+
+// repeatedBranch returns the same condition→action branch under fresh IDs.
+func repeatedBranch(suffix string) []config.NodeRef {
+	return []config.NodeRef{
+		{Condition: &config.ConditionDef{
+			ID:         "cond_amount_" + suffix,
+			Expression: "payload.amount > 1000",
+			Children: []config.NodeRef{
+				{Action: &config.ActionDef{
+					ID:     "act_bonus_" + suffix,
+					Type:   "reward_points",
+					Params: map[string]interface{}{"operation": "award", "points": float64(10)},
+				}},
+			},
+		}},
+	}
+}
+
+func dedupeConfig(dedupe bool) *config.RuleConfig {
+	return &config.RuleConfig{
+		Version: "v1",
+		Engine:  config.EngineConf{DedupeNodes: dedupe},
+		Scenarios: []config.Scenario{
+			{ID: "sc_a", Enabled: true, EventTypes: []string{"transaction"}, Children: repeatedBranch("a")},
+			{ID: "sc_b", Enabled: true, EventTypes: []string{"transaction"}, Children: repeatedBranch("b")},
+		},
+	}
+}
+
+func TestBuild_DedupeSharesIdenticalSubtrees(t *testing.T) {
+	plain, err := dag.Build(dedupeConfig(false))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	g, err := dag.Build(dedupeConfig(true))
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	if plain.NodeCount() != 6 || g.NodeCount() != 4 {
+		t.Errorf("expected 6 nodes without dedupe and 4 with, got %d and %d", plain.NodeCount(), g.NodeCount())
+	}
+	if g.RefCount("cond_amount_a") != 2 {
+		t.Errorf("expected shared condition to have 2 parents, got %d", g.RefCount("cond_amount_a"))
+	}
+	if n := g.Node("act_bonus_b"); n == nil || n.ID() != "act_bonus_a" {
+		t.Errorf("expected act_bonus_b to alias act_bonus_a, got %v", n)
+	}
+
+	ev := makeEvent("transaction", "", map[string]interface{}{"amount": float64(1500)})
+	actions, scenarios, err := dag.Evaluate(g, ev)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(scenarios) != 2 || len(actions) != 2 {
+		t.Fatalf("expected both scenarios to match through the shared branch, got %v / %d actions", scenarios, len(actions))
+	}
+	// Evaluation is shared; each match still names its scenario's action.
+	for i, want := range []string{"act_bonus_a", "act_bonus_b"} {
+		if actions[i].ActionID != want || actions[i].Node.ID() != "act_bonus_a" {
+			t.Errorf("match %d: ActionID %q on node %q, want %q on the shared node", i, actions[i].ActionID, actions[i].Node.ID(), want)
+		}
+	}
+}
+
+func TestBuild_DedupeKeepsSiblingIDs(t *testing.T) {
+	bonus := func(id string) config.NodeRef {
+		return config.NodeRef{Action: &config.ActionDef{
+			ID: id, Type: "reward_points", Params: map[string]interface{}{"operation": "award", "points": float64(10)},
+		}}
+	}
+	cfg := &config.RuleConfig{
+		Version: "v1",
+		Engine:  config.EngineConf{DedupeNodes: true},
+		Scenarios: []config.Scenario{
+			{ID: "sc_a", Enabled: true, EventTypes: []string{"transaction"}, Children: []config.NodeRef{bonus("act_first"), bonus("act_second")}},
+			{ID: "sc_b", Enabled: true, EventTypes: []string{"transaction"}, Children: []config.NodeRef{bonus("act_third")}},
+		},
+	}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	actions, _, err := dag.Evaluate(g, makeEvent("transaction", "", nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ids []string
+	for _, a := range actions {
+		ids = append(ids, a.ActionID)
+	}
+	if strings.Join(ids, ",") != "act_first,act_second,act_third" {
+		t.Errorf("matched %v, want each action once under its own ID", ids)
+	}
+}
+
+func TestBuild_RejectsInvalidFieldPaths(t *testing.T) {
+	for _, expr := range []string{
+		"paylod.amount > 10",         // typo in namespace
+		"event.kind == \"x\"",        // unknown event field
+		"meta.tier > 3",              // meta is string-valued
+		"payload.spent > event.type", // event fields are strings
+	} {
+		cfg := &config.RuleConfig{
+			Version: "v1",
+			Scenarios: []config.Scenario{{
+				ID: "sc", Enabled: true, EventTypes: []string{"t"},
+				Children: []config.NodeRef{{Condition: &config.ConditionDef{ID: "c", Expression: expr}}},
+			}},
+		}
+		if _, err := dag.Build(cfg); err == nil {
+			t.Errorf("expected build error for %q", expr)
+		}
+	}
+}
+
+func TestBuild_PrunesStaticallyFalseBranches(t *testing.T) {
+	flagged := func(suffix string) []config.NodeRef {
+		return []config.NodeRef{{Condition: &config.ConditionDef{
+			ID:         "cond_gate_" + suffix,
+			Expression: "payload.amount > 0",
+			Children: append([]config.NodeRef{{Condition: &config.ConditionDef{
+				ID:         "cond_flag_" + suffix,
+				Expression: `"off" == "on" AND payload.amount > 5`,
+				Children: []config.NodeRef{{Action: &config.ActionDef{
+					ID: "act_beta_" + suffix, Type: "reward_points", Params: map[string]interface{}{"operation": "award", "points": float64(1)},
+				}}},
+			}}}, repeatedBranch(suffix)...),
+		}}}
+	}
+	cfg := &config.RuleConfig{
+		Version: "v1",
+		Engine:  config.EngineConf{DedupeNodes: true},
+		Scenarios: []config.Scenario{
+			{ID: "sc_a", Enabled: true, EventTypes: []string{"transaction"}, Children: flagged("a")},
+			{ID: "sc_b", Enabled: true, EventTypes: []string{"transaction"}, Children: flagged("b")},
+		},
+	}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	if got := g.Pruned(); len(got) != 4 || got[0] != "cond_flag_a" || got[1] != "act_beta_a" {
+		t.Errorf("Pruned = %v, want the flag branch of both scenarios", got)
+	}
+	if g.Node("act_beta_a") != nil {
+		t.Error("pruned action is still in the graph")
+	}
+	// The dedupe aliases of the copy must skip the pruned sibling.
+	if n := g.Node("act_bonus_b"); n == nil || n.ID() != "act_bonus_a" {
+		t.Errorf("expected act_bonus_b to alias act_bonus_a, got %v", n)
+	}
+	actions, _, _ := dag.Evaluate(g, makeEvent("transaction", "", map[string]interface{}{"amount": float64(1500)}))
+	if len(actions) != 2 {
+		t.Errorf("expected the live branch of both scenarios to match, got %d actions", len(actions))
+	}
+}
+
+func TestBuild_RunsConditionTests(t *testing.T) {
+	yes, no := true, false
+	build := func(tests ...config.ConditionTest) error {
+		cfg := &config.RuleConfig{
+			Version: "v1",
+			Scenarios: []config.Scenario{{
+				ID: "sc", Enabled: true, EventTypes: []string{"transaction"},
+				RelatedActors: map[string]string{"referrer": "payload.referrer_id"},
+				Children: []config.NodeRef{{Condition: &config.ConditionDef{
+					ID:         "c",
+					Expression: `payload.amount > 100 AND actor.tier == "gold" AND referrer.active == true`,
+					Tests:      tests,
+				}}},
+			}},
+		}
+		_, err := dag.Build(cfg)
+		return err
+	}
+
+	match := config.ConditionTest{
+		Name:    "gold with active referrer",
+		Payload: map[string]interface{}{"amount": 150, "referrer_id": "r1"},
+		Actor:   map[string]interface{}{"tier": "gold"},
+		Related: map[string]map[string]interface{}{"referrer": {"active": true}},
+		Expect:  &yes,
+	}
+	small := config.ConditionTest{Payload: map[string]interface{}{"amount": 50}, Expect: &no}
+	if err := build(match, small); err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	noActor := match
+	noActor.Actor = nil // actor.tier is missing, so the expression errors out
+	if err := build(noActor); err == nil || !strings.Contains(err.Error(), "gold with active referrer") {
+		t.Errorf("expected a failed test naming the case, got %v", err)
+	}
+	stray := small
+	stray.Related = map[string]map[string]interface{}{"sponsor": {}}
+	if err := build(stray); err == nil {
+		t.Error("expected an error for an unknown related alias")
+	}
+}
+
+func TestBuild_CollectsStreakTypes(t *testing.T) {
+	yes := true
+	build := func(expr, formula string) (*dag.Graph, error) {
+		return dag.Build(&config.RuleConfig{
+			Version: "v1",
+			Scenarios: []config.Scenario{{
+				ID: "sc", Enabled: true, EventTypes: []string{"purchase"},
+				Children: []config.NodeRef{{Condition: &config.ConditionDef{
+					ID:         "c",
+					Expression: expr,
+					Tests:      []config.ConditionTest{{Streaks: map[string]int{"login": 7}, Expect: &yes}},
+					Children: []config.NodeRef{{Action: &config.ActionDef{
+						ID: "a", Type: "reward_points",
+						Params: map[string]interface{}{"operation": "award", "points_formula": formula},
+					}}},
+				}}},
+			}},
+		})
+	}
+	g, err := build(`streak("login") >= 7`, `streak("checkin") * 10`)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	if got := g.StreakTypes(); len(got) != 2 || got[0] != "checkin" || got[1] != "login" {
+		t.Errorf("StreakTypes = %v, want [checkin login]", got)
+	}
+	if g.TracksStreak("purchase") {
+		t.Error("purchase streaks are tracked without being read")
+	}
+	if _, err := build(`streak(payload.kind) >= 7`, "10"); err == nil || !strings.Contains(err.Error(), "quoted string") {
+		t.Errorf("expected a non-literal streak type to be rejected, got %v", err)
+	}
+
+	// streak() is for rules only; other users of pkg/condition lack it.
+	if _, err := condition.Parse(`streak("login") >= 7`); err == nil {
+		t.Error("condition.Parse accepted streak()")
+	}
+	if !slices.Contains(dag.Functions(), "streak") || !slices.Contains(dag.Functions(), "age") {
+		t.Errorf("Functions = %v, want streak and the built-ins", dag.Functions())
+	}
+}
+
+func TestBuild_DeriveRulesReadOnlyTheEvent(t *testing.T) {
+	build := func(when string) error {
+		_, err := dag.Build(&config.RuleConfig{
+			Version:    "v1",
+			EventTypes: []config.EventTypeDef{{Type: "purchase", Derive: []config.DeriveRule{{When: when, Type: "refund"}}}},
+		})
+		return err
+	}
+	if err := build(`payload.amount < 0 AND event.raw_type == "txn"`); err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	for _, when := range []string{`actor.tier == "gold"`, `streak("login") > 1`, `payload.amount <`} {
+		if err := build(when); err == nil || !strings.Contains(err.Error(), "derive[0]") {
+			t.Errorf("derive %q: expected an error, got %v", when, err)
+		}
+	}
+}
+
+func TestBuild_ReadsState(t *testing.T) {
+	build := func(expr string) *dag.Graph {
+		t.Helper()
+		g, err := dag.Build(&config.RuleConfig{
+			Version: "v1",
+			Scenarios: []config.Scenario{{
+				ID: "sc", Enabled: true, EventTypes: []string{"purchase"},
+				Children: []config.NodeRef{{Condition: &config.ConditionDef{
+					ID:         "c",
+					Expression: expr,
+					Children: []config.NodeRef{{Action: &config.ActionDef{
+						ID: "a", Type: "reward_points",
+						Params: map[string]interface{}{"operation": "award", "points": float64(10)},
+					}}},
+				}}},
+			}},
+		})
+		if err != nil {
+			t.Fatalf("Build(%q) error: %v", expr, err)
+		}
+		return g
+	}
+	if build("payload.amount > 100").ReadsState() {
+		t.Error("a graph reading only the event reports state reads")
+	}
+	for _, expr := range []string{`actor.tier == "gold"`, `tenant.plan == "pro"`, `streak("login") > 2`, `age(event.occurred_at) < 1h`} {
+		if !build(expr).ReadsState() {
+			t.Errorf("%s: ReadsState = false", expr)
+		}
+	}
+}
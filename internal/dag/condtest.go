@@ -0,0 +1,96 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
+)
+
+// testActorID is the actor ID of the sample events built for condition tests.
+const testActorID = "test-actor"
+
+// runConditionTests evaluates c's inline tests (config.ConditionTest) against
+// its compiled expression and returns an error naming the first mismatch.
+func runConditionTests(sc *config.Scenario, c *config.ConditionDef, ast condition.Expr) error {
+	for i, t := range c.Tests {
+		name := fmt.Sprintf("tests[%d]", i)
+		if t.Name != "" {
+			name += fmt.Sprintf(" (%s)", t.Name)
+		}
+		for alias := range t.Related {
+			if _, ok := sc.RelatedActors[alias]; !ok {
+				return fmt.Errorf("condition %s: %s: related alias %q is not in the scenario's related_actors", c.ID, name, alias)
+			}
+		}
+		ctx := conditionTestContext(sc, t)
+		got, err := evalNode(NewConditionNode(c.ID, ast), ctx)
+		if want := t.Expect != nil && *t.Expect; got != want {
+			msg := fmt.Sprintf("condition %s: %s: %q evaluated to %v, want %v", c.ID, name, c.Expression, got, want)
+			if err != nil {
+				msg += fmt.Sprintf(" (%v)", err)
+			}
+			return errors.New(msg)
+		}
+	}
+	return nil
+}
+
+// conditionTestContext builds the evaluation context for one test: an event
+// of the scenario's first type and source with the test's payload and meta,
+// and lookups served from the test's actor, tenant and related profiles and
+// streaks.
+func conditionTestContext(sc *config.Scenario, t config.ConditionTest) *EvalContext {
+	ev := &event.Event{
+		ID:         "condition-test",
+		ActorID:    testActorID,
+		OccurredAt: time.Now(),
+		Payload:    t.Payload,
+		Meta:       make(map[string]string, len(t.Meta)+1),
+	}
+	if len(sc.EventTypes) > 0 {
+		ev.Type = sc.EventTypes[0]
+	}
+	if len(sc.Sources) > 0 {
+		ev.Source = sc.Sources[0]
+	}
+	for k, v := range t.Meta {
+		ev.Meta[k] = v
+	}
+	if t.Tenant != nil && ev.Meta["tenant"] == "" {
+		ev.Meta["tenant"] = "test-tenant"
+	}
+
+	ctx := &EvalContext{Event: ev}
+	profiles := map[string]map[string]interface{}{}
+	if t.Actor != nil {
+		profiles[testActorID] = t.Actor
+	}
+	if len(sc.RelatedActors) > 0 {
+		ctx.related = make(map[string][]string, len(sc.RelatedActors))
+		for alias, path := range sc.RelatedActors {
+			ctx.related[alias] = strings.Split(path, ".")
+		}
+		// A related profile is served for whatever ID the alias resolves to.
+		for alias, data := range t.Related {
+			if id, ok := ctx.Resolve(ctx.related[alias]); ok {
+				profiles[fmt.Sprint(id)] = data
+			}
+		}
+	}
+	ctx.LoadActor = func(id string) (map[string]interface{}, bool) {
+		data, ok := profiles[id]
+		return data, ok
+	}
+	ctx.LoadTenant = func(string) (map[string]interface{}, bool) {
+		return t.Tenant, t.Tenant != nil
+	}
+	ctx.LoadStreak = func(eventType string) (int, error) {
+		return t.Streaks[eventType], nil
+	}
+	return ctx
+}
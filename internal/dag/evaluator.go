@@ -2,29 +2,63 @@ package dag
 
 import (
 	"fmt"
+	"runtime/debug"
+	"slices"
 
 	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
 )
 
+// TraceStep is one evaluated node, recorded when EvalContext.Explain is set.
+type TraceStep struct {
+	NodeID string   `json:"node_id"`
+	Type   NodeType `json:"type"`
+	Passed bool     `json:"passed"`
+	Error  string   `json:"error,omitempty"`
+}
+
 // ActionMatch records a triggered action during DFS traversal.
 type ActionMatch struct {
 	ScenarioID string
-	Node       *ActionNode
+	// ActionID is the action's ID in ScenarioID's config. With
+	// engine.dedupe_nodes, Node may be shared with identical actions of other
+	// scenarios and carry the ID of the first of them.
+	ActionID string
+	Node     *ActionNode
+
+	// Collapsed are matches with the same signature, from this or other
+	// scenarios, that engine.dedupe_actions folded into this one.
+	Collapsed []ActionMatch
 }
 
 // Evaluate runs DFS over the graph for the given event and returns matched actions.
 func Evaluate(g *Graph, ev *event.Event) ([]ActionMatch, []string, error) {
-	ctx := &EvalContext{
+	return EvaluateContext(g, &EvalContext{
 		Event:   ev,
-		Results: make(map[string]interface{}),
-	}
+		Results: NewResults(0),
+	})
+}
 
+// EvaluateContext is Evaluate with a caller-supplied context, letting the
+// caller wire lazy lookups (e.g. Actor) and reuse the context for actions.
+func EvaluateContext(g *Graph, ctx *EvalContext) ([]ActionMatch, []string, error) {
 	var matches []ActionMatch
 	var scenariosMatched []string
 	var evalErr error
 
+	if ctx.loc == nil && (g.zoned || len(g.streaks) > 0) {
+		// Resolved up front so evaluation only reads it.
+		ctx.loc = ctx.resolveLocation()
+	}
 	for _, root := range g.Roots() {
-		ok, err := root.Evaluate(ctx)
+		if ctx.ForceScenario != "" && root.ID() != ctx.ForceScenario {
+			continue
+		}
+		if ctx.Scenarios != nil && !slices.Contains(ctx.Scenarios, root.ID()) {
+			continue
+		}
+		ok, err := evalNode(root, ctx)
 		if err != nil {
 			ctx.Errors = append(ctx.Errors, fmt.Errorf("scenario %s: %w", root.ID(), err))
 			continue
@@ -32,8 +66,15 @@ func Evaluate(g *Graph, ev *event.Event) ([]ActionMatch, []string, error) {
 		if !ok {
 			continue
 		}
+		if ctx.ShedExpensive && root.expensive {
+			ctx.Shed = append(ctx.Shed, root.ID())
+			continue
+		}
+		// Related-actor aliases are scoped to the scenario being traversed.
+		ctx.related = root.related
 		// DFS from this scenario's children.
-		actions, err := dfs(g, ctx, root.ID(), root.ID())
+		actions, err := dfs(g, ctx, root.ID(), root.ID(), root.ID())
+		ctx.related = nil
 		if err != nil {
 			ctx.Errors = append(ctx.Errors, err)
 			continue
@@ -51,11 +92,13 @@ func Evaluate(g *Graph, ev *event.Event) ([]ActionMatch, []string, error) {
 }
 
 // dfs does a depth-first traversal with early branch pruning.
-// Returns all ActionNodes reachable from parentID whose entire ancestor chain passed.
-func dfs(g *Graph, ctx *EvalContext, parentID, scenarioID string) ([]ActionMatch, error) {
+// Returns all ActionNodes reachable from parentID whose entire ancestor chain
+// passed. localID is parentID's config ID in the scenario being traversed,
+// which differs from parentID inside a shared subtree.
+func dfs(g *Graph, ctx *EvalContext, parentID, localID, scenarioID string) ([]ActionMatch, error) {
 	var results []ActionMatch
 	for _, child := range g.Children(parentID) {
-		ok, err := child.Evaluate(ctx)
+		ok, err := evalNode(child, ctx)
 		if err != nil {
 			ctx.Errors = append(ctx.Errors, fmt.Errorf("node %s: %w", child.ID(), err))
 			continue // fail-open: skip this branch
@@ -63,10 +106,11 @@ func dfs(g *Graph, ctx *EvalContext, parentID, scenarioID string) ([]ActionMatch
 		if !ok {
 			continue // prune this branch
 		}
+		id := g.localID(localID, child.ID())
 		if an, isAction := child.(*ActionNode); isAction {
-			results = append(results, ActionMatch{ScenarioID: scenarioID, Node: an})
+			results = append(results, ActionMatch{ScenarioID: scenarioID, ActionID: id, Node: an})
 		} else {
-			sub, err := dfs(g, ctx, child.ID(), scenarioID)
+			sub, err := dfs(g, ctx, child.ID(), id, scenarioID)
 			if err != nil {
 				return results, err
 			}
@@ -75,3 +119,26 @@ func dfs(g *Graph, ctx *EvalContext, parentID, scenarioID string) ([]ActionMatch
 	}
 	return results, nil
 }
+
+// evalNode evaluates n, turning a panic (e.g. in a condition function) into
+// an error so the branch fails open like any other evaluation error.
+func evalNode(n Node, ctx *EvalContext) (ok bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.PanicsRecovered.WithLabelValues("condition", n.ID()).Inc()
+			logctx.From(ctx.LogContext).Error("condition evaluation panicked", "node_id", n.ID(), "panic", r, "stack", string(debug.Stack()))
+			ok, err = false, fmt.Errorf("panic: %v", r)
+		}
+		if err != nil && ctx.Results != nil {
+			ctx.Results.AddDiagnostic(n.ID(), err.Error())
+		}
+		if ctx.Explain {
+			step := TraceStep{NodeID: n.ID(), Type: n.Type(), Passed: ok}
+			if err != nil {
+				step.Error = err.Error()
+			}
+			ctx.Trace = append(ctx.Trace, step)
+		}
+	}()
+	return n.Evaluate(ctx)
+}
@@ -1,6 +1,9 @@
 package dag_test
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
@@ -117,6 +120,46 @@ func TestEvaluate_ConditionPrune(t *testing.T) {
 	}
 }
 
+func TestEvaluate_RequiredFieldsShortCircuit(t *testing.T) {
+	cfg := &config.RuleConfig{Scenarios: []config.Scenario{{
+		ID:             "sc_spend",
+		Enabled:        true,
+		EventTypes:     []string{"transaction"},
+		RequiresFields: []string{"payload.amount", "payload.category"},
+		Children: []config.NodeRef{{Condition: &config.ConditionDef{
+			ID:         "cond_amount",
+			Expression: "payload.amount > 10",
+			Children: []config.NodeRef{{Action: &config.ActionDef{
+				ID: "act_bonus", Type: "reward_points", Params: map[string]interface{}{"operation": "award", "points": float64(5)},
+			}}},
+		}}},
+	}}}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+
+	evalCtx := &dag.EvalContext{
+		Event:   makeEvent("transaction", "", map[string]interface{}{"amount": float64(50)}),
+		Results: dag.NewResults(0),
+	}
+	actions, _, err := dag.EvaluateContext(g, evalCtx)
+	if err != nil || len(actions) != 0 || len(evalCtx.Errors) != 0 {
+		t.Fatalf("expected a silent skip, got actions=%v err=%v errors=%v", actions, err, evalCtx.Errors)
+	}
+	if got := evalCtx.MissingFields["sc_spend"]; len(got) != 1 || got[0] != "payload.category" {
+		t.Errorf("MissingFields = %v, want [payload.category]", evalCtx.MissingFields)
+	}
+
+	evalCtx = &dag.EvalContext{
+		Event:   makeEvent("transaction", "", map[string]interface{}{"amount": float64(50), "category": "food"}),
+		Results: dag.NewResults(0),
+	}
+	if actions, _, _ := dag.EvaluateContext(g, evalCtx); len(actions) != 1 || evalCtx.MissingFields != nil {
+		t.Errorf("complete event should match, got actions=%v missing=%v", actions, evalCtx.MissingFields)
+	}
+}
+
 func TestEvaluate_WrongEventType(t *testing.T) {
 	g := buildTestGraph(t)
 
@@ -180,3 +223,416 @@ func TestEvaluate_DisabledScenario(t *testing.T) {
 		t.Errorf("disabled scenario should not match, got %v", scenarios)
 	}
 }
+
+func TestEvaluate_RelatedActor(t *testing.T) {
+	cfg := &config.RuleConfig{
+		Version: "v1",
+		Scenarios: []config.Scenario{
+			{
+				ID:            "sc_referral",
+				Enabled:       true,
+				EventTypes:    []string{"signup"},
+				RelatedActors: map[string]string{"referrer": "payload.referrer_id"},
+				Children: []config.NodeRef{
+					{Condition: &config.ConditionDef{
+						ID:         "cond_referrer_active",
+						Expression: "referrer.points_total > 100 AND actor.tier == \"new\"",
+						Children: []config.NodeRef{
+							{Action: &config.ActionDef{ID: "act_referral", Type: "reward_points"}},
+						},
+					}},
+				},
+			},
+		},
+	}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	profiles := map[string]map[string]interface{}{
+		"user_42": {"tier": "new"},
+		"user_7":  {"points_total": float64(250)},
+	}
+	loads := 0
+	ev := makeEvent("signup", "", map[string]interface{}{"referrer_id": "user_7"})
+	ctx := &dag.EvalContext{
+		Event:   ev,
+		Results: dag.NewResults(0),
+		LoadActor: func(id string) (map[string]interface{}, bool) {
+			loads++
+			p, ok := profiles[id]
+			return p, ok
+		},
+	}
+	actions, _, err := dag.EvaluateContext(g, ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(actions) != 1 || actions[0].Node.ID() != "act_referral" {
+		t.Errorf("expected act_referral, got %v", actions)
+	}
+	if loads != 2 {
+		t.Errorf("expected both actors loaded once each, got %d loads", loads)
+	}
+}
+
+func TestEvaluate_TenantNamespace(t *testing.T) {
+	cfg := &config.RuleConfig{
+		Version: "v1",
+		Scenarios: []config.Scenario{
+			{
+				ID:         "sc_premium",
+				Enabled:    true,
+				EventTypes: []string{"purchase"},
+				Children: []config.NodeRef{
+					{Condition: &config.ConditionDef{
+						ID:         "cond_premium_big",
+						Expression: "tenant.plan == \"premium\" AND payload.amount > tenant.limits.min_amount AND actor.tier == \"gold\"",
+						Children: []config.NodeRef{
+							{Action: &config.ActionDef{ID: "act_bonus", Type: "reward_points"}},
+						},
+					}},
+				},
+			},
+		},
+	}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	tenants := map[string]map[string]interface{}{
+		"acme": {"plan": "premium", "limits": map[string]interface{}{"min_amount": float64(100)}},
+		"beta": {"plan": "free"},
+	}
+	run := func(tenant string) ([]string, int, error) {
+		loads := 0
+		ev := makeEvent("purchase", "", map[string]interface{}{"amount": float64(150)})
+		ev.Meta = map[string]string{"tenant": tenant}
+		ctx := &dag.EvalContext{
+			Event:   ev,
+			Results: dag.NewResults(0),
+			LoadActor: func(string) (map[string]interface{}, bool) {
+				return map[string]interface{}{"tier": "gold"}, true
+			},
+			LoadTenant: func(id string) (map[string]interface{}, bool) {
+				loads++
+				p, ok := tenants[id]
+				return p, ok
+			},
+		}
+		actions, _, err := dag.EvaluateContext(g, ctx)
+		var ids []string
+		for _, a := range actions {
+			ids = append(ids, a.Node.ID())
+		}
+		return ids, loads, err
+	}
+
+	if ids, loads, err := run("acme"); err != nil || len(ids) != 1 || ids[0] != "act_bonus" || loads != 1 {
+		t.Errorf("acme: got actions %v after %d tenant loads (err %v), want [act_bonus] after 1", ids, loads, err)
+	}
+	if ids, _, err := run("beta"); err != nil || len(ids) != 0 {
+		t.Errorf("beta is on the free plan, got %v (err %v)", ids, err)
+	}
+	// Without meta.tenant the field is missing, as for any unknown path.
+	if ids, loads, err := run(""); err == nil || len(ids) != 0 || loads != 0 {
+		t.Errorf("no tenant: got actions %v after %d loads (err %v), want a missing-field error", ids, loads, err)
+	}
+}
+
+func TestEvalContext_LocationPrecedence(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	tokyo, _ := time.LoadLocation("Asia/Tokyo")
+	profile := func(string) (map[string]interface{}, bool) {
+		return map[string]interface{}{"timezone": "Asia/Tokyo"}, true
+	}
+
+	ev := makeEvent("t", "", nil)
+	if loc := (&dag.EvalContext{Event: ev}).Location(); loc != time.UTC {
+		t.Errorf("expected UTC fallback, got %v", loc)
+	}
+	if loc := (&dag.EvalContext{Event: ev, DefaultLocation: kolkata, LoadActor: profile}).Location(); loc.String() != tokyo.String() {
+		t.Errorf("expected actor profile zone, got %v", loc)
+	}
+	ev.Meta = map[string]string{"timezone": "Asia/Kolkata"}
+	if loc := (&dag.EvalContext{Event: ev, LoadActor: profile}).Location(); loc.String() != kolkata.String() {
+		t.Errorf("expected meta.timezone to win, got %v", loc)
+	}
+	ev.Meta["timezone"] = "Mars/Olympus"
+	if loc := (&dag.EvalContext{Event: ev}).Location(); loc != time.UTC {
+		t.Errorf("expected unknown zone to be skipped, got %v", loc)
+	}
+}
+
+func TestEvaluate_ResolvesLocationOnce(t *testing.T) {
+	build := func(expr string) *dag.Graph {
+		g, err := dag.Build(&config.RuleConfig{Version: "v1", Scenarios: []config.Scenario{{
+			ID: "sc_evening", Enabled: true, EventTypes: []string{"login"},
+			Children: []config.NodeRef{{Condition: &config.ConditionDef{
+				ID: "cond_evening", Expression: expr,
+				Children: []config.NodeRef{{Action: &config.ActionDef{ID: "act_evening", Type: "log"}}},
+			}}},
+		}}})
+		if err != nil {
+			t.Fatalf("Build error: %v", err)
+		}
+		return g
+	}
+	run := func(g *dag.Graph) (int, []dag.ActionMatch) {
+		loads := 0
+		ev := makeEvent("login", "", nil)
+		ev.OccurredAt = time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC) // 19:00 in Tokyo
+		ctx := &dag.EvalContext{
+			Event:   ev,
+			Results: dag.NewResults(0),
+			LoadActor: func(string) (map[string]interface{}, bool) {
+				loads++
+				return map[string]interface{}{"timezone": "Asia/Tokyo"}, true
+			},
+		}
+		matches, _, _ := dag.EvaluateContext(g, ctx)
+		return loads, matches
+	}
+
+	if loads, matches := run(build("hour(event.occurred_at) >= 18 AND day(event.occurred_at) == 5")); loads != 1 || len(matches) != 1 {
+		t.Errorf("calendar graph: %d actor loads, %d matches; want 1 and 1", loads, len(matches))
+	}
+	// Graphs without calendar functions do not look the zone up.
+	if loads, _ := run(build("payload.amount > 1")); loads != 0 {
+		t.Errorf("graph without calendar functions loaded the actor %d times", loads)
+	}
+}
+
+func TestEvaluate_OccurredAtMissing(t *testing.T) {
+	ev := makeEvent("login", "", nil)
+	ev.OccurredAt = time.Time{}
+	if v, ok := (&dag.EvalContext{Event: ev}).Resolve([]string{"event", "occurred_at"}); ok {
+		t.Errorf("event.occurred_at = %v without occurred_at, want missing", v)
+	}
+}
+
+func TestResults_ConcurrentWritesAndLimit(t *testing.T) {
+	r := dag.NewResults(50)
+	var wg sync.WaitGroup
+	for i := range 100 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			id := fmt.Sprintf("act_%d", i)
+			r.SetOutput(id, i)
+			r.AddDiagnostic(id, "ran")
+			r.SetVar("last", i)
+		}()
+	}
+	wg.Wait()
+	if r.Len() != 50 || !r.Full() {
+		t.Errorf("outputs = %d (full %v), want the limit of 50", r.Len(), r.Full())
+	}
+	dropped := r.Dropped()
+	if len(dropped) != 50 {
+		t.Fatalf("dropped %d outputs, want 50", len(dropped))
+	}
+	if _, ok := r.Output(dropped[0]); ok {
+		t.Errorf("dropped output %s was stored", dropped[0])
+	}
+	for i := range 100 {
+		id := fmt.Sprintf("act_%d", i)
+		if _, ok := r.Output(id); !ok {
+			continue
+		}
+		r.SetOutput(id, "replaced")
+		if v, _ := r.Output(id); v != "replaced" {
+			t.Errorf("replacing %s was refused at the limit", id)
+		}
+		break
+	}
+	if len(r.Dropped()) != 50 {
+		t.Error("a replacement was counted as dropped")
+	}
+	if len(r.Diagnostics()) != 100 {
+		t.Errorf("diagnostics for %d nodes, want 100", len(r.Diagnostics()))
+	}
+	if _, ok := r.Var("last"); !ok {
+		t.Error("var not set")
+	}
+}
+
+func TestResults_CopiesOutputsAndVars(t *testing.T) {
+	r := dag.NewResults(0)
+	out := map[string]interface{}{"fields": map[string]interface{}{"plan": "pro"}, "tags": []interface{}{"a"}}
+	r.SetOutput("act_log", out)
+	r.SetVar("profile", out)
+	out["fields"].(map[string]interface{})["plan"] = "free" // the writer keeps its map
+
+	for name, get := range map[string]func() (interface{}, bool){
+		"output": func() (interface{}, bool) { return r.Output("act_log") },
+		"var":    func() (interface{}, bool) { return r.Var("profile") },
+	} {
+		v, _ := get()
+		m := v.(map[string]interface{})
+		if m["fields"].(map[string]interface{})["plan"] != "pro" {
+			t.Errorf("%s changed with the writer's map: %v", name, m)
+		}
+		m["tags"].([]interface{})[0] = "b" // a reader changes its copy
+		if v, _ := get(); v.(map[string]interface{})["tags"].([]interface{})[0] != "a" {
+			t.Errorf("%s changed with a reader's copy", name)
+		}
+	}
+	if r.Outputs()["act_log"] == nil {
+		t.Error("Outputs lacks act_log")
+	}
+}
+
+func TestEvaluate_ErrorsRecordedAsDiagnostics(t *testing.T) {
+	g := buildTestGraph(t)
+	ev := makeEvent("transaction", "pos-system", map[string]interface{}{"category": "food"}) // no amount
+	ctx := &dag.EvalContext{Event: ev, Results: dag.NewResults(0)}
+	if _, _, err := dag.EvaluateContext(g, ctx); err == nil {
+		t.Fatal("expected a missing-field error")
+	}
+	if notes := ctx.Results.Diagnostics()["cond_amount"]; len(notes) != 1 {
+		t.Errorf("diagnostics = %v, want one note for cond_amount", ctx.Results.Diagnostics())
+	}
+}
+
+func TestEvaluate_ShedsExpensiveScenarios(t *testing.T) {
+	scenario := func(id, cost, expr string) config.Scenario {
+		return config.Scenario{
+			ID: id, Enabled: true, EventTypes: []string{"purchase"}, Cost: cost,
+			Children: []config.NodeRef{{Condition: &config.ConditionDef{
+				ID: "cond_" + id, Expression: expr,
+				Children: []config.NodeRef{{Action: &config.ActionDef{ID: "act_" + id, Type: "log"}}},
+			}}},
+		}
+	}
+	cfg := &config.RuleConfig{Version: "v1", Scenarios: []config.Scenario{
+		scenario("sc_cheap", "", "payload.amount > 1"),
+		scenario("sc_actor", "", `actor.tier == "gold"`),
+		scenario("sc_tenant", "", `tenant.plan == "premium"`),
+		scenario("sc_declared", "expensive", "payload.amount > 1"),
+		scenario("sc_forced_cheap", "cheap", `actor.tier == "gold"`),
+	}}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	expensive := map[string]bool{}
+	for _, r := range g.Roots() {
+		expensive[r.ID()] = r.Expensive()
+	}
+	want := map[string]bool{"sc_cheap": false, "sc_actor": true, "sc_tenant": true, "sc_declared": true, "sc_forced_cheap": false}
+	for id, w := range want {
+		if expensive[id] != w {
+			t.Errorf("%s expensive = %v, want %v", id, expensive[id], w)
+		}
+	}
+
+	ev := makeEvent("purchase", "", map[string]interface{}{"amount": float64(5)})
+	ctx := &dag.EvalContext{
+		Event:         ev,
+		Results:       dag.NewResults(0),
+		ShedExpensive: true,
+		LoadActor: func(string) (map[string]interface{}, bool) {
+			return map[string]interface{}{"tier": "gold"}, true
+		},
+	}
+	_, matched, _ := dag.EvaluateContext(g, ctx)
+	if len(matched) != 2 || matched[0] != "sc_cheap" || matched[1] != "sc_forced_cheap" {
+		t.Errorf("matched %v, want only the cheap scenarios", matched)
+	}
+	if len(ctx.Shed) != 3 {
+		t.Errorf("shed %v, want the three expensive scenarios", ctx.Shed)
+	}
+
+	// Events of other types are not counted as shed.
+	ctx = &dag.EvalContext{Event: makeEvent("login", "", nil), Results: dag.NewResults(0), ShedExpensive: true}
+	dag.EvaluateContext(g, ctx)
+	if len(ctx.Shed) != 0 {
+		t.Errorf("shed %v for an unrelated event type", ctx.Shed)
+	}
+}
+
+func TestBuild_ExternalActionsAreExpensive(t *testing.T) {
+	scenario := func(id, cost, actionType string) config.Scenario {
+		return config.Scenario{
+			ID: id, Enabled: true, EventTypes: []string{"purchase"}, Cost: cost,
+			Children: []config.NodeRef{{Condition: &config.ConditionDef{
+				ID: "cond_" + id, Expression: "payload.amount > 1",
+				Children: []config.NodeRef{{Action: &config.ActionDef{ID: "act_" + id, Type: actionType}}},
+			}}},
+		}
+	}
+	cfg := &config.RuleConfig{Version: "v1", Scenarios: []config.Scenario{
+		scenario("sc_log", "", "log"),
+		scenario("sc_webhook", "", "webhook"),
+		scenario("sc_webhook_cheap", "cheap", "webhook"),
+	}}
+	opts := dag.BuildOptions{ExternalAction: func(t string) bool { return t == "webhook" }}
+	g, err := dag.BuildContext(context.Background(), cfg, opts)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	want := map[string]bool{"sc_log": false, "sc_webhook": true, "sc_webhook_cheap": false}
+	for _, r := range g.Roots() {
+		if r.Expensive() != want[r.ID()] {
+			t.Errorf("%s expensive = %v, want %v", r.ID(), r.Expensive(), want[r.ID()])
+		}
+	}
+
+	// Evaluation can be limited to some scenarios, e.g. deferred ones.
+	ctx := &dag.EvalContext{
+		Event:     makeEvent("purchase", "", map[string]interface{}{"amount": float64(5)}),
+		Results:   dag.NewResults(0),
+		Scenarios: []string{"sc_webhook"},
+	}
+	if _, matched, _ := dag.EvaluateContext(g, ctx); len(matched) != 1 || matched[0] != "sc_webhook" {
+		t.Errorf("matched %v, want sc_webhook only", matched)
+	}
+}
+
+// benchGraph builds n scenarios spread over 10 event types, each a two-level
+// condition chain ending in an action, so an event is matched against n/10
+// scenarios and passes the conditions of half of them.
+func benchGraph(b *testing.B, n int) *dag.Graph {
+	b.Helper()
+	cfg := &config.RuleConfig{Version: "v1"}
+	for i := 0; i < n; i++ {
+		cfg.Scenarios = append(cfg.Scenarios, config.Scenario{
+			ID:         fmt.Sprintf("sc_%d", i),
+			Enabled:    true,
+			EventTypes: []string{fmt.Sprintf("type_%d", i%10)},
+			Children: []config.NodeRef{{Condition: &config.ConditionDef{
+				ID:         fmt.Sprintf("cond_cat_%d", i),
+				Expression: fmt.Sprintf(`payload.category == "cat_%d"`, i/10%2),
+				Children: []config.NodeRef{{Condition: &config.ConditionDef{
+					ID:         fmt.Sprintf("cond_amount_%d", i),
+					Expression: fmt.Sprintf("payload.amount > %d", i%100),
+					Children: []config.NodeRef{{Action: &config.ActionDef{
+						ID: fmt.Sprintf("act_%d", i), Type: "reward_points",
+						Params: map[string]interface{}{"operation": "award", "points": float64(10)},
+					}}},
+				}}},
+			}}},
+		})
+	}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		b.Fatalf("Build error: %v", err)
+	}
+	return g
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 10000} {
+		g := benchGraph(b, n)
+		ev := makeEvent("type_0", "pos-system", map[string]interface{}{"category": "cat_0", "amount": 500.0})
+		b.Run(fmt.Sprintf("scenarios=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, _, err := dag.Evaluate(g, ev); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package dag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
+)
+
+// eventType is a compiled config.EventTypeDef.
+type eventType struct {
+	name   string
+	derive []deriveRule
+}
+
+type deriveRule struct {
+	expr condition.Expr
+	typ  string
+}
+
+// buildEventTypes compiles cfg.EventTypes into g, keyed by the lower-cased
+// canonical type and each alias.
+func (b *builder) buildEventTypes(defs []config.EventTypeDef) error {
+	for _, d := range defs {
+		et := &eventType{name: d.Type}
+		for i, r := range d.Derive {
+			ast, err := b.compile(r.When)
+			if err != nil {
+				return fmt.Errorf("event type %s: derive[%d]: parse %q: %w", d.Type, i, r.When, err)
+			}
+			schema := &lookupRecorder{}
+			if err := condition.Check(ast, schema); err != nil {
+				return fmt.Errorf("event type %s: derive[%d]: %w", d.Type, i, err)
+			}
+			streaks := make(map[string]bool)
+			if err := streakRefs(ast, streaks); err != nil || schema.external || len(streaks) > 0 {
+				return fmt.Errorf("event type %s: derive[%d]: %q may read only the event", d.Type, i, r.When)
+			}
+			et.derive = append(et.derive, deriveRule{expr: condition.Fold(ast), typ: r.Type})
+		}
+		b.g.eventTypes[strings.ToLower(d.Type)] = et
+		for _, a := range d.Aliases {
+			b.g.eventTypes[strings.ToLower(a)] = et
+		}
+	}
+	return nil
+}
+
+// Canonicalize rewrites the type of ctx.Event as the config's event_types
+// declare: an alias becomes its canonical type, and the first derive rule
+// that holds replaces that. A rule that fails to evaluate, e.g. for a missing
+// payload field, does not hold. The type as sent is kept in RawType.
+// Canonicalize reports whether the type changed.
+func (g *Graph) Canonicalize(ctx *EvalContext) bool {
+	ev := ctx.Event
+	et, ok := g.eventTypes[strings.ToLower(ev.Type)]
+	if !ok {
+		return false
+	}
+	raw := ev.Type
+	ev.Type = et.name
+	for _, r := range et.derive {
+		if ok, err := condition.Evaluate(r.expr, ctx); err == nil && ok {
+			ev.Type = r.typ
+			break
+		}
+	}
+	if ev.Type == raw {
+		return false
+	}
+	ev.RawType = raw
+	return true
+}
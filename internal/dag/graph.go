@@ -1,18 +1,43 @@
 package dag
 
+import (
+	"sort"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/i18n"
+)
+
 // Graph holds nodes and their parent→children adjacency list.
 // It is immutable once built; hot-reload creates a new Graph and swaps atomically.
 type Graph struct {
-	nodes    map[string]Node   // id → Node
-	children map[string][]Node // parent id → ordered children
-	roots    []*ScenarioNode   // entry points
+	nodes      map[string]Node       // id → Node
+	children   map[string][]Node     // parent id → ordered children
+	roots      []*ScenarioNode       // entry points
+	refs       map[string]int        // child id → number of incoming edges
+	aliases    map[string]string     // deduplicated id → shared node id
+	locals     map[localKey]string   // shared node under a deduplicated parent → its config id there
+	pruned     []string              // ids of config nodes removed as statically unreachable
+	streaks    map[string]bool       // event types read with streak()
+	zoned      bool                  // conditions call calendar functions
+	clocked    bool                  // conditions call age(), which reads the clock
+	external   bool                  // conditions read actor, tenant or related-actor fields
+	eventTypes map[string]*eventType // lower-cased raw type → canonical type (event_types)
+
+	source *config.RuleConfig // config snapshot this graph was built from
+	hash   string             // config.Hash(source)
+	msgs   *i18n.Catalog      // localized action messages
 }
 
 // NewGraph allocates an empty Graph.
 func NewGraph() *Graph {
 	return &Graph{
-		nodes:    make(map[string]Node),
-		children: make(map[string][]Node),
+		nodes:      make(map[string]Node),
+		children:   make(map[string][]Node),
+		refs:       make(map[string]int),
+		aliases:    make(map[string]string),
+		locals:     make(map[localKey]string),
+		streaks:    make(map[string]bool),
+		eventTypes: make(map[string]*eventType),
 	}
 }
 
@@ -27,13 +52,91 @@ func (g *Graph) AddNode(n Node) {
 // AddEdge records that parent has child as a direct successor.
 func (g *Graph) AddEdge(parentID string, child Node) {
 	g.children[parentID] = append(g.children[parentID], child)
+	g.refs[child.ID()]++
+}
+
+// localKey names a shared node by the config ID of the parent it is
+// reached from.
+type localKey struct{ parent, node string }
+
+// addAlias records that id, a child of parentID in the config, was
+// deduplicated into the node canonicalID.
+func (g *Graph) addAlias(parentID, id, canonicalID string) {
+	if id != canonicalID {
+		g.aliases[id] = canonicalID
+		g.locals[localKey{parentID, canonicalID}] = id
+	}
+}
+
+// localID returns the config ID of node nodeID when reached from the parent
+// whose config ID is parentID: the ID it was deduplicated from, if any.
+func (g *Graph) localID(parentID, nodeID string) string {
+	if id, ok := g.locals[localKey{parentID, nodeID}]; ok {
+		return id
+	}
+	return nodeID
 }
 
-// Node returns a node by ID (nil if not found).
+// Node returns a node by ID (nil if not found). IDs removed by
+// deduplication resolve to the shared node that replaced them.
 func (g *Graph) Node(id string) Node {
+	if canonical, ok := g.aliases[id]; ok {
+		id = canonical
+	}
 	return g.nodes[id]
 }
 
+// RefCount returns how many parents reference the node with the given ID.
+func (g *Graph) RefCount(id string) int {
+	return g.refs[id]
+}
+
+// SharedNodeCount returns the number of nodes referenced by more than one parent.
+func (g *Graph) SharedNodeCount() int {
+	n := 0
+	for _, c := range g.refs {
+		if c > 1 {
+			n++
+		}
+	}
+	return n
+}
+
+// AliasCount returns the number of config node IDs collapsed by deduplication.
+func (g *Graph) AliasCount() int {
+	return len(g.aliases)
+}
+
+// Pruned returns the IDs of config nodes left out of the graph because a
+// condition above them folded to false at build time.
+func (g *Graph) Pruned() []string {
+	return g.pruned
+}
+
+// StreakTypes returns the event types whose streaks the graph reads, sorted.
+func (g *Graph) StreakTypes() []string {
+	types := make([]string, 0, len(g.streaks))
+	for t := range g.streaks {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// TracksStreak reports whether the graph reads streaks of eventType, so
+// events of that type must advance them.
+func (g *Graph) TracksStreak(eventType string) bool {
+	return g.streaks[eventType]
+}
+
+// ReadsState reports whether evaluating the graph can read data from
+// outside the event: actor, tenant or related-actor fields, streaks, the
+// actor's time zone for calendar functions, or the clock for age(). Such
+// results cannot be reused for an identical event.
+func (g *Graph) ReadsState() bool {
+	return g.external || g.zoned || g.clocked || len(g.streaks) > 0
+}
+
 // Children returns the direct successors of a node.
 func (g *Graph) Children(id string) []Node {
 	return g.children[id]
@@ -48,3 +151,26 @@ func (g *Graph) Roots() []*ScenarioNode {
 func (g *Graph) NodeCount() int {
 	return len(g.nodes)
 }
+
+// Config returns the config snapshot the graph was built from (nil for hand-built graphs).
+func (g *Graph) Config() *config.RuleConfig {
+	return g.source
+}
+
+// Version returns the rules version of the source config.
+func (g *Graph) Version() string {
+	if g.source == nil {
+		return ""
+	}
+	return g.source.Version
+}
+
+// Hash returns the content hash of the source config.
+func (g *Graph) Hash() string {
+	return g.hash
+}
+
+// Messages returns the message catalog built from the source config (may be nil).
+func (g *Graph) Messages() *i18n.Catalog {
+	return g.msgs
+}
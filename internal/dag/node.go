@@ -1,11 +1,16 @@
 package dag
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/gyaneshwarpardhi/ifttt/internal/condition"
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
 	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/i18n"
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
 )
 
 // NodeType discriminates the three kinds of DAG nodes.
@@ -27,10 +32,153 @@ type Node interface {
 // EvalContext carries per-event state through the DFS traversal.
 type EvalContext struct {
 	Event   *event.Event
-	Results map[string]interface{}
+	Results *Results // shared by every action run for the event
 	Errors  []error
+
+	// MissingFields maps scenarios skipped for lacking required fields to
+	// the paths that were absent.
+	MissingFields map[string][]string
+
+	// Messages is the active graph's message catalog for localized action output.
+	Messages *i18n.Catalog
+
+	// MatchInputLimit caps string length fed to the matches operator (0 = unlimited).
+	MatchInputLimit int
+
+	// DefaultLocation is the time zone for calendar functions when neither
+	// meta.timezone nor the actor profile names one. Nil = UTC.
+	DefaultLocation *time.Location
+
+	// LoadActor loads actor-scoped data for the "actor.*" namespace and for
+	// related-actor aliases. It is called at most once per actor ID per
+	// context, on first use. Nil disables both.
+	LoadActor func(actorID string) (map[string]interface{}, bool)
+
+	// LoadTenant loads tenant-scoped data (plan, limits, flags) for the
+	// "tenant.*" namespace, keyed by meta.tenant. It is called at most once
+	// per context. Nil disables the namespace.
+	LoadTenant func(tenantID string) (map[string]interface{}, bool)
+
+	// LoadStreak returns the event actor's streak, in days, for an event
+	// type, for streak("type"). It is called at most once per type per
+	// context. Nil makes streak() fail.
+	LoadStreak func(eventType string) (int, error)
+
+	// ShedExpensive skips scenarios of the expensive cost class; the ones
+	// whose event type and source matched are recorded in Shed.
+	ShedExpensive bool
+	Shed          []string
+
+	// Scenarios, when set, limits evaluation to these scenarios; their event
+	// type and source filters still apply. The engine uses it to evaluate
+	// scenarios deferred by load shedding.
+	Scenarios []string
+
+	// ForceScenario, when set, evaluates only that scenario and skips its
+	// event type and source filters; required fields and conditions still
+	// apply.
+	ForceScenario string
+
+	// Explain records every evaluated node, in evaluation order, in Trace.
+	Explain bool
+	Trace   []TraceStep
+
+	// LogContext carries logctx fields (event_id, request_id, …) for log
+	// lines written during evaluation. Nil = none.
+	LogContext context.Context
+
+	actors  map[string]map[string]interface{} // actor ID → loaded data (nil = lookup failed)
+	tenant  map[string]interface{}            // loaded tenant data (memoised with tenantLoaded)
+	related map[string][]string               // alias → path of the related actor's ID (current scenario)
+	loc     *time.Location                    // zone resolved by EvaluateContext (nil = not yet)
+	streaks map[string]int                    // event type → memoised LoadStreak result
+
+	tenantLoaded bool
+}
+
+// actorData returns (memoised) data for actorID.
+func (c *EvalContext) actorData(actorID string) (map[string]interface{}, bool) {
+	if c.LoadActor == nil || actorID == "" {
+		return nil, false
+	}
+	if data, ok := c.actors[actorID]; ok {
+		return data, data != nil
+	}
+	if c.actors == nil {
+		c.actors = make(map[string]map[string]interface{})
+	}
+	data, ok := c.LoadActor(actorID)
+	if !ok {
+		data = nil
+	}
+	c.actors[actorID] = data
+	return data, data != nil
+}
+
+// tenantData returns (memoised) data for the event's tenant.
+func (c *EvalContext) tenantData() (map[string]interface{}, bool) {
+	if !c.tenantLoaded {
+		c.tenantLoaded = true
+		if id := c.Event.Meta["tenant"]; c.LoadTenant != nil && id != "" {
+			if data, ok := c.LoadTenant(id); ok {
+				c.tenant = data
+			}
+		}
+	}
+	return c.tenant, c.tenant != nil
+}
+
+// Location implements condition.Locator. The zone comes from meta.timezone
+// (tenant or client supplied), then the actor profile's "timezone", then
+// DefaultLocation. Unknown zone names are skipped. EvaluateContext resolves
+// it once, before evaluation, for graphs that use it; otherwise it is
+// resolved on each call.
+func (c *EvalContext) Location() *time.Location {
+	if c.loc != nil {
+		return c.loc
+	}
+	return c.resolveLocation()
+}
+
+func (c *EvalContext) resolveLocation() *time.Location {
+	if loc := loadLocation(c.Event.Meta["timezone"]); loc != nil {
+		return loc
+	}
+	if data, ok := c.actorData(c.Event.ActorID); ok {
+		if name, _ := data["timezone"].(string); name != "" {
+			if loc := loadLocation(name); loc != nil {
+				return loc
+			}
+		}
+	}
+	if c.DefaultLocation != nil {
+		return c.DefaultLocation
+	}
+	return time.UTC
 }
 
+// locations caches time.LoadLocation results (nil for unknown names), which
+// otherwise read tzdata on every call.
+var locations sync.Map
+
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return nil
+	}
+	if v, ok := locations.Load(name); ok {
+		return v.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		loc = nil
+	}
+	locations.Store(name, loc)
+	return loc
+}
+
+// MaxMatchInput implements condition.MatchLimiter.
+func (c *EvalContext) MaxMatchInput() int { return c.MatchInputLimit }
+
 // Resolve implements condition.EvalContext.
 // It walks a dot-separated path into the event's fields.
 func (c *EvalContext) Resolve(path []string) (interface{}, bool) {
@@ -52,6 +200,18 @@ func (c *EvalContext) Resolve(path []string) (interface{}, bool) {
 			m[k] = v
 		}
 		return resolveMap(m, path[1:])
+	case "actor":
+		data, ok := c.actorData(c.Event.ActorID)
+		if !ok {
+			return nil, false
+		}
+		return resolveMap(data, path[1:])
+	case "tenant":
+		data, ok := c.tenantData()
+		if !ok {
+			return nil, false
+		}
+		return resolveMap(data, path[1:])
 	case "event":
 		if len(path) < 2 {
 			return nil, false
@@ -59,13 +219,39 @@ func (c *EvalContext) Resolve(path []string) (interface{}, bool) {
 		switch path[1] {
 		case "type":
 			return c.Event.Type, true
+		case "raw_type":
+			if c.Event.RawType == "" {
+				return c.Event.Type, true
+			}
+			return c.Event.RawType, true
 		case "source":
 			return c.Event.Source, true
 		case "actor_id":
 			return c.Event.ActorID, true
 		case "id":
 			return c.Event.ID, true
+		case "occurred_at":
+			if c.Event.OccurredAt.IsZero() {
+				return nil, false
+			}
+			return c.Event.OccurredAt, true
+		}
+	default:
+		// Related actor alias declared on the scenario, e.g. referrer.* where
+		// referrer is resolved from payload.referrer_id.
+		idPath, ok := c.related[path[0]]
+		if !ok {
+			return nil, false
+		}
+		idVal, ok := c.Resolve(idPath)
+		if !ok || idVal == nil {
+			return nil, false
+		}
+		data, ok := c.actorData(fmt.Sprint(idVal))
+		if !ok {
+			return nil, false
 		}
+		return resolveMap(data, path[1:])
 	}
 	return nil, false
 }
@@ -98,6 +284,9 @@ type ScenarioNode struct {
 	id         string
 	eventTypes map[string]struct{}
 	sources    map[string]struct{} // empty = all sources allowed
+	related    map[string][]string // related-actor alias → ID field path
+	required   [][]string          // field paths every event must carry
+	expensive  bool                // cost class; shed under overload
 }
 
 func NewScenarioNode(id string, eventTypes, sources []string) *ScenarioNode {
@@ -115,14 +304,56 @@ func NewScenarioNode(id string, eventTypes, sources []string) *ScenarioNode {
 func (n *ScenarioNode) ID() string     { return n.id }
 func (n *ScenarioNode) Type() NodeType { return NodeTypeScenario }
 
-func (n *ScenarioNode) Evaluate(ctx *EvalContext) (bool, error) {
-	if _, ok := n.eventTypes[strings.ToLower(ctx.Event.Type)]; !ok {
-		return false, nil
+// SetRelatedActors declares aliases (e.g. "referrer") whose data is loaded
+// for the actor whose ID is found at the given field path.
+func (n *ScenarioNode) SetRelatedActors(aliases map[string]string) {
+	n.related = make(map[string][]string, len(aliases))
+	for alias, path := range aliases {
+		n.related[alias] = strings.Split(path, ".")
 	}
-	if len(n.sources) > 0 {
-		if _, ok := n.sources[strings.ToLower(ctx.Event.Source)]; !ok {
+}
+
+// Expensive reports whether the scenario is in the expensive cost class,
+// either declared (cost: expensive) or because its conditions read actor,
+// tenant or related-actor fields or it runs an external action.
+func (n *ScenarioNode) Expensive() bool { return n.expensive }
+
+// SetRequiredFields declares field paths (e.g. "payload.amount") an event
+// must carry for the scenario to be evaluated.
+func (n *ScenarioNode) SetRequiredFields(paths []string) {
+	n.required = make([][]string, len(paths))
+	for i, p := range paths {
+		n.required[i] = strings.Split(p, ".")
+	}
+}
+
+// Evaluate passes when the event's type and source match, or the scenario
+// is ctx.ForceScenario. An event that
+// matches but lacks a required field fails without an error, and the missing
+// paths are recorded in ctx.MissingFields.
+func (n *ScenarioNode) Evaluate(ctx *EvalContext) (bool, error) {
+	if ctx.ForceScenario != n.id {
+		if _, ok := n.eventTypes[strings.ToLower(ctx.Event.Type)]; !ok {
 			return false, nil
 		}
+		if len(n.sources) > 0 {
+			if _, ok := n.sources[strings.ToLower(ctx.Event.Source)]; !ok {
+				return false, nil
+			}
+		}
+	}
+	var missing []string
+	for _, path := range n.required {
+		if _, ok := ctx.Resolve(path); !ok {
+			missing = append(missing, strings.Join(path, "."))
+		}
+	}
+	if len(missing) > 0 {
+		if ctx.MissingFields == nil {
+			ctx.MissingFields = make(map[string][]string)
+		}
+		ctx.MissingFields[n.id] = missing
+		return false, nil
 	}
 	return true, nil
 }
@@ -158,6 +389,8 @@ type ActionNode struct {
 	id         string
 	actionType string
 	params     map[string]interface{}
+	limit      *config.ActionLimit
+	signature  string
 }
 
 func NewActionNode(id, actionType string, params map[string]interface{}) *ActionNode {
@@ -169,10 +402,17 @@ func (n *ActionNode) Type() NodeType                 { return NodeTypeAction }
 func (n *ActionNode) ActionType() string             { return n.actionType }
 func (n *ActionNode) Params() map[string]interface{} { return n.params }
 
+// Limit is the per-actor execution limit, or nil.
+func (n *ActionNode) Limit() *config.ActionLimit { return n.limit }
+
+// Signature identifies what the action does: actions with the same type,
+// params and limit have the same signature, whatever their IDs. Set by Build.
+func (n *ActionNode) Signature() string { return n.signature }
+
 func (n *ActionNode) Evaluate(ctx *EvalContext) (bool, error) {
 	// ActionNodes are leaves; "evaluation" just signals the engine to execute.
 	if ctx.Results == nil {
-		return false, fmt.Errorf("nil results map")
+		return false, fmt.Errorf("nil results")
 	}
 	return true, nil
 }
@@ -0,0 +1,155 @@
+package dag
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// Results collects what one event's evaluation and actions produce. Entries
+// are namespaced so actions running in parallel never write to the same map:
+//
+//   - outputs: one value per action ID, written by the action's executor
+//   - diagnostics: notes per node ID, such as evaluation errors
+//   - vars: named values computed by one action for later ones to read
+//
+// All methods are safe for concurrent use. Outputs and vars are copied on
+// the way in and out when they are JSON-shaped maps and slices
+// (map[string]interface{}, []interface{}); values of other types are stored
+// as given and must not be changed once stored.
+type Results struct {
+	limit int // cap on outputs; 0 = unlimited
+
+	mu      sync.RWMutex
+	outputs map[string]interface{}
+	dropped []string // action IDs whose output the limit refused
+	diags   map[string][]string
+	vars    map[string]interface{}
+}
+
+// NewResults creates an empty Results holding at most limit action outputs
+// (0 = unlimited), per engine.max_results.
+func NewResults(limit int) *Results {
+	return &Results{limit: limit}
+}
+
+// SetOutput records actionID's output. Once the output limit is reached, v
+// is dropped and actionID is reported by Dropped; replacing an existing
+// output always succeeds.
+func (r *Results) SetOutput(actionID string, v interface{}) {
+	v = cloneValue(v)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.outputs[actionID]; !ok && r.limit > 0 && len(r.outputs) >= r.limit {
+		r.dropped = append(r.dropped, actionID)
+		return
+	}
+	if r.outputs == nil {
+		r.outputs = make(map[string]interface{})
+	}
+	r.outputs[actionID] = v
+}
+
+// Output returns actionID's output.
+func (r *Results) Output(actionID string) (interface{}, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.outputs[actionID]
+	return cloneValue(v), ok
+}
+
+// Outputs returns every action output by action ID.
+func (r *Results) Outputs() map[string]interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make(map[string]interface{}, len(r.outputs))
+	for id, v := range r.outputs {
+		out[id] = cloneValue(v)
+	}
+	return out
+}
+
+// Len returns the number of action outputs.
+func (r *Results) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.outputs)
+}
+
+// Full reports whether the output limit is reached.
+func (r *Results) Full() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.limit > 0 && len(r.outputs) >= r.limit
+}
+
+// Dropped returns the action IDs whose output SetOutput dropped at the
+// limit, in the order they were dropped.
+func (r *Results) Dropped() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.dropped)
+}
+
+// AddDiagnostic appends a note for nodeID.
+func (r *Results) AddDiagnostic(nodeID, msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.diags == nil {
+		r.diags = make(map[string][]string)
+	}
+	r.diags[nodeID] = append(r.diags[nodeID], msg)
+}
+
+// Diagnostics returns the notes recorded per node ID, or nil when there
+// are none.
+func (r *Results) Diagnostics() map[string][]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if len(r.diags) == 0 {
+		return nil
+	}
+	out := make(map[string][]string, len(r.diags))
+	for id, notes := range r.diags {
+		out[id] = slices.Clone(notes)
+	}
+	return out
+}
+
+// SetVar sets a computed variable.
+func (r *Results) SetVar(name string, v interface{}) {
+	v = cloneValue(v)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.vars == nil {
+		r.vars = make(map[string]interface{})
+	}
+	r.vars[name] = v
+}
+
+// Var returns a computed variable.
+func (r *Results) Var(name string) (interface{}, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.vars[name]
+	return cloneValue(v), ok
+}
+
+// cloneValue deep-copies the maps and slices of a JSON-shaped value.
+func cloneValue(v interface{}) interface{} {
+	switch x := v.(type) {
+	case map[string]interface{}:
+		out := maps.Clone(x)
+		for k, e := range out {
+			out[k] = cloneValue(e)
+		}
+		return out
+	case []interface{}:
+		out := slices.Clone(x)
+		for i, e := range out {
+			out[i] = cloneValue(e)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,65 @@
+package dag
+
+import (
+	"fmt"
+
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
+)
+
+// fieldSchema is the build-time view of what EvalContext.Resolve can return
+// for a scenario: the fixed event fields, string-valued meta, and open-ended
+// payload, actor and related-actor namespaces.
+type fieldSchema struct {
+	related map[string]string // related-actor alias → ID path
+}
+
+var eventFields = map[string]struct{}{
+	"type": {}, "raw_type": {}, "source": {}, "actor_id": {}, "id": {},
+}
+
+// lookupRecorder notes whether any checked path reads data fetched from
+// outside the event (actor, tenant or related-actor fields).
+type lookupRecorder struct {
+	fieldSchema
+	external bool
+}
+
+func (r *lookupRecorder) Lookup(path []string) (condition.FieldKind, error) {
+	if _, related := r.related[path[0]]; related || path[0] == "actor" || path[0] == "tenant" {
+		r.external = true
+	}
+	return r.fieldSchema.Lookup(path)
+}
+
+func (s fieldSchema) Lookup(path []string) (condition.FieldKind, error) {
+	switch path[0] {
+	case "payload", "actor", "tenant":
+		if len(path) < 2 {
+			return condition.KindUnknown, fmt.Errorf("%s needs a field name, e.g. %s.amount", path[0], path[0])
+		}
+		return condition.KindUnknown, nil
+	case "meta":
+		if len(path) != 2 {
+			return condition.KindUnknown, fmt.Errorf("meta values are flat strings; use meta.<key>")
+		}
+		return condition.KindString, nil
+	case "event":
+		if len(path) != 2 {
+			return condition.KindUnknown, fmt.Errorf("use event.type, event.raw_type, event.source, event.actor_id, event.id or event.occurred_at")
+		}
+		if path[1] == "occurred_at" {
+			return condition.KindUnknown, nil // a timestamp, typically passed to age()
+		}
+		if _, ok := eventFields[path[1]]; !ok {
+			return condition.KindUnknown, fmt.Errorf("unknown event field %q; use event.type, event.raw_type, event.source, event.actor_id, event.id or event.occurred_at", path[1])
+		}
+		return condition.KindString, nil
+	}
+	if _, ok := s.related[path[0]]; ok {
+		if len(path) < 2 {
+			return condition.KindUnknown, fmt.Errorf("%s needs a field name", path[0])
+		}
+		return condition.KindUnknown, nil
+	}
+	return condition.KindUnknown, fmt.Errorf("unknown namespace %q (expected payload, meta, event, actor, tenant or a related_actors alias)", path[0])
+}
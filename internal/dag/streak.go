@@ -0,0 +1,107 @@
+package dag
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
+)
+
+// funcs are the functions only rule expressions can call, since they need
+// the rules' EvalContext. They are kept off the condition package's
+// registry, which every user of pkg/condition shares.
+var funcs = func() *condition.Funcs {
+	f := condition.NewFuncs()
+	f.Register("streak", condition.KindNumber, streakFunc)
+	return f
+}()
+
+// Parse parses a rule expression: condition.Parse plus streak().
+func Parse(expr string) (condition.Expr, error) {
+	return funcs.Parse(expr)
+}
+
+// Functions returns the names of the functions rule expressions can call,
+// sorted.
+func Functions() []string {
+	return funcs.Names()
+}
+
+// streakFunc implements streak("type"): the number of consecutive days, up
+// to the event's, on which the event's actor sent an event of that type. A
+// streak whose last day is yesterday still counts, so streak("login") >= 7
+// holds for a purchase the morning after a week of logins.
+func streakFunc(arg interface{}, ctx condition.EvalContext) (interface{}, error) {
+	eventType, ok := arg.(string)
+	if !ok || eventType == "" {
+		return nil, fmt.Errorf("needs an event type, got %T", arg)
+	}
+	c, ok := ctx.(*EvalContext)
+	if !ok {
+		return nil, errors.New("streaks are only available to rules")
+	}
+	n, err := c.streak(eventType)
+	if err != nil {
+		return nil, err
+	}
+	return float64(n), nil
+}
+
+// streak returns the (memoised) streak of the event's actor for eventType.
+func (c *EvalContext) streak(eventType string) (int, error) {
+	if n, ok := c.streaks[eventType]; ok {
+		return n, nil
+	}
+	if c.LoadStreak == nil {
+		return 0, errors.New("streaks are not tracked here")
+	}
+	n, err := c.LoadStreak(eventType)
+	if err != nil {
+		return 0, err
+	}
+	if c.streaks == nil {
+		c.streaks = make(map[string]int)
+	}
+	c.streaks[eventType] = n
+	return n, nil
+}
+
+// streakRefs adds the event types named by streak() calls in expr to types.
+// The engine only keeps streaks the rules read, so the type must be a
+// string literal known when the graph is built.
+func streakRefs(expr condition.Expr, types map[string]bool) error {
+	switch e := expr.(type) {
+	case *condition.BinaryExpr:
+		if err := streakRefs(e.Left, types); err != nil {
+			return err
+		}
+		return streakRefs(e.Right, types)
+	case *condition.NotExpr:
+		return streakRefs(e.Expr, types)
+	case *condition.ComparisonExpr:
+		if err := streakOperandRefs(e.Left, types); err != nil {
+			return err
+		}
+		return streakOperandRefs(e.Right, types)
+	}
+	return nil
+}
+
+func streakOperandRefs(op condition.Operand, types map[string]bool) error {
+	f, ok := op.(*condition.FuncOperand)
+	if !ok {
+		return nil
+	}
+	if f.Name != "streak" {
+		return streakOperandRefs(f.Arg, types)
+	}
+	var eventType string
+	if lit, ok := f.Arg.(*condition.LiteralOperand); ok {
+		eventType, _ = lit.Value.(string)
+	}
+	if eventType == "" {
+		return fmt.Errorf("%s: the event type must be a quoted string", condition.OperandString(f))
+	}
+	types[eventType] = true
+	return nil
+}
@@ -0,0 +1,379 @@
+// Package drift profiles the payloads of processed events per event type and
+// reports when producers change them: a field appears, a field's presence
+// ratio moves, or its value type changes. Rules keyed on a renamed or
+// retyped field stop matching without any error, so this is the early
+// warning.
+//
+// A sample of events feeds the current window. When the window closes, each
+// event type with enough samples is compared field by field against an
+// exponentially weighted baseline of its earlier windows.
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// Bounds on what is tracked, so a producer sending random keys cannot grow
+// memory or metric cardinality without limit.
+const (
+	maxEventTypes = 100
+	maxFields     = 200 // per event type
+	maxDepth      = 8   // nested objects below this are recorded as "object"
+	recentDrifts  = 100
+	forgetBelow   = 0.01 // baseline presence under which a field is dropped
+)
+
+// otherType labels the payload size metric for event types that are neither
+// in the rules nor baselined, so clients cannot mint series by sending
+// arbitrary types.
+const otherType = "other"
+
+// Drift kinds.
+const (
+	NewField   = "new_field" // a field absent from the baseline now appears in many events
+	Presence   = "presence"  // the share of events carrying a field moved by the threshold
+	TypeChange = "type"      // the most common value type of a field changed
+)
+
+// Drift is one field of one event type that moved away from its baseline.
+type Drift struct {
+	EventType        string    `json:"event_type"`
+	Field            string    `json:"field"`
+	Kind             string    `json:"kind"`
+	Presence         float64   `json:"presence"`
+	BaselinePresence float64   `json:"baseline_presence"`
+	Type             string    `json:"type,omitempty"`
+	BaselineType     string    `json:"baseline_type,omitempty"`
+	Samples          int       `json:"samples"`
+	WindowEnd        time.Time `json:"window_end"`
+}
+
+// Field is the profile of one payload field in the last closed window.
+type Field struct {
+	Path             string         `json:"field"`
+	Presence         float64        `json:"presence"`
+	Types            map[string]int `json:"types,omitempty"` // value type → sampled events
+	BaselinePresence float64        `json:"baseline_presence"`
+	BaselineType     string         `json:"baseline_type,omitempty"`
+}
+
+// Profile is the last closed window of one event type.
+type Profile struct {
+	EventType       string    `json:"event_type"`
+	Samples         int       `json:"samples"`
+	WindowEnd       time.Time `json:"window_end"`
+	PayloadBytesAvg float64   `json:"payload_bytes_avg"`
+	PayloadBytesMax int       `json:"payload_bytes_max"`
+	Fields          []Field   `json:"fields"`
+}
+
+// Report is what GET /v1/analytics/payloads returns.
+type Report struct {
+	SampleRate float64   `json:"sample_rate"`
+	WindowSec  int       `json:"window_sec"`
+	Profiles   []Profile `json:"event_types"`
+	Drift      []Drift   `json:"drift"` // newest first
+}
+
+type window struct {
+	samples  int
+	bytes    int
+	maxBytes int
+	fields   map[string]map[string]int // path → value type → count
+}
+
+type baseline struct {
+	presence float64
+	kind     string
+}
+
+// Analyzer samples processed events and detects payload drift.
+type Analyzer struct {
+	conf   config.DriftConf
+	sample func() float64
+
+	mu         sync.Mutex
+	configured map[string]bool // event types the rules name (lower case)
+	current    map[string]*window
+	baselines  map[string]map[string]*baseline // event type → path → baseline
+	last       map[string]Profile
+	drifts     []Drift // oldest first, at most recentDrifts
+}
+
+// New creates an Analyzer for conf.
+func New(conf config.DriftConf) *Analyzer {
+	return &Analyzer{
+		conf:      conf,
+		sample:    rand.Float64,
+		current:   make(map[string]*window),
+		baselines: make(map[string]map[string]*baseline),
+		last:      make(map[string]Profile),
+	}
+}
+
+// SetRules records the event types cfg's scenarios and event_types name, so
+// their payload sizes are labelled by type before they have a baseline.
+func (a *Analyzer) SetRules(cfg *config.RuleConfig) {
+	types := make(map[string]bool)
+	for _, sc := range cfg.Scenarios {
+		for _, t := range sc.EventTypes {
+			types[strings.ToLower(t)] = true
+		}
+	}
+	for _, et := range cfg.EventTypes {
+		types[strings.ToLower(et.Type)] = true
+		for _, d := range et.Derive {
+			types[strings.ToLower(d.Type)] = true
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.configured = types
+}
+
+// Observe profiles ev if it is sampled. It is safe for concurrent use.
+func (a *Analyzer) Observe(ev *event.Event) {
+	if a.conf.SampleRate < 1 && a.sample() >= a.conf.SampleRate {
+		return
+	}
+	eventType := strings.ToLower(ev.Type)
+	size := 0
+	if b, err := json.Marshal(ev.Payload); err == nil {
+		size = len(b)
+	}
+	fields := make(map[string]string)
+	flatten("", ev.Payload, 0, fields)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	w := a.current[eventType]
+	if w == nil {
+		if _, known := a.baselines[eventType]; !known && len(a.current)+len(a.baselines) >= maxEventTypes {
+			return
+		}
+		w = &window{fields: make(map[string]map[string]int)}
+		a.current[eventType] = w
+	}
+	label := eventType
+	if _, baselined := a.baselines[eventType]; !baselined && !a.configured[eventType] {
+		label = otherType
+	}
+	metrics.PayloadBytes.WithLabelValues(label).Observe(float64(size))
+	w.samples++
+	w.bytes += size
+	w.maxBytes = max(w.maxBytes, size)
+	for path, kind := range fields {
+		kinds := w.fields[path]
+		if kinds == nil {
+			if len(w.fields) >= maxFields {
+				continue
+			}
+			kinds = make(map[string]int)
+			w.fields[path] = kinds
+		}
+		kinds[kind]++
+	}
+}
+
+// Run closes a window every conf.WindowSec until ctx is cancelled, logging
+// each drift.
+func (a *Analyzer) Run(ctx context.Context) {
+	t := time.NewTicker(a.conf.WindowSec.Duration())
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			for _, d := range a.Roll(now) {
+				slog.Warn("payload drift",
+					"event_type", d.EventType,
+					"field", d.Field,
+					"kind", d.Kind,
+					"presence", d.Presence,
+					"baseline_presence", d.BaselinePresence,
+					"type", d.Type,
+					"baseline_type", d.BaselineType,
+				)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Roll closes the current window, compares every event type with at least
+// conf.MinSamples samples against its baseline, updates the baselines and
+// returns the drifts found. The first window of a type only sets its
+// baseline.
+func (a *Analyzer) Roll(now time.Time) []Drift {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	current := a.current
+	a.current = make(map[string]*window)
+
+	var found []Drift
+	for eventType, w := range current {
+		if w.samples < a.conf.MinSamples || w.samples == 0 {
+			continue
+		}
+		base, seen := a.baselines[eventType]
+		if !seen {
+			base = make(map[string]*baseline)
+			a.baselines[eventType] = base
+		}
+		prof := Profile{
+			EventType:       eventType,
+			Samples:         w.samples,
+			WindowEnd:       now,
+			PayloadBytesAvg: float64(w.bytes) / float64(w.samples),
+			PayloadBytesMax: w.maxBytes,
+		}
+
+		// Fields in the baseline but not this window have presence 0.
+		paths := make(map[string]struct{}, len(w.fields)+len(base))
+		for p := range w.fields {
+			paths[p] = struct{}{}
+		}
+		for p := range base {
+			paths[p] = struct{}{}
+		}
+		for path := range paths {
+			kinds := w.fields[path]
+			present, kind := 0, dominant(kinds)
+			for _, n := range kinds {
+				present += n
+			}
+			presence := float64(present) / float64(w.samples)
+			d := Drift{EventType: eventType, Field: path, Presence: presence, Type: kind, Samples: w.samples, WindowEnd: now}
+
+			b, ok := base[path]
+			switch {
+			case !ok:
+				if seen && presence >= a.conf.Threshold {
+					d.Kind = NewField
+				}
+				b = &baseline{presence: presence, kind: kind}
+				base[path] = b
+			default:
+				d.BaselinePresence, d.BaselineType = b.presence, b.kind
+				if math.Abs(presence-b.presence) >= a.conf.Threshold {
+					d.Kind = Presence
+				} else if kind != "" && b.kind != "" && kind != b.kind {
+					d.Kind = TypeChange
+				}
+				b.presence = a.conf.Alpha*presence + (1-a.conf.Alpha)*b.presence
+				if kind != "" {
+					b.kind = kind
+				}
+			}
+			if d.Kind != "" {
+				metrics.PayloadDrift.WithLabelValues(eventType, d.Kind).Inc()
+				found = append(found, d)
+			}
+			if b.presence < forgetBelow {
+				delete(base, path)
+				metrics.PayloadFieldPresence.DeleteLabelValues(eventType, path)
+				continue
+			}
+			metrics.PayloadFieldPresence.WithLabelValues(eventType, path).Set(presence)
+			prof.Fields = append(prof.Fields, Field{
+				Path:             path,
+				Presence:         presence,
+				Types:            kinds,
+				BaselinePresence: b.presence,
+				BaselineType:     b.kind,
+			})
+		}
+		sort.Slice(prof.Fields, func(i, j int) bool { return prof.Fields[i].Path < prof.Fields[j].Path })
+		a.last[eventType] = prof
+	}
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].EventType != found[j].EventType {
+			return found[i].EventType < found[j].EventType
+		}
+		return found[i].Field < found[j].Field
+	})
+	a.drifts = append(a.drifts, found...)
+	if n := len(a.drifts) - recentDrifts; n > 0 {
+		a.drifts = append(a.drifts[:0:0], a.drifts[n:]...)
+	}
+	return found
+}
+
+// Report returns the last closed window of every event type, or only of
+// eventType when it is not empty, and the recent drifts.
+func (a *Analyzer) Report(eventType string) Report {
+	eventType = strings.ToLower(eventType)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r := Report{
+		SampleRate: a.conf.SampleRate,
+		WindowSec:  int(a.conf.WindowSec),
+		Profiles:   []Profile{},
+		Drift:      []Drift{},
+	}
+	for t, p := range a.last {
+		if eventType == "" || t == eventType {
+			r.Profiles = append(r.Profiles, p)
+		}
+	}
+	sort.Slice(r.Profiles, func(i, j int) bool { return r.Profiles[i].EventType < r.Profiles[j].EventType })
+	for i := len(a.drifts) - 1; i >= 0; i-- {
+		if d := a.drifts[i]; eventType == "" || d.EventType == eventType {
+			r.Drift = append(r.Drift, d)
+		}
+	}
+	return r
+}
+
+// flatten records the value type of every field in m under its dotted path.
+// Objects are recorded themselves and descended into; arrays are not.
+func flatten(prefix string, m map[string]interface{}, depth int, out map[string]string) {
+	for k, v := range m {
+		path := prefix + k
+		out[path] = kindOf(v)
+		if obj, ok := v.(map[string]interface{}); ok && depth+1 < maxDepth {
+			flatten(path+".", obj, depth+1, out)
+		}
+	}
+}
+
+// kindOf names the JSON type of a decoded value.
+func kindOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return "number"
+}
+
+// dominant returns the most common value type, ties broken by name.
+func dominant(kinds map[string]int) string {
+	best, n := "", 0
+	for k, c := range kinds {
+		if c > n || (c == n && k < best) {
+			best, n = k, c
+		}
+	}
+	return best
+}
@@ -0,0 +1,102 @@
+package drift
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+func feed(a *Analyzer, n int, payload func(i int) map[string]interface{}) {
+	for i := 0; i < n; i++ {
+		a.Observe(&event.Event{Type: "Transaction", Payload: payload(i)})
+	}
+}
+
+func TestAnalyzer_DetectsDrift(t *testing.T) {
+	a := New(config.DriftConf{SampleRate: 1, MinSamples: 10, Threshold: 0.2, Alpha: 0.3})
+	now := time.Now()
+
+	feed(a, 20, func(i int) map[string]interface{} {
+		return map[string]interface{}{"amount": 10.0, "card": map[string]interface{}{"last4": "4242"}}
+	})
+	if d := a.Roll(now); len(d) != 0 {
+		t.Fatalf("first window only sets the baseline, got %+v", d)
+	}
+
+	// The producer renames amount to total (sent as a string) and retypes
+	// card.last4 as a number.
+	feed(a, 20, func(i int) map[string]interface{} {
+		return map[string]interface{}{"total": "10", "card": map[string]interface{}{"last4": 4242.0}}
+	})
+	got := map[string]string{}
+	for _, d := range a.Roll(now) {
+		got[d.Field] = d.Kind
+	}
+	want := map[string]string{"amount": Presence, "total": NewField, "card.last4": TypeChange}
+	if len(got) != len(want) {
+		t.Fatalf("drifts = %v, want %v", got, want)
+	}
+	for f, k := range want {
+		if got[f] != k {
+			t.Errorf("%s: kind %q, want %q", f, got[f], k)
+		}
+	}
+
+	r := a.Report("transaction")
+	if len(r.Profiles) != 1 || r.Profiles[0].Samples != 20 || len(r.Drift) != 3 {
+		t.Fatalf("report = %+v", r)
+	}
+	for _, f := range r.Profiles[0].Fields {
+		if f.Path == "total" && (f.Presence != 1 || f.Types["string"] != 20) {
+			t.Errorf("total profile = %+v", f)
+		}
+	}
+}
+
+func TestAnalyzer_SamplingAndSmallWindows(t *testing.T) {
+	a := New(config.DriftConf{SampleRate: 0.5, MinSamples: 10, Threshold: 0.2, Alpha: 0.3})
+	calls := 0
+	a.sample = func() float64 { calls++; return float64(calls%2) * 0.9 } // every other event
+	feed(a, 30, func(int) map[string]interface{} { return map[string]interface{}{"x": true} })
+	a.Roll(time.Now())
+	if r := a.Report(""); len(r.Profiles) != 1 || r.Profiles[0].Samples != 15 {
+		t.Fatalf("expected 15 of 30 events sampled, got %+v", r.Profiles)
+	}
+
+	feed(a, 10, func(int) map[string]interface{} { return map[string]interface{}{"y": true} })
+	if d := a.Roll(time.Now()); d != nil {
+		t.Errorf("a window under min_samples must not be compared, got %+v", d)
+	}
+}
+
+func TestAnalyzer_PayloadBytesLabels(t *testing.T) {
+	samples := func(label string) uint64 {
+		var m dto.Metric
+		if err := metrics.PayloadBytes.WithLabelValues(label).(prometheus.Histogram).Write(&m); err != nil {
+			t.Fatal(err)
+		}
+		return m.GetHistogram().GetSampleCount()
+	}
+	a := New(config.DriftConf{SampleRate: 1, MinSamples: 1, Threshold: 0.2, Alpha: 0.3})
+	a.SetRules(&config.RuleConfig{Scenarios: []config.Scenario{{ID: "sc", EventTypes: []string{"Label_Ruled"}}}})
+	other := samples(otherType)
+
+	a.Observe(&event.Event{Type: "label_ruled"})
+	a.Observe(&event.Event{Type: "label_unknown"})
+	if samples("label_ruled") != 1 || samples(otherType) != other+1 {
+		t.Fatalf("ruled type not labelled by type, or unknown type not labelled %q", otherType)
+	}
+
+	// Once baselined, a type gets its own series.
+	a.Roll(time.Now())
+	a.Observe(&event.Event{Type: "label_unknown"})
+	if samples("label_unknown") != 1 || samples(otherType) != other+1 {
+		t.Errorf("baselined type not labelled by type")
+	}
+}
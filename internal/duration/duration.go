@@ -0,0 +1,61 @@
+// Package duration parses human-friendly durations used in config and
+// expressions. It accepts everything time.ParseDuration does plus the day
+// ("d") and week ("w") units, e.g. "5m", "24h", "7d", "1d12h".
+package duration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var units = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// Parse parses a duration string such as "90s", "7d" or "-1h30m".
+func Parse(s string) (time.Duration, error) {
+	orig := s
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return 0, fmt.Errorf("invalid duration %q", orig)
+	}
+	var total time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.') {
+			i++
+		}
+		j := i
+		for j < len(s) && !(s[j] >= '0' && s[j] <= '9' || s[j] == '.') {
+			j++
+		}
+		if i == 0 || j == i {
+			return 0, fmt.Errorf("invalid duration %q: want <number><unit>, e.g. 5m or 7d", orig)
+		}
+		n, err := strconv.ParseFloat(s[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", orig, err)
+		}
+		unit, ok := units[s[i:j]]
+		if !ok {
+			return 0, fmt.Errorf("invalid duration %q: unknown unit %q", orig, s[i:j])
+		}
+		total += time.Duration(n * float64(unit))
+		s = s[j:]
+	}
+	if neg {
+		total = -total
+	}
+	return total, nil
+}
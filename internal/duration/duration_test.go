@@ -0,0 +1,29 @@
+package duration
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"500ms":  500 * time.Millisecond,
+		"5m":     5 * time.Minute,
+		"24h":    24 * time.Hour,
+		"7d":     7 * 24 * time.Hour,
+		"1d12h":  36 * time.Hour,
+		"1.5h":   90 * time.Minute,
+		"2w":     14 * 24 * time.Hour,
+		"-1h30m": -90 * time.Minute,
+	} {
+		got, err := Parse(in)
+		if err != nil || got != want {
+			t.Errorf("Parse(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "5", "h", "5x", "1d-2h"} {
+		if _, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q): expected error", in)
+		}
+	}
+}
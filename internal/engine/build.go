@@ -0,0 +1,44 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+)
+
+// BuildGraph validates cfg, migrates and validates its action params
+// against reg's executors, and builds its graph. Every config that becomes
+// the live graph, at startup, on hot-reload or through the API, goes
+// through it. ctx supplies logctx fields for what the build logs.
+func BuildGraph(ctx context.Context, reg *action.Registry, cfg *config.RuleConfig) (*dag.Graph, error) {
+	if err := config.Validate(cfg); err != nil {
+		return nil, err
+	}
+	if err := reg.PrepareParams(cfg); err != nil {
+		return nil, err
+	}
+	return dag.BuildContext(ctx, cfg, dag.BuildOptions{ExternalAction: reg.IsExternal})
+}
+
+// builtGraph is the last graph Engine.BuildGraph built and its config.
+type builtGraph struct {
+	cfg *config.RuleConfig
+	g   *dag.Graph
+}
+
+// BuildGraph is the package-level BuildGraph with the engine's registry. It
+// remembers the last graph it built, so an API change and the loader's
+// OnChange callback that both build the same config build it once.
+func (e *Engine) BuildGraph(ctx context.Context, cfg *config.RuleConfig) (*dag.Graph, error) {
+	if b := e.built.Load(); b != nil && b.cfg == cfg {
+		return b.g, nil
+	}
+	g, err := BuildGraph(ctx, e.registry, cfg)
+	if err != nil {
+		return nil, err
+	}
+	e.built.Store(&builtGraph{cfg: cfg, g: g})
+	return g, nil
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"slices"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+)
+
+// collapseActions keeps the first of each group of matches with the same
+// action signature and folds the rest into its Collapsed, so an event that
+// matches one action through several scenarios runs it once
+// (engine.dedupe_actions). Matches stay in evaluation order.
+func collapseActions(matches []dag.ActionMatch) []dag.ActionMatch {
+	if len(matches) < 2 {
+		return matches
+	}
+	first := make(map[string]int, len(matches))
+	out := make([]dag.ActionMatch, 0, len(matches))
+	for _, m := range matches {
+		sig := m.Node.Signature()
+		if i, ok := first[sig]; ok && sig != "" {
+			out[i].Collapsed = append(out[i].Collapsed, m)
+			continue
+		}
+		first[sig] = len(out)
+		out = append(out, m)
+	}
+	return out
+}
+
+// collapsedInto returns the scenarios that matched m or a match collapsed
+// into it, and the IDs of those collapsed actions other than m's own; both
+// are nil when nothing was collapsed.
+func collapsedInto(m dag.ActionMatch) (scenarios, actionIDs []string) {
+	if len(m.Collapsed) == 0 {
+		return nil, nil
+	}
+	scenarios = []string{m.ScenarioID}
+	for _, c := range m.Collapsed {
+		if !slices.Contains(scenarios, c.ScenarioID) {
+			scenarios = append(scenarios, c.ScenarioID)
+		}
+		if id := c.ActionID; id != m.ActionID && !slices.Contains(actionIDs, id) {
+			actionIDs = append(actionIDs, id)
+		}
+	}
+	return scenarios, actionIDs
+}
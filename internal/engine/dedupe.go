@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// ErrDuplicate is returned by Enqueue for an event whose ID was already
+// accepted within engine.dedupe_window_ms.
+var ErrDuplicate = errors.New("duplicate event id")
+
+// dedupeGCEvery bounds how often expired IDs are swept.
+const dedupeGCEvery = time.Minute
+
+// seenIDs remembers accepted event IDs for the dedupe window. It sits in
+// front of every submission path (sync, streaming, async and the inbox
+// dispatcher), so an ID sent to /v1/events and again in a batch is processed
+// once whichever arrives first.
+type seenIDs struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+	lastGC  time.Time
+}
+
+func newSeenIDs() *seenIDs {
+	return &seenIDs{expires: make(map[string]time.Time)}
+}
+
+// claim records id until now+window and reports whether it was new.
+func (s *seenIDs) claim(id string, window time.Duration, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastGC) >= dedupeGCEvery {
+		for k, exp := range s.expires {
+			if !now.Before(exp) {
+				delete(s.expires, k)
+			}
+		}
+		s.lastGC = now
+	}
+	if exp, ok := s.expires[id]; ok && now.Before(exp) {
+		return false
+	}
+	s.expires[id] = now.Add(window)
+	return true
+}
+
+// release forgets id, for events that were claimed but never queued, so a
+// retry after a full queue is not mistaken for a duplicate.
+func (s *seenIDs) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.expires, id)
+}
+
+// admit claims ev's ID. It returns false for a duplicate; with dedupe off
+// or an empty ID every event is admitted.
+func (e *Engine) admit(ev *event.Event) bool {
+	window := e.conf.Load().DedupeWindowMs.Duration()
+	if window <= 0 || ev.ID == "" {
+		return true
+	}
+	if e.seen.claim(ev.ID, window, time.Now()) {
+		return true
+	}
+	metrics.EventsDuplicate.Inc()
+	return false
+}
+
+// duplicateResult is the result reported for an event skipped by admit.
+func (e *Engine) duplicateResult(ev *event.Event) *EventResult {
+	return &EventResult{
+		EventID:          ev.ID,
+		ScenariosMatched: []string{},
+		ActionsExecuted:  []*action.ActionResult{},
+		Duplicate:        true,
+		Region:           e.conf.Load().Region,
+	}
+}
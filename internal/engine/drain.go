@@ -0,0 +1,74 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrShuttingDown is returned for events submitted after StopIntake.
+var ErrShuttingDown = errors.New("engine shutting down")
+
+// drainPoll is how often DrainQueue checks the queue length.
+const drainPoll = 10 * time.Millisecond
+
+// eventPools returns the pools that take events.
+func (e *Engine) eventPools() []*workerPool[*eventWork, *EventResult] {
+	if e.syncPool != nil {
+		return []*workerPool[*eventWork, *EventResult]{e.syncPool, e.eventPool}
+	}
+	return []*workerPool[*eventWork, *EventResult]{e.eventPool}
+}
+
+// StopIntake makes the engine refuse new events with ErrShuttingDown.
+// Events already queued are still processed.
+func (e *Engine) StopIntake() {
+	e.stopping.Store(true)
+	for _, p := range e.eventPools() {
+		p.Close()
+	}
+}
+
+// DrainQueue stops intake and waits until workers have taken every queued
+// event, or ctx is done. It returns how many events are still queued.
+func (e *Engine) DrainQueue(ctx context.Context) (int, error) {
+	e.StopIntake()
+	t := time.NewTicker(drainPoll)
+	defer t.Stop()
+	for {
+		queued := 0
+		for _, p := range e.eventPools() {
+			queued += p.QueueLen()
+		}
+		if queued == 0 {
+			return 0, nil
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return queued, ctx.Err()
+		}
+	}
+}
+
+// WaitIdle stops intake and waits until the events workers hold, and the
+// actions they run, have finished, or ctx is done. It returns how many events
+// were still queued or being processed. Cancelling the context the engine
+// was created with abandons them.
+func (e *Engine) WaitIdle(ctx context.Context) (int, error) {
+	e.StopIntake()
+	for _, p := range e.eventPools() {
+		if err := p.Wait(ctx); err != nil {
+			left := 0
+			for _, p := range e.eventPools() {
+				left += p.QueueLen() + p.Busy()
+			}
+			return left, err
+		}
+	}
+	e.actionPool.Close()
+	if err := e.actionPool.Wait(ctx); err != nil {
+		return e.actionPool.QueueLen() + e.actionPool.Busy(), err
+	}
+	return 0, nil
+}
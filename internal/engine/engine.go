@@ -2,38 +2,85 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/actor"
+	"github.com/gyaneshwarpardhi/ifttt/internal/anomaly"
 	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/counter"
 	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
 	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
 	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+	"github.com/gyaneshwarpardhi/ifttt/internal/streak"
 )
 
 // EventResult is the outcome of processing a single event.
 type EventResult struct {
-	EventID          string                 `json:"event_id"`
-	DurationMs       int64                  `json:"duration_ms"`
-	ScenariosMatched []string               `json:"scenarios_matched"`
-	ActionsExecuted  []*action.ActionResult `json:"actions_executed"`
-	Error            string                 `json:"error,omitempty"`
+	EventID           string                 `json:"event_id"`
+	DurationMs        int64                  `json:"duration_ms"`
+	ScenariosMatched  []string               `json:"scenarios_matched"`
+	ActionsExecuted   []*action.ActionResult `json:"actions_executed"`
+	Error             string                 `json:"error,omitempty"`
+	Quarantined       bool                   `json:"quarantined,omitempty"`
+	MissingFields     map[string][]string    `json:"missing_fields,omitempty"`     // scenario → required fields the event lacked
+	ScenariosShed     []string               `json:"scenarios_shed,omitempty"`     // expensive scenarios skipped under overload (engine.shed_expensive_at)
+	ScenariosDeferred []string               `json:"scenarios_deferred,omitempty"` // expensive scenarios put off under overload (engine.shed_mode: defer)
+	Deferred          bool                   `json:"deferred,omitempty"`           // the later evaluation of an event's deferred scenarios
+	Duplicate         bool                   `json:"duplicate,omitempty"`          // skipped: ID already seen within engine.dedupe_window_ms
+	Region            string                 `json:"region,omitempty"`             // engine.region of the instance that processed it
+	Options           *EvalOptions           `json:"options,omitempty"`            // per-event options the event was evaluated with
+	Trace             []dag.TraceStep        `json:"trace,omitempty"`              // evaluated nodes, with options.explain
+	Diagnostics       map[string][]string    `json:"diagnostics,omitempty"`        // node → evaluation errors and executor notes
 }
 
 // Engine processes events through the DAG.
 type Engine struct {
 	graph      atomic.Pointer[dag.Graph]
+	built      atomic.Pointer[builtGraph] // memo of BuildGraph
 	registry   *action.Registry
-	eventPool  *workerPool[*eventWork, *EventResult]
+	eventPool  *workerPool[*eventWork, *EventResult] // all traffic, or async only when syncPool is set
+	syncPool   *workerPool[*eventWork, *EventResult] // nil unless sync_worker_fraction > 0
 	actionPool *workerPool[*actionWork, *action.ActionResult]
-	conf       *config.EngineConf
+	conf       atomic.Pointer[config.EngineConf] // replaced wholesale by Tune
+	tuneMu     sync.Mutex                        // serialises Tune's read-modify-write of conf
+	actors     *actor.Cache                      // nil = actor.* namespace disabled
+	tenants    *actor.Cache                      // nil = tenant.* namespace disabled
+	hooks      hooks
+	quarantine quarantine
+	seen       *seenIDs
+	limiter    ingestLimiter
+	recent     *recentRing     // nil when recent_results < 0
+	counters   counter.Store   // action limits; process-local unless SetCounters
+	streaks    *streak.Tracker // streak() state; process-local unless SetStreakStore
+	loc        *time.Location  // default zone for calendar functions
+	stopping   atomic.Bool     // set by StopIntake
+	deferred   deferredQueue   // events whose shed scenarios wait for the queue to clear
 }
 
+// ErrQueueFull is returned when an event cannot be queued.
+var ErrQueueFull = errors.New("event queue full")
+
+// ErrTimeout is returned when a synchronous event is not processed within
+// the engine's event timeout.
+var ErrTimeout = errors.New("event processing timeout")
+
 type eventWork struct {
-	ev      *event.Event
-	resultC chan *EventResult
+	ev       *event.Event
+	resultC  chan *EventResult
+	onAction func(*action.ActionResult) // non-nil in streaming mode
+	done     func(*EventResult)         // non-nil for async events whose result is wanted
+	opts     *EvalOptions               // nil = normal processing
+	deferred []string                   // scenarios put off by load shedding; only these are evaluated
+	logArgs  []any                      // logctx fields of the submitting context
 }
 
 type actionWork struct {
@@ -46,51 +93,214 @@ type actionWork struct {
 func New(ctx context.Context, g *dag.Graph, reg *action.Registry, conf config.EngineConf) *Engine {
 	e := &Engine{
 		registry: reg,
-		conf:     &conf,
+		loc:      time.UTC,
+		recent:   newRecentRing(conf.RecentResults),
+		counters: counter.NewMemory(),
+		streaks:  streak.NewTracker(streak.NewMemoryStore()),
+		seen:     newSeenIDs(),
+	}
+	if conf.DefaultTimezone != "" {
+		// Validated at config load; an unknown zone here falls back to UTC.
+		if loc, err := time.LoadLocation(conf.DefaultTimezone); err == nil {
+			e.loc = loc
+		}
 	}
 	e.graph.Store(g)
+	e.conf.Store(&conf)
 
 	// Start action pool first so event workers can submit to it.
 	e.actionPool = newWorkerPool[*actionWork, *action.ActionResult](
 		ctx,
 		conf.ActionWorkers,
 		conf.ActionWorkers*10,
+		nil,
 		func(ctx context.Context, w *actionWork) (*action.ActionResult, error) {
 			return e.executeAction(ctx, w)
 		},
 	)
 
-	e.eventPool = newWorkerPool[*eventWork, *EventResult](
-		ctx,
-		conf.EventWorkers,
-		conf.QueueDepth,
-		func(ctx context.Context, w *eventWork) (*EventResult, error) {
-			res := e.processEvent(ctx, w.ev)
-			if w.resultC != nil {
-				w.resultC <- res
-			}
-			return res, nil
-		},
-	)
+	var start func(int)
+	if conf.PinWorkers {
+		start = pinWorker
+	}
+	process := func(ctx context.Context, w *eventWork) (*EventResult, error) {
+		res := e.processEvent(ctx, w)
+		if w.resultC != nil {
+			w.resultC <- res
+		}
+		if w.done != nil {
+			w.done(res)
+		}
+		return res, nil
+	}
+	syncN, asyncN := splitWorkers(conf.EventWorkers, conf.SyncWorkerFraction)
+	if syncN > 0 {
+		syncQ, asyncQ := splitWorkers(conf.QueueDepth, conf.SyncWorkerFraction)
+		e.syncPool = newWorkerPool[*eventWork, *EventResult](ctx, syncN, max(syncQ, 1), start, process)
+		e.eventPool = newWorkerPool[*eventWork, *EventResult](ctx, asyncN, max(asyncQ, 1), offset(start, syncN), process)
+	} else {
+		e.eventPool = newWorkerPool[*eventWork, *EventResult](ctx, conf.EventWorkers, conf.QueueDepth, start, process)
+	}
+	go e.replayDeferred(ctx)
 
 	return e
 }
 
-// SwapGraph atomically replaces the DAG (used on hot-reload).
+// SwapGraph atomically replaces the DAG (used on hot-reload). Swapping in
+// the graph already live does nothing.
 func (e *Engine) SwapGraph(g *dag.Graph) {
-	e.graph.Store(g)
+	old := e.graph.Swap(g)
+	if old == g {
+		return
+	}
+	for _, fn := range e.hooks.load().graphs {
+		fn(old, g)
+	}
+}
+
+// SetDetector feeds every processed event into d for match-rate anomaly
+// detection and drops baselines of scenarios removed by a graph swap.
+func (e *Engine) SetDetector(d *anomaly.Detector) {
+	e.OnEventProcessed(func(_ *event.Event, res *EventResult) {
+		d.Observe(res.ScenariosMatched)
+	})
+	e.OnGraphSwapped(func(_, g *dag.Graph) {
+		ids := make(map[string]struct{}, len(g.Roots()))
+		for _, r := range g.Roots() {
+			ids[r.ID()] = struct{}{}
+		}
+		d.Forget(ids)
+	})
+}
+
+// Settings returns the engine settings in effect, including runtime tuning.
+func (e *Engine) Settings() config.EngineConf {
+	return *e.conf.Load()
+}
+
+// Tune applies fn to a copy of the settings and makes the result current.
+// Only settings read per event or per action take effect (timeouts, size
+// guards, result limits, the action pipeline); pool and queue sizes are fixed
+// at New. Changing action_timeout_ms, action_retries or
+// action_retry_backoff_ms replaces the registry's middlewares with a fresh
+// action.Pipeline, which restarts circuit breakers and error budgets.
+func (e *Engine) Tune(fn func(*config.EngineConf)) config.EngineConf {
+	e.tuneMu.Lock()
+	defer e.tuneMu.Unlock()
+	old := e.conf.Load()
+	next := *old
+	fn(&next)
+	e.conf.Store(&next)
+	if next.ActionTimeoutMs != old.ActionTimeoutMs || next.ActionRetries != old.ActionRetries ||
+		next.ActionRetryBackoffMs != old.ActionRetryBackoffMs {
+		e.registry.SetMiddlewares(action.Pipeline(next)...)
+	}
+	return next
+}
+
+// SetCounters replaces the store that enforces action limits, e.g. with a
+// counter.GCounter or counter.SQLStore shared across regions. Call before
+// the engine starts receiving events.
+func (e *Engine) SetCounters(s counter.Store) {
+	e.counters = s
+}
+
+// SetStreakStore replaces the store that keeps per-actor streaks, e.g. with
+// a streak.SQLStore that survives restarts. Call before the engine starts
+// receiving events.
+func (e *Engine) SetStreakStore(s streak.Store) {
+	e.streaks = streak.NewTracker(s)
+}
+
+// Streaks returns the store that keeps per-actor streaks.
+func (e *Engine) Streaks() streak.Store {
+	return e.streaks.Store()
+}
+
+// Registry returns the action executors the engine runs.
+func (e *Engine) Registry() *action.Registry {
+	return e.registry
+}
+
+// Counters returns the store that enforces action limits.
+func (e *Engine) Counters() counter.Store {
+	return e.counters
+}
+
+// SetActorCache enables the actor.* expression namespace backed by c.
+// Call before the engine starts receiving events.
+func (e *Engine) SetActorCache(c *actor.Cache) {
+	e.actors = c
+}
+
+// InvalidateActor drops cached actor data so the next event re-fetches it.
+func (e *Engine) InvalidateActor(actorID string) {
+	if e.actors != nil {
+		e.actors.Invalidate(actorID)
+	}
+}
+
+// SetTenantCache enables the tenant.* expression namespace backed by c,
+// keyed by the event's meta.tenant. Call before the engine starts receiving
+// events.
+func (e *Engine) SetTenantCache(c *actor.Cache) {
+	e.tenants = c
+}
+
+// InvalidateTenant drops cached tenant data so the next event re-fetches it.
+func (e *Engine) InvalidateTenant(tenantID string) {
+	if e.tenants != nil {
+		e.tenants.Invalidate(tenantID)
+	}
+}
+
+// Graph returns the currently active DAG.
+func (e *Engine) Graph() *dag.Graph {
+	return e.graph.Load()
 }
 
 // ProcessSync processes an event synchronously and returns the result.
-// Returns 429 error if the queue is full.
+// Returns 429 error if the queue is full. An event whose ID was already
+// accepted within engine.dedupe_window_ms is not processed again; its result
+// has Duplicate set.
 func (e *Engine) ProcessSync(ctx context.Context, ev *event.Event) (*EventResult, error) {
+	return e.submitAndWait(ctx, &eventWork{ev: ev, logArgs: logctx.Args(ctx)})
+}
+
+// ProcessStream is ProcessSync for interactive clients: onAction receives
+// each action's result as soon as it completes, before the full EventResult
+// is returned. Actions run in the same order as for ProcessSync. onAction is
+// called from the event worker goroutine.
+func (e *Engine) ProcessStream(ctx context.Context, ev *event.Event, onAction func(*action.ActionResult)) (*EventResult, error) {
+	return e.submitAndWait(ctx, &eventWork{ev: ev, onAction: onAction, logArgs: logctx.Args(ctx)})
+}
+
+func (e *Engine) submitAndWait(ctx context.Context, w *eventWork) (*EventResult, error) {
 	resultC := make(chan *EventResult, 1)
-	w := &eventWork{ev: ev, resultC: resultC}
+	w.resultC = resultC
 
-	timeout := time.Duration(e.conf.EventTimeoutMs) * time.Millisecond
-	if !e.eventPool.Submit(w) {
+	// A dry run leaves no trace, so it neither claims nor checks the ID.
+	dryRun := w.opts != nil && w.opts.DryRun
+	if !e.allowRate() {
+		return nil, ErrRateLimited
+	}
+	if !dryRun && !e.admit(w.ev) {
+		return e.duplicateResult(w.ev), nil
+	}
+	timeout := e.conf.Load().EventTimeoutMs.Duration()
+	pool := e.eventPool
+	if e.syncPool != nil {
+		pool = e.syncPool
+	}
+	if !pool.Submit(w) {
+		if !dryRun {
+			e.seen.release(w.ev.ID)
+		}
+		if e.stopping.Load() {
+			return nil, ErrShuttingDown
+		}
 		metrics.EventsDropped.Inc()
-		return nil, fmt.Errorf("event queue full (capacity %d)", e.conf.QueueDepth)
+		return nil, fmt.Errorf("%w (capacity %d)", ErrQueueFull, pool.QueueCap())
 	}
 	metrics.EventsEnqueued.Inc()
 
@@ -98,95 +308,312 @@ func (e *Engine) ProcessSync(ctx context.Context, ev *event.Event) (*EventResult
 	case res := <-resultC:
 		return res, nil
 	case <-time.After(timeout):
-		return nil, fmt.Errorf("event processing timeout after %v", timeout)
+		return nil, fmt.Errorf("%w after %v", ErrTimeout, timeout)
 	case <-ctx.Done():
 		return nil, ctx.Err()
 	}
 }
 
-// ProcessAsync enqueues an event for background processing. Returns false if the queue is full.
+// ProcessAsync enqueues an event for background processing. Returns false if
+// the queue is full or the event is a duplicate.
 func (e *Engine) ProcessAsync(ev *event.Event) bool {
-	w := &eventWork{ev: ev}
+	return e.Enqueue(context.Background(), ev, nil) == nil
+}
+
+// ProcessAsyncFunc is ProcessAsync with a callback that receives the result
+// on the event worker once processing finishes. done may be nil.
+func (e *Engine) ProcessAsyncFunc(ev *event.Event, done func(*EventResult)) bool {
+	return e.Enqueue(context.Background(), ev, done) == nil
+}
+
+// Enqueue is ProcessAsyncFunc for callers that need to tell the failures
+// apart: it returns ErrQueueFull, ErrRateLimited, ErrDuplicate or ErrShuttingDown. done is not called for a
+// rejected event. ctx only supplies logctx fields; processing is not
+// cancelled with it.
+func (e *Engine) Enqueue(ctx context.Context, ev *event.Event, done func(*EventResult)) error {
+	if !e.allowRate() {
+		return ErrRateLimited
+	}
+	if !e.admit(ev) {
+		return ErrDuplicate
+	}
+	w := &eventWork{ev: ev, done: done, logArgs: logctx.Args(ctx)}
 	if !e.eventPool.Submit(w) {
+		e.seen.release(ev.ID)
+		if e.stopping.Load() {
+			return ErrShuttingDown
+		}
 		metrics.EventsDropped.Inc()
-		return false
+		return ErrQueueFull
 	}
 	metrics.EventsEnqueued.Inc()
-	return true
+	return nil
 }
 
-// QueueUtilization returns queue used / capacity (0–1).
+// allowRate applies engine.ingest_rate_limit to one submitted event.
+func (e *Engine) allowRate() bool {
+	conf := e.conf.Load()
+	if e.limiter.allow(conf.IngestRateLimit, conf.IngestBurst, time.Now()) {
+		return true
+	}
+	metrics.EventsRateLimited.Inc()
+	return false
+}
+
+// QueueUtilization returns queue used / capacity (0–1) across event pools.
 func (e *Engine) QueueUtilization() float64 {
-	if e.eventPool.QueueCap() == 0 {
+	used, capacity := e.eventPool.QueueLen(), e.eventPool.QueueCap()
+	if e.syncPool != nil {
+		used += e.syncPool.QueueLen()
+		capacity += e.syncPool.QueueCap()
+	}
+	if capacity == 0 {
 		return 0
 	}
-	return float64(e.eventPool.QueueLen()) / float64(e.eventPool.QueueCap())
+	return float64(used) / float64(capacity)
 }
 
-func (e *Engine) processEvent(ctx context.Context, ev *event.Event) *EventResult {
+// fetchPauseAt is the queue utilization at which pull sources stop fetching.
+const fetchPauseAt = 0.9
+
+// FetchSize is how many events a pull source (the inbox dispatcher, a queue
+// consumer) should fetch next, at most limit. It is the free space of the
+// queue ProcessSync submits to, scaled down as the engine's queues fill and
+// 0 from 90% utilization, so a source never leases more messages than the
+// engine can absorb before their lease or visibility timeout runs out.
+func (e *Engine) FetchSize(limit int) int {
+	util := e.QueueUtilization()
+	if util >= fetchPauseAt {
+		return 0
+	}
+	pool := e.eventPool
+	if e.syncPool != nil {
+		pool = e.syncPool
+	}
+	free := pool.QueueCap() - pool.QueueLen()
+	return min(limit, int(float64(free)*(1-util/fetchPauseAt)))
+}
+
+// splitWorkers divides n by the sync fraction. It returns (0, n) when no
+// split is configured or n is too small to give each side at least one.
+func splitWorkers(n int, syncFraction float64) (syncN, asyncN int) {
+	if syncFraction <= 0 || n < 2 {
+		return 0, n
+	}
+	syncN = min(max(int(math.Round(float64(n)*syncFraction)), 1), n-1)
+	return syncN, n - syncN
+}
+
+// offset shifts worker indices so a second pool pins to the following CPUs.
+func offset(start func(int), by int) func(int) {
+	if start == nil {
+		return nil
+	}
+	return func(i int) { start(i + by) }
+}
+
+func (e *Engine) processEvent(ctx context.Context, w *eventWork) *EventResult {
+	ev, opts, onAction := w.ev, w.opts, w.onAction
 	start := time.Now()
 	g := e.graph.Load()
+	conf := e.conf.Load()
 
-	matches, scenariosMatched, _ := dag.Evaluate(g, ev)
+	if res := e.Quarantine(ev); res != nil {
+		res.DurationMs = time.Since(start).Milliseconds()
+		return res
+	}
+
+	evalCtx := e.newEvalContext(ctx, g, ev)
+	dryRun := opts != nil && opts.DryRun
+	if w.deferred != nil {
+		// The first pass canonicalized the type and recorded streaks; the
+		// deferred scenarios are not shed again.
+		evalCtx.Scenarios = w.deferred
+	} else {
+		if g.Canonicalize(evalCtx) {
+			metrics.EventsAliased.WithLabelValues(strings.ToLower(ev.RawType), ev.Type).Inc()
+		}
+		evalCtx.ShedExpensive = conf.ShedExpensiveAt > 0 && e.QueueUtilization() >= conf.ShedExpensiveAt
+		if !dryRun {
+			e.recordStreak(ctx, g, evalCtx)
+		}
+	}
+	if opts != nil {
+		evalCtx.ForceScenario, evalCtx.Explain = opts.ForceScenario, opts.Explain
+	}
+	matches, scenariosMatched, _ := dag.EvaluateContext(g, evalCtx)
+	shed, deferred := evalCtx.Shed, []string(nil)
+	if len(shed) > 0 && conf.ShedMode == config.ShedDefer && !dryRun && e.deferShed(w, shed) {
+		shed, deferred = nil, evalCtx.Shed
+	}
+	for _, id := range shed {
+		metrics.ScenariosShed.WithLabelValues(id).Inc()
+	}
+	for _, id := range deferred {
+		metrics.ScenariosDeferred.WithLabelValues(id).Inc()
+	}
 
 	result := &EventResult{
-		EventID:          ev.ID,
-		ScenariosMatched: scenariosMatched,
-		ActionsExecuted:  make([]*action.ActionResult, 0, len(matches)),
+		EventID:           ev.ID,
+		ScenariosMatched:  scenariosMatched,
+		ActionsExecuted:   make([]*action.ActionResult, 0, len(matches)),
+		MissingFields:     evalCtx.MissingFields,
+		ScenariosShed:     shed,
+		ScenariosDeferred: deferred,
+		Deferred:          w.deferred != nil,
+		Region:            conf.Region,
+		Options:           opts,
+		Trace:             evalCtx.Trace,
 	}
 
-	if len(matches) > 0 {
-		evalCtx := &dag.EvalContext{
-			Event:   ev,
-			Results: make(map[string]interface{}),
-		}
-		// Execute actions synchronously within the event worker.
+	matches, held := heldActions(matches, opts)
+	if conf.DedupeActions {
+		matches = collapseActions(matches)
 		for _, m := range matches {
-			ar := e.runAction(ctx, m, evalCtx)
-			result.ActionsExecuted = append(result.ActionsExecuted, ar)
+			for _, c := range m.Collapsed {
+				metrics.ActionsCollapsed.WithLabelValues(c.Node.ActionType()).Inc()
+			}
+		}
+	}
+	for _, ar := range held {
+		result.ActionsExecuted = append(result.ActionsExecuted, ar)
+		if onAction != nil {
+			onAction(ar)
+		}
+	}
+	// Execute actions in order within the event worker, streamed or not, so
+	// later actions see earlier outputs either way.
+	for _, m := range matches {
+		if evalCtx.Results.Full() {
+			result.Error = fmt.Sprintf("results limit %d reached; remaining actions skipped", conf.MaxResults)
+			break
+		}
+		ar := e.runAction(ctx, m, evalCtx)
+		result.ActionsExecuted = append(result.ActionsExecuted, ar)
+		if onAction != nil {
+			onAction(ar)
+		}
+	}
+	if dropped := evalCtx.Results.Dropped(); len(dropped) > 0 {
+		// An executor wrote more outputs than the limit left room for.
+		if result.Error == "" {
+			result.Error = fmt.Sprintf("results limit %d reached", conf.MaxResults)
 		}
+		result.Error += "; outputs of " + strings.Join(dropped, ", ") + " dropped"
 	}
+	result.Diagnostics = evalCtx.Results.Diagnostics()
 
-	result.DurationMs = time.Since(start).Milliseconds()
+	elapsed := time.Since(start)
+	result.DurationMs = elapsed.Milliseconds()
 
 	// Metrics.
 	metrics.EventsProcessed.Inc()
+	metrics.EventProcessingDuration().Observe(float64(elapsed) / float64(time.Millisecond))
+	if opts != nil && opts.DryRun {
+		return result
+	}
 	for _, sc := range scenariosMatched {
 		metrics.ScenariosMatched.WithLabelValues(sc).Inc()
 	}
+	for sc := range evalCtx.MissingFields {
+		metrics.ScenarioMissingFields.WithLabelValues(sc).Inc()
+	}
+	e.recent.add(ev, result)
+	hs := e.hooks.load()
+	if w.deferred == nil {
+		// Event hooks advance workflows and scheduled events, which the
+		// first pass already did.
+		for _, fn := range hs.events {
+			fn(ev, result)
+		}
+	}
+	for _, w := range hs.watches {
+		w.fn(ev, result)
+	}
 
 	return result
 }
 
+// logFields are the logctx fields the event pools attach while w is
+// processed: the submitter's, then the event's.
+func (w *eventWork) logFields() []any {
+	return append(slices.Clip(w.logArgs), eventLogArgs(w.ev)...)
+}
+
+// eventLogArgs are the logctx fields attached while an event is processed.
+func eventLogArgs(ev *event.Event) []any {
+	args := []any{"event_id", ev.ID}
+	if tenant := ev.Meta["tenant"]; tenant != "" {
+		args = append(args, "tenant", tenant)
+	}
+	return args
+}
+
+// newEvalContext builds the per-event evaluation context for g.
+func (e *Engine) newEvalContext(ctx context.Context, g *dag.Graph, ev *event.Event) *dag.EvalContext {
+	evalCtx := &dag.EvalContext{
+		Event:           ev,
+		Results:         dag.NewResults(e.conf.Load().MaxResults),
+		Messages:        g.Messages(),
+		MatchInputLimit: e.conf.Load().MaxMatchInput,
+		DefaultLocation: e.loc,
+		LogContext:      ctx,
+	}
+	evalCtx.LoadStreak = e.streakLoader(ctx, evalCtx)
+	if e.actors != nil {
+		evalCtx.LoadActor = func(actorID string) (map[string]interface{}, bool) {
+			data, err := e.actors.Get(ctx, actorID)
+			return data, err == nil
+		}
+	}
+	if e.tenants != nil {
+		evalCtx.LoadTenant = func(tenantID string) (map[string]interface{}, bool) {
+			data, err := e.tenants.Get(ctx, tenantID)
+			return data, err == nil
+		}
+	}
+	return evalCtx
+}
+
+// runAction resolves the executor for m and runs it. Retries, timeouts, circuit
+// breaking and action metrics are applied by the registry's middleware pipeline.
 func (e *Engine) runAction(ctx context.Context, m dag.ActionMatch, evalCtx *dag.EvalContext) *action.ActionResult {
+	ctx = logctx.With(ctx, "scenario_id", m.ScenarioID)
+	res := e.checkLimit(ctx, m, evalCtx)
+	if res == nil {
+		res = e.execute(ctx, m, evalCtx)
+	}
+	res.Scenarios, res.Collapsed = collapsedInto(m)
+	if res.Success && !res.Sandbox && res.Points != 0 {
+		op, _ := m.Node.Params()["operation"].(string)
+		metrics.PointsAwarded.WithLabelValues(m.ScenarioID, m.ActionID, op).Observe(res.Points)
+	}
+	for _, fn := range e.hooks.load().actions {
+		fn(evalCtx.Event, m, res)
+	}
+	return res
+}
+
+func (e *Engine) execute(ctx context.Context, m dag.ActionMatch, evalCtx *dag.EvalContext) *action.ActionResult {
 	exec, err := e.registry.Get(m.Node.ActionType())
 	if err != nil {
 		metrics.ActionsExecuted.WithLabelValues(m.Node.ActionType(), "error").Inc()
 		return &action.ActionResult{
-			ActionID: m.Node.ID(),
+			ActionID: m.ActionID,
 			Type:     m.Node.ActionType(),
 			Success:  false,
 			Message:  err.Error(),
 		}
 	}
-	res, err := exec.Execute(ctx, m.Node.ID(), m.Node.Params(), evalCtx)
-	if err != nil {
-		metrics.ActionsExecuted.WithLabelValues(m.Node.ActionType(), "error").Inc()
-		if res == nil {
-			res = &action.ActionResult{
-				ActionID: m.Node.ID(),
-				Type:     m.Node.ActionType(),
-				Success:  false,
-				Message:  err.Error(),
-			}
+	res, err := exec.Execute(ctx, m.ActionID, m.Node.Params(), evalCtx)
+	if err != nil && res == nil {
+		res = &action.ActionResult{
+			ActionID: m.ActionID,
+			Type:     m.Node.ActionType(),
+			Success:  false,
+			Message:  err.Error(),
 		}
-		return res
 	}
-	status := "success"
-	if !res.Success {
-		status = "error"
-	}
-	metrics.ActionsExecuted.WithLabelValues(m.Node.ActionType(), status).Inc()
 	return res
 }
 
@@ -195,11 +622,14 @@ func (e *Engine) executeAction(ctx context.Context, w *actionWork) (*action.Acti
 	if err != nil {
 		return nil, err
 	}
-	return exec.Execute(ctx, w.match.Node.ID(), w.match.Node.Params(), w.evalCtx)
+	return exec.Execute(ctx, w.match.ActionID, w.match.Node.Params(), w.evalCtx)
 }
 
 // Shutdown drains both pools gracefully.
 func (e *Engine) Shutdown() {
 	e.eventPool.Drain()
+	if e.syncPool != nil {
+		e.syncPool.Drain()
+	}
 	e.actionPool.Drain()
 }
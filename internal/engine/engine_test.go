@@ -0,0 +1,952 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/action/points"
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/counter"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func testConfig() *config.RuleConfig {
+	return &config.RuleConfig{
+		Version: "v1",
+		Engine: config.EngineConf{
+			EventWorkers:   2,
+			ActionWorkers:  2,
+			QueueDepth:     16,
+			EventTimeoutMs: 1000,
+		},
+		Scenarios: []config.Scenario{
+			{
+				ID:         "sc_login",
+				Enabled:    true,
+				EventTypes: []string{"login"},
+				Children: []config.NodeRef{
+					{Action: &config.ActionDef{
+						ID:     "act_welcome",
+						Type:   "reward_points",
+						Params: map[string]interface{}{"operation": "award", "points": float64(50)},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func newTestEngine(t *testing.T, cfg *config.RuleConfig) *engine.Engine {
+	t.Helper()
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	reg := action.NewRegistry()
+	reg.Register(points.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	eng := engine.New(ctx, g, reg, cfg.Engine)
+	t.Cleanup(func() {
+		cancel()
+		eng.Shutdown()
+	})
+	return eng
+}
+
+func TestEngine_Hooks(t *testing.T) {
+	cfg := testConfig()
+	eng := newTestEngine(t, cfg)
+
+	var processed, executed, swapped int
+	eng.OnEventProcessed(func(ev *event.Event, res *engine.EventResult) { processed++ })
+	eng.OnActionExecuted(func(ev *event.Event, m dag.ActionMatch, res *action.ActionResult) {
+		if m.Node.ID() == "act_welcome" && res.Success {
+			executed++
+		}
+	})
+	eng.OnGraphSwapped(func(old, new *dag.Graph) { swapped++ })
+
+	res, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+	if err != nil {
+		t.Fatalf("ProcessSync: %v", err)
+	}
+	if len(res.ActionsExecuted) != 1 {
+		t.Fatalf("expected 1 action, got %+v", res.ActionsExecuted)
+	}
+	g, _ := dag.Build(cfg)
+	eng.SwapGraph(g)
+
+	if processed != 1 || executed != 1 || swapped != 1 {
+		t.Errorf("hooks called processed=%d executed=%d swapped=%d, want 1/1/1", processed, executed, swapped)
+	}
+}
+
+func TestEngine_WatchEventsStops(t *testing.T) {
+	eng := newTestEngine(t, testConfig())
+	var watched atomic.Int32
+	stop := eng.WatchEvents(func(*event.Event, *engine.EventResult) { watched.Add(1) })
+	if _, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	if _, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e2", Type: "login", ActorID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := watched.Load(); n != 1 {
+		t.Errorf("watch saw %d events, want 1 (none after stop)", n)
+	}
+}
+
+func TestEngine_QuarantinesOversizedPayload(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.MaxPayloadBytes = 64
+	eng := newTestEngine(t, cfg)
+
+	big := map[string]interface{}{"blob": strings.Repeat("x", 128)}
+	res, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1", Payload: big})
+	if err != nil {
+		t.Fatalf("ProcessSync: %v", err)
+	}
+	if !res.Quarantined || len(res.ActionsExecuted) != 0 {
+		t.Fatalf("expected quarantined event with no actions, got %+v", res)
+	}
+	if q := eng.Quarantined(); len(q) != 1 || q[0].EventID != "e1" {
+		t.Errorf("expected e1 in quarantine, got %+v", q)
+	}
+
+	res, _ = eng.ProcessSync(context.Background(), &event.Event{ID: "e2", Type: "login", ActorID: "u1"})
+	if res.Quarantined || len(res.ActionsExecuted) != 1 {
+		t.Errorf("small event should be evaluated normally, got %+v", res)
+	}
+}
+
+func TestEngine_RecentResultsRing(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.RecentResults = 3
+	eng := newTestEngine(t, cfg)
+
+	for i, ev := range []*event.Event{
+		{ID: "e1", Type: "login", ActorID: "u1"},
+		{ID: "e2", Type: "logout", ActorID: "u1"},
+		{ID: "e3", Type: "login", ActorID: "u2"},
+		{ID: "e4", Type: "login", ActorID: "u1"},
+	} {
+		if _, err := eng.ProcessSync(context.Background(), ev); err != nil {
+			t.Fatalf("event %d: %v", i, err)
+		}
+	}
+
+	all := eng.Recent(engine.RecentFilter{})
+	if len(all) != 3 || all[0].EventID != "e4" || all[2].EventID != "e2" {
+		t.Fatalf("expected e4, e3, e2 newest first, got %+v", all)
+	}
+	got := eng.Recent(engine.RecentFilter{ActorID: "u1", Status: "matched"})
+	if len(got) != 1 || got[0].EventID != "e4" {
+		t.Errorf("expected only e4 for u1 matched, got %+v", got)
+	}
+	if got := eng.Recent(engine.RecentFilter{Status: "unmatched", Limit: 1}); len(got) != 1 || got[0].EventID != "e2" {
+		t.Errorf("expected e2 as the only unmatched result, got %+v", got)
+	}
+
+	// Matched results expire under their scenario's TTL; unmatched ones stay.
+	ttl := func(scenarios []string) time.Duration {
+		if len(scenarios) > 0 {
+			return time.Minute
+		}
+		return 0
+	}
+	if n := eng.PurgeRecent(time.Now().Add(2*time.Minute), ttl); n != 2 {
+		t.Errorf("expected 2 matched results purged, got %d", n)
+	}
+	if got := eng.Recent(engine.RecentFilter{}); len(got) != 1 || got[0].EventID != "e2" {
+		t.Errorf("expected only e2 after purge, got %+v", got)
+	}
+}
+
+func TestEngine_ActionLimitSharedAcrossRegions(t *testing.T) {
+	limited := func(region string) *config.RuleConfig {
+		cfg := testConfig()
+		cfg.Engine.Region = region
+		cfg.Scenarios[0].Children[0].Action.Limit = &config.ActionLimit{Max: 1, WindowMs: 86400000}
+		return cfg
+	}
+	engEU, engUS := newTestEngine(t, limited("eu")), newTestEngine(t, limited("us"))
+	shared := counter.NewMemory() // stands in for the central store
+	engEU.SetCounters(shared)
+	engUS.SetCounters(shared)
+
+	res, err := engEU.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+	if err != nil || !res.ActionsExecuted[0].Success || res.Region != "eu" {
+		t.Fatalf("first claim in eu: res=%+v err=%v", res, err)
+	}
+	res, err = engUS.ProcessSync(context.Background(), &event.Event{ID: "e2", Type: "login", ActorID: "u1"})
+	if err != nil {
+		t.Fatalf("ProcessSync: %v", err)
+	}
+	if ar := res.ActionsExecuted[0]; ar.Success || ar.Status != action.StatusLimited || res.Region != "us" {
+		t.Errorf("second claim in us should be limited, got %+v (region %q)", ar, res.Region)
+	}
+	res, _ = engUS.ProcessSync(context.Background(), &event.Event{ID: "e3", Type: "login", ActorID: "u2"})
+	if !res.ActionsExecuted[0].Success {
+		t.Errorf("another actor should not be limited: %+v", res.ActionsExecuted[0])
+	}
+}
+
+func TestEngine_TuneTakesEffectWithoutRestart(t *testing.T) {
+	eng := newTestEngine(t, testConfig())
+	ev := func(id string) *event.Event {
+		return &event.Event{ID: id, Type: "login", ActorID: "u1", Payload: map[string]interface{}{"note": "hello world"}}
+	}
+	if res, _ := eng.ProcessSync(context.Background(), ev("e1")); res.Quarantined {
+		t.Fatalf("unexpected quarantine before tuning: %+v", res)
+	}
+
+	got := eng.Tune(func(c *config.EngineConf) { c.MaxPayloadBytes = 8 })
+	if got.MaxPayloadBytes != 8 || eng.Settings().MaxPayloadBytes != 8 {
+		t.Fatalf("Tune did not apply: %+v", got)
+	}
+	if res, _ := eng.ProcessSync(context.Background(), ev("e2")); !res.Quarantined {
+		t.Errorf("expected quarantine under the tuned payload limit, got %+v", res)
+	}
+}
+
+func TestEngine_PointsAwardedMetric(t *testing.T) {
+	eng := newTestEngine(t, testConfig())
+	for _, id := range []string{"p1", "p2"} {
+		if _, err := eng.ProcessSync(context.Background(), &event.Event{ID: id, Type: "login", ActorID: "u1"}); err != nil {
+			t.Fatalf("ProcessSync: %v", err)
+		}
+	}
+	var m dto.Metric
+	h := metrics.PointsAwarded.WithLabelValues("sc_login", "act_welcome", "award").(prometheus.Metric)
+	if err := h.Write(&m); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := m.GetHistogram(); got.GetSampleCount() < 2 || got.GetSampleSum() < 100 {
+		t.Errorf("expected at least 2 observations summing to 100, got count=%d sum=%v", got.GetSampleCount(), got.GetSampleSum())
+	}
+}
+
+func TestEngine_DedupeAcrossPaths(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.DedupeWindowMs = 60000
+	eng := newTestEngine(t, cfg)
+	ev := func() *event.Event { return &event.Event{ID: "d1", Type: "login", ActorID: "u1"} }
+
+	res, err := eng.ProcessSync(context.Background(), ev())
+	if err != nil || res.Duplicate || len(res.ActionsExecuted) != 1 {
+		t.Fatalf("first copy: res=%+v err=%v", res, err)
+	}
+	if err := eng.Enqueue(context.Background(), ev(), nil); !errors.Is(err, engine.ErrDuplicate) {
+		t.Errorf("async copy: err = %v, want ErrDuplicate", err)
+	}
+	res, err = eng.ProcessSync(context.Background(), ev())
+	if err != nil || !res.Duplicate || len(res.ActionsExecuted) != 0 {
+		t.Errorf("sync copy should be skipped as duplicate: res=%+v err=%v", res, err)
+	}
+	if res, _ := eng.ProcessSync(context.Background(), &event.Event{ID: "d2", Type: "login", ActorID: "u1"}); res.Duplicate {
+		t.Errorf("a new id must not be a duplicate: %+v", res)
+	}
+}
+
+func TestEngine_SyncAsyncSplit(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.EventWorkers = 4
+	cfg.Engine.SyncWorkerFraction = 0.25
+	eng := newTestEngine(t, cfg)
+
+	if !eng.ProcessAsync(&event.Event{ID: "a1", Type: "login", ActorID: "u1"}) {
+		t.Fatal("ProcessAsync rejected event")
+	}
+	res, err := eng.ProcessSync(context.Background(), &event.Event{ID: "s1", Type: "login", ActorID: "u1"})
+	if err != nil || len(res.ActionsExecuted) != 1 {
+		t.Fatalf("ProcessSync through the sync pool: res=%+v err=%v", res, err)
+	}
+}
+
+func TestEngine_ProcessAsyncFunc(t *testing.T) {
+	eng := newTestEngine(t, testConfig())
+
+	done := make(chan *engine.EventResult, 1)
+	if !eng.ProcessAsyncFunc(&event.Event{ID: "a1", Type: "login", ActorID: "u1"}, func(res *engine.EventResult) { done <- res }) {
+		t.Fatal("ProcessAsyncFunc rejected event")
+	}
+	if res := <-done; res.EventID != "a1" || len(res.ActionsExecuted) != 1 {
+		t.Errorf("unexpected async result %+v", res)
+	}
+}
+
+func TestEngine_StreamMatchesSync(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.MaxResults = 2
+	for _, id := range []string{"act_second", "act_third"} {
+		cfg.Scenarios[0].Children = append(cfg.Scenarios[0].Children, config.NodeRef{Action: &config.ActionDef{
+			ID: id, Type: "reward_points", Params: map[string]interface{}{"operation": "award", "points": float64(10)},
+		}})
+	}
+	eng := newTestEngine(t, cfg)
+
+	ids := func(ars []*action.ActionResult) []string {
+		var out []string
+		for _, ar := range ars {
+			out = append(out, ar.ActionID)
+		}
+		return out
+	}
+	synced, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []*action.ActionResult
+	stream, err := eng.ProcessStream(context.Background(), &event.Event{ID: "e2", Type: "login", ActorID: "u1"},
+		func(ar *action.ActionResult) { streamed = append(streamed, ar) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The results limit stops both after two actions, in config order.
+	want := "act_welcome,act_second"
+	if got := strings.Join(ids(synced.ActionsExecuted), ","); got != want || synced.Error == "" {
+		t.Errorf("sync ran %s (error %q), want %s and a results limit error", got, synced.Error, want)
+	}
+	if got := strings.Join(ids(stream.ActionsExecuted), ","); got != want || stream.Error != synced.Error {
+		t.Errorf("stream ran %s (error %q), want %s and %q", got, stream.Error, want, synced.Error)
+	}
+	if got := strings.Join(ids(streamed), ","); got != want {
+		t.Errorf("streamed %s, want %s", got, want)
+	}
+}
+
+// fanoutExecutor writes an output for its action and for each ID in its
+// "extra" param.
+type fanoutExecutor struct{}
+
+func (fanoutExecutor) Type() string                          { return "fanout" }
+func (fanoutExecutor) Validate(map[string]interface{}) error { return nil }
+func (fanoutExecutor) Execute(_ context.Context, id string, params map[string]interface{}, evalCtx *dag.EvalContext) (*action.ActionResult, error) {
+	evalCtx.Results.SetOutput(id, true)
+	extra, _ := params["extra"].([]interface{})
+	for _, x := range extra {
+		evalCtx.Results.SetOutput(x.(string), true)
+	}
+	return &action.ActionResult{ActionID: id, Type: "fanout", Success: true}, nil
+}
+
+func TestEngine_ReportsDroppedOutputs(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.MaxResults = 2
+	cfg.Scenarios[0].Children[0].Action = &config.ActionDef{
+		ID: "act_fanout", Type: "fanout", Params: map[string]interface{}{"extra": []interface{}{"out_a", "out_b"}},
+	}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	reg := action.NewRegistry()
+	reg.Register(fanoutExecutor{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng := engine.New(ctx, g, reg, cfg.Engine)
+	defer eng.Shutdown()
+
+	res, err := eng.ProcessSync(ctx, &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "results limit 2 reached; outputs of out_b dropped"; res.Error != want {
+		t.Errorf("error = %q, want %q", res.Error, want)
+	}
+}
+
+func TestEngine_ReportsDiagnostics(t *testing.T) {
+	cfg := testConfig()
+	welcome := cfg.Scenarios[0].Children[0]
+	cfg.Scenarios[0].Children = []config.NodeRef{{Condition: &config.ConditionDef{
+		ID: "cond_amount", Expression: "payload.amount > 10", Children: []config.NodeRef{welcome},
+	}}}
+	eng := newTestEngine(t, cfg)
+
+	res, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if notes := res.Diagnostics["cond_amount"]; len(notes) != 1 {
+		t.Errorf("diagnostics = %v, want the evaluation error of cond_amount", res.Diagnostics)
+	}
+}
+
+func TestEngine_EvalOptions(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.DedupeWindowMs = 60000
+	eng := newTestEngine(t, cfg)
+	var processed int
+	eng.OnEventProcessed(func(*event.Event, *engine.EventResult) { processed++ })
+	ctx := context.Background()
+
+	if err := eng.CheckOptions(&engine.EvalOptions{ForceScenario: "sc_nope", SkipActions: []string{"sc_login"}}); err == nil ||
+		!strings.Contains(err.Error(), "sc_nope") || !strings.Contains(err.Error(), `unknown action "sc_login"`) {
+		t.Errorf("CheckOptions = %v, want both unknown IDs reported", err)
+	}
+
+	// A dry run reports the action, explains the path and leaves no trace:
+	// the same ID still processes normally afterwards.
+	opts := &engine.EvalOptions{DryRun: true, Explain: true}
+	res, err := eng.ProcessOptions(ctx, &event.Event{ID: "o1", Type: "login", ActorID: "u1"}, opts, nil)
+	if err != nil {
+		t.Fatalf("ProcessOptions: %v", err)
+	}
+	if len(res.ActionsExecuted) != 1 || res.ActionsExecuted[0].Status != action.StatusDryRun || res.Options != opts {
+		t.Fatalf("dry run result = %+v", res)
+	}
+	if len(res.Trace) != 2 || res.Trace[0].NodeID != "sc_login" || !res.Trace[1].Passed {
+		t.Errorf("trace = %+v, want scenario then action, both passed", res.Trace)
+	}
+	if processed != 0 {
+		t.Errorf("dry run notified %d event hooks", processed)
+	}
+	res, _ = eng.ProcessSync(ctx, &event.Event{ID: "o1", Type: "login", ActorID: "u1"})
+	if res.Duplicate || len(res.ActionsExecuted) != 1 || !res.ActionsExecuted[0].Success || res.ActionsExecuted[0].Status != "" {
+		t.Errorf("real run after dry run = %+v", res)
+	}
+
+	// force_scenario ignores the event type, so it may only dry-run.
+	if err := eng.CheckOptions(&engine.EvalOptions{ForceScenario: "sc_login"}); err == nil || !strings.Contains(err.Error(), "dry_run") {
+		t.Errorf("CheckOptions(force_scenario without dry_run) = %v", err)
+	}
+	opts = &engine.EvalOptions{ForceScenario: "sc_login", DryRun: true}
+	res, _ = eng.ProcessOptions(ctx, &event.Event{ID: "o2", Type: "purchase", ActorID: "u1"}, opts, nil)
+	if len(res.ActionsExecuted) != 1 || res.ActionsExecuted[0].Status != action.StatusDryRun || res.Trace != nil {
+		t.Errorf("forced dry run result = %+v", res)
+	}
+
+	// skip_actions holds the action back.
+	opts = &engine.EvalOptions{SkipActions: []string{"act_welcome"}}
+	res, _ = eng.ProcessOptions(ctx, &event.Event{ID: "o3", Type: "login", ActorID: "u1"}, opts, nil)
+	if len(res.ActionsExecuted) != 1 || res.ActionsExecuted[0].Status != action.StatusSkipped {
+		t.Errorf("skipped result = %+v", res)
+	}
+}
+
+func TestEngine_SkipActionsKeepsDedupedTwins(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.DedupeNodes = true
+	copied := cfg.Scenarios[0]
+	copied.ID = "sc_login_copy"
+	copied.Children = []config.NodeRef{{Action: &config.ActionDef{
+		ID:     "act_welcome_copy",
+		Type:   "reward_points",
+		Params: map[string]interface{}{"operation": "award", "points": float64(50)},
+	}}}
+	cfg.Scenarios = append(cfg.Scenarios, copied)
+	eng := newTestEngine(t, cfg)
+
+	opts := &engine.EvalOptions{SkipActions: []string{"act_welcome_copy"}}
+	res, err := eng.ProcessOptions(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"}, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, ar := range res.ActionsExecuted {
+		got[ar.ActionID] = ar.Status
+	}
+	if len(got) != 2 || got["act_welcome"] != "" || got["act_welcome_copy"] != action.StatusSkipped {
+		t.Errorf("statuses %v, want only act_welcome_copy skipped", got)
+	}
+}
+
+func TestEngine_FetchSize(t *testing.T) {
+	eng := newTestEngine(t, testConfig()) // queue_depth 16, idle
+	if n := eng.FetchSize(8); n != 8 {
+		t.Errorf("FetchSize(8) = %d, want the limit when the queue is empty", n)
+	}
+	if n := eng.FetchSize(64); n != 16 {
+		t.Errorf("FetchSize(64) = %d, want the free queue space (16)", n)
+	}
+
+	// One worker held in its action lets the queue fill up.
+	cfg := testConfig()
+	cfg.Engine.EventWorkers = 1
+	cfg.Scenarios[0].Children[0].Action = &config.ActionDef{ID: "act_block", Type: "block"}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	exec := &blockingExecutor{started: make(chan struct{}, 16), release: make(chan struct{})}
+	reg := action.NewRegistry()
+	reg.Register(exec)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng = engine.New(ctx, g, reg, cfg.Engine)
+	defer eng.Shutdown()
+	defer close(exec.release)
+
+	enqueue := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			if err := eng.Enqueue(ctx, &event.Event{Type: "login", ActorID: "u1"}, nil); err != nil {
+				t.Fatalf("Enqueue: %v", err)
+			}
+		}
+	}
+	enqueue(1)
+	<-exec.started // the worker holds the first event; the queue is empty
+	enqueue(8)
+	// Half full: 8 free slots, scaled down by how close the queue is to the
+	// 90% pause (1 - 0.5/0.9), so 3.
+	if n := eng.FetchSize(64); n != 3 {
+		t.Errorf("FetchSize(64) at 50%% = %d, want 3", n)
+	}
+	enqueue(6) // 14/16 = 87.5%
+	if n := eng.FetchSize(64); n != 0 {
+		t.Errorf("FetchSize(64) at 87.5%% = %d, want 0 (2 free × 0.03)", n)
+	}
+	enqueue(1) // 15/16 ≥ 90%
+	for _, limit := range []int{1, 64} {
+		if n := eng.FetchSize(limit); n != 0 {
+			t.Errorf("FetchSize(%d) at 94%% = %d, want 0: fetching pauses at 90%%", limit, n)
+		}
+	}
+}
+
+// blockingExecutor holds every action until release is closed.
+type blockingExecutor struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingExecutor) Type() string                          { return "block" }
+func (b *blockingExecutor) Validate(map[string]interface{}) error { return nil }
+func (b *blockingExecutor) Execute(ctx context.Context, id string, _ map[string]interface{}, _ *dag.EvalContext) (*action.ActionResult, error) {
+	b.started <- struct{}{}
+	select {
+	case <-b.release:
+		return &action.ActionResult{ActionID: id, Type: "block", Success: true}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestEngine_DefersShedScenarios(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.EventWorkers = 1
+	cfg.Engine.QueueDepth = 4
+	cfg.Engine.ShedExpensiveAt = 0.5
+	cfg.Engine.ShedMode = config.ShedDefer
+	cfg.Scenarios[0].Children[0].Action = &config.ActionDef{ID: "act_block", Type: "block"}
+	cfg.Scenarios = append(cfg.Scenarios, config.Scenario{
+		ID: "sc_purchase", Enabled: true, EventTypes: []string{"purchase"}, Cost: config.CostExpensive,
+		Children: []config.NodeRef{{Action: &config.ActionDef{
+			ID: "act_bonus", Type: "reward_points",
+			Params: map[string]interface{}{"operation": "award", "points": float64(10)},
+		}}},
+	})
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	exec := &blockingExecutor{started: make(chan struct{}, 4), release: make(chan struct{})}
+	reg := action.NewRegistry()
+	reg.Register(exec)
+	reg.Register(points.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng := engine.New(ctx, g, reg, cfg.Engine)
+	defer eng.Shutdown()
+	defer close(exec.release)
+
+	results := make(chan *engine.EventResult, 8)
+	eng.WatchEvents(func(ev *event.Event, res *engine.EventResult) {
+		if ev.Type == "purchase" {
+			results <- res
+		}
+	})
+	enqueue := func(id, typ string) {
+		t.Helper()
+		if err := eng.Enqueue(ctx, &event.Event{ID: id, Type: typ, ActorID: "u1"}, nil); err != nil {
+			t.Fatalf("Enqueue %s: %v", id, err)
+		}
+	}
+	enqueue("l1", "login")
+	<-exec.started
+	enqueue("p1", "purchase")
+	enqueue("l2", "login")
+	enqueue("l3", "login")
+	exec.release <- struct{}{} // p1 runs with l2 and l3 queued: 50%
+
+	res := <-results
+	if len(res.ScenariosDeferred) != 1 || res.ScenariosDeferred[0] != "sc_purchase" || len(res.ScenariosShed) != 0 {
+		t.Fatalf("first pass deferred %v, shed %v; want sc_purchase deferred", res.ScenariosDeferred, res.ScenariosShed)
+	}
+	if len(res.ActionsExecuted) != 0 || res.Deferred {
+		t.Errorf("first pass = %+v, want no actions", res)
+	}
+
+	// With l2 in its action and l3 queued (25%), the deferred scenario is
+	// queued behind l3.
+	<-exec.started
+	exec.release <- struct{}{}
+	exec.release <- struct{}{}
+	select {
+	case res = <-results:
+	case <-time.After(2 * time.Second):
+		t.Fatal("deferred scenario was not evaluated")
+	}
+	if !res.Deferred || len(res.ScenariosMatched) != 1 || res.ScenariosMatched[0] != "sc_purchase" ||
+		len(res.ActionsExecuted) != 1 || res.ActionsExecuted[0].ActionID != "act_bonus" {
+		t.Errorf("deferred pass = %+v, want act_bonus run", res)
+	}
+}
+
+func TestEngine_DrainInStages(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.EventWorkers = 1
+	cfg.Scenarios[0].Children[0].Action = &config.ActionDef{ID: "act_block", Type: "block"}
+	g, err := dag.Build(cfg)
+	if err != nil {
+		t.Fatalf("Build error: %v", err)
+	}
+	exec := &blockingExecutor{started: make(chan struct{}, 3), release: make(chan struct{})}
+	reg := action.NewRegistry()
+	reg.Register(exec)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	eng := engine.New(ctx, g, reg, cfg.Engine)
+
+	for _, id := range []string{"d1", "d2", "d3"} {
+		if err := eng.Enqueue(ctx, &event.Event{ID: id, Type: "login", ActorID: "u1"}, nil); err != nil {
+			t.Fatalf("Enqueue %s: %v", id, err)
+		}
+	}
+	<-exec.started // d1 is in its action; d2 and d3 are queued
+
+	short := func() context.Context {
+		c, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		t.Cleanup(stop)
+		return c
+	}
+	if left, err := eng.DrainQueue(short()); left != 2 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("DrainQueue = %d, %v; want 2 queued and a timeout", left, err)
+	}
+	if err := eng.Enqueue(ctx, &event.Event{ID: "d4", Type: "login"}, nil); !errors.Is(err, engine.ErrShuttingDown) {
+		t.Errorf("Enqueue after StopIntake = %v, want ErrShuttingDown", err)
+	}
+	if _, err := eng.ProcessSync(ctx, &event.Event{ID: "d5", Type: "login"}); !errors.Is(err, engine.ErrShuttingDown) {
+		t.Errorf("ProcessSync after StopIntake = %v, want ErrShuttingDown", err)
+	}
+	if left, err := eng.WaitIdle(short()); left != 3 || err == nil {
+		t.Errorf("WaitIdle = %d, %v; want 3 events left and a timeout", left, err)
+	}
+
+	close(exec.release)
+	if left, err := eng.WaitIdle(context.Background()); left != 0 || err != nil {
+		t.Errorf("WaitIdle = %d, %v; want every event finished", left, err)
+	}
+	eng.Shutdown() // idempotent after WaitIdle
+}
+
+func TestEngine_Streaks(t *testing.T) {
+	cfg := testConfig()
+	cfg.Scenarios = append(cfg.Scenarios, config.Scenario{
+		ID:         "sc_streak_bonus",
+		Enabled:    true,
+		EventTypes: []string{"purchase"},
+		Children: []config.NodeRef{
+			{Condition: &config.ConditionDef{
+				ID:         "cond_streak",
+				Expression: `streak("login") >= 2`,
+				Children: []config.NodeRef{
+					{Action: &config.ActionDef{
+						ID:     "act_bonus",
+						Type:   "reward_points",
+						Params: map[string]interface{}{"operation": "award", "points_formula": `streak("login") * 10`},
+					}},
+				},
+			}},
+		},
+	})
+	eng := newTestEngine(t, cfg)
+	if got := eng.Graph().StreakTypes(); len(got) != 1 || got[0] != "login" {
+		t.Fatalf("StreakTypes = %v", got)
+	}
+	ctx := context.Background()
+	day := func(d, h int) time.Time { return time.Date(2026, 3, d, h, 0, 0, 0, time.UTC) }
+	purchase := func(id string, at time.Time) *engine.EventResult {
+		t.Helper()
+		res, err := eng.ProcessSync(ctx, &event.Event{ID: id, Type: "purchase", ActorID: "u1", OccurredAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	// A dry-run login counts for its own evaluation but is not recorded.
+	dry := &event.Event{ID: "dry", Type: "login", ActorID: "u1", OccurredAt: day(1, 8)}
+	if _, err := eng.ProcessOptions(ctx, dry, &engine.EvalOptions{DryRun: true}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := eng.Streaks().Get(ctx, "u1", "login"); s != nil {
+		t.Fatalf("dry run recorded %+v", s)
+	}
+
+	for i, at := range []time.Time{day(1, 8), day(1, 20), day(2, 9)} {
+		if _, err := eng.ProcessSync(ctx, &event.Event{ID: fmt.Sprintf("login-%d", i), Type: "login", ActorID: "u1", OccurredAt: at}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if res := purchase("p1", day(3, 10)); len(res.ActionsExecuted) != 1 || res.ActionsExecuted[0].Points != 20 {
+		t.Fatalf("purchase the day after a 2-day streak: %+v", res.ActionsExecuted)
+	}
+	if res := purchase("p2", day(4, 10)); len(res.ScenariosMatched) != 0 {
+		t.Fatalf("streak broken by a missed day still matched: %v", res.ScenariosMatched)
+	}
+	s, err := eng.Streaks().Get(ctx, "u1", "login")
+	if err != nil || s == nil || s.Current != 2 || s.Best != 2 || s.LastDay != "2026-03-02" {
+		t.Fatalf("stored streak = %+v, %v", s, err)
+	}
+}
+
+func TestEngine_EventTypeAliases(t *testing.T) {
+	cfg := testConfig()
+	cfg.EventTypes = []config.EventTypeDef{{
+		Type:    "login",
+		Aliases: []string{"user.signed_in", "SignIn"},
+		Derive:  []config.DeriveRule{{When: `payload.method == "sso"`, Type: "sso_login"}},
+	}}
+	eng := newTestEngine(t, cfg)
+	ctx := context.Background()
+
+	ev := &event.Event{ID: "e1", Type: "signin", ActorID: "u1"}
+	res, err := eng.ProcessSync(ctx, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.ScenariosMatched) != 1 || ev.Type != "login" || ev.RawType != "signin" {
+		t.Fatalf("alias: matched %v, type %q raw %q", res.ScenariosMatched, ev.Type, ev.RawType)
+	}
+
+	ev = &event.Event{ID: "e2", Type: "user.signed_in", ActorID: "u1", Payload: map[string]interface{}{"method": "sso"}}
+	res, err = eng.ProcessSync(ctx, ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.ScenariosMatched) != 0 || ev.Type != "sso_login" || ev.RawType != "user.signed_in" {
+		t.Fatalf("derived: matched %v, type %q raw %q", res.ScenariosMatched, ev.Type, ev.RawType)
+	}
+
+	ev = &event.Event{ID: "e3", Type: "login", ActorID: "u1"}
+	if _, err := eng.ProcessSync(ctx, ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != "login" || ev.RawType != "" {
+		t.Fatalf("canonical type without a matching rule changed: type %q raw %q", ev.Type, ev.RawType)
+	}
+}
+
+func TestEngine_DedupeActions(t *testing.T) {
+	promo := func(id, actionID string, points float64) config.Scenario {
+		return config.Scenario{ID: id, Enabled: true, EventTypes: []string{"login"}, Children: []config.NodeRef{
+			{Action: &config.ActionDef{
+				ID:     actionID,
+				Type:   "reward_points",
+				Params: map[string]interface{}{"operation": "award", "points": points},
+			}},
+		}}
+	}
+	for _, dedupe := range []bool{false, true} {
+		cfg := testConfig()
+		cfg.Engine.DedupeActions = dedupe
+		// sc_promo_a awards the same 50 points as sc_login; sc_promo_b does not.
+		cfg.Scenarios = append(cfg.Scenarios, promo("sc_promo_a", "act_promo_a", 50), promo("sc_promo_b", "act_promo_b", 100))
+		eng := newTestEngine(t, cfg)
+
+		res, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.ScenariosMatched) != 3 {
+			t.Fatalf("dedupe=%v: matched %v, want all three scenarios", dedupe, res.ScenariosMatched)
+		}
+		if !dedupe {
+			if len(res.ActionsExecuted) != 3 {
+				t.Errorf("without dedupe_actions: %d actions, want 3", len(res.ActionsExecuted))
+			}
+			continue
+		}
+		if len(res.ActionsExecuted) != 2 {
+			t.Fatalf("with dedupe_actions: %d actions, want 2", len(res.ActionsExecuted))
+		}
+		for _, ar := range res.ActionsExecuted {
+			switch ar.ActionID {
+			case "act_welcome":
+				if fmt.Sprint(ar.Scenarios) != "[sc_login sc_promo_a]" || fmt.Sprint(ar.Collapsed) != "[act_promo_a]" {
+					t.Errorf("act_welcome: scenarios %v, collapsed %v", ar.Scenarios, ar.Collapsed)
+				}
+			case "act_promo_b":
+				if ar.Scenarios != nil || ar.Collapsed != nil {
+					t.Errorf("act_promo_b was not collapsed but records %v %v", ar.Scenarios, ar.Collapsed)
+				}
+			default:
+				t.Errorf("unexpected action %s", ar.ActionID)
+			}
+		}
+	}
+}
+
+func TestEngine_DedupeNodesReportsOwnActionIDs(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.DedupeNodes = true
+	copied := cfg.Scenarios[0]
+	copied.ID = "sc_login_copy"
+	copied.Children = []config.NodeRef{{Action: &config.ActionDef{
+		ID:     "act_welcome_copy",
+		Type:   "reward_points",
+		Params: map[string]interface{}{"operation": "award", "points": float64(50)},
+	}}}
+	cfg.Scenarios = append(cfg.Scenarios, copied)
+	eng := newTestEngine(t, cfg)
+
+	res, err := eng.ProcessSync(context.Background(), &event.Event{ID: "e1", Type: "login", ActorID: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, ar := range res.ActionsExecuted {
+		ids = append(ids, ar.ActionID)
+	}
+	if fmt.Sprint(ids) != "[act_welcome act_welcome_copy]" {
+		t.Errorf("executed %v, want each scenario's own action ID", ids)
+	}
+}
+
+func TestEngine_DedupedActionsKeepOwnLimits(t *testing.T) {
+	cfg := testConfig()
+	cfg.Engine.DedupeNodes = true
+	limit := &config.ActionLimit{Max: 1, WindowMs: 86400000}
+	cfg.Scenarios[0].Children[0].Action.Limit = limit
+	copied := cfg.Scenarios[0]
+	copied.ID = "sc_login_copy"
+	copied.Children = []config.NodeRef{{Action: &config.ActionDef{
+		ID:     "act_welcome_copy",
+		Type:   "reward_points",
+		Params: map[string]interface{}{"operation": "award", "points": float64(50)},
+		Limit:  limit,
+	}}}
+	cfg.Scenarios = append(cfg.Scenarios, copied)
+	eng := newTestEngine(t, cfg)
+
+	for i, want := range []string{"[true true]", "[false false]"} {
+		res, err := eng.ProcessSync(context.Background(), &event.Event{ID: fmt.Sprint("e", i), Type: "login", ActorID: "u1"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ok []bool
+		for _, ar := range res.ActionsExecuted {
+			ok = append(ok, ar.Success)
+		}
+		if fmt.Sprint(ok) != want {
+			t.Errorf("event %d: success %v, want %s (one claim per scenario)", i, ok, want)
+		}
+	}
+}
+
+// BenchmarkEngine_Throughput measures end-to-end synchronous processing:
+// queueing, evaluation and a points action, from many goroutines.
+func BenchmarkEngine_Throughput(b *testing.B) {
+	cfg := testConfig()
+	cfg.Engine.EventWorkers = 8
+	cfg.Engine.QueueDepth = 4096
+	g, err := dag.Build(cfg)
+	if err != nil {
+		b.Fatalf("Build error: %v", err)
+	}
+	reg := action.NewRegistry()
+	reg.Register(points.New())
+	ctx, cancel := context.WithCancel(context.Background())
+	eng := engine.New(ctx, g, reg, cfg.Engine)
+	defer func() {
+		cancel()
+		eng.Shutdown()
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			res, err := eng.ProcessSync(ctx, &event.Event{Type: "login", ActorID: "u1"})
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			if len(res.ActionsExecuted) != 1 {
+				b.Errorf("actions = %d, want 1", len(res.ActionsExecuted))
+				return
+			}
+		}
+	})
+	b.ReportMetric(float64(b.N)/time.Since(start).Seconds(), "events/s")
+}
+
+func TestBuildGraph_Rejects(t *testing.T) {
+	reg := action.NewRegistry()
+	reg.Register(points.New())
+	for name, mutate := range map[string]func(*config.RuleConfig){
+		"unregistered action type": func(cfg *config.RuleConfig) {
+			cfg.Scenarios[0].Children[0].Action.Type = "send_sms"
+		},
+		"invalid params": func(cfg *config.RuleConfig) {
+			cfg.Scenarios[0].Children[0].Action.Params = map[string]interface{}{"operation": "teleport"}
+		},
+		"unknown workflow": func(cfg *config.RuleConfig) {
+			cfg.Scenarios[0].Children[0].Action.Type = config.StartWorkflowType
+			cfg.Scenarios[0].Children[0].Action.Params = map[string]interface{}{"workflow": "wf_missing"}
+		},
+	} {
+		cfg := testConfig()
+		mutate(cfg)
+		if _, err := engine.BuildGraph(context.Background(), reg, cfg); err == nil {
+			t.Errorf("%s: BuildGraph accepted the config", name)
+		}
+	}
+	if _, err := engine.BuildGraph(context.Background(), reg, testConfig()); err != nil {
+		t.Errorf("BuildGraph(testConfig) = %v", err)
+	}
+}
+
+func TestEngine_BuildGraphOncePerConfig(t *testing.T) {
+	cfg := testConfig()
+	eng := newTestEngine(t, cfg)
+	swaps := 0
+	eng.OnGraphSwapped(func(_, _ *dag.Graph) { swaps++ })
+
+	g1, err := eng.BuildGraph(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An API change and the loader's OnChange callback build and swap the
+	// same config.
+	for range 2 {
+		g, err := eng.BuildGraph(context.Background(), cfg)
+		if err != nil || g != g1 {
+			t.Fatalf("rebuilt the same config: %p, %v", g, err)
+		}
+		eng.SwapGraph(g)
+	}
+	if swaps != 1 {
+		t.Errorf("graph hooks ran %d times, want 1", swaps)
+	}
+	if g, _ := eng.BuildGraph(context.Background(), testConfig()); g == g1 {
+		t.Error("a different config reused the memoized graph")
+	}
+}
@@ -0,0 +1,151 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+const quarantineCapacity = 500
+
+// QuarantinedEvent records an event rejected by a size guard. The payload is
+// deliberately not retained: oversized payloads are what we are protecting against.
+type QuarantinedEvent struct {
+	EventID       string    `json:"event_id"`
+	Type          string    `json:"type"`
+	Source        string    `json:"source"`
+	ActorID       string    `json:"actor_id"`
+	Reason        string    `json:"reason"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// quarantine keeps the most recent quarantined events in memory.
+type quarantine struct {
+	mu      sync.Mutex
+	entries []QuarantinedEvent
+}
+
+func (q *quarantine) add(ev *event.Event, reason string) {
+	metrics.EventsQuarantined.Inc()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.entries = append(q.entries, QuarantinedEvent{
+		EventID:       ev.ID,
+		Type:          ev.Type,
+		Source:        ev.Source,
+		ActorID:       ev.ActorID,
+		Reason:        reason,
+		QuarantinedAt: time.Now(),
+	})
+	if len(q.entries) > quarantineCapacity {
+		q.entries = q.entries[len(q.entries)-quarantineCapacity:]
+	}
+}
+
+func (q *quarantine) list() []QuarantinedEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]QuarantinedEvent, len(q.entries))
+	copy(out, q.entries)
+	return out
+}
+
+// Quarantined returns recently quarantined events, oldest first.
+func (e *Engine) Quarantined() []QuarantinedEvent {
+	return e.quarantine.list()
+}
+
+// CheckSize returns an error if ev exceeds the payload size guards, letting
+// callers reject oversized events before they are queued.
+func (e *Engine) CheckSize(ev *event.Event) error {
+	if reason := e.checkSize(ev); reason != "" {
+		return errors.New(reason)
+	}
+	return nil
+}
+
+// Quarantine checks ev against the payload size guards, so callers can turn
+// an oversized event away before it is queued or stored. It returns nil when
+// ev is within the guards; otherwise ev is quarantined and its result
+// returned.
+func (e *Engine) Quarantine(ev *event.Event) *EventResult {
+	reason := e.checkSize(ev)
+	if reason == "" {
+		return nil
+	}
+	e.quarantine.add(ev, reason)
+	metrics.EventsProcessed.Inc()
+	return &EventResult{
+		EventID:          ev.ID,
+		ScenariosMatched: []string{},
+		ActionsExecuted:  []*action.ActionResult{},
+		Error:            "quarantined: " + reason,
+		Quarantined:      true,
+		Region:           e.conf.Load().Region,
+	}
+}
+
+// checkSize returns a non-empty reason if ev exceeds the payload guards.
+func (e *Engine) checkSize(ev *event.Event) string {
+	conf := e.conf.Load()
+	maxBytes, maxDepth := conf.MaxPayloadBytes, conf.MaxPayloadDepth
+	w := &sizeWalker{maxBytes: maxBytes, maxDepth: maxDepth}
+	w.walk(map[string]interface{}(ev.Payload), 1)
+	for k, v := range ev.Meta {
+		w.size += len(k) + len(v)
+	}
+	switch {
+	case maxDepth > 0 && w.depth > maxDepth:
+		return fmt.Sprintf("payload nesting exceeds %d levels", maxDepth)
+	case maxBytes > 0 && w.size > maxBytes:
+		return fmt.Sprintf("payload exceeds %d bytes", maxBytes)
+	}
+	return ""
+}
+
+// sizeWalker approximates the encoded size and nesting depth of a decoded
+// JSON value, stopping as soon as a limit is exceeded.
+type sizeWalker struct {
+	maxBytes, maxDepth int
+	size, depth        int
+}
+
+func (w *sizeWalker) exceeded() bool {
+	return (w.maxBytes > 0 && w.size > w.maxBytes) || (w.maxDepth > 0 && w.depth > w.maxDepth)
+}
+
+func (w *sizeWalker) walk(v interface{}, depth int) {
+	if w.exceeded() {
+		return
+	}
+	if depth > w.depth {
+		w.depth = depth
+	}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			w.size += len(k) + 4
+			w.walk(child, depth+1)
+			if w.exceeded() {
+				return
+			}
+		}
+	case []interface{}:
+		for _, child := range t {
+			w.size++
+			w.walk(child, depth+1)
+			if w.exceeded() {
+				return
+			}
+		}
+	case string:
+		w.size += len(t) + 2
+	default:
+		w.size += 8
+	}
+}
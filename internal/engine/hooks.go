@@ -0,0 +1,87 @@
+package engine
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// EventHook is called after an event has been fully processed.
+type EventHook func(ev *event.Event, res *EventResult)
+
+// ActionHook is called after each action has executed (successfully or not).
+type ActionHook func(ev *event.Event, match dag.ActionMatch, res *action.ActionResult)
+
+// GraphHook is called after the active graph has been replaced.
+type GraphHook func(old, new *dag.Graph)
+
+// hookSet is immutable once published; registration copies it, so the hot
+// path reads hooks with a single atomic load and no lock.
+type hookSet struct {
+	events  []EventHook
+	watches []*eventWatch // removable event hooks, see WatchEvents
+	actions []ActionHook
+	graphs  []GraphHook
+}
+
+// eventWatch boxes an EventHook so it can be found again to remove it.
+type eventWatch struct{ fn EventHook }
+
+type hooks struct {
+	mu  sync.Mutex // serialises registration
+	set atomic.Pointer[hookSet]
+}
+
+func (h *hooks) load() *hookSet {
+	if s := h.set.Load(); s != nil {
+		return s
+	}
+	return &hookSet{}
+}
+
+func (h *hooks) update(fn func(s *hookSet)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	cur := h.load()
+	next := &hookSet{
+		events:  append([]EventHook(nil), cur.events...),
+		watches: append([]*eventWatch(nil), cur.watches...),
+		actions: append([]ActionHook(nil), cur.actions...),
+		graphs:  append([]GraphHook(nil), cur.graphs...),
+	}
+	fn(next)
+	h.set.Store(next)
+}
+
+// OnEventProcessed registers fn to run after every processed event.
+// Hooks run synchronously on the event worker and must be fast.
+func (e *Engine) OnEventProcessed(fn EventHook) {
+	e.hooks.update(func(s *hookSet) { s.events = append(s.events, fn) })
+}
+
+// WatchEvents is OnEventProcessed for short-lived listeners, such as one
+// GET /v1/tail connection: stop removes fn again.
+func (e *Engine) WatchEvents(fn EventHook) (stop func()) {
+	w := &eventWatch{fn: fn}
+	e.hooks.update(func(s *hookSet) { s.watches = append(s.watches, w) })
+	return func() {
+		e.hooks.update(func(s *hookSet) {
+			s.watches = slices.DeleteFunc(s.watches, func(x *eventWatch) bool { return x == w })
+		})
+	}
+}
+
+// OnActionExecuted registers fn to run after every action execution.
+// Hooks run synchronously on the executing goroutine and must be fast.
+func (e *Engine) OnActionExecuted(fn ActionHook) {
+	e.hooks.update(func(s *hookSet) { s.actions = append(s.actions, fn) })
+}
+
+// OnGraphSwapped registers fn to run after SwapGraph installs a new graph.
+func (e *Engine) OnGraphSwapped(fn GraphHook) {
+	e.hooks.update(func(s *hookSet) { s.graphs = append(s.graphs, fn) })
+}
@@ -0,0 +1,60 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// LimitKey is the counter key of an action's limit for actorID. Limits are
+// per scenario and action ID, so actions shared through engine.dedupe_nodes
+// keep separate counts.
+func LimitKey(scenarioID, actionID, actorID string) string {
+	return scenarioID + "|" + actionID + "|" + actorID
+}
+
+// SplitLimitKey reverses LimitKey. It splits at the first two "|", so actor
+// IDs may contain one but scenario and action IDs may not.
+func SplitLimitKey(key string) (scenarioID, actionID, actorID string, ok bool) {
+	scenarioID, rest, ok := strings.Cut(key, "|")
+	if !ok {
+		return "", "", "", false
+	}
+	actionID, actorID, ok = strings.Cut(rest, "|")
+	return scenarioID, actionID, actorID, ok
+}
+
+// checkLimit claims a slot in m's per-actor limit. It returns nil when the
+// action may run, and a skipped result when the actor has used up the
+// window. A counter store error also skips the action: for rate-limited
+// rewards a missed grant is cheaper than a double claim.
+func (e *Engine) checkLimit(ctx context.Context, m dag.ActionMatch, evalCtx *dag.EvalContext) *action.ActionResult {
+	l := m.Node.Limit()
+	if l == nil {
+		return nil
+	}
+	limit := max(l.Max, 1)
+	ok, err := e.counters.Claim(ctx, LimitKey(m.ScenarioID, m.ActionID, evalCtx.Event.ActorID), limit, l.WindowMs.Duration(), time.Now())
+	if ok && err == nil {
+		return nil
+	}
+	res := &action.ActionResult{
+		ActionID: m.ActionID,
+		Type:     m.Node.ActionType(),
+		Success:  false,
+		Status:   action.StatusLimited,
+		Message:  fmt.Sprintf("limit of %d per %s reached for actor %s", limit, l.WindowMs.Duration(), evalCtx.Event.ActorID),
+	}
+	if err != nil {
+		logctx.From(ctx).Warn("action limit check failed; skipping action", "action_id", m.ActionID, "err", err)
+		res.Message = "limit check failed: " + err.Error()
+	}
+	metrics.ActionsExecuted.WithLabelValues(m.Node.ActionType(), action.StatusLimited).Inc()
+	return res
+}
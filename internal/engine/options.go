@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+)
+
+// EvalOptions change how a single event is evaluated. The zero value is
+// normal processing.
+type EvalOptions struct {
+	// Explain adds the evaluated nodes, in order, to the result's trace.
+	Explain bool `json:"explain,omitempty"`
+	// DryRun evaluates the event without side effects: no action runs, no
+	// limit is claimed, the event ID is not recorded for dedupe, and event
+	// hooks (workflows, scheduled-event cancellation) are not notified.
+	DryRun bool `json:"dry_run,omitempty"`
+	// SkipActions lists action IDs that are reported but not executed.
+	SkipActions []string `json:"skip_actions,omitempty"`
+	// ForceScenario evaluates only this scenario, whatever its event types
+	// and sources; its required fields and conditions still apply. It
+	// requires DryRun, since the filters it bypasses decide who may trigger
+	// the scenario's actions.
+	ForceScenario string `json:"force_scenario,omitempty"`
+}
+
+// CheckOptions validates opts against the active graph: the forced
+// scenario must exist and be a dry run, and every skipped ID must name an
+// action.
+func (e *Engine) CheckOptions(opts *EvalOptions) error {
+	if opts == nil {
+		return nil
+	}
+	g := e.graph.Load()
+	var errs []string
+	if id := opts.ForceScenario; id != "" {
+		if _, ok := g.Node(id).(*dag.ScenarioNode); !ok {
+			errs = append(errs, fmt.Sprintf("force_scenario: unknown scenario %q", id))
+		} else if !opts.DryRun {
+			errs = append(errs, "force_scenario requires dry_run")
+		}
+	}
+	for _, id := range opts.SkipActions {
+		if _, ok := g.Node(id).(*dag.ActionNode); !ok {
+			errs = append(errs, fmt.Sprintf("skip_actions: unknown action %q", id))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ProcessOptions is ProcessSync, or ProcessStream when onAction is non-nil,
+// with per-event options. opts may be nil.
+func (e *Engine) ProcessOptions(ctx context.Context, ev *event.Event, opts *EvalOptions, onAction func(*action.ActionResult)) (*EventResult, error) {
+	return e.submitAndWait(ctx, &eventWork{ev: ev, opts: opts, onAction: onAction, logArgs: logctx.Args(ctx)})
+}
+
+// heldActions splits matches into those to run and results for those held
+// back by opts, which are reported without being executed.
+func heldActions(matches []dag.ActionMatch, opts *EvalOptions) (run []dag.ActionMatch, held []*action.ActionResult) {
+	if opts == nil || (!opts.DryRun && len(opts.SkipActions) == 0) {
+		return matches, nil
+	}
+	// Matches carry their scenario's own action ID, so skipping an action
+	// deduplicated by engine.dedupe_nodes leaves its twins elsewhere running.
+	skip := make(map[string]bool, len(opts.SkipActions))
+	for _, id := range opts.SkipActions {
+		skip[id] = true
+	}
+	for _, m := range matches {
+		res := &action.ActionResult{ActionID: m.ActionID, Type: m.Node.ActionType()}
+		switch {
+		case opts.DryRun:
+			res.Success, res.Status, res.Message = true, action.StatusDryRun, "dry run: not executed"
+		case skip[m.ActionID]:
+			res.Status, res.Message = action.StatusSkipped, "skipped by request"
+		default:
+			run = append(run, m)
+			continue
+		}
+		held = append(held, res)
+	}
+	return run, held
+}
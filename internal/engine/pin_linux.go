@@ -0,0 +1,41 @@
+//go:build linux
+
+package engine
+
+import (
+	"log/slog"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// pinWorker locks the calling goroutine to its OS thread and restricts that
+// thread to the worker's CPU, chosen round-robin from the process affinity set.
+func pinWorker(worker int) {
+	runtime.LockOSThread()
+	var allowed unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &allowed); err != nil {
+		slog.Debug("worker pinning unavailable", "err", err)
+		return
+	}
+	n := allowed.Count()
+	if n == 0 {
+		return
+	}
+	target := worker % n
+	for cpu := 0; ; cpu++ {
+		if !allowed.IsSet(cpu) {
+			continue
+		}
+		if target > 0 {
+			target--
+			continue
+		}
+		var one unix.CPUSet
+		one.Set(cpu)
+		if err := unix.SchedSetaffinity(0, &one); err != nil {
+			slog.Debug("worker pinning failed", "cpu", cpu, "err", err)
+		}
+		return
+	}
+}
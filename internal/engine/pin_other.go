@@ -0,0 +1,11 @@
+//go:build !linux
+
+package engine
+
+import "runtime"
+
+// pinWorker locks the calling goroutine to its OS thread. CPU affinity is
+// only applied on Linux.
+func pinWorker(int) {
+	runtime.LockOSThread()
+}
@@ -0,0 +1,46 @@
+package engine
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when an event arrives faster than
+// engine.ingest_rate_limit allows.
+var ErrRateLimited = errors.New("event rate limit exceeded")
+
+// ingestLimiter is a token bucket in front of every submission path. Rate and
+// burst are passed on each call so PATCH /v1/admin/engine takes effect on the
+// next event.
+type ingestLimiter struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow takes one token if one is available. rate <= 0 allows everything;
+// burst <= 0 means one second's worth of rate.
+func (l *ingestLimiter) allow(rate float64, burst int, now time.Time) bool {
+	if rate <= 0 {
+		return true
+	}
+	capacity := float64(burst)
+	if burst <= 0 {
+		capacity = math.Max(1, rate)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.last.IsZero() {
+		l.tokens = capacity
+	} else {
+		l.tokens = math.Min(capacity, l.tokens+now.Sub(l.last).Seconds()*rate)
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
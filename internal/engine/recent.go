@@ -0,0 +1,166 @@
+package engine
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// RecentResult is an EventResult with the event's identifying fields. The
+// payload is not retained, which keeps the buffer small and free of PII.
+type RecentResult struct {
+	EventID          string                 `json:"event_id"`
+	Type             string                 `json:"type"`
+	Source           string                 `json:"source"`
+	ActorID          string                 `json:"actor_id"`
+	ProcessedAt      time.Time              `json:"processed_at"`
+	DurationMs       int64                  `json:"duration_ms"`
+	ScenariosMatched []string               `json:"scenarios_matched"`
+	ActionsExecuted  []*action.ActionResult `json:"actions_executed"`
+	Error            string                 `json:"error,omitempty"`
+	Region           string                 `json:"region,omitempty"`
+}
+
+// failed reports whether processing errored or any action did not succeed.
+func (r *RecentResult) failed() bool {
+	if r.Error != "" {
+		return true
+	}
+	for _, a := range r.ActionsExecuted {
+		if a != nil && !a.Success {
+			return true
+		}
+	}
+	return false
+}
+
+// RecentFilter selects results from Engine.Recent. Zero fields match anything.
+type RecentFilter struct {
+	ActorID    string
+	ScenarioID string
+	Type       string
+	Status     string // "matched", "unmatched" or "failed"
+	Since      time.Time
+	Limit      int // 0 = every buffered result
+}
+
+func (f *RecentFilter) matches(r *RecentResult) bool {
+	switch {
+	case f.ActorID != "" && r.ActorID != f.ActorID,
+		f.Type != "" && r.Type != f.Type,
+		f.ScenarioID != "" && !slices.Contains(r.ScenariosMatched, f.ScenarioID),
+		!f.Since.IsZero() && r.ProcessedAt.Before(f.Since):
+		return false
+	}
+	switch f.Status {
+	case "matched":
+		return len(r.ScenariosMatched) > 0
+	case "unmatched":
+		return len(r.ScenariosMatched) == 0
+	case "failed":
+		return r.failed()
+	}
+	return true
+}
+
+// recentRing keeps the last len(buf) results. It is written once per
+// processed event, so it overwrites in place instead of reslicing.
+type recentRing struct {
+	mu   sync.Mutex
+	buf  []RecentResult
+	next int
+	full bool
+}
+
+func newRecentRing(capacity int) *recentRing {
+	if capacity <= 0 {
+		return nil
+	}
+	return &recentRing{buf: make([]RecentResult, capacity)}
+}
+
+func (r *recentRing) add(ev *event.Event, res *EventResult) {
+	if r == nil {
+		return
+	}
+	entry := RecentResult{
+		EventID:          res.EventID,
+		Type:             ev.Type,
+		Source:           ev.Source,
+		ActorID:          ev.ActorID,
+		ProcessedAt:      time.Now(),
+		DurationMs:       res.DurationMs,
+		ScenariosMatched: res.ScenariosMatched,
+		ActionsExecuted:  res.ActionsExecuted,
+		Error:            res.Error,
+		Region:           res.Region,
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf[r.next] = entry
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns matching results, newest first.
+func (r *recentRing) list(f RecentFilter) []RecentResult {
+	out := []RecentResult{}
+	if r == nil {
+		return out
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := r.next
+	if r.full {
+		n = len(r.buf)
+	}
+	for i := 1; i <= n; i++ {
+		e := &r.buf[(r.next-i+len(r.buf))%len(r.buf)]
+		if e.ProcessedAt.IsZero() || !f.matches(e) { // zero = purged
+			continue
+		}
+		out = append(out, *e)
+		if f.Limit > 0 && len(out) == f.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// purge clears entries whose ttl has passed at now and returns how many.
+func (r *recentRing) purge(now time.Time, ttl func(scenarios []string) time.Duration) int {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for i := range r.buf {
+		e := &r.buf[i]
+		if e.ProcessedAt.IsZero() {
+			continue
+		}
+		if d := ttl(e.ScenariosMatched); d > 0 && now.Sub(e.ProcessedAt) >= d {
+			r.buf[i] = RecentResult{}
+			n++
+		}
+	}
+	return n
+}
+
+// Recent returns buffered results matching f, newest first. The buffer
+// holds the last engine.recent_results processed events.
+func (e *Engine) Recent(f RecentFilter) []RecentResult {
+	return e.recent.list(f)
+}
+
+// PurgeRecent drops buffered results older than ttl(ScenariosMatched) and
+// returns how many; ttl 0 keeps a result until the ring overwrites it.
+func (e *Engine) PurgeRecent(now time.Time, ttl func(scenarios []string) time.Duration) int {
+	return e.recent.purge(now, ttl)
+}
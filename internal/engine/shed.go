@@ -0,0 +1,103 @@
+package engine
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+const (
+	// deferredCapacity bounds the events waiting for deferred scenarios.
+	// Past it, further shed scenarios are skipped as with shed_mode: skip.
+	deferredCapacity = 10000
+	// deferredPoll is how often deferred scenarios are retried.
+	deferredPoll = 100 * time.Millisecond
+	// deferredBatch is the most deferred events submitted per poll.
+	deferredBatch = 100
+)
+
+// deferredQueue holds events whose expensive scenarios were deferred by
+// load shedding, oldest first. Each work item evaluates only those
+// scenarios.
+type deferredQueue struct {
+	mu    sync.Mutex
+	items []*eventWork
+}
+
+// push adds w unless the queue is full.
+func (q *deferredQueue) push(w *eventWork) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) >= deferredCapacity {
+		return false
+	}
+	q.items = append(q.items, w)
+	metrics.DeferredEvents.Set(float64(len(q.items)))
+	return true
+}
+
+// take removes and returns up to n of the oldest items.
+func (q *deferredQueue) take(n int) []*eventWork {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n = min(n, len(q.items))
+	out := q.items[:n:n]
+	q.items = q.items[n:]
+	metrics.DeferredEvents.Set(float64(len(q.items)))
+	return out
+}
+
+// requeue puts items that could not be submitted back at the front.
+func (q *deferredQueue) requeue(ws []*eventWork) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = append(ws[:len(ws):len(ws)], q.items...)
+	metrics.DeferredEvents.Set(float64(len(q.items)))
+}
+
+func (q *deferredQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// deferShed queues the scenarios of w's event that load shedding put off.
+// It returns false when the queue is full and they have to be skipped.
+func (e *Engine) deferShed(w *eventWork, scenarios []string) bool {
+	ev := *w.ev // the first pass's event may still be read by hooks
+	return e.deferred.push(&eventWork{ev: &ev, deferred: scenarios, logArgs: w.logArgs})
+}
+
+// replayDeferred submits deferred scenarios to the async pool once queue use
+// is back under engine.shed_expensive_at, as many at a time as FetchSize
+// allows, until ctx is done. What is still deferred then is dropped.
+func (e *Engine) replayDeferred(ctx context.Context) {
+	t := time.NewTicker(deferredPoll)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if n := e.deferred.len(); n > 0 {
+				slog.Warn("deferred scenarios dropped", "events", n)
+			}
+			return
+		case <-t.C:
+		}
+		if e.stopping.Load() || e.deferred.len() == 0 {
+			continue
+		}
+		if at := e.conf.Load().ShedExpensiveAt; at > 0 && e.QueueUtilization() >= at {
+			continue
+		}
+		ws := e.deferred.take(e.FetchSize(deferredBatch))
+		for i, w := range ws {
+			if !e.eventPool.Submit(w) {
+				e.deferred.requeue(ws[i:])
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// SimulationResult describes what an event would trigger against the active
+// graph. No actions are executed.
+type SimulationResult struct {
+	GraphHash        string              `json:"graph_hash"`
+	ScenariosMatched []string            `json:"scenarios_matched"`
+	Actions          []SimulatedAction   `json:"actions"`
+	MissingFields    map[string][]string `json:"missing_fields,omitempty"`
+	Errors           []string            `json:"errors,omitempty"`
+}
+
+// SimulatedAction is an action that would run for the simulated event.
+type SimulatedAction struct {
+	ScenarioID string                 `json:"scenario_id"`
+	ActionID   string                 `json:"action_id"`
+	Type       string                 `json:"type"`
+	Params     map[string]interface{} `json:"params,omitempty"`
+	Scenarios  []string               `json:"scenarios,omitempty"` // as in action.ActionResult, with engine.dedupe_actions
+	Collapsed  []string               `json:"collapsed,omitempty"`
+}
+
+// Simulate evaluates ev against the active graph on the caller's goroutine,
+// bypassing the queue, and reports matches without executing actions.
+func (e *Engine) Simulate(ctx context.Context, ev *event.Event) *SimulationResult {
+	g := e.graph.Load()
+	evalCtx := e.newEvalContext(ctx, g, ev)
+	g.Canonicalize(evalCtx)
+	matches, scenarios, _ := dag.EvaluateContext(g, evalCtx)
+	if e.conf.Load().DedupeActions {
+		matches = collapseActions(matches)
+	}
+
+	res := &SimulationResult{
+		GraphHash:        g.Hash(),
+		ScenariosMatched: scenarios,
+		Actions:          make([]SimulatedAction, 0, len(matches)),
+		MissingFields:    evalCtx.MissingFields,
+	}
+	for _, m := range matches {
+		sa := SimulatedAction{
+			ScenarioID: m.ScenarioID,
+			ActionID:   m.ActionID,
+			Type:       m.Node.ActionType(),
+			Params:     m.Node.Params(),
+		}
+		sa.Scenarios, sa.Collapsed = collapsedInto(m)
+		res.Actions = append(res.Actions, sa)
+	}
+	for _, err := range evalCtx.Errors {
+		res.Errors = append(res.Errors, err.Error())
+	}
+	return res
+}
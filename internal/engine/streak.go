@@ -0,0 +1,59 @@
+package engine
+
+import (
+	"context"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/streak"
+)
+
+// recordStreak advances the actor's streak for the event's type when g reads
+// streaks of that type. The day is taken in the zone calendar functions use
+// for the event (meta.timezone, the actor profile, then the default). A
+// failed update is logged and evaluation goes on with the stored streak.
+func (e *Engine) recordStreak(ctx context.Context, g *dag.Graph, evalCtx *dag.EvalContext) {
+	ev := evalCtx.Event
+	if ev.ActorID == "" || !g.TracksStreak(ev.Type) {
+		return
+	}
+	day := streak.Day(eventTime(ev), evalCtx.Location())
+	if _, err := e.streaks.Record(ctx, ev.ActorID, ev.Type, day, time.Now()); err != nil {
+		logctx.From(ctx).Warn("streak update failed", "actor_id", ev.ActorID, "event_type", ev.Type, "error", err)
+	}
+}
+
+// streakLoader returns evalCtx's LoadStreak: the actor's streak as seen on
+// the event's day. An event of the requested type counts towards it whether
+// or not it was recorded, so dry runs and simulations see what a real run
+// would.
+func (e *Engine) streakLoader(ctx context.Context, evalCtx *dag.EvalContext) func(string) (int, error) {
+	return func(eventType string) (int, error) {
+		ev := evalCtx.Event
+		if ev.ActorID == "" {
+			return 0, nil
+		}
+		s, err := e.streaks.Get(ctx, ev.ActorID, eventType)
+		if err != nil {
+			return 0, err
+		}
+		day := streak.Day(eventTime(ev), evalCtx.Location())
+		if ev.Type == eventType {
+			s = s.Advance(day)
+		}
+		return s.Length(day), nil
+	}
+}
+
+// eventTime is when ev happened: occurred_at, else when it was received.
+func eventTime(ev *event.Event) time.Time {
+	switch {
+	case !ev.OccurredAt.IsZero():
+		return ev.OccurredAt
+	case !ev.ReceivedAt.IsZero():
+		return ev.ReceivedAt
+	}
+	return time.Now()
+}
@@ -2,7 +2,13 @@ package engine
 
 import (
 	"context"
+	"fmt"
+	"runtime/debug"
 	"sync"
+	"sync/atomic"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
 )
 
 // job is the unit of work dispatched to a worker.
@@ -16,15 +22,26 @@ type jobResult[T any] struct {
 	err     error
 }
 
+// loggedPayload is a payload carrying logctx fields. The pool attaches them
+// to the context it is processed with, so a recovered panic logs them too.
+type loggedPayload interface {
+	logFields() []any
+}
+
 // workerPool is a fixed-size goroutine pool with a bounded input queue.
 type workerPool[T, R any] struct {
 	queue   chan job[T]
 	process func(ctx context.Context, t T) (R, error)
 	wg      sync.WaitGroup
+	busy    atomic.Int64 // jobs being processed
+
+	mu     sync.RWMutex // guards closed against sends on a closed queue
+	closed bool
 }
 
 // newWorkerPool creates and starts a pool with n goroutines and queue capacity cap.
-func newWorkerPool[T, R any](ctx context.Context, n, cap int, fn func(context.Context, T) (R, error)) *workerPool[T, R] {
+// If start is non-nil, each worker calls it with its index before taking jobs.
+func newWorkerPool[T, R any](ctx context.Context, n, cap int, start func(worker int), fn func(context.Context, T) (R, error)) *workerPool[T, R] {
 	p := &workerPool[T, R]{
 		queue:   make(chan job[T], cap),
 		process: fn,
@@ -33,6 +50,9 @@ func newWorkerPool[T, R any](ctx context.Context, n, cap int, fn func(context.Co
 		p.wg.Add(1)
 		go func() {
 			defer p.wg.Done()
+			if start != nil {
+				start(i)
+			}
 			p.run(ctx)
 		}()
 	}
@@ -46,7 +66,9 @@ func (p *workerPool[T, R]) run(ctx context.Context) {
 			if !ok {
 				return
 			}
-			_, err := p.process(ctx, j.payload)
+			p.busy.Add(1)
+			err := p.safeProcess(ctx, j.payload)
+			p.busy.Add(-1)
 			if j.result != nil {
 				j.result <- jobResult[T]{payload: j.payload, err: err}
 			}
@@ -56,8 +78,31 @@ func (p *workerPool[T, R]) run(ctx context.Context) {
 	}
 }
 
-// Submit enqueues a job without blocking (returns false if full).
+// safeProcess runs process, recovering a panic into an error so the worker
+// keeps serving. Executor and condition panics are recovered closer to the
+// source; this is the last line of defence for engine bugs.
+func (p *workerPool[T, R]) safeProcess(ctx context.Context, t T) (err error) {
+	if lp, ok := any(t).(loggedPayload); ok {
+		ctx = logctx.With(ctx, lp.logFields()...)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.PanicsRecovered.WithLabelValues("worker", "").Inc()
+			logctx.From(ctx).Error("worker recovered from panic", "panic", r, "stack", string(debug.Stack()))
+			err = fmt.Errorf("worker panic: %v", r)
+		}
+	}()
+	_, err = p.process(ctx, t)
+	return err
+}
+
+// Submit enqueues a job without blocking (returns false if full or closed).
 func (p *workerPool[T, R]) Submit(t T) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
 	select {
 	case p.queue <- job[T]{payload: t}:
 		return true
@@ -66,12 +111,43 @@ func (p *workerPool[T, R]) Submit(t T) bool {
 	}
 }
 
+// Close stops accepting jobs. Workers finish the queued ones and exit. It is
+// safe to call more than once.
+func (p *workerPool[T, R]) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+}
+
+// Wait blocks until every worker has exited or ctx is done.
+func (p *workerPool[T, R]) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Drain closes the queue and waits for all workers to finish.
 func (p *workerPool[T, R]) Drain() {
-	close(p.queue)
+	p.Close()
 	p.wg.Wait()
 }
 
+// Busy returns how many jobs workers are processing right now.
+func (p *workerPool[T, R]) Busy() int {
+	return int(p.busy.Load())
+}
+
 // QueueLen returns how many jobs are currently queued.
 func (p *workerPool[T, R]) QueueLen() int {
 	return len(p.queue)
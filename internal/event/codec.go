@@ -0,0 +1,161 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Codec names, as accepted by NewCodec and the -store-codec flag.
+const (
+	CodecJSON    = "json"
+	CodecMsgPack = "msgpack"
+	CodecProto   = "proto"
+)
+
+// Codec serializes events for the durable stores (the inbox and the schedule
+// store). ReceivedAt is not part of the encoding; stores keep it in a column.
+//
+// Binary encodings start with a two-byte header (0x00, codec ID) that JSON
+// never does, so Decode reads rows written by any codec and the codec can be
+// changed without draining the stores first. Numbers decode as float64 with
+// every codec, as they do from JSON, so conditions behave the same whichever
+// codec wrote the event.
+type Codec interface {
+	Name() string
+	Marshal(ev *Event) ([]byte, error)
+	Unmarshal(data []byte, ev *Event) error
+}
+
+// Binary codec header.
+const (
+	headerMagic = 0x00
+	idMsgPack   = 0x01
+	idProto     = 0x02
+)
+
+// maxDepth bounds the nesting of decoded payload values, so a corrupt or
+// hostile body cannot exhaust the stack.
+const maxDepth = 64
+
+// NewCodec returns the codec registered under name.
+func NewCodec(name string) (Codec, error) {
+	switch name {
+	case CodecJSON, "":
+		return jsonCodec{}, nil
+	case CodecMsgPack:
+		return msgpackCodec{}, nil
+	case CodecProto:
+		return protoCodec{}, nil
+	}
+	return nil, fmt.Errorf("unknown event codec %q (expected %s, %s or %s)", name, CodecJSON, CodecMsgPack, CodecProto)
+}
+
+// Decode decodes data written by any Codec.
+func Decode(data []byte, ev *Event) error {
+	if len(data) < 2 || data[0] != headerMagic {
+		return jsonCodec{}.Unmarshal(data, ev)
+	}
+	switch data[1] {
+	case idMsgPack:
+		return msgpackCodec{}.Unmarshal(data, ev)
+	case idProto:
+		return protoCodec{}.Unmarshal(data, ev)
+	}
+	return fmt.Errorf("event decode: unknown codec ID %#x", data[1])
+}
+
+// checkHeader strips the binary header of codec id from data.
+func checkHeader(data []byte, id byte, name string) ([]byte, error) {
+	if len(data) < 2 || data[0] != headerMagic || data[1] != id {
+		return nil, fmt.Errorf("%s decode: missing %s header", name, name)
+	}
+	return data[2:], nil
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return CodecJSON }
+
+func (jsonCodec) Marshal(ev *Event) ([]byte, error) { return json.Marshal(ev) }
+
+func (jsonCodec) Unmarshal(data []byte, ev *Event) error { return json.Unmarshal(data, ev) }
+
+// unixNano encodes t for the binary codecs; the zero time is 0.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano reverses unixNano. Times come back in UTC.
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n).UTC()
+}
+
+// plainMap returns a copy of m holding only the types JSON decoding produces
+// (nil, bool, float64, string, []interface{}, map[string]interface{}), the
+// ones the binary codecs write and return. Other numbers become float64 and
+// anything else takes a JSON round trip, so it encodes exactly as it would
+// with the JSON codec. Values nested deeper than maxDepth are an error.
+func plainMap(m map[string]interface{}) (map[string]interface{}, error) {
+	v, err := plain(m, 0)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+func plain(v interface{}, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("payload nested too deeply")
+	}
+	switch x := v.(type) {
+	case nil, bool, float64, string:
+		return v, nil
+	case int:
+		return float64(x), nil
+	case int64:
+		return float64(x), nil
+	case int32:
+		return float64(x), nil
+	case uint64:
+		return float64(x), nil
+	case float32:
+		return float64(x), nil
+	case json.Number:
+		return x.Float64()
+	case []interface{}:
+		out := make([]interface{}, len(x))
+		for i, e := range x {
+			var err error
+			if out[i], err = plain(e, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			var err error
+			if out[k], err = plain(e, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return plain(out, depth)
+}
@@ -0,0 +1,201 @@
+package event
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var codecNames = []string{CodecJSON, CodecMsgPack, CodecProto}
+
+func sampleEvent() *Event {
+	return &Event{
+		ID:         "evt-1",
+		Type:       "transaction",
+		OccurredAt: time.Date(2026, 3, 1, 12, 30, 0, 123456789, time.UTC),
+		Source:     "pos",
+		ActorID:    "u1",
+		Payload: map[string]interface{}{
+			"amount":   1250.5,
+			"count":    3,
+			"negative": -70000.0,
+			"big":      float64(1 << 40),
+			"currency": "INR",
+			"flagged":  false,
+			"note":     nil,
+			"tags":     []interface{}{"a", 2.0, true, nil},
+			"card":     map[string]interface{}{"last4": "4242", "exp": map[string]interface{}{"year": 2030.0}},
+			"long":     strings.Repeat("x", 70000),
+		},
+		Meta: map[string]string{"tenant": "acme", "region": "ap-south-1"},
+	}
+}
+
+// viaJSON is what the JSON codec gives back for ev: the reference every
+// codec must match.
+func viaJSON(t *testing.T, ev *Event) *Event {
+	t.Helper()
+	b, err := json.Marshal(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out Event
+	if err := json.Unmarshal(b, &out); err != nil {
+		t.Fatal(err)
+	}
+	return &out
+}
+
+func TestCodecs_RoundTripLikeJSON(t *testing.T) {
+	for _, ev := range []*Event{sampleEvent(), {ID: "bare", Type: "login"}} {
+		want := viaJSON(t, ev)
+		for _, name := range codecNames {
+			c, err := NewCodec(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := c.Marshal(ev)
+			if err != nil {
+				t.Fatalf("%s: Marshal: %v", name, err)
+			}
+			var got Event
+			if err := Decode(b, &got); err != nil {
+				t.Fatalf("%s: Decode: %v", name, err)
+			}
+			if !got.OccurredAt.Equal(want.OccurredAt) {
+				t.Errorf("%s: occurred_at = %v, want %v", name, got.OccurredAt, want.OccurredAt)
+			}
+			got.OccurredAt = want.OccurredAt // binary codecs return UTC
+			if !reflect.DeepEqual(&got, want) {
+				t.Errorf("%s: round trip of %s differs from JSON:\n got %v\nwant %v", name, ev.ID, got, *want)
+			}
+		}
+	}
+}
+
+func TestCodecs_RejectCorruptInput(t *testing.T) {
+	for _, name := range codecNames[1:] {
+		c, _ := NewCodec(name)
+		b, err := c.Marshal(sampleEvent())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, cut := range []int{1, 3, len(b) / 2, len(b) - 1} { // 2 bytes is a valid empty proto message
+			var ev Event
+			if err := Decode(b[:cut], &ev); err == nil {
+				t.Errorf("%s: decoding %d of %d bytes succeeded", name, cut, len(b))
+			}
+		}
+	}
+	var ev Event
+	if err := Decode([]byte{0x00, 0x7f, 0x01}, &ev); err == nil {
+		t.Error("unknown codec ID decoded")
+	}
+	if _, err := NewCodec("avro"); err == nil {
+		t.Error("NewCodec accepted an unknown name")
+	}
+}
+
+func TestCodecs_DecodeEarlierRows(t *testing.T) {
+	// Rows written before the codecs moved to the msgpack and protobuf
+	// libraries.
+	rows := map[string]string{
+		CodecMsgPack: "000197a26531a56c6f67696ed31898b5d9b3d68000a3776562a2753183a178cb3ff8000000000000a47461677391a161a16e0381a674656e616e74a461636d65",
+		CodecProto:   "00020a02653112056c6f67696e188080da9e9bbbadcc1822037765622a02753132310a0e0a0178120911000000000000f83f0a0f0a0474616773120732050a031a01610a0e0a016e12091100000000000008403a0e0a0674656e616e74120461636d65",
+	}
+	want := &Event{ID: "e1", Type: "login", OccurredAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), Source: "web", ActorID: "u1",
+		Payload: map[string]interface{}{"n": 3.0, "x": 1.5, "tags": []interface{}{"a"}}, Meta: map[string]string{"tenant": "acme"}}
+	for name, row := range rows {
+		b, err := hex.DecodeString(row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Event
+		if err := Decode(b, &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Errorf("%s: decoded %+v, want %+v", name, got, *want)
+		}
+	}
+}
+
+func TestCodecs_PayloadDepth(t *testing.T) {
+	nested := func(depth int) map[string]interface{} {
+		m := map[string]interface{}{"leaf": 1.0}
+		for i := 0; i < depth; i++ {
+			m = map[string]interface{}{"next": m}
+		}
+		return m
+	}
+	for _, name := range codecNames[1:] {
+		c, _ := NewCodec(name)
+		b, err := c.Marshal(&Event{ID: "deep", Type: "t", Payload: nested(maxDepth - 1)})
+		if err != nil {
+			t.Fatalf("%s: %d levels: %v", name, maxDepth, err)
+		}
+		var ev Event
+		if err := Decode(b, &ev); err != nil {
+			t.Errorf("%s: decoding %d levels: %v", name, maxDepth, err)
+		}
+		if _, err := c.Marshal(&Event{ID: "deeper", Type: "t", Payload: nested(maxDepth + 1)}); err == nil {
+			t.Errorf("%s: encoded a payload nested past %d levels", name, maxDepth)
+		}
+	}
+}
+
+// benchPayload is a large, realistically nested payload.
+func benchPayload() map[string]interface{} {
+	items := make([]interface{}, 50)
+	for i := range items {
+		items[i] = map[string]interface{}{
+			"sku":      fmt.Sprintf("SKU-%05d", i),
+			"qty":      float64(i%4 + 1),
+			"price":    19.99 + float64(i),
+			"category": "electronics",
+			"tags":     []interface{}{"sale", "featured"},
+		}
+	}
+	return map[string]interface{}{
+		"order_id": "ord-123456",
+		"amount":   2499.5,
+		"currency": "INR",
+		"items":    items,
+		"shipping": map[string]interface{}{"city": "Pune", "pin": "411001", "express": true},
+	}
+}
+
+func BenchmarkCodec(b *testing.B) {
+	ev := &Event{ID: "evt-1", Type: "order_placed", OccurredAt: time.Now(), Source: "shop", ActorID: "u1",
+		Payload: benchPayload(), Meta: map[string]string{"tenant": "acme"}}
+	for _, name := range codecNames {
+		c, _ := NewCodec(name)
+		data, err := c.Marshal(ev)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(name+"/marshal", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if _, err := c.Marshal(ev); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(name+"/unmarshal", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				var out Event
+				if err := c.Unmarshal(data, &out); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: internal/event/eventpb/event.proto
+
+package eventpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Event struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Id                 string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type               string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	OccurredAtUnixNano int64                  `protobuf:"varint,3,opt,name=occurred_at_unix_nano,json=occurredAtUnixNano,proto3" json:"occurred_at_unix_nano,omitempty"`
+	Source             string                 `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+	ActorId            string                 `protobuf:"bytes,5,opt,name=actor_id,json=actorId,proto3" json:"actor_id,omitempty"`
+	Payload            *structpb.Struct       `protobuf:"bytes,6,opt,name=payload,proto3" json:"payload,omitempty"`
+	Meta               map[string]string      `protobuf:"bytes,7,rep,name=meta,proto3" json:"meta,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_internal_event_eventpb_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_event_eventpb_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_internal_event_eventpb_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Event) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Event) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Event) GetOccurredAtUnixNano() int64 {
+	if x != nil {
+		return x.OccurredAtUnixNano
+	}
+	return 0
+}
+
+func (x *Event) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *Event) GetActorId() string {
+	if x != nil {
+		return x.ActorId
+	}
+	return ""
+}
+
+func (x *Event) GetPayload() *structpb.Struct {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *Event) GetMeta() map[string]string {
+	if x != nil {
+		return x.Meta
+	}
+	return nil
+}
+
+var File_internal_event_eventpb_event_proto protoreflect.FileDescriptor
+
+const file_internal_event_eventpb_event_proto_rawDesc = "" +
+	"\n" +
+	"\"internal/event/eventpb/event.proto\x12\x0efluxflow.event\x1a\x1cgoogle/protobuf/struct.proto\"\xb2\x02\n" +
+	"\x05Event\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x121\n" +
+	"\x15occurred_at_unix_nano\x18\x03 \x01(\x03R\x12occurredAtUnixNano\x12\x16\n" +
+	"\x06source\x18\x04 \x01(\tR\x06source\x12\x19\n" +
+	"\bactor_id\x18\x05 \x01(\tR\aactorId\x121\n" +
+	"\apayload\x18\x06 \x01(\v2\x17.google.protobuf.StructR\apayload\x123\n" +
+	"\x04meta\x18\a \x03(\v2\x1f.fluxflow.event.Event.MetaEntryR\x04meta\x1a7\n" +
+	"\tMetaEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B:Z8github.com/gyaneshwarpardhi/ifttt/internal/event/eventpbb\x06proto3"
+
+var (
+	file_internal_event_eventpb_event_proto_rawDescOnce sync.Once
+	file_internal_event_eventpb_event_proto_rawDescData []byte
+)
+
+func file_internal_event_eventpb_event_proto_rawDescGZIP() []byte {
+	file_internal_event_eventpb_event_proto_rawDescOnce.Do(func() {
+		file_internal_event_eventpb_event_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_event_eventpb_event_proto_rawDesc), len(file_internal_event_eventpb_event_proto_rawDesc)))
+	})
+	return file_internal_event_eventpb_event_proto_rawDescData
+}
+
+var file_internal_event_eventpb_event_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_internal_event_eventpb_event_proto_goTypes = []any{
+	(*Event)(nil),           // 0: fluxflow.event.Event
+	nil,                     // 1: fluxflow.event.Event.MetaEntry
+	(*structpb.Struct)(nil), // 2: google.protobuf.Struct
+}
+var file_internal_event_eventpb_event_proto_depIdxs = []int32{
+	2, // 0: fluxflow.event.Event.payload:type_name -> google.protobuf.Struct
+	1, // 1: fluxflow.event.Event.meta:type_name -> fluxflow.event.Event.MetaEntry
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_internal_event_eventpb_event_proto_init() }
+func file_internal_event_eventpb_event_proto_init() {
+	if File_internal_event_eventpb_event_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_event_eventpb_event_proto_rawDesc), len(file_internal_event_eventpb_event_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_internal_event_eventpb_event_proto_goTypes,
+		DependencyIndexes: file_internal_event_eventpb_event_proto_depIdxs,
+		MessageInfos:      file_internal_event_eventpb_event_proto_msgTypes,
+	}.Build()
+	File_internal_event_eventpb_event_proto = out.File
+	file_internal_event_eventpb_event_proto_goTypes = nil
+	file_internal_event_eventpb_event_proto_depIdxs = nil
+}
@@ -0,0 +1,126 @@
+package event
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// msgpackCodec encodes an event as a MessagePack array
+//
+//	[id, type, occurred_at (unix ns, 0 when zero), source, actor_id, payload, meta]
+//
+// after the binary header. Decoding skips extra elements, so fields can be
+// appended later. Integral numbers use the integer formats, which are
+// shorter.
+type msgpackCodec struct{}
+
+// mpFields is the length of the array msgpackCodec writes.
+const mpFields = 7
+
+func (msgpackCodec) Name() string { return CodecMsgPack }
+
+func (msgpackCodec) Marshal(ev *Event) ([]byte, error) {
+	var payload map[string]interface{}
+	if ev.Payload != nil {
+		var err error
+		if payload, err = plainMap(ev.Payload); err != nil {
+			return nil, fmt.Errorf("msgpack encode %s: %w", ev.ID, err)
+		}
+	}
+	var buf bytes.Buffer
+	buf.Grow(256)
+	buf.Write([]byte{headerMagic, idMsgPack})
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+	enc.Reset(&buf)
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	err := enc.EncodeArrayLen(mpFields)
+	for _, s := range []string{ev.ID, ev.Type} {
+		if err == nil {
+			err = enc.EncodeString(s)
+		}
+	}
+	if err == nil {
+		err = enc.EncodeInt(unixNano(ev.OccurredAt))
+	}
+	for _, s := range []string{ev.Source, ev.ActorID} {
+		if err == nil {
+			err = enc.EncodeString(s)
+		}
+	}
+	if err == nil {
+		err = enc.Encode(payload)
+	}
+	if err == nil {
+		err = enc.Encode(ev.Meta)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("msgpack encode %s: %w", ev.ID, err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, ev *Event) error {
+	data, err := checkHeader(data, idMsgPack, CodecMsgPack)
+	if err != nil {
+		return err
+	}
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+	dec.Reset(bytes.NewReader(data))
+	dec.UseLooseInterfaceDecoding(true)
+	if err := mpDecode(dec, ev); err != nil {
+		return fmt.Errorf("msgpack decode: %w", err)
+	}
+	return nil
+}
+
+func mpDecode(dec *msgpack.Decoder, ev *Event) error {
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+	if n < mpFields {
+		return fmt.Errorf("event has %d fields, want at least %d", n, mpFields)
+	}
+	*ev = Event{}
+	if ev.ID, err = dec.DecodeString(); err != nil {
+		return err
+	}
+	if ev.Type, err = dec.DecodeString(); err != nil {
+		return err
+	}
+	ns, err := dec.DecodeInt64()
+	if err != nil {
+		return err
+	}
+	ev.OccurredAt = fromUnixNano(ns)
+	if ev.Source, err = dec.DecodeString(); err != nil {
+		return err
+	}
+	if ev.ActorID, err = dec.DecodeString(); err != nil {
+		return err
+	}
+	payload, err := dec.DecodeMap()
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		// Integers decode as integers; JSON numbers are float64.
+		if ev.Payload, err = plainMap(payload); err != nil {
+			return err
+		}
+	}
+	if err := dec.Decode(&ev.Meta); err != nil {
+		return err
+	}
+	for i := mpFields; i < n; i++ {
+		if err := dec.Skip(); err != nil { // appended by a newer version
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package event
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/event/eventpb"
+)
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative internal/event/eventpb/event.proto
+
+// protoCodec encodes an event, after the binary header, as the eventpb.Event
+// message (eventpb/event.proto), with the payload as a google.protobuf.Struct,
+// so other services can read the stores with generated code.
+type protoCodec struct{}
+
+// protoUnmarshal bounds message nesting: the event, then a Value and a
+// Struct or ListValue per payload level.
+var protoUnmarshal = proto.UnmarshalOptions{RecursionLimit: 2*maxDepth + 8}
+
+func (protoCodec) Name() string { return CodecProto }
+
+func (protoCodec) Marshal(ev *Event) ([]byte, error) {
+	msg := &eventpb.Event{
+		Id:                 ev.ID,
+		Type:               ev.Type,
+		OccurredAtUnixNano: unixNano(ev.OccurredAt),
+		Source:             ev.Source,
+		ActorId:            ev.ActorID,
+		Meta:               ev.Meta,
+	}
+	if ev.Payload != nil {
+		payload, err := plainMap(ev.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("proto encode %s: %w", ev.ID, err)
+		}
+		if msg.Payload, err = structpb.NewStruct(payload); err != nil {
+			return nil, fmt.Errorf("proto encode %s: %w", ev.ID, err)
+		}
+	}
+	b, err := proto.MarshalOptions{Deterministic: true}.MarshalAppend([]byte{headerMagic, idProto}, msg)
+	if err != nil {
+		return nil, fmt.Errorf("proto encode %s: %w", ev.ID, err)
+	}
+	return b, nil
+}
+
+func (protoCodec) Unmarshal(data []byte, ev *Event) error {
+	data, err := checkHeader(data, idProto, CodecProto)
+	if err != nil {
+		return err
+	}
+	var msg eventpb.Event
+	if err := protoUnmarshal.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("proto decode: %w", err)
+	}
+	*ev = Event{
+		ID:         msg.Id,
+		Type:       msg.Type,
+		OccurredAt: fromUnixNano(msg.OccurredAtUnixNano),
+		Source:     msg.Source,
+		ActorID:    msg.ActorId,
+		Meta:       msg.Meta,
+	}
+	if msg.Payload != nil {
+		ev.Payload = msg.Payload.AsMap()
+	}
+	return nil
+}
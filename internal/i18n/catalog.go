@@ -0,0 +1,108 @@
+// Package i18n renders action message templates in the recipient's locale.
+package i18n
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resolver looks up a dot-separated field path (e.g. dag.EvalContext.Resolve).
+type Resolver func(path []string) (interface{}, bool)
+
+// Catalog holds message templates per locale, built once per config.
+type Catalog struct {
+	defaultLocale string
+	messages      map[string]map[string]string // locale → key → template
+}
+
+// NewCatalog builds a Catalog. Locale tags are matched case-insensitively.
+func NewCatalog(defaultLocale string, catalogs map[string]map[string]string) *Catalog {
+	c := &Catalog{
+		defaultLocale: strings.ToLower(defaultLocale),
+		messages:      make(map[string]map[string]string, len(catalogs)),
+	}
+	for locale, msgs := range catalogs {
+		c.messages[strings.ToLower(locale)] = msgs
+	}
+	return c
+}
+
+// DefaultLocale returns the fallback locale.
+func (c *Catalog) DefaultLocale() string {
+	if c == nil {
+		return ""
+	}
+	return c.defaultLocale
+}
+
+// Lookup returns the template for key in locale, falling back from a
+// regional tag to its base language ("pt-br" → "pt") and then to the
+// default locale.
+func (c *Catalog) Lookup(locale, key string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	locale = strings.ToLower(locale)
+	candidates := []string{locale}
+	if base, _, ok := strings.Cut(locale, "-"); ok {
+		candidates = append(candidates, base)
+	}
+	candidates = append(candidates, c.defaultLocale)
+	for _, l := range candidates {
+		if tmpl, ok := c.messages[l][key]; ok {
+			return tmpl, true
+		}
+	}
+	return "", false
+}
+
+// Locale picks the recipient's locale: meta.locale, then the actor profile's
+// locale, then the catalog default.
+func (c *Catalog) Locale(resolve Resolver) string {
+	for _, path := range [][]string{{"meta", "locale"}, {"actor", "locale"}} {
+		if v, ok := resolve(path); ok {
+			if s, ok := v.(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return c.DefaultLocale()
+}
+
+// Message renders key in the recipient's locale. ok is false when the
+// catalog has no template for key, so callers can fall back to a built-in text.
+func (c *Catalog) Message(key string, vars map[string]interface{}, resolve Resolver) (msg string, ok bool) {
+	tmpl, ok := c.Lookup(c.Locale(resolve), key)
+	if !ok {
+		return "", false
+	}
+	return Render(tmpl, vars, resolve), true
+}
+
+// Render replaces {{name}} placeholders with vars[name] or, failing that, the
+// value of the field path name. Unresolvable placeholders render empty.
+func Render(tmpl string, vars map[string]interface{}, resolve Resolver) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(tmpl, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(tmpl[start:], "}}")
+		if end < 0 {
+			break
+		}
+		b.WriteString(tmpl[:start])
+		name := strings.TrimSpace(tmpl[start+2 : start+end])
+		if v, ok := vars[name]; ok {
+			fmt.Fprint(&b, v)
+		} else if resolve != nil {
+			if v, ok := resolve(strings.Split(name, ".")); ok {
+				fmt.Fprint(&b, v)
+			}
+		}
+		tmpl = tmpl[start+end+2:]
+	}
+	b.WriteString(tmpl)
+	return b.String()
+}
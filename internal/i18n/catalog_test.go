@@ -0,0 +1,44 @@
+package i18n
+
+import "testing"
+
+func resolver(fields map[string]interface{}) Resolver {
+	return func(path []string) (interface{}, bool) {
+		key := path[0]
+		for _, p := range path[1:] {
+			key += "." + p
+		}
+		v, ok := fields[key]
+		return v, ok
+	}
+}
+
+func TestCatalog_MessageLocaleFallback(t *testing.T) {
+	c := NewCatalog("en", map[string]map[string]string{
+		"en": {"reward": "Awarded {{points}} points", "bye": "Goodbye"},
+		"pt": {"reward": "{{points}} pontos para {{event.actor_id}}"},
+	})
+	cases := []struct {
+		name   string
+		key    string
+		fields map[string]interface{}
+		want   string
+	}{
+		{"meta locale", "reward", map[string]interface{}{"meta.locale": "pt", "event.actor_id": "u1"}, "50 pontos para u1"},
+		{"regional falls back to base", "reward", map[string]interface{}{"meta.locale": "pt-BR", "event.actor_id": "u1"}, "50 pontos para u1"},
+		{"actor profile locale", "reward", map[string]interface{}{"actor.locale": "pt", "event.actor_id": "u2"}, "50 pontos para u2"},
+		{"missing key falls back to default", "bye", map[string]interface{}{"meta.locale": "pt"}, "Goodbye"},
+		{"no locale uses default", "reward", nil, "Awarded 50 points"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := c.Message(tc.key, map[string]interface{}{"points": 50}, resolver(tc.fields))
+			if !ok || got != tc.want {
+				t.Errorf("Message = %q, %v; want %q", got, ok, tc.want)
+			}
+		})
+	}
+	if _, ok := c.Message("unknown", nil, resolver(nil)); ok {
+		t.Errorf("expected unknown key to report !ok")
+	}
+}
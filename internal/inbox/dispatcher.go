@@ -0,0 +1,82 @@
+package inbox
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+const (
+	claimBatch   = 64
+	claimLease   = 30 * time.Second
+	idleInterval = 100 * time.Millisecond
+)
+
+// Dispatcher moves events from an Inbox into the engine.
+type Dispatcher struct {
+	inbox *Inbox
+	eng   *engine.Engine
+}
+
+// NewDispatcher creates a Dispatcher.
+func NewDispatcher(ib *Inbox, eng *engine.Engine) *Dispatcher {
+	return &Dispatcher{inbox: ib, eng: eng}
+}
+
+// Run claims and processes batches until ctx is cancelled; a batch already
+// claimed is finished first. Each event is
+// acked only after the engine returns its result; failures (queue full,
+// timeout) leave it leased and it is retried after claimLease. Batches are
+// sized by engine.FetchSize, so under load the dispatcher claims fewer events
+// (none near a full queue) instead of leasing ones it would only fail and
+// redeliver.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		var events []*event.Event
+		size := d.eng.FetchSize(claimBatch)
+		metrics.InboxFetchSize.Set(float64(size))
+		if size > 0 {
+			var err error
+			events, err = d.inbox.Claim(ctx, size, claimLease)
+			if err != nil && ctx.Err() == nil {
+				logctx.From(ctx).Warn("inbox claim failed", "err", err)
+			}
+		}
+		if n, err := d.inbox.Pending(ctx); err == nil {
+			metrics.InboxPending.Set(float64(n))
+		}
+		if len(events) == 0 {
+			select {
+			case <-time.After(idleInterval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		// A claimed batch is finished even if ctx is cancelled meanwhile, so
+		// shutdown does not leave processed events unacked; the engine's
+		// event timeout still bounds each one.
+		batchCtx := context.WithoutCancel(ctx)
+		var wg sync.WaitGroup
+		for _, ev := range events {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := d.eng.ProcessSync(batchCtx, ev); err != nil {
+					logctx.From(batchCtx).Warn("inbox dispatch failed; will retry", "event_id", ev.ID, "err", err)
+					return
+				}
+				if err := d.inbox.Ack(batchCtx, ev.ID); err != nil {
+					logctx.From(batchCtx).Warn("inbox ack failed; event may be redelivered", "event_id", ev.ID, "err", err)
+				}
+			}()
+		}
+		wg.Wait()
+	}
+}
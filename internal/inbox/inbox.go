@@ -0,0 +1,178 @@
+// Package inbox is a durable, SQLite-backed queue in front of the engine.
+// Events are acknowledged to HTTP clients only once written to the inbox;
+// a Dispatcher feeds them to the engine and deletes each row only after the
+// engine has processed it, giving at-least-once delivery across crashes.
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/seal"
+)
+
+const schema = `CREATE TABLE IF NOT EXISTS inbox (
+	id          TEXT PRIMARY KEY,
+	body        BLOB    NOT NULL,
+	received_at INTEGER NOT NULL,
+	attempts    INTEGER NOT NULL DEFAULT 0,
+	lease_until INTEGER NOT NULL DEFAULT 0
+)`
+
+// ErrDuplicate is returned by Put for an event ID that is still pending.
+var ErrDuplicate = errors.New("event id already in the inbox")
+
+// Inbox stores pending events in a single SQLite table.
+type Inbox struct {
+	db     *sql.DB
+	codec  event.Codec
+	sealer *seal.Sealer
+}
+
+// Open opens (creating if needed) the inbox at dsn using a database/sql
+// driver registered under driverName, e.g. "sqlite3" from github.com/mattn/go-sqlite3.
+func Open(driverName, dsn string) (*Inbox, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("inbox open: %w", err)
+	}
+	// SQLite allows one writer; serialising here avoids SQLITE_BUSY churn.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("inbox schema: %w", err)
+	}
+	codec, _ := event.NewCodec(event.CodecJSON)
+	return &Inbox{db: db, codec: codec}, nil
+}
+
+// SetCodec sets how new events are encoded (JSON by default). Rows already
+// written with another codec are still read.
+func (i *Inbox) SetCodec(c event.Codec) {
+	i.codec = c
+}
+
+// SetSealer encrypts new events with s (nil, the default, stores them in
+// the clear). Rows written in the clear are still read; Reseal rewrites them.
+func (i *Inbox) SetSealer(s *seal.Sealer) {
+	i.sealer = s
+}
+
+// Reseal rewrites pending events not sealed under the primary key, and
+// returns how many it rewrote.
+func (i *Inbox) Reseal(ctx context.Context) (int, error) {
+	return seal.ResealTable(ctx, i.db, i.sealer, "inbox")
+}
+
+// Close closes the underlying database.
+func (i *Inbox) Close() error {
+	return i.db.Close()
+}
+
+// Put durably stores ev. Re-submitting an event ID that is still pending
+// stores nothing and returns ErrDuplicate.
+func (i *Inbox) Put(ctx context.Context, ev *event.Event) error {
+	body, err := i.codec.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("inbox encode %s: %w", ev.ID, err)
+	}
+	if body, err = i.sealer.Seal(ctx, body); err != nil {
+		return fmt.Errorf("inbox seal %s: %w", ev.ID, err)
+	}
+	res, err := i.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO inbox (id, body, received_at) VALUES (?, ?, ?)`,
+		ev.ID, body, ev.ReceivedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("inbox put %s: %w", ev.ID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+// Claim leases up to limit pending events for lease. Events not acked before
+// the lease expires become claimable again.
+func (i *Inbox) Claim(ctx context.Context, limit int, lease time.Duration) ([]*event.Event, error) {
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("inbox claim: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, body, received_at FROM inbox WHERE lease_until <= ? ORDER BY received_at LIMIT ?`,
+		now.UnixNano(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("inbox claim: %w", err)
+	}
+	var (
+		events []*event.Event
+		ids    []interface{}
+	)
+	for rows.Next() {
+		var (
+			id         string
+			body       []byte
+			receivedAt int64
+		)
+		if err := rows.Scan(&id, &body, &receivedAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("inbox claim: %w", err)
+		}
+		if body, err = i.sealer.Open(ctx, body); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("inbox open %s: %w", id, err)
+		}
+		var ev event.Event
+		if err := event.Decode(body, &ev); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("inbox decode %s: %w", id, err)
+		}
+		ev.ReceivedAt = time.Unix(0, receivedAt)
+		events = append(events, &ev)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("inbox claim: %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	args := append([]interface{}{now.Add(lease).UnixNano()}, ids...)
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE inbox SET lease_until = ?, attempts = attempts + 1 WHERE id IN (`+placeholders+`)`,
+		args...); err != nil {
+		return nil, fmt.Errorf("inbox lease: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("inbox claim commit: %w", err)
+	}
+	return events, nil
+}
+
+// Ack removes a processed event.
+func (i *Inbox) Ack(ctx context.Context, id string) error {
+	if _, err := i.db.ExecContext(ctx, `DELETE FROM inbox WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("inbox ack %s: %w", id, err)
+	}
+	return nil
+}
+
+// Pending returns the number of events not yet acked.
+func (i *Inbox) Pending(ctx context.Context) (int, error) {
+	var n int
+	if err := i.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM inbox`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("inbox pending: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,122 @@
+//go:build cgo
+
+package inbox
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+func openTest(t *testing.T) *Inbox {
+	t.Helper()
+	in, err := Open("sqlite3", filepath.Join(t.TempDir(), "inbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { in.Close() })
+	return in
+}
+
+func testEvent(id string, received time.Time) *event.Event {
+	return &event.Event{
+		ID:         id,
+		Type:       "login",
+		ActorID:    "u1",
+		OccurredAt: received.UTC(),
+		ReceivedAt: received,
+		Payload:    map[string]interface{}{"plan": "pro"},
+	}
+}
+
+func TestInbox_PutClaimAck(t *testing.T) {
+	ctx := context.Background()
+	in := openTest(t)
+	now := time.Now()
+	for i, id := range []string{"e1", "e2", "e3"} {
+		if err := in.Put(ctx, testEvent(id, now.Add(time.Duration(i)*time.Millisecond))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evs, err := in.Claim(ctx, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 2 || evs[0].ID != "e1" || evs[1].ID != "e2" {
+		t.Fatalf("claimed %v, want e1 and e2 in order", evs)
+	}
+	if evs[0].Payload["plan"] != "pro" || evs[0].ActorID != "u1" {
+		t.Errorf("claimed event decoded as %+v", evs[0])
+	}
+
+	// Leased events are not handed out again.
+	evs, err = in.Claim(ctx, 10, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].ID != "e3" {
+		t.Fatalf("second claim = %v, want e3 only", evs)
+	}
+
+	for _, id := range []string{"e1", "e2", "e3"} {
+		if err := in.Ack(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := in.Pending(ctx); err != nil || n != 0 {
+		t.Errorf("Pending = %d, %v after acking everything", n, err)
+	}
+}
+
+func TestInbox_LeaseExpiry(t *testing.T) {
+	ctx := context.Background()
+	in := openTest(t)
+	if err := in.Put(ctx, testEvent("e1", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if evs, err := in.Claim(ctx, 1, 20*time.Millisecond); err != nil || len(evs) != 1 {
+		t.Fatalf("Claim = %v, %v", evs, err)
+	}
+	if evs, _ := in.Claim(ctx, 1, time.Minute); len(evs) != 0 {
+		t.Fatalf("claimed %v while the lease is held", evs)
+	}
+
+	// A worker that dies without acking loses its lease.
+	time.Sleep(30 * time.Millisecond)
+	evs, err := in.Claim(ctx, 1, time.Minute)
+	if err != nil || len(evs) != 1 || evs[0].ID != "e1" {
+		t.Fatalf("Claim after expiry = %v, %v", evs, err)
+	}
+	if n, _ := in.Pending(ctx); n != 1 {
+		t.Errorf("Pending = %d, want 1", n)
+	}
+}
+
+func TestInbox_PutDuplicate(t *testing.T) {
+	ctx := context.Background()
+	in := openTest(t)
+	if err := in.Put(ctx, testEvent("e1", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Put(ctx, testEvent("e1", time.Now())); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("second Put = %v, want ErrDuplicate", err)
+	}
+	if n, _ := in.Pending(ctx); n != 1 {
+		t.Errorf("Pending = %d, want 1", n)
+	}
+
+	// Once processed and acked, the ID can be stored again.
+	if err := in.Ack(ctx, "e1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Put(ctx, testEvent("e1", time.Now())); err != nil {
+		t.Errorf("Put after Ack = %v", err)
+	}
+}
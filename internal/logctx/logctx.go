@@ -0,0 +1,69 @@
+// Package logctx carries correlation fields (request ID, tenant, event ID,
+// scenario ID) in a context.Context, so every log line written while handling
+// one request or event shares them without passing loggers around.
+//
+// Fields are attached as a context is handed down: the API adds request_id,
+// the engine adds event_id and tenant when a worker picks up an event and
+// scenario_id before each action runs. Code that logs calls From(ctx) instead
+// of the package-level slog functions.
+package logctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+type ctxKey struct{}
+
+// With returns a copy of ctx whose logger also carries args, given as
+// alternating keys and values or as slog.Attr, like slog.Logger.With.
+func With(ctx context.Context, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, ctxKey{}, append(slices.Clip(Args(ctx)), args...))
+}
+
+// Args returns the fields attached to ctx, for handing them to another
+// context (e.g. across a worker queue). ctx may be nil.
+func Args(ctx context.Context) []any {
+	if ctx == nil {
+		return nil
+	}
+	args, _ := ctx.Value(ctxKey{}).([]any)
+	return args
+}
+
+// Has reports whether ctx carries a field named key, so code that logs an
+// ID it also has at hand can add it only when the context does not.
+func Has(ctx context.Context, key string) bool {
+	args := Args(ctx)
+	for i := 0; i < len(args); i++ {
+		switch a := args[i].(type) {
+		case slog.Attr:
+			if a.Key == key {
+				return true
+			}
+		case string:
+			if a == key {
+				return true
+			}
+			i++ // skip the value
+		}
+	}
+	return false
+}
+
+// From returns slog.Default() with ctx's fields attached.
+func From(ctx context.Context) *slog.Logger {
+	return Wrap(slog.Default(), ctx)
+}
+
+// Wrap returns l with ctx's fields attached.
+func Wrap(l *slog.Logger, ctx context.Context) *slog.Logger {
+	if args := Args(ctx); len(args) > 0 {
+		return l.With(args...)
+	}
+	return l
+}
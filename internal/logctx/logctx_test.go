@@ -0,0 +1,46 @@
+package logctx
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestWith_FieldsAccumulateWithoutLeaking(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewTextHandler(&buf, nil))
+
+	req := With(context.Background(), "request_id", "r1")
+	ev := With(req, "event_id", "e1")
+	other := With(req, "event_id", "e2")
+
+	Wrap(base, ev).Info("first")
+	Wrap(base, other).Info("second")
+	Wrap(base, req).Info("third")
+	Wrap(base, nil).Info("plain")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{"request_id=r1 event_id=e1", "request_id=r1 event_id=e2", "request_id=r1", ""}
+	for i, w := range want {
+		if !strings.HasSuffix(lines[i], w) || (w == "" && strings.Contains(lines[i], "request_id")) {
+			t.Errorf("line %d = %q, want fields %q", i, lines[i], w)
+		}
+	}
+	if strings.Contains(lines[2], "event_id") {
+		t.Errorf("child fields leaked into the parent context: %q", lines[2])
+	}
+}
+
+func TestHas(t *testing.T) {
+	ctx := With(context.Background(), "request_id", "event_id", slog.String("tenant", "t1"))
+	for key, want := range map[string]bool{"request_id": true, "event_id": false, "tenant": true, "scenario_id": false} {
+		if got := Has(ctx, key); got != want {
+			t.Errorf("Has(%q) = %v, want %v", key, got, want)
+		}
+	}
+	if Has(nil, "request_id") {
+		t.Error("Has on a nil context")
+	}
+}
@@ -1,10 +1,79 @@
 package metrics
 
 import (
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
+// DefaultLatencyBucketsMs are the classic buckets of the latency histograms:
+// 0.05ms to ~1.2s in ×2.5 steps, since most evaluations finish well under a
+// millisecond. engine.latency_buckets_ms replaces them.
+var DefaultLatencyBucketsMs = prometheus.ExponentialBuckets(0.05, 2.5, 12)
+
+// Latency histograms. They carry native buckets as well, so scrapers with
+// native histograms enabled get full resolution whatever the classic buckets.
+// They are registered once, by SetLatencyBuckets or on first use.
+var (
+	latencyOnce             sync.Once
+	eventProcessingDuration prometheus.Histogram
+	actionDuration          *prometheus.HistogramVec
+)
+
+// SetLatencyBuckets registers the latency histograms with bucketsMs as their
+// classic buckets (empty = DefaultLatencyBucketsMs). Call it at startup: once
+// the histograms are registered, by an earlier call or a first observation,
+// it does nothing and returns false.
+func SetLatencyBuckets(bucketsMs []float64) bool {
+	set := false
+	latencyOnce.Do(func() {
+		registerLatency(bucketsMs)
+		set = true
+	})
+	return set
+}
+
+// EventProcessingDuration is the event evaluation and action execution time
+// per processed event.
+func EventProcessingDuration() prometheus.Histogram {
+	latencyOnce.Do(func() { registerLatency(nil) })
+	return eventProcessingDuration
+}
+
+// ActionDuration is the executor time per action attempt, by action type.
+func ActionDuration() *prometheus.HistogramVec {
+	latencyOnce.Do(func() { registerLatency(nil) })
+	return actionDuration
+}
+
+func registerLatency(bucketsMs []float64) {
+	if len(bucketsMs) == 0 {
+		bucketsMs = DefaultLatencyBucketsMs
+	}
+	eventProcessingDuration = prometheus.NewHistogram(latencyOpts(
+		"ifttt_event_processing_duration_ms",
+		"Event evaluation and action execution time in milliseconds, per processed event.",
+		bucketsMs))
+	actionDuration = prometheus.NewHistogramVec(latencyOpts(
+		"ifttt_action_duration_ms",
+		"Action executor time in milliseconds per attempt, labelled by action type.",
+		bucketsMs), []string{"action_type"})
+	prometheus.MustRegister(eventProcessingDuration, actionDuration)
+}
+
+func latencyOpts(name, help string, bucketsMs []float64) prometheus.HistogramOpts {
+	return prometheus.HistogramOpts{
+		Name:                            name,
+		Help:                            help,
+		Buckets:                         bucketsMs,
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxBucketNumber:  160,
+		NativeHistogramMinResetDuration: time.Hour,
+	}
+}
+
 var (
 	EventsEnqueued = promauto.NewCounter(prometheus.CounterOpts{
 		Name: "ifttt_events_enqueued_total",
@@ -31,14 +100,158 @@ var (
 		Help: "Total number of actions executed, labelled by type and status.",
 	}, []string{"action_type", "status"})
 
-	EventProcessingDuration = promauto.NewHistogram(prometheus.HistogramOpts{
-		Name:    "ifttt_event_processing_duration_ms",
-		Help:    "End-to-end event processing latency in milliseconds.",
-		Buckets: []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500},
-	})
-
 	QueueUtilization = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "ifttt_queue_utilization_ratio",
 		Help: "Current event queue utilization (0–1).",
 	})
+
+	EventsRateLimited = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ifttt_events_rate_limited_total",
+		Help: "Total number of events rejected by engine.ingest_rate_limit.",
+	})
+
+	EventsDeferred = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ifttt_events_deferred_total",
+		Help: "Sync events switched to async processing because the queue was busy.",
+	})
+
+	EventsDuplicate = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ifttt_events_duplicate_total",
+		Help: "Total number of events skipped because their ID was already accepted within the dedupe window.",
+	})
+
+	EventsQuarantined = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ifttt_events_quarantined_total",
+		Help: "Total number of events quarantined by payload size guards.",
+	})
+
+	EventsOutOfScope = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_events_out_of_scope_total",
+		Help: "Events rejected because their source or type is outside the API token's scope.",
+	}, []string{"token"})
+
+	ActionsCollapsed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_actions_collapsed_total",
+		Help: "Matched actions not run because an identical action ran for the same event (engine.dedupe_actions).",
+	}, []string{"action_type"})
+
+	RuleChangesDenied = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_rule_changes_denied_total",
+		Help: "Scenario changes rejected because the API token is not in the scenario's owning team.",
+	}, []string{"token"})
+
+	EventsAliased = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_events_aliased_total",
+		Help: "Events processed under a canonical type from event_types, by the type they were sent as.",
+	}, []string{"raw_type", "type"})
+
+	WorkflowTransitions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_workflow_transitions_total",
+		Help: "Workflow instances entering each status.",
+	}, []string{"workflow_id", "status"})
+
+	PanicsRecovered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_panics_recovered_total",
+		Help: "Panics recovered in action executors, condition evaluation and workers.",
+	}, []string{"component", "name"})
+
+	ActionDegraded = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ifttt_action_degraded",
+		Help: "1 while an action is skipped for exhausting its error budget.",
+	}, []string{"action_id"})
+
+	InboxPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ifttt_inbox_pending",
+		Help: "Events persisted in the ingestion inbox and not yet processed.",
+	})
+
+	ConfigFromCache = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ifttt_config_from_cache",
+		Help: "1 while the server serves the last-known-good config cache because the config files could not be loaded at startup.",
+	})
+
+	InboxFetchSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ifttt_inbox_fetch_size",
+		Help: "Batch size of the inbox dispatcher's last claim, sized by engine queue headroom (0 = paused).",
+	})
+
+	ScenarioMissingFields = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_scenario_missing_fields_total",
+		Help: "Events of a scenario's types skipped because they lacked one of its requires_fields.",
+	}, []string{"scenario_id"})
+
+	ScenariosShed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_scenarios_shed_total",
+		Help: "Expensive-class scenarios skipped for a matching event because the queue was over engine.shed_expensive_at.",
+	}, []string{"scenario_id"})
+
+	ScenariosDeferred = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_scenarios_deferred_total",
+		Help: "Expensive-class scenarios put off for a matching event because the queue was over engine.shed_expensive_at (shed_mode: defer).",
+	}, []string{"scenario_id"})
+
+	DeferredEvents = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ifttt_deferred_events",
+		Help: "Events waiting for queue use to fall under engine.shed_expensive_at so their deferred scenarios can run.",
+	})
+
+	ScheduledEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_scheduled_events_total",
+		Help: "Delayed events from emit_later by outcome: scheduled, emitted, cancelled or dead (dropped after repeated dispatch failures).",
+	}, []string{"outcome"})
+
+	ScheduledPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ifttt_scheduled_events_pending",
+		Help: "Delayed events from emit_later waiting to be emitted.",
+	})
+
+	ScenarioMatchRatio = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ifttt_scenario_match_ratio",
+		Help: "Fraction of events matched by a scenario in the last anomaly window.",
+	}, []string{"scenario_id"})
+
+	ScenarioMatchAnomalies = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_scenario_match_anomalies_total",
+		Help: "Total number of windows where a scenario's match ratio deviated from baseline.",
+	}, []string{"scenario_id", "direction"})
+
+	PayloadFieldPresence = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ifttt_payload_field_presence",
+		Help: "Fraction of sampled events of a type carrying a payload field in the last drift window.",
+	}, []string{"event_type", "field"})
+
+	PayloadDrift = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_payload_drift_total",
+		Help: "Payload fields whose presence or type drifted from baseline, by kind: new_field, presence or type.",
+	}, []string{"event_type", "kind"})
+
+	PayloadBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ifttt_event_payload_bytes",
+		Help:    "Encoded payload size of sampled events, by event type (\"other\" for types neither in the rules nor baselined).",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 8), // 64 B … 1 MiB
+	}, []string{"event_type"})
+
+	MonitorProbeOK = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ifttt_monitor_probe_ok",
+		Help: "1 if a synthetic monitor probe's last run produced the expected outcome, 0 if not.",
+	}, []string{"probe_id"})
+
+	MonitorProbeFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_monitor_probe_failures_total",
+		Help: "Total number of synthetic monitor probe runs that did not produce the expected outcome.",
+	}, []string{"probe_id"})
+
+	RetentionPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ifttt_retention_purged_total",
+		Help: "Total number of entries deleted for exceeding their scenario's retention period.",
+	}, []string{"store"})
+
+	PointsAwarded = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:                            "ifttt_points_awarded",
+		Help:                            "Points per reward_points execution, labelled by scenario, action and operation (award or deduct). The sum is the total.",
+		Buckets:                         []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 5000},
+		NativeHistogramBucketFactor:     1.1,
+		NativeHistogramMaxBucketNumber:  160,
+		NativeHistogramMinResetDuration: time.Hour,
+	}, []string{"scenario_id", "action_id", "operation"})
 )
@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestSetLatencyBuckets(t *testing.T) {
+	if !SetLatencyBuckets([]float64{0.1, 1, 10}) {
+		t.Fatal("SetLatencyBuckets before any observation = false")
+	}
+	if SetLatencyBuckets([]float64{5}) {
+		t.Error("a second SetLatencyBuckets replaced the registered histograms")
+	}
+
+	EventProcessingDuration().Observe(0.05)
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+	var h *dto.Histogram
+	for _, mf := range families {
+		if mf.GetName() == "ifttt_event_processing_duration_ms" {
+			h = mf.GetMetric()[0].GetHistogram()
+		}
+	}
+	if h == nil {
+		t.Fatal("ifttt_event_processing_duration_ms not registered")
+	}
+	if b := h.GetBucket(); len(b) != 3 || b[0].GetUpperBound() != 0.1 || b[0].GetCumulativeCount() != 1 {
+		t.Errorf("buckets = %v, want the configured 0.1/1/10 with the sub-ms sample in the first", b)
+	}
+}
@@ -0,0 +1,218 @@
+// Package monitor runs synthetic probes: configured events simulated against
+// the active rules on an interval, and after every reload, with assertions
+// on which scenarios and actions they trigger. A rule change that silently
+// breaks a flow shows up as ifttt_monitor_probe_ok dropping to 0.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// tick is how often Run looks for due probes.
+const tick = time.Second
+
+// Simulator evaluates an event without executing actions; *engine.Engine
+// implements it.
+type Simulator interface {
+	Simulate(ctx context.Context, ev *event.Event) *engine.SimulationResult
+}
+
+// Result is the outcome of one probe run.
+type Result struct {
+	ProbeID   string    `json:"probe_id"`
+	OK        bool      `json:"ok"`
+	Failures  []string  `json:"failures,omitempty"`
+	GraphHash string    `json:"graph_hash"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Runner schedules probes and keeps each one's latest Result.
+type Runner struct {
+	sim  Simulator
+	kick chan struct{}
+
+	mu      sync.Mutex
+	probes  []*probe
+	results map[string]Result
+}
+
+type probe struct {
+	def  config.MonitorDef
+	next time.Time
+}
+
+// NewRunner creates a Runner with no probes; call Load.
+func NewRunner(sim Simulator) *Runner {
+	return &Runner{
+		sim:     sim,
+		kick:    make(chan struct{}, 1),
+		results: make(map[string]Result),
+	}
+}
+
+// Load replaces the probe set and makes every probe due immediately, so
+// calling it from an engine.GraphHook re-checks the new rules right away.
+func (r *Runner) Load(defs []config.MonitorDef) {
+	r.mu.Lock()
+	keep := make(map[string]bool, len(defs))
+	r.probes = r.probes[:0]
+	for _, d := range defs {
+		keep[d.ID] = true
+		r.probes = append(r.probes, &probe{def: d})
+	}
+	for id := range r.results {
+		if !keep[id] {
+			delete(r.results, id)
+			metrics.MonitorProbeOK.DeleteLabelValues(id)
+		}
+	}
+	r.mu.Unlock()
+
+	select {
+	case r.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Run checks due probes every second, and right after Load, until ctx is
+// cancelled.
+func (r *Runner) Run(ctx context.Context) {
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			r.RunDue(ctx, now)
+		case <-r.kick:
+			r.RunDue(ctx, time.Now())
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// RunDue runs every probe whose interval has elapsed at now and returns
+// their results.
+func (r *Runner) RunDue(ctx context.Context, now time.Time) []Result {
+	r.mu.Lock()
+	var due []config.MonitorDef
+	for _, p := range r.probes {
+		if !now.Before(p.next) {
+			due = append(due, p.def)
+			p.next = now.Add(p.def.IntervalMs.Duration())
+		}
+	}
+	r.mu.Unlock()
+
+	out := make([]Result, 0, len(due))
+	for _, def := range due {
+		res := r.check(ctx, def, now)
+		r.record(res)
+		out = append(out, res)
+	}
+	return out
+}
+
+// Results returns the latest result of each probe that has run, by probe id.
+func (r *Runner) Results() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := slices.Collect(maps.Values(r.results))
+	sort.Slice(out, func(i, j int) bool { return out[i].ProbeID < out[j].ProbeID })
+	return out
+}
+
+func (r *Runner) check(ctx context.Context, def config.MonitorDef, now time.Time) Result {
+	sim := r.sim.Simulate(ctx, probeEvent(def, now))
+	x := def.Expect
+	var failures []string
+	for _, id := range x.Scenarios {
+		if !slices.Contains(sim.ScenariosMatched, id) {
+			failures = append(failures, fmt.Sprintf("scenario %s did not match", id))
+		}
+	}
+	for _, id := range x.NotScenarios {
+		if slices.Contains(sim.ScenariosMatched, id) {
+			failures = append(failures, fmt.Sprintf("scenario %s matched but must not", id))
+		}
+	}
+	for _, id := range x.Actions {
+		if !slices.ContainsFunc(sim.Actions, func(a engine.SimulatedAction) bool { return a.ActionID == id }) {
+			failures = append(failures, fmt.Sprintf("action %s did not fire", id))
+		}
+	}
+	if x.NoErrors {
+		for _, e := range sim.Errors {
+			failures = append(failures, "evaluation error: "+e)
+		}
+	}
+	return Result{
+		ProbeID:   def.ID,
+		OK:        len(failures) == 0,
+		Failures:  failures,
+		GraphHash: sim.GraphHash,
+		CheckedAt: now,
+	}
+}
+
+// record stores res and exports it. Failures are logged on every run;
+// recovery is logged once.
+func (r *Runner) record(res Result) {
+	r.mu.Lock()
+	prev, seen := r.results[res.ProbeID]
+	r.results[res.ProbeID] = res
+	r.mu.Unlock()
+
+	if res.OK {
+		metrics.MonitorProbeOK.WithLabelValues(res.ProbeID).Set(1)
+		if seen && !prev.OK {
+			slog.Info("synthetic monitor recovered", "probe_id", res.ProbeID, "graph_hash", res.GraphHash)
+		}
+		return
+	}
+	metrics.MonitorProbeOK.WithLabelValues(res.ProbeID).Set(0)
+	metrics.MonitorProbeFailures.WithLabelValues(res.ProbeID).Inc()
+	slog.Warn("synthetic monitor failed",
+		"probe_id", res.ProbeID, "graph_hash", res.GraphHash, "failures", res.Failures)
+}
+
+// probeEvent builds a fresh event for one run, so evaluation never sees
+// state left by a previous one.
+func probeEvent(def config.MonitorDef, now time.Time) *event.Event {
+	ev := &event.Event{
+		ID:         fmt.Sprintf("monitor-%s-%d", def.ID, now.UnixNano()),
+		Type:       def.Event.Type,
+		Source:     def.Event.Source,
+		ActorID:    def.Event.ActorID,
+		OccurredAt: now,
+		ReceivedAt: now,
+		Payload:    maps.Clone(def.Event.Payload),
+		Meta:       maps.Clone(def.Event.Meta),
+	}
+	if ev.Source == "" {
+		ev.Source = "monitor"
+	}
+	if ev.ActorID == "" {
+		ev.ActorID = "synthetic-monitor"
+	}
+	if ev.Payload == nil {
+		ev.Payload = map[string]interface{}{}
+	}
+	if ev.Meta == nil {
+		ev.Meta = map[string]string{}
+	}
+	ev.Meta["synthetic"] = "true"
+	return ev
+}
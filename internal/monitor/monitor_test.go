@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// fakeSim matches sc_big (firing notify_big) for amounts over threshold.
+type fakeSim struct {
+	threshold float64
+	seen      *event.Event
+}
+
+func (f *fakeSim) Simulate(_ context.Context, ev *event.Event) *engine.SimulationResult {
+	f.seen = ev
+	res := &engine.SimulationResult{GraphHash: "h"}
+	if amt, _ := ev.Payload["amount"].(float64); amt > f.threshold {
+		res.ScenariosMatched = []string{"sc_big"}
+		res.Actions = []engine.SimulatedAction{{ScenarioID: "sc_big", ActionID: "notify_big"}}
+	}
+	return res
+}
+
+func TestRunner_DetectsRegressionAfterReload(t *testing.T) {
+	sim := &fakeSim{threshold: 1000}
+	r := NewRunner(sim)
+	defs := []config.MonitorDef{{
+		ID:         "big_txn",
+		IntervalMs: 60000,
+		Event:      config.MonitorEvent{Type: "transaction", Payload: map[string]interface{}{"amount": 5000.0}},
+		Expect:     config.MonitorExpect{Scenarios: []string{"sc_big"}, Actions: []string{"notify_big"}},
+	}}
+	r.Load(defs)
+	now := time.Now()
+
+	res := r.RunDue(context.Background(), now)
+	if len(res) != 1 || !res[0].OK {
+		t.Fatalf("expected passing probe, got %+v", res)
+	}
+	if sim.seen.Source != "monitor" || sim.seen.Meta["synthetic"] != "true" {
+		t.Errorf("probe event not marked synthetic: %+v", sim.seen)
+	}
+	if res := r.RunDue(context.Background(), now.Add(time.Second)); len(res) != 0 {
+		t.Fatalf("probe ran before its interval: %+v", res)
+	}
+
+	// A reload raises the threshold past the probe amount; Load makes the
+	// probe due again at once.
+	sim.threshold = 10000
+	r.Load(defs)
+	res = r.RunDue(context.Background(), now.Add(2*time.Second))
+	if len(res) != 1 || res[0].OK || len(res[0].Failures) != 2 {
+		t.Fatalf("expected failing probe with 2 failures, got %+v", res)
+	}
+	if got := r.Results(); len(got) != 1 || got[0].OK {
+		t.Errorf("Results() = %+v", got)
+	}
+
+	r.Load(nil)
+	if got := r.Results(); len(got) != 0 {
+		t.Errorf("removed probe still reported: %+v", got)
+	}
+}
@@ -0,0 +1,159 @@
+// Package perf reads `go test -bench` output and compares two runs, so a
+// change can be checked against a baseline before it is merged.
+package perf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Result is one benchmark's measurements, averaged over every line of it in
+// a run (go test -count).
+type Result struct {
+	Pkg     string
+	Name    string             // without the -GOMAXPROCS suffix
+	Runs    int                // lines averaged
+	Metrics map[string]float64 // unit → mean, e.g. "ns/op", "allocs/op", "events/s"
+}
+
+// String is the short name used in reports, e.g. "dag.BenchmarkEvaluate/scenarios=100".
+func (r *Result) String() string {
+	if r.Pkg == "" {
+		return r.Name
+	}
+	return path.Base(r.Pkg) + "." + r.Name
+}
+
+// Run is the parsed output of one benchmark run, keyed by package and name.
+type Run map[string]*Result
+
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// Parse reads benchmark lines from r. Other lines are skipped, except "pkg:"
+// headers, which qualify the benchmarks that follow.
+func Parse(r io.Reader) (Run, error) {
+	run := make(Run)
+	sums := make(map[string]map[string]float64)
+	counts := make(map[string]map[string]int)
+	pkg := ""
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		if p, ok := strings.CutPrefix(line, "pkg: "); ok {
+			pkg = strings.TrimSpace(p)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") || len(fields)%2 != 0 {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := procsSuffix.ReplaceAllString(fields[0], "")
+		key := pkg + " " + name
+		res, ok := run[key]
+		if !ok {
+			res = &Result{Pkg: pkg, Name: name, Metrics: make(map[string]float64)}
+			run[key] = res
+			sums[key] = make(map[string]float64)
+			counts[key] = make(map[string]int)
+		}
+		res.Runs++
+		for i := 2; i < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad value %q for %s", name, fields[i], fields[i+1])
+			}
+			sums[key][fields[i+1]] += v
+			counts[key][fields[i+1]]++
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for key, res := range run {
+		for unit, sum := range sums[key] {
+			res.Metrics[unit] = sum / float64(counts[key][unit])
+		}
+	}
+	return run, nil
+}
+
+// Delta is the change of one metric of one benchmark between two runs.
+type Delta struct {
+	Benchmark  string // Result.String
+	Unit       string
+	Old, New   float64
+	Change     float64 // (New-Old)/Old; 0 when Old is 0
+	Regression bool
+}
+
+// Comparison is the outcome of Compare.
+type Comparison struct {
+	Deltas  []Delta
+	Added   []string // benchmarks only in head
+	Removed []string // benchmarks only in base
+}
+
+// Regressions returns the deltas that crossed the threshold.
+func (c *Comparison) Regressions() []Delta {
+	var out []Delta
+	for _, d := range c.Deltas {
+		if d.Regression {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Compare diffs every metric base and head share. A rate (a unit ending in
+// "/s", such as MB/s) regresses when it drops by more than threshold, any
+// other metric when it grows by more; threshold 0.1 is 10%. Going from zero
+// to a non-zero cost, e.g. from 0 to 1 allocs/op, is always a regression.
+func Compare(base, head Run, threshold float64) *Comparison {
+	c := &Comparison{}
+	for key, o := range base {
+		n, ok := head[key]
+		if !ok {
+			c.Removed = append(c.Removed, o.String())
+			continue
+		}
+		for unit, ov := range o.Metrics {
+			nv, ok := n.Metrics[unit]
+			if !ok {
+				continue
+			}
+			d := Delta{Benchmark: o.String(), Unit: unit, Old: ov, New: nv}
+			if ov != 0 {
+				d.Change = (nv - ov) / ov
+			}
+			if strings.HasSuffix(unit, "/s") {
+				d.Regression = -d.Change > threshold
+			} else {
+				d.Regression = d.Change > threshold || (ov == 0 && nv > 0)
+			}
+			c.Deltas = append(c.Deltas, d)
+		}
+	}
+	for key, n := range head {
+		if _, ok := base[key]; !ok {
+			c.Added = append(c.Added, n.String())
+		}
+	}
+	sort.Slice(c.Deltas, func(i, j int) bool {
+		if c.Deltas[i].Benchmark != c.Deltas[j].Benchmark {
+			return c.Deltas[i].Benchmark < c.Deltas[j].Benchmark
+		}
+		return c.Deltas[i].Unit < c.Deltas[j].Unit
+	})
+	sort.Strings(c.Added)
+	sort.Strings(c.Removed)
+	return c
+}
@@ -0,0 +1,63 @@
+package perf
+
+import (
+	"strings"
+	"testing"
+)
+
+const baseRun = `goos: linux
+goarch: amd64
+pkg: github.com/gyaneshwarpardhi/ifttt/pkg/condition
+BenchmarkParse-8      	  300000	      4000 ns/op	  39.10 MB/s	    2832 B/op	      57 allocs/op
+BenchmarkParse-8      	  300000	      4200 ns/op	  37.20 MB/s	    2832 B/op	      57 allocs/op
+PASS
+pkg: github.com/gyaneshwarpardhi/ifttt/internal/dag
+BenchmarkEvaluate/scenarios=10-8    	  600000	      1800 ns/op	     416 B/op	       7 allocs/op
+BenchmarkGone-8    	  100	      10 ns/op
+ok  	github.com/gyaneshwarpardhi/ifttt/internal/dag	2.1s
+`
+
+const headRun = `pkg: github.com/gyaneshwarpardhi/ifttt/pkg/condition
+BenchmarkParse-4      	  300000	      4150 ns/op	  30.00 MB/s	    2832 B/op	      57 allocs/op
+pkg: github.com/gyaneshwarpardhi/ifttt/internal/dag
+BenchmarkEvaluate/scenarios=10-4    	  600000	      1700 ns/op	     416 B/op	       9 allocs/op
+BenchmarkNew-4    	  100	      10 ns/op
+`
+
+func TestParse(t *testing.T) {
+	run, err := Parse(strings.NewReader(baseRun))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(run) != 3 {
+		t.Fatalf("parsed %d benchmarks, want 3", len(run))
+	}
+	p := run["github.com/gyaneshwarpardhi/ifttt/pkg/condition BenchmarkParse"]
+	if p == nil || p.Runs != 2 || p.Metrics["ns/op"] != 4100 || p.Metrics["allocs/op"] != 57 {
+		t.Fatalf("BenchmarkParse = %+v", p)
+	}
+	if got := p.String(); got != "condition.BenchmarkParse" {
+		t.Errorf("String = %q", got)
+	}
+	if e := run["github.com/gyaneshwarpardhi/ifttt/internal/dag BenchmarkEvaluate/scenarios=10"]; e == nil {
+		t.Error("sub-benchmark missing or its -GOMAXPROCS suffix kept")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base, _ := Parse(strings.NewReader(baseRun))
+	head, _ := Parse(strings.NewReader(headRun))
+	c := Compare(base, head, 0.1)
+
+	var got []string
+	for _, d := range c.Regressions() {
+		got = append(got, d.Benchmark+" "+d.Unit)
+	}
+	want := []string{"condition.BenchmarkParse MB/s", "dag.BenchmarkEvaluate/scenarios=10 allocs/op"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("regressions = %v, want %v", got, want)
+	}
+	if len(c.Added) != 1 || c.Added[0] != "dag.BenchmarkNew" || len(c.Removed) != 1 || c.Removed[0] != "dag.BenchmarkGone" {
+		t.Errorf("added %v, removed %v", c.Added, c.Removed)
+	}
+}
@@ -0,0 +1,171 @@
+// Package retention purges data once its retention period has passed:
+// buffered results, audit entries and finished workflow state after the
+// periods of the scenarios they belong to, and streaks after
+// engine.streak_retention_ms. Stores register a Target; the Purger sweeps
+// them on an interval under the Policy derived from the active config.
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+	"github.com/gyaneshwarpardhi/ifttt/internal/workflow"
+)
+
+// sweepInterval is how often the Purger runs; retention periods are honoured
+// to within this.
+const sweepInterval = time.Minute
+
+// Policy is the per-scenario retention in effect. Zero or missing entries
+// mean no time limit (capacity-based eviction only).
+type Policy struct {
+	Results map[string]time.Duration // scenario id → TTL of results that matched it
+	Audit   map[string]time.Duration // scenario id → TTL of its audit entries
+	State   map[string]time.Duration // workflow id → TTL of its finished instances
+	Streaks time.Duration            // TTL of streaks since their last update
+}
+
+// PolicyFor derives the Policy from cfg. A workflow's state takes the
+// shortest state_ms of the scenarios that start it.
+func PolicyFor(cfg *config.RuleConfig) *Policy {
+	p := &Policy{
+		Results: make(map[string]time.Duration),
+		Audit:   make(map[string]time.Duration),
+		State:   make(map[string]time.Duration),
+		Streaks: cfg.Engine.StreakRetentionMs.Duration(),
+	}
+	for _, sc := range cfg.Scenarios {
+		r := sc.Retention
+		if r.ResultsMs > 0 {
+			p.Results[sc.ID] = r.ResultsMs.Duration()
+		}
+		if r.AuditMs > 0 {
+			p.Audit[sc.ID] = r.AuditMs.Duration()
+		}
+		if r.StateMs > 0 {
+			walkStarts(sc.Children, func(wf string) {
+				p.State[wf] = shortest(p.State[wf], r.StateMs.Duration())
+			})
+		}
+	}
+	return p
+}
+
+func walkStarts(refs []config.NodeRef, fn func(workflowID string)) {
+	for _, ref := range refs {
+		switch {
+		case ref.Action != nil && ref.Action.Type == workflow.StartActionType:
+			if wf, _ := ref.Action.Params["workflow"].(string); wf != "" {
+				fn(wf)
+			}
+		case ref.Condition != nil:
+			walkStarts(ref.Condition.Children, fn)
+		}
+	}
+}
+
+// shortest returns the smaller positive duration; 0 means unset.
+func shortest(a, b time.Duration) time.Duration {
+	if a == 0 || (b > 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// ResultTTL is the TTL of a result that matched scenarios: the shortest set
+// among them, or 0 if none is set.
+func (p *Policy) ResultTTL(scenarios []string) time.Duration {
+	var ttl time.Duration
+	for _, id := range scenarios {
+		ttl = shortest(ttl, p.Results[id])
+	}
+	return ttl
+}
+
+// AuditTTL is the TTL of audit entries for scenarioID, or 0.
+func (p *Policy) AuditTTL(scenarioID string) time.Duration {
+	return p.Audit[scenarioID]
+}
+
+// StateCutoffs maps each workflow with a state TTL to the time before which
+// its finished instances have expired at now.
+func (p *Policy) StateCutoffs(now time.Time) map[string]time.Time {
+	out := make(map[string]time.Time, len(p.State))
+	for wf, ttl := range p.State {
+		out[wf] = now.Add(-ttl)
+	}
+	return out
+}
+
+// Target deletes its expired data under p and returns how many entries it
+// removed.
+type Target func(ctx context.Context, now time.Time, p *Policy) (int, error)
+
+// Purger sweeps registered targets every minute.
+type Purger struct {
+	policy atomic.Pointer[Policy]
+
+	mu      sync.Mutex
+	targets map[string]Target
+}
+
+// NewPurger creates a Purger enforcing the retention in cfg.
+func NewPurger(cfg *config.RuleConfig) *Purger {
+	p := &Purger{targets: make(map[string]Target)}
+	p.SetConfig(cfg)
+	return p
+}
+
+// SetConfig replaces the policy, e.g. from an engine.GraphHook after a reload.
+func (p *Purger) SetConfig(cfg *config.RuleConfig) {
+	p.policy.Store(PolicyFor(cfg))
+}
+
+// Register adds a store under name (the metric label).
+func (p *Purger) Register(name string, t Target) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.targets[name] = t
+}
+
+// Run sweeps every minute until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	t := time.NewTicker(sweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			p.Sweep(ctx, now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Sweep runs every target once and returns the number of entries purged.
+func (p *Purger) Sweep(ctx context.Context, now time.Time) int {
+	p.mu.Lock()
+	targets := maps.Clone(p.targets)
+	p.mu.Unlock()
+
+	policy := p.policy.Load()
+	total := 0
+	for name, t := range targets {
+		n, err := t(ctx, now, policy)
+		if err != nil {
+			slog.Warn("retention purge failed", "store", name, "err", err)
+		}
+		if n > 0 {
+			metrics.RetentionPurged.WithLabelValues(name).Add(float64(n))
+			slog.Info("retention purge", "store", name, "purged", n)
+		}
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,55 @@
+package retention
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+)
+
+func TestPolicyFor_ShortestRetentionWins(t *testing.T) {
+	start := func(wf string) config.NodeRef {
+		return config.NodeRef{Action: &config.ActionDef{ID: "start_" + wf, Type: "start_workflow", Params: map[string]interface{}{"workflow": wf}}}
+	}
+	cfg := &config.RuleConfig{Engine: config.EngineConf{StreakRetentionMs: 259200000}, Scenarios: []config.Scenario{
+		{ID: "sc_kyc", Retention: config.RetentionConf{ResultsMs: 3600000, AuditMs: 86400000, StateMs: 7200000},
+			Children: []config.NodeRef{{Condition: &config.ConditionDef{ID: "c", Children: []config.NodeRef{start("wf_onboard")}}}}},
+		{ID: "sc_promo", Retention: config.RetentionConf{ResultsMs: 600000, StateMs: 86400000},
+			Children: []config.NodeRef{start("wf_onboard"), start("wf_promo")}},
+		{ID: "sc_plain"},
+	}}
+	p := PolicyFor(cfg)
+
+	if got := p.ResultTTL([]string{"sc_kyc", "sc_promo", "sc_plain"}); got != 10*time.Minute {
+		t.Errorf("ResultTTL = %v, want 10m", got)
+	}
+	if got := p.ResultTTL([]string{"sc_plain"}); got != 0 {
+		t.Errorf("ResultTTL without retention = %v, want 0", got)
+	}
+	if got := p.AuditTTL("sc_kyc"); got != 24*time.Hour {
+		t.Errorf("AuditTTL = %v, want 24h", got)
+	}
+	if p.State["wf_onboard"] != 2*time.Hour || p.State["wf_promo"] != 24*time.Hour {
+		t.Errorf("State = %v", p.State)
+	}
+	if p.Streaks != 72*time.Hour {
+		t.Errorf("Streaks = %v, want 72h", p.Streaks)
+	}
+
+	purger := NewPurger(cfg)
+	var seen *Policy
+	purger.Register("fake", func(_ context.Context, _ time.Time, pol *Policy) (int, error) {
+		seen = pol
+		return 3, nil
+	})
+	if n := purger.Sweep(context.Background(), time.Now()); n != 3 || seen == nil {
+		t.Fatalf("Sweep = %d, target saw %v", n, seen)
+	}
+
+	purger.SetConfig(&config.RuleConfig{})
+	purger.Sweep(context.Background(), time.Now())
+	if len(seen.Results) != 0 {
+		t.Errorf("SetConfig did not replace the policy: %v", seen.Results)
+	}
+}
@@ -0,0 +1,332 @@
+// Package schedule emits events at a later time. The emit_later action
+// stores a synthetic event with a due time; the Scheduler feeds it to the
+// engine once due, unless a later event from the same actor cancelled it.
+// That covers follow-ups like "emit churn_risk if the actor has not purchased
+// 3 days after signup" without an external job runner.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/duration"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+)
+
+// ActionType is the action type that schedules an event.
+const ActionType = "emit_later"
+
+// Source is the source of every emitted event.
+const Source = "scheduler"
+
+const (
+	tick       = time.Second
+	dueBatch   = 64
+	maxDelay   = 366 * 24 * time.Hour
+	metaOrigin = "scheduled_by" // meta key naming the event that scheduled it
+
+	// lease is how long an emitted entry is held back while the engine has
+	// it queued. If the process dies first, the entry is emitted again then.
+	lease = time.Minute
+	// An entry the engine does not accept is retried after retryBackoff,
+	// doubling up to maxRetryBackoff, and dropped after maxAttempts.
+	retryBackoff    = tick
+	maxRetryBackoff = 5 * time.Minute
+	maxAttempts     = 8
+)
+
+// Processor queues an event for the rules; *engine.Engine implements it.
+type Processor interface {
+	Enqueue(ctx context.Context, ev *event.Event, done func(*engine.EventResult)) error
+}
+
+// Scheduler stores scheduled events and emits them when due.
+type Scheduler struct {
+	store Store
+	now   func() time.Time
+
+	mu       sync.Mutex
+	attempts map[string]int // entry ID → rejected emits, since this process started
+}
+
+// New creates a Scheduler backed by store.
+func New(store Store) *Scheduler {
+	return &Scheduler{store: store, now: time.Now, attempts: make(map[string]int)}
+}
+
+// Run emits due events every second until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context, p Processor) {
+	t := time.NewTicker(tick)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			s.EmitDue(ctx, p)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// EmitDue queues every entry due now on the engine's async path and returns
+// how many it queued. An entry is leased while queued and deleted only once
+// the engine has processed it, so a crash in between emits it again
+// (at-least-once); the emitted event keeps its ID, which
+// engine.dedupe_window_ms can use to drop the repeat. An entry the engine
+// does not accept, e.g. because its queue is full, is retried with backoff
+// and dropped as dead after maxAttempts; the others are still emitted.
+func (s *Scheduler) EmitDue(ctx context.Context, p Processor) int {
+	emitted := 0
+	defer func() {
+		if n, err := s.store.Pending(ctx); err == nil {
+			metrics.ScheduledPending.Set(float64(n))
+		}
+	}()
+	for {
+		now := s.now()
+		due, err := s.store.Due(ctx, now, dueBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				logctx.From(ctx).Warn("scheduled events lookup failed", "err", err)
+			}
+			return emitted
+		}
+		for _, e := range due {
+			if ctx.Err() != nil {
+				return emitted
+			}
+			ectx := logctx.With(ctx, "event_id", e.ID)
+			if err := s.store.Postpone(ectx, e.ID, now.Add(lease)); err != nil {
+				logctx.From(ectx).Warn("scheduled event lease failed", "err", err)
+				return emitted
+			}
+			ev := *e.Event
+			ev.ReceivedAt = now
+			id := e.ID
+			err := p.Enqueue(ectx, &ev, func(*engine.EventResult) { s.finish(ectx, id) })
+			switch {
+			case err == nil:
+				emitted++
+			case errors.Is(err, engine.ErrDuplicate):
+				// An earlier emit of the entry is queued or was processed
+				// within the dedupe window.
+				s.finish(ectx, id)
+			default:
+				s.retry(ectx, e, err)
+			}
+		}
+		if len(due) < dueBatch {
+			return emitted
+		}
+	}
+}
+
+// finish deletes an entry the engine has processed. It runs on an event
+// worker, possibly after ctx is cancelled, so shutdown does not emit it twice.
+func (s *Scheduler) finish(ctx context.Context, id string) {
+	s.mu.Lock()
+	delete(s.attempts, id)
+	s.mu.Unlock()
+	ctx = context.WithoutCancel(ctx)
+	if err := s.store.Delete(ctx, id); err != nil {
+		logctx.From(ctx).Warn("scheduled event delete failed; it may be emitted again", "err", err)
+	}
+	metrics.ScheduledEvents.WithLabelValues("emitted").Inc()
+}
+
+// retry postpones an entry the engine rejected with err, or drops it after
+// maxAttempts.
+func (s *Scheduler) retry(ctx context.Context, e *Entry, err error) {
+	s.mu.Lock()
+	s.attempts[e.ID]++
+	n := s.attempts[e.ID]
+	if n >= maxAttempts {
+		delete(s.attempts, e.ID)
+	}
+	s.mu.Unlock()
+	log := logctx.From(ctx)
+	if n >= maxAttempts {
+		log.Error("scheduled event dropped after repeated dispatch failures",
+			"attempts", n, "actor_id", e.ActorID, "event_type", e.Event.Type, "due", e.Due, "err", err)
+		if err := s.store.Delete(ctx, e.ID); err != nil {
+			log.Warn("scheduled event delete failed", "err", err)
+		}
+		metrics.ScheduledEvents.WithLabelValues("dead").Inc()
+		return
+	}
+	wait := min(retryBackoff<<(n-1), maxRetryBackoff)
+	log.Warn("scheduled event dispatch failed; will retry", "attempt", n, "retry_in", wait, "err", err)
+	if err := s.store.Postpone(ctx, e.ID, s.now().Add(wait)); err != nil {
+		log.Warn("scheduled event postpone failed", "err", err)
+	}
+}
+
+// Observe cancels the actor's scheduled events that list ev's type in
+// cancel_on. Register it as an engine.OnEventProcessed hook.
+func (s *Scheduler) Observe(ctx context.Context, ev *event.Event) {
+	if ev.ActorID == "" {
+		return
+	}
+	n, err := s.store.Cancel(ctx, ev.ActorID, ev.Type)
+	if err != nil {
+		logctx.From(ctx).Warn("scheduled event cancel failed", "event_id", ev.ID, "actor_id", ev.ActorID, "err", err)
+		return
+	}
+	if n > 0 {
+		metrics.ScheduledEvents.WithLabelValues("cancelled").Add(float64(n))
+		logctx.From(ctx).Info("scheduled events cancelled", "event_id", ev.ID, "actor_id", ev.ActorID, "event_type", ev.Type, "count", n)
+	}
+}
+
+// Executor returns the emit_later action executor backed by s.
+//
+// Params:
+//   - event_type: type of the event to emit (required)
+//   - delay:      how long after the triggering event, e.g. "3d", or milliseconds (required)
+//   - payload:    payload of the emitted event (default empty)
+//   - cancel_on:  event types from the same actor that cancel it before it is due
+//
+// The emitted event has source "scheduler", the triggering event's actor and
+// meta, and meta.scheduled_by set to the triggering event's ID.
+func (s *Scheduler) Executor() action.Executor { return &emitExecutor{s: s} }
+
+type emitExecutor struct {
+	s *Scheduler
+}
+
+func (x *emitExecutor) Type() string { return ActionType }
+
+func (x *emitExecutor) Validate(params map[string]interface{}) error {
+	if t, _ := params["event_type"].(string); t == "" {
+		return fmt.Errorf("%s: event_type is required", ActionType)
+	}
+	d, err := delayParam(params)
+	if err != nil {
+		return err
+	}
+	if d <= 0 || d > maxDelay {
+		return fmt.Errorf("%s: delay must be > 0 and at most 366d, got %s", ActionType, d)
+	}
+	if p, ok := params["payload"]; ok {
+		if _, ok := p.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s: payload must be a map, got %T", ActionType, p)
+		}
+	}
+	if _, err := cancelOnParam(params); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (x *emitExecutor) Execute(
+	ctx context.Context,
+	actionID string,
+	params map[string]interface{},
+	evalCtx *dag.EvalContext,
+) (*action.ActionResult, error) {
+	fail := func(err error) (*action.ActionResult, error) {
+		return &action.ActionResult{ActionID: actionID, Type: ActionType, Success: false, Message: err.Error()}, err
+	}
+	src := evalCtx.Event
+	eventType, _ := params["event_type"].(string)
+	delay, err := delayParam(params)
+	if err != nil {
+		return fail(err)
+	}
+	cancelOn, err := cancelOnParam(params)
+	if err != nil {
+		return fail(err)
+	}
+	payload, _ := params["payload"].(map[string]interface{})
+
+	due := x.s.now().Add(delay)
+	meta := maps.Clone(src.Meta)
+	if meta == nil {
+		meta = make(map[string]string, 1)
+	}
+	meta[metaOrigin] = src.ID
+	// The ID derives from the triggering event and action, so a retried
+	// action or redelivered event schedules one event, not several.
+	id := src.ID + ":" + actionID
+	e := &Entry{
+		ID:       id,
+		ActorID:  src.ActorID,
+		Due:      due,
+		CancelOn: cancelOn,
+		Event: &event.Event{
+			ID:         id,
+			Type:       eventType,
+			OccurredAt: due,
+			Source:     Source,
+			ActorID:    src.ActorID,
+			Payload:    maps.Clone(payload),
+			Meta:       meta,
+		},
+	}
+	if e.Event.Payload == nil {
+		e.Event.Payload = map[string]interface{}{}
+	}
+	if err := x.s.store.Put(ctx, e); err != nil {
+		return fail(err)
+	}
+	metrics.ScheduledEvents.WithLabelValues("scheduled").Inc()
+	evalCtx.Results.SetOutput(actionID, map[string]interface{}{
+		"event_id": id,
+		"due":      due.UTC().Format(time.RFC3339),
+	})
+	return &action.ActionResult{
+		ActionID: actionID,
+		Type:     ActionType,
+		Success:  true,
+		Message:  fmt.Sprintf("%s scheduled for %s (%s)", eventType, due.UTC().Format(time.RFC3339), id),
+	}, nil
+}
+
+// delayParam reads delay as a duration string or milliseconds.
+func delayParam(params map[string]interface{}) (time.Duration, error) {
+	switch v := params["delay"].(type) {
+	case string:
+		d, err := duration.Parse(v)
+		if err != nil {
+			return 0, fmt.Errorf("%s: delay: %w", ActionType, err)
+		}
+		return d, nil
+	case int:
+		return time.Duration(v) * time.Millisecond, nil
+	case float64:
+		return time.Duration(v * float64(time.Millisecond)), nil
+	case nil:
+		return 0, fmt.Errorf("%s: delay is required", ActionType)
+	default:
+		return 0, fmt.Errorf("%s: delay must be a duration or milliseconds, got %T", ActionType, v)
+	}
+}
+
+func cancelOnParam(params map[string]interface{}) ([]string, error) {
+	raw, ok := params["cancel_on"]
+	if !ok {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: cancel_on must be a list of event types", ActionType)
+	}
+	out := make([]string, 0, len(list))
+	for _, v := range list {
+		s, _ := v.(string)
+		if s == "" {
+			return nil, fmt.Errorf("%s: cancel_on entries must be non-empty event types", ActionType)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
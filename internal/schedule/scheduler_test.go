@@ -0,0 +1,146 @@
+package schedule
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/engine"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// processor records the events it is given and processes them at once. It
+// rejects events of actors in reject.
+type processor struct {
+	got    []*event.Event
+	reject map[string]bool
+}
+
+func (p *processor) Enqueue(_ context.Context, ev *event.Event, done func(*engine.EventResult)) error {
+	if p.reject[ev.ActorID] {
+		return engine.ErrQueueFull
+	}
+	p.got = append(p.got, ev)
+	done(&engine.EventResult{EventID: ev.ID})
+	return nil
+}
+
+func newTestScheduler(now *time.Time) *Scheduler {
+	s := New(NewMemoryStore())
+	s.now = func() time.Time { return *now }
+	return s
+}
+
+func schedule(t *testing.T, s *Scheduler, src *event.Event, params map[string]interface{}) {
+	t.Helper()
+	x := s.Executor()
+	if err := x.Validate(params); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	ctx := &dag.EvalContext{Event: src, Results: dag.NewResults(0)}
+	if _, err := x.Execute(context.Background(), "act_later", params, ctx); err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+}
+
+func TestScheduler_EmitsWhenDue(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestScheduler(&now)
+	src := &event.Event{ID: "e1", Type: "signup", ActorID: "u1", Meta: map[string]string{"tenant": "acme"}}
+	params := map[string]interface{}{
+		"event_type": "churn_risk",
+		"delay":      "3d",
+		"payload":    map[string]interface{}{"reason": "no_purchase"},
+	}
+	schedule(t, s, src, params)
+	schedule(t, s, src, params) // a retry schedules nothing new
+
+	p := &processor{}
+	if n := s.EmitDue(context.Background(), p); n != 0 {
+		t.Fatalf("emitted %d events before they were due", n)
+	}
+	now = now.Add(72 * time.Hour)
+	if n := s.EmitDue(context.Background(), p); n != 1 {
+		t.Fatalf("emitted %d events when due, want 1", n)
+	}
+	ev := p.got[0]
+	if ev.ID != "e1:act_later" || ev.Type != "churn_risk" || ev.Source != Source || ev.ActorID != "u1" {
+		t.Errorf("unexpected emitted event %+v", ev)
+	}
+	if ev.Meta["tenant"] != "acme" || ev.Meta["scheduled_by"] != "e1" || ev.Payload["reason"] != "no_purchase" {
+		t.Errorf("emitted event lost meta or payload: meta=%v payload=%v", ev.Meta, ev.Payload)
+	}
+	if n := s.EmitDue(context.Background(), p); n != 0 {
+		t.Errorf("emitted event again: %d", n)
+	}
+}
+
+func TestScheduler_CancelOn(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestScheduler(&now)
+	params := map[string]interface{}{
+		"event_type": "churn_risk",
+		"delay":      float64(60000),
+		"cancel_on":  []interface{}{"purchase"},
+	}
+	schedule(t, s, &event.Event{ID: "e1", Type: "signup", ActorID: "u1"}, params)
+	schedule(t, s, &event.Event{ID: "e2", Type: "signup", ActorID: "u2"}, params)
+
+	s.Observe(context.Background(), &event.Event{ID: "e3", Type: "login", ActorID: "u1"})
+	s.Observe(context.Background(), &event.Event{ID: "e4", Type: "purchase", ActorID: "u1"})
+
+	now = now.Add(time.Minute)
+	p := &processor{}
+	s.EmitDue(context.Background(), p)
+	if len(p.got) != 1 || p.got[0].ActorID != "u2" {
+		t.Fatalf("want only u2's event emitted, got %v", p.got)
+	}
+}
+
+func TestEmitExecutor_Validate(t *testing.T) {
+	x := New(NewMemoryStore()).Executor()
+	for name, params := range map[string]map[string]interface{}{
+		"no event_type":  {"delay": "1h"},
+		"no delay":       {"event_type": "x"},
+		"bad delay":      {"event_type": "x", "delay": "soon"},
+		"negative delay": {"event_type": "x", "delay": "-1h"},
+		"too long":       {"event_type": "x", "delay": "400d"},
+		"bad payload":    {"event_type": "x", "delay": "1h", "payload": "nope"},
+		"bad cancel_on":  {"event_type": "x", "delay": "1h", "cancel_on": "purchase"},
+	} {
+		if err := x.Validate(params); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestScheduler_RetriesRejectedEntriesAndContinues(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestScheduler(&now)
+	params := map[string]interface{}{"event_type": "churn_risk", "delay": "1m"}
+	schedule(t, s, &event.Event{ID: "e1", Type: "signup", ActorID: "u1"}, params)
+	schedule(t, s, &event.Event{ID: "e2", Type: "signup", ActorID: "u2"}, params)
+
+	now = now.Add(time.Minute)
+	p := &processor{reject: map[string]bool{"u1": true}}
+	if n := s.EmitDue(ctx, p); n != 1 || len(p.got) != 1 || p.got[0].ActorID != "u2" {
+		t.Fatalf("emitted %d (%v), want u2's event despite u1's failure", n, p.got)
+	}
+	if n, _ := s.store.Pending(ctx); n != 1 {
+		t.Fatalf("pending = %d, want u1's entry kept for a retry", n)
+	}
+	// The failed entry backs off instead of being retried every tick.
+	if n := s.EmitDue(ctx, p); n != 0 || s.attempts["e1:act_later"] != 1 {
+		t.Errorf("retried before its backoff: attempts %d", s.attempts["e1:act_later"])
+	}
+
+	for range maxAttempts {
+		now = now.Add(maxRetryBackoff)
+		s.EmitDue(ctx, p)
+	}
+	if n, _ := s.store.Pending(ctx); n != 0 {
+		t.Errorf("pending = %d, want the entry dropped after %d attempts", n, maxAttempts)
+	}
+}
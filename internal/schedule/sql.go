@@ -0,0 +1,216 @@
+package schedule
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/seal"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS scheduled_events (
+	id        TEXT PRIMARY KEY,
+	actor_id  TEXT    NOT NULL,
+	due       INTEGER NOT NULL,
+	cancel_on TEXT    NOT NULL DEFAULT '',
+	body      BLOB    NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS scheduled_events_due ON scheduled_events (due)`,
+	`CREATE INDEX IF NOT EXISTS scheduled_events_actor ON scheduled_events (actor_id)`,
+}
+
+// SQLStore keeps pending entries in a single SQL table, so scheduled events
+// survive restarts.
+//
+// With the JSON codec the body is the whole Entry as JSON. With a binary
+// codec it is only the event, and the rest of the Entry is read back from the
+// other columns; both forms are read whatever the current codec.
+type SQLStore struct {
+	db     *sql.DB
+	codec  event.Codec
+	sealer *seal.Sealer
+}
+
+// OpenSQLStore opens (creating if needed) a store at dsn using a database/sql
+// driver registered under driverName, e.g. "sqlite3" from github.com/mattn/go-sqlite3.
+func OpenSQLStore(driverName, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("schedule store open: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("schedule store schema: %w", err)
+		}
+	}
+	codec, _ := event.NewCodec(event.CodecJSON)
+	return &SQLStore{db: db, codec: codec}, nil
+}
+
+// SetCodec sets how new entries are encoded (JSON by default).
+func (s *SQLStore) SetCodec(c event.Codec) {
+	s.codec = c
+}
+
+// SetSealer encrypts new entries with s (nil, the default, stores them in
+// the clear). Rows written in the clear are still read; Reseal rewrites them.
+func (s *SQLStore) SetSealer(sl *seal.Sealer) {
+	s.sealer = sl
+}
+
+// Reseal rewrites entries not sealed under the primary key, and returns how
+// many it rewrote.
+func (s *SQLStore) Reseal(ctx context.Context) (int, error) {
+	return seal.ResealTable(ctx, s.db, s.sealer, "scheduled_events")
+}
+
+// Close closes the underlying database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Put(ctx context.Context, e *Entry) error {
+	var (
+		body []byte
+		err  error
+	)
+	if s.codec.Name() == event.CodecJSON {
+		body, err = json.Marshal(e)
+	} else {
+		body, err = s.codec.Marshal(e.Event)
+	}
+	if err != nil {
+		return fmt.Errorf("schedule encode %s: %w", e.ID, err)
+	}
+	if body, err = s.sealer.Seal(ctx, body); err != nil {
+		return fmt.Errorf("schedule seal %s: %w", e.ID, err)
+	}
+	// cancel_on is stored as ",a,b," so instr() matches whole types only.
+	cancelOn := ""
+	if len(e.CancelOn) > 0 {
+		cancelOn = "," + strings.Join(e.CancelOn, ",") + ","
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO scheduled_events (id, actor_id, due, cancel_on, body) VALUES (?, ?, ?, ?, ?)`,
+		e.ID, e.ActorID, e.Due.UnixNano(), cancelOn, body)
+	if err != nil {
+		return fmt.Errorf("schedule put %s: %w", e.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Due(ctx context.Context, now time.Time, limit int) ([]*Entry, error) {
+	return s.query(ctx, `SELECT id, actor_id, due, cancel_on, body FROM scheduled_events WHERE due <= ? ORDER BY due LIMIT ?`,
+		now.UnixNano(), limit)
+}
+
+func (s *SQLStore) ForActor(ctx context.Context, actorID string) ([]*Entry, error) {
+	return s.query(ctx, `SELECT id, actor_id, due, cancel_on, body FROM scheduled_events WHERE actor_id = ? ORDER BY due`, actorID)
+}
+
+func (s *SQLStore) Actors(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT actor_id FROM scheduled_events`)
+	if err != nil {
+		return nil, fmt.Errorf("schedule actors: %w", err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("schedule actors: %w", err)
+		}
+		out = append(out, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("schedule actors: %w", err)
+	}
+	return out, nil
+}
+
+func (s *SQLStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM scheduled_events WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("schedule delete %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Postpone(ctx context.Context, id string, due time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `UPDATE scheduled_events SET due = ? WHERE id = ?`, due.UnixNano(), id); err != nil {
+		return fmt.Errorf("schedule postpone %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Cancel(ctx context.Context, actorID, eventType string) (int, error) {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM scheduled_events WHERE actor_id = ? AND instr(cancel_on, ?) > 0`,
+		actorID, ","+eventType+",")
+	if err != nil {
+		return 0, fmt.Errorf("schedule cancel %s: %w", actorID, err)
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (s *SQLStore) Pending(ctx context.Context) (int, error) {
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM scheduled_events`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("schedule pending: %w", err)
+	}
+	return n, nil
+}
+
+func (s *SQLStore) query(ctx context.Context, q string, args ...interface{}) ([]*Entry, error) {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("schedule query: %w", err)
+	}
+	defer rows.Close()
+	var out []*Entry
+	for rows.Next() {
+		var (
+			e        Entry
+			due      int64
+			cancelOn string
+			body     []byte
+		)
+		if err := rows.Scan(&e.ID, &e.ActorID, &due, &cancelOn, &body); err != nil {
+			return nil, fmt.Errorf("schedule query: %w", err)
+		}
+		body, err := s.sealer.Open(ctx, body)
+		if err != nil {
+			return nil, fmt.Errorf("schedule open %s: %w", e.ID, err)
+		}
+		if len(body) > 0 && body[0] == '{' {
+			// A whole Entry written by the JSON codec. The due column is
+			// authoritative: Postpone moves it without rewriting the body.
+			if err := json.Unmarshal(body, &e); err != nil {
+				return nil, fmt.Errorf("schedule decode %s: %w", e.ID, err)
+			}
+			e.Due = time.Unix(0, due)
+			out = append(out, &e)
+			continue
+		}
+		e.Due = time.Unix(0, due)
+		if cancelOn = strings.Trim(cancelOn, ","); cancelOn != "" {
+			e.CancelOn = strings.Split(cancelOn, ",")
+		}
+		e.Event = &event.Event{}
+		if err := event.Decode(body, e.Event); err != nil {
+			return nil, fmt.Errorf("schedule decode %s: %w", e.ID, err)
+		}
+		out = append(out, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("schedule query: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,85 @@
+//go:build cgo
+
+package schedule
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+func TestSQLStore(t *testing.T) {
+	for _, name := range []string{event.CodecJSON, event.CodecMsgPack, event.CodecProto} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			st, err := OpenSQLStore("sqlite3", filepath.Join(t.TempDir(), "schedule.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer st.Close()
+			codec, err := event.NewCodec(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			st.SetCodec(codec)
+
+			now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+			entry := func(id, actor string, due time.Time, cancelOn ...string) *Entry {
+				return &Entry{ID: id, ActorID: actor, Due: due, CancelOn: cancelOn, Event: &event.Event{
+					ID: id, Type: "churn_risk", ActorID: actor, Source: Source, OccurredAt: due,
+					Payload: map[string]interface{}{"reason": "no_purchase"},
+				}}
+			}
+			for _, e := range []*Entry{
+				entry("a", "u1", now.Add(time.Minute), "purchase"),
+				entry("b", "u1", now.Add(2*time.Minute)),
+				entry("c", "u2", now.Add(time.Hour), "purchase", "login"),
+			} {
+				if err := st.Put(ctx, e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			// Putting a pending ID again is a no-op.
+			if err := st.Put(ctx, entry("a", "u1", now.Add(time.Hour))); err != nil {
+				t.Fatal(err)
+			}
+
+			due, err := st.Due(ctx, now.Add(2*time.Minute), 10)
+			if err != nil || len(due) != 2 || due[0].ID != "a" || due[1].ID != "b" {
+				t.Fatalf("Due = %v, %v; want a then b", due, err)
+			}
+			if due[0].Event.Payload["reason"] != "no_purchase" || len(due[0].CancelOn) != 1 {
+				t.Errorf("entry a read back as %+v", due[0])
+			}
+
+			// A postponed entry is not due until its new time.
+			if err := st.Postpone(ctx, "a", now.Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if due, _ := st.Due(ctx, now.Add(2*time.Minute), 10); len(due) != 1 || due[0].ID != "b" {
+				t.Fatalf("Due after postponing a = %v, want b only", due)
+			}
+
+			if n, err := st.Cancel(ctx, "u2", "login"); err != nil || n != 1 {
+				t.Errorf("Cancel(u2, login) = %d, %v; want 1", n, err)
+			}
+			if err := st.Delete(ctx, "b"); err != nil {
+				t.Fatal(err)
+			}
+			if n, _ := st.Pending(ctx); n != 1 {
+				t.Errorf("Pending = %d, want 1", n)
+			}
+			if es, _ := st.ForActor(ctx, "u1"); len(es) != 1 || es[0].ID != "a" || !es[0].Due.Equal(now.Add(time.Hour)) {
+				t.Errorf("ForActor(u1) = %v, want a due in an hour", es)
+			}
+			if actors, _ := st.Actors(ctx); len(actors) != 1 || actors[0] != "u1" {
+				t.Errorf("Actors = %v, want [u1]", actors)
+			}
+		})
+	}
+}
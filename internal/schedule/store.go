@@ -0,0 +1,150 @@
+package schedule
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// Entry is one event waiting to be emitted.
+type Entry struct {
+	ID       string       `json:"id"` // also the emitted event's ID
+	ActorID  string       `json:"actor_id"`
+	Due      time.Time    `json:"due"`
+	CancelOn []string     `json:"cancel_on,omitempty"` // event types from the actor that cancel it
+	Event    *event.Event `json:"event"`
+}
+
+// cancelledBy reports whether an event of type eventType cancels e.
+func (e *Entry) cancelledBy(eventType string) bool {
+	return slices.Contains(e.CancelOn, eventType)
+}
+
+// Store persists pending entries.
+type Store interface {
+	// Put stores e. Putting an ID that is already pending is a no-op, so a
+	// retried action schedules its event once.
+	Put(ctx context.Context, e *Entry) error
+	// Due returns up to limit entries due at or before now, earliest first.
+	Due(ctx context.Context, now time.Time, limit int) ([]*Entry, error)
+	// Delete removes an entry once emitted.
+	Delete(ctx context.Context, id string) error
+	// Postpone moves an entry's due time, to lease it while it is being
+	// emitted or to retry it later. Postponing a missing ID is a no-op.
+	Postpone(ctx context.Context, id string, due time.Time) error
+	// Cancel removes actorID's entries cancelled by eventType and returns
+	// how many it removed.
+	Cancel(ctx context.Context, actorID, eventType string) (int, error)
+	// Pending returns the number of stored entries.
+	Pending(ctx context.Context) (int, error)
+	// ForActor returns actorID's pending entries, earliest first.
+	ForActor(ctx context.Context, actorID string) ([]*Entry, error)
+	// Actors returns the IDs of actors with pending entries.
+	Actors(ctx context.Context) ([]string, error)
+}
+
+// MemoryStore is a process-local Store. Pending entries are lost on restart,
+// so it suits tests and deployments without -schedule-store.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+func (s *MemoryStore) Put(_ context.Context, e *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[e.ID]; !ok {
+		cp := *e
+		s.entries[e.ID] = &cp
+	}
+	return nil
+}
+
+func (s *MemoryStore) Due(_ context.Context, now time.Time, limit int) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Entry
+	for _, e := range s.entries {
+		if !e.Due.After(now) {
+			cp := *e
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Due.Before(out[j].Due) })
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *MemoryStore) Postpone(_ context.Context, id string, due time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[id]; ok {
+		e.Due = due
+	}
+	return nil
+}
+
+func (s *MemoryStore) Cancel(_ context.Context, actorID, eventType string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, e := range s.entries {
+		if e.ActorID == actorID && e.cancelledBy(eventType) {
+			delete(s.entries, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) Pending(_ context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries), nil
+}
+
+func (s *MemoryStore) ForActor(_ context.Context, actorID string) ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Entry
+	for _, e := range s.entries {
+		if e.ActorID == actorID {
+			cp := *e
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Due.Before(out[j].Due) })
+	return out, nil
+}
+
+func (s *MemoryStore) Actors(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]struct{})
+	var out []string
+	for _, e := range s.entries {
+		if _, ok := seen[e.ActorID]; !ok {
+			seen[e.ActorID] = struct{}{}
+			out = append(out, e.ActorID)
+		}
+	}
+	return out, nil
+}
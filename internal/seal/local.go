@@ -0,0 +1,110 @@
+package seal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// KeyFile is the YAML format of a local key file. Keys are base64-encoded
+// 32-byte AES keys. Rotating means adding a key and making it primary; older
+// keys stay in the file until no blob is sealed under them.
+type KeyFile struct {
+	Primary string     `yaml:"primary"`
+	Keys    []KeyEntry `yaml:"keys"`
+}
+
+// KeyEntry is one key of a KeyFile.
+type KeyEntry struct {
+	ID  string `yaml:"id"`
+	Key string `yaml:"key"`
+}
+
+// LocalKeys wraps data keys with AES-256-GCM keys held in memory.
+type LocalKeys struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// LoadKeyFile reads a KeyFile.
+func LoadKeyFile(path string) (*LocalKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read keys %s: %w", path, err)
+	}
+	var f KeyFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse keys %s: %w", path, err)
+	}
+	lk, err := NewLocalKeys(f)
+	if err != nil {
+		return nil, fmt.Errorf("keys %s: %w", path, err)
+	}
+	return lk, nil
+}
+
+// NewLocalKeys validates f and returns its keys.
+func NewLocalKeys(f KeyFile) (*LocalKeys, error) {
+	lk := &LocalKeys{primary: f.Primary, keys: make(map[string][]byte, len(f.Keys))}
+	for _, e := range f.Keys {
+		switch {
+		case e.ID == "" || len(e.ID) > 255:
+			return nil, fmt.Errorf("key ID %q must be 1 to 255 bytes", e.ID)
+		case lk.keys[e.ID] != nil:
+			return nil, fmt.Errorf("key %s is listed twice", e.ID)
+		}
+		raw, err := base64.StdEncoding.DecodeString(e.Key)
+		if err != nil || len(raw) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes, base64-encoded", e.ID)
+		}
+		lk.keys[e.ID] = raw
+	}
+	if lk.keys[f.Primary] == nil {
+		return nil, fmt.Errorf("primary key %q is not listed", f.Primary)
+	}
+	return lk, nil
+}
+
+// GenerateKey returns a new key entry for a KeyFile.
+func GenerateKey(id string) (KeyEntry, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return KeyEntry{}, err
+	}
+	return KeyEntry{ID: id, Key: base64.StdEncoding.EncodeToString(raw)}, nil
+}
+
+func (lk *LocalKeys) Primary() string { return lk.primary }
+
+func (lk *LocalKeys) Wrap(_ context.Context, dataKey []byte) ([]byte, error) {
+	aead, err := newAEAD(lk.keys[lk.primary])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, dataKey, []byte(lk.primary)), nil
+}
+
+func (lk *LocalKeys) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := lk.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", keyID)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("wrapped key too short")
+	}
+	n := aead.NonceSize()
+	return aead.Open(nil, wrapped[:n], wrapped[n:], []byte(keyID))
+}
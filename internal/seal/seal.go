@@ -0,0 +1,257 @@
+// Package seal encrypts blobs persisted by the stores (inbox, schedule store,
+// workflow store) with envelope encryption. Each blob is encrypted with
+// AES-256-GCM under a data key; the data key is stored next to it, wrapped
+// by a key-encryption key that never leaves its KeyWrapper (a local key file,
+// or a KMS).
+//
+// Blobs that were written before sealing was enabled are read as they are,
+// so it can be turned on for an existing store; Reseal methods on the stores
+// then rewrite them. State kept only in memory (the audit log, the results
+// ring) is not sealed.
+package seal
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A sealed blob starts with this header. JSON never starts with 0x00, and
+// the event codecs' binary headers use other second bytes.
+const (
+	headerMagic = 0x00
+	headerID    = 0x7f
+	version     = 1
+)
+
+// Data keys are reused for a while so a KMS is not called per blob.
+const (
+	dataKeyTTL   = 10 * time.Minute
+	dataKeyUses  = 1 << 20
+	openCacheMax = 1024
+)
+
+// ErrNoKeys is returned when reading a sealed blob without a Sealer.
+var ErrNoKeys = errors.New("blob is sealed but no keys are configured")
+
+// KeyWrapper wraps and unwraps data keys with key-encryption keys. A KMS
+// integration implements it by calling the KMS's encrypt and decrypt APIs.
+type KeyWrapper interface {
+	// Primary is the ID of the key new data keys are wrapped with.
+	Primary() string
+	Wrap(ctx context.Context, dataKey []byte) ([]byte, error)
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Sealer seals and opens blobs. A nil *Sealer leaves new blobs in the clear
+// and fails on sealed ones, so stores can call it unconditionally.
+type Sealer struct {
+	kw KeyWrapper
+
+	mu      sync.Mutex
+	current *dataKey
+	opened  map[string]cipher.AEAD // keyID + wrapped data key → cipher
+}
+
+type dataKey struct {
+	keyID   string
+	wrapped []byte
+	aead    cipher.AEAD
+	expires time.Time
+	uses    int
+}
+
+// New returns a Sealer wrapping data keys with kw.
+func New(kw KeyWrapper) *Sealer {
+	return &Sealer{kw: kw, opened: make(map[string]cipher.AEAD)}
+}
+
+// Primary is the ID of the key new blobs are sealed under.
+func (s *Sealer) Primary() string {
+	if s == nil {
+		return ""
+	}
+	return s.kw.Primary()
+}
+
+// Seal encrypts plaintext.
+func (s *Sealer) Seal(ctx context.Context, plaintext []byte) ([]byte, error) {
+	if s == nil {
+		return plaintext, nil
+	}
+	dk, err := s.dataKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, dk.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+	out := make([]byte, 0, 6+len(dk.keyID)+len(dk.wrapped)+len(nonce)+len(plaintext)+dk.aead.Overhead())
+	out = append(out, headerMagic, headerID, version, byte(len(dk.keyID)))
+	out = append(out, dk.keyID...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(dk.wrapped)))
+	out = append(out, dk.wrapped...)
+	out = append(out, nonce...)
+	// The header is authenticated, so a blob cannot be moved to another key.
+	// Seal must not write over its additional data, hence the copy.
+	aad := append([]byte(nil), out[:len(out)-len(nonce)]...)
+	return dk.aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+// Open decrypts a blob written by Seal. Other blobs are returned unchanged.
+func (s *Sealer) Open(ctx context.Context, blob []byte) ([]byte, error) {
+	if !IsSealed(blob) {
+		return blob, nil
+	}
+	if s == nil {
+		return nil, ErrNoKeys
+	}
+	h, err := parseHeader(blob)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := s.openKey(ctx, h.keyID, h.wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if len(blob) < h.size+aead.NonceSize() {
+		return nil, errors.New("seal: truncated blob")
+	}
+	nonce := blob[h.size : h.size+aead.NonceSize()]
+	plain, err := aead.Open(nil, nonce, blob[h.size+aead.NonceSize():], blob[:h.size])
+	if err != nil {
+		return nil, fmt.Errorf("seal: open with key %s: %w", h.keyID, err)
+	}
+	return plain, nil
+}
+
+// Current reports whether blob is sealed under the primary key, i.e. whether
+// a reseal would leave it as it is. With a nil Sealer, unsealed blobs are
+// current.
+func (s *Sealer) Current(blob []byte) bool {
+	if s == nil {
+		return !IsSealed(blob)
+	}
+	h, err := parseHeader(blob)
+	return err == nil && h.keyID == s.kw.Primary()
+}
+
+// IsSealed reports whether blob was written by Seal.
+func IsSealed(blob []byte) bool {
+	return len(blob) >= 3 && blob[0] == headerMagic && blob[1] == headerID
+}
+
+// KeyID returns the ID of the key blob is sealed under, or "" if it is not sealed.
+func KeyID(blob []byte) string {
+	h, err := parseHeader(blob)
+	if err != nil {
+		return ""
+	}
+	return h.keyID
+}
+
+type header struct {
+	keyID   string
+	wrapped []byte
+	size    int // header length in bytes
+}
+
+func parseHeader(blob []byte) (header, error) {
+	if !IsSealed(blob) {
+		return header{}, errors.New("seal: not a sealed blob")
+	}
+	if blob[2] != version {
+		return header{}, fmt.Errorf("seal: unknown version %d", blob[2])
+	}
+	if len(blob) < 4 {
+		return header{}, errors.New("seal: truncated blob")
+	}
+	n := int(blob[3])
+	if len(blob) < 4+n+2 {
+		return header{}, errors.New("seal: truncated blob")
+	}
+	h := header{keyID: string(blob[4 : 4+n])}
+	w := int(binary.BigEndian.Uint16(blob[4+n:]))
+	h.size = 4 + n + 2 + w
+	if len(blob) < h.size {
+		return header{}, errors.New("seal: truncated blob")
+	}
+	h.wrapped = blob[4+n+2 : h.size]
+	return h, nil
+}
+
+// dataKey returns the data key for new blobs, generating and wrapping a new
+// one when the current one is old, used up, or under a retired primary.
+func (s *Sealer) dataKey(ctx context.Context) (*dataKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	primary := s.kw.Primary()
+	if dk := s.current; dk != nil && dk.keyID == primary && dk.uses < dataKeyUses && time.Now().Before(dk.expires) {
+		dk.uses++
+		return dk, nil
+	}
+	if len(primary) > 255 {
+		return nil, fmt.Errorf("seal: key ID %q is longer than 255 bytes", primary)
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+	wrapped, err := s.kw.Wrap(ctx, raw)
+	if err != nil {
+		return nil, fmt.Errorf("seal: wrap data key with %s: %w", primary, err)
+	}
+	if len(wrapped) > 0xffff {
+		return nil, fmt.Errorf("seal: wrapped data key is %d bytes", len(wrapped))
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	s.current = &dataKey{keyID: primary, wrapped: wrapped, aead: aead, expires: time.Now().Add(dataKeyTTL), uses: 1}
+	return s.current, nil
+}
+
+// openKey unwraps (and caches) the data key of a blob.
+func (s *Sealer) openKey(ctx context.Context, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	cacheKey := keyID + "\x00" + string(wrapped)
+	s.mu.Lock()
+	aead, ok := s.opened[cacheKey]
+	s.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+	raw, err := s.kw.Unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("seal: unwrap data key with %s: %w", keyID, err)
+	}
+	if aead, err = newAEAD(raw); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	if len(s.opened) >= openCacheMax {
+		clear(s.opened)
+	}
+	s.opened[cacheKey] = aead
+	s.mu.Unlock()
+	return aead, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("seal: %w", err)
+	}
+	return aead, nil
+}
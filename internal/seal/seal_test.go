@@ -0,0 +1,103 @@
+package seal
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func testKeys(t *testing.T, primary string, ids ...string) *LocalKeys {
+	t.Helper()
+	f := KeyFile{Primary: primary}
+	for _, id := range ids {
+		e, err := GenerateKey(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Keys = append(f.Keys, e)
+	}
+	lk, err := NewLocalKeys(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lk
+}
+
+func TestSealOpen(t *testing.T) {
+	ctx := context.Background()
+	s := New(testKeys(t, "k1", "k1"))
+	plain := []byte(`{"id":"e1","payload":{"email":"a@example.com"}}`)
+
+	blob, err := s.Seal(ctx, plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(blob) || bytes.Contains(blob, []byte("example.com")) {
+		t.Fatalf("blob is not sealed: %q", blob)
+	}
+	got, err := s.Open(ctx, blob)
+	if err != nil || !bytes.Equal(got, plain) {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	// Blobs written before sealing was enabled read as they are.
+	if got, err := s.Open(ctx, plain); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("Open(unsealed) = %q, %v", got, err)
+	}
+	var none *Sealer
+	if _, err := none.Open(ctx, blob); !errors.Is(err, ErrNoKeys) {
+		t.Errorf("nil Sealer Open = %v, want ErrNoKeys", err)
+	}
+
+	blob[len(blob)-1] ^= 1
+	if _, err := s.Open(ctx, blob); err == nil {
+		t.Error("tampered blob opened")
+	}
+}
+
+func TestRotation(t *testing.T) {
+	ctx := context.Background()
+	k1 := testKeys(t, "k1", "k1")
+	old, err := New(k1).Seal(ctx, []byte("before rotation"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// k2 becomes primary; k1 is kept to read old blobs.
+	rotated := testKeys(t, "k2", "k2")
+	rotated.keys["k1"] = k1.keys["k1"]
+	s := New(rotated)
+	if s.Current(old) {
+		t.Error("a blob under k1 is current after rotating to k2")
+	}
+	got, err := s.Open(ctx, old)
+	if err != nil || string(got) != "before rotation" {
+		t.Fatalf("Open(old) = %q, %v", got, err)
+	}
+	blob, err := s.Seal(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(blob) != "k2" || !s.Current(blob) {
+		t.Errorf("resealed blob is under %q", KeyID(blob))
+	}
+
+	delete(rotated.keys, "k1")
+	if _, err := New(rotated).Open(ctx, old); err == nil {
+		t.Error("blob opened after its key was removed")
+	}
+}
+
+func TestNewLocalKeys_Validates(t *testing.T) {
+	good, _ := GenerateKey("k1")
+	for name, f := range map[string]KeyFile{
+		"missing primary": {Primary: "k2", Keys: []KeyEntry{good}},
+		"short key":       {Primary: "k1", Keys: []KeyEntry{{ID: "k1", Key: "c2hvcnQ="}}},
+		"duplicate":       {Primary: "k1", Keys: []KeyEntry{good, good}},
+	} {
+		if _, err := NewLocalKeys(f); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
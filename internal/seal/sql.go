@@ -0,0 +1,73 @@
+package seal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+const resealBatch = 500
+
+// ResealTable rewrites the body of every row of table (which has a TEXT id
+// and a BLOB body column) that is not sealed under s's primary key, and
+// returns how many rows it rewrote: rows written before sealing was enabled
+// and rows sealed under a retired key.
+//
+// Rows are rewritten only if unchanged since they were read, so it can run
+// while the store is in use.
+func ResealTable(ctx context.Context, db *sql.DB, s *Sealer, table string) (int, error) {
+	type row struct {
+		id   string
+		body []byte
+	}
+	n, after := 0, ""
+	for {
+		// Stores hold a single connection, so a batch is read in full
+		// before any of it is written.
+		rows, err := db.QueryContext(ctx,
+			`SELECT id, body FROM `+table+` WHERE id > ? ORDER BY id LIMIT ?`, after, resealBatch)
+		if err != nil {
+			return n, fmt.Errorf("%s reseal: %w", table, err)
+		}
+		var stale []row
+		read := 0
+		for rows.Next() {
+			var r row
+			if err := rows.Scan(&r.id, &r.body); err != nil {
+				rows.Close()
+				return n, fmt.Errorf("%s reseal: %w", table, err)
+			}
+			read++
+			after = r.id
+			if !s.Current(r.body) {
+				stale = append(stale, r)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return n, fmt.Errorf("%s reseal: %w", table, err)
+		}
+
+		for _, r := range stale {
+			plain, err := s.Open(ctx, r.body)
+			if err != nil {
+				return n, fmt.Errorf("%s reseal %s: %w", table, r.id, err)
+			}
+			body, err := s.Seal(ctx, plain)
+			if err != nil {
+				return n, fmt.Errorf("%s reseal %s: %w", table, r.id, err)
+			}
+			res, err := db.ExecContext(ctx,
+				`UPDATE `+table+` SET body = ? WHERE id = ? AND body = ?`, body, r.id, r.body)
+			if err != nil {
+				return n, fmt.Errorf("%s reseal %s: %w", table, r.id, err)
+			}
+			if k, _ := res.RowsAffected(); k > 0 {
+				n++
+			}
+		}
+		if read < resealBatch {
+			return n, nil
+		}
+	}
+}
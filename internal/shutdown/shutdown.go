@@ -0,0 +1,102 @@
+// Package shutdown stops the server in ordered stages. Each stage gets its
+// own timeout and reports what it had to leave behind, so a slow stage cannot
+// eat the budget of the ones after it and the final log says exactly what
+// was abandoned.
+package shutdown
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Stage is one step of the shutdown.
+type Stage struct {
+	Name    string
+	Timeout time.Duration
+	// Run stops the stage's work and returns how many units (events,
+	// requests, …) it left unfinished. It must return once ctx is done.
+	Run func(ctx context.Context) (left int, err error)
+}
+
+// Result is the outcome of one stage.
+type Result struct {
+	Stage    string
+	Duration time.Duration
+	Left     int
+	TimedOut bool
+	Err      error
+}
+
+// Run runs stages in order, each under its own timeout; a stage that fails
+// or times out does not stop the ones after it. Every stage is logged as it
+// ends.
+func Run(stages ...Stage) []Result {
+	results := make([]Result, 0, len(stages))
+	for _, s := range stages {
+		ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+		start := time.Now()
+		left, err := s.Run(ctx)
+		r := Result{
+			Stage:    s.Name,
+			Duration: time.Since(start),
+			Left:     left,
+			TimedOut: ctx.Err() != nil,
+			Err:      err,
+		}
+		cancel()
+		results = append(results, r)
+
+		args := []any{"stage", r.Stage, "duration_ms", r.Duration.Milliseconds()}
+		switch {
+		case r.TimedOut:
+			slog.Warn("shutdown stage timed out", append(args, "timeout", s.Timeout, "left", r.Left)...)
+		case r.Err != nil:
+			slog.Warn("shutdown stage failed", append(args, "left", r.Left, "err", r.Err)...)
+		default:
+			slog.Info("shutdown stage done", args...)
+		}
+	}
+	return results
+}
+
+// Clean reports whether every stage finished in time with nothing left.
+func Clean(results []Result) bool {
+	for _, r := range results {
+		if r.TimedOut || r.Err != nil || r.Left > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Group tracks goroutines so a stage can wait for them to return.
+type Group struct {
+	wg sync.WaitGroup
+}
+
+// Go runs fn in a new goroutine.
+func (g *Group) Go(fn func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn()
+	}()
+}
+
+// Wait blocks until every goroutine started with Go has returned or ctx is
+// done.
+func (g *Group) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,62 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun_OrderAndPerStageTimeouts(t *testing.T) {
+	var order []string
+	stage := func(name string, timeout time.Duration, run func(ctx context.Context) (int, error)) Stage {
+		return Stage{Name: name, Timeout: timeout, Run: func(ctx context.Context) (int, error) {
+			order = append(order, name)
+			return run(ctx)
+		}}
+	}
+	results := Run(
+		stage("sources", time.Second, func(context.Context) (int, error) { return 0, nil }),
+		stage("queue", 20*time.Millisecond, func(ctx context.Context) (int, error) {
+			<-ctx.Done()
+			return 3, ctx.Err()
+		}),
+		stage("actions", time.Second, func(ctx context.Context) (int, error) {
+			// A timed-out stage must not shorten the next one's budget.
+			if d, ok := ctx.Deadline(); !ok || time.Until(d) < 500*time.Millisecond {
+				t.Errorf("actions stage deadline = %v", d)
+			}
+			return 0, nil
+		}),
+		stage("sinks", time.Second, func(context.Context) (int, error) { return 0, errors.New("close failed") }),
+	)
+
+	if len(order) != 4 || order[0] != "sources" || order[3] != "sinks" {
+		t.Fatalf("stages ran as %v", order)
+	}
+	if r := results[1]; !r.TimedOut || r.Left != 3 {
+		t.Errorf("queue result = %+v, want timed out with 3 left", r)
+	}
+	if results[0].TimedOut || results[2].TimedOut || results[3].Err == nil {
+		t.Errorf("results = %+v", results)
+	}
+	if Clean(results) || !Clean(results[:1]) {
+		t.Error("Clean must be false only when a stage left work behind")
+	}
+}
+
+func TestGroup_Wait(t *testing.T) {
+	var g Group
+	release := make(chan struct{})
+	g.Go(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := g.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, want deadline exceeded while a goroutine runs", err)
+	}
+	close(release)
+	if err := g.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait = %v", err)
+	}
+}
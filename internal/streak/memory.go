@@ -0,0 +1,83 @@
+package streak
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type key struct{ actorID, eventType string }
+
+// MemoryStore is a process-local Store. Streaks are lost on restart, so it
+// suits tests and deployments without -streak-store. It holds every streak
+// until Purge drops it, which the server does after
+// engine.streak_retention_ms.
+type MemoryStore struct {
+	mu      sync.Mutex
+	streaks map[key]Streak
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{streaks: make(map[key]Streak)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, actorID, eventType string) (*Streak, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.streaks[key{actorID, eventType}]
+	if !ok {
+		return nil, nil
+	}
+	return &st, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, st *Streak) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streaks[key{st.ActorID, st.EventType}] = *st
+	return nil
+}
+
+func (s *MemoryStore) ForActor(_ context.Context, actorID string) ([]*Streak, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Streak
+	for k, st := range s.streaks {
+		if k.actorID == actorID {
+			cp := st
+			out = append(out, &cp)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].EventType < out[j].EventType })
+	return out, nil
+}
+
+func (s *MemoryStore) Actors(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := make(map[string]struct{})
+	var out []string
+	for k := range s.streaks {
+		if _, ok := seen[k.actorID]; !ok {
+			seen[k.actorID] = struct{}{}
+			out = append(out, k.actorID)
+		}
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func (s *MemoryStore) Purge(_ context.Context, before time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for k, st := range s.streaks {
+		if st.UpdatedAt.Before(before) {
+			delete(s.streaks, k)
+			n++
+		}
+	}
+	return n, nil
+}
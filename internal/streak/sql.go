@@ -0,0 +1,124 @@
+package streak
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS streaks (
+	actor_id   TEXT    NOT NULL,
+	event_type TEXT    NOT NULL,
+	current    INTEGER NOT NULL,
+	best       INTEGER NOT NULL,
+	last_day   TEXT    NOT NULL,
+	updated_at INTEGER NOT NULL,
+	PRIMARY KEY (actor_id, event_type)
+)`,
+}
+
+// SQLStore keeps streaks in a single SQL table, so they survive restarts and
+// can be shared by replicas.
+type SQLStore struct {
+	db *sql.DB
+}
+
+// OpenSQLStore opens (creating if needed) a store at dsn using a database/sql
+// driver registered under driverName, e.g. "sqlite3" from github.com/mattn/go-sqlite3.
+func OpenSQLStore(driverName, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("streak store open: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("streak store schema: %w", err)
+		}
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Get(ctx context.Context, actorID, eventType string) (*Streak, error) {
+	out, err := s.query(ctx, `SELECT actor_id, event_type, current, best, last_day, updated_at FROM streaks WHERE actor_id = ? AND event_type = ?`,
+		actorID, eventType)
+	if err != nil || len(out) == 0 {
+		return nil, err
+	}
+	return out[0], nil
+}
+
+func (s *SQLStore) Save(ctx context.Context, st *Streak) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO streaks (actor_id, event_type, current, best, last_day, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		st.ActorID, st.EventType, st.Current, st.Best, st.LastDay, st.UpdatedAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("streak save %s/%s: %w", st.ActorID, st.EventType, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) ForActor(ctx context.Context, actorID string) ([]*Streak, error) {
+	return s.query(ctx, `SELECT actor_id, event_type, current, best, last_day, updated_at FROM streaks WHERE actor_id = ? ORDER BY event_type`, actorID)
+}
+
+func (s *SQLStore) Actors(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT actor_id FROM streaks ORDER BY actor_id`)
+	if err != nil {
+		return nil, fmt.Errorf("streak actors: %w", err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("streak actors: %w", err)
+		}
+		out = append(out, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("streak actors: %w", err)
+	}
+	return out, nil
+}
+
+func (s *SQLStore) Purge(ctx context.Context, before time.Time) (int, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM streaks WHERE updated_at < ?`, before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("streak purge: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func (s *SQLStore) query(ctx context.Context, q string, args ...interface{}) ([]*Streak, error) {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("streak query: %w", err)
+	}
+	defer rows.Close()
+	var out []*Streak
+	for rows.Next() {
+		var (
+			st      Streak
+			updated int64
+		)
+		if err := rows.Scan(&st.ActorID, &st.EventType, &st.Current, &st.Best, &st.LastDay, &updated); err != nil {
+			return nil, fmt.Errorf("streak query: %w", err)
+		}
+		st.UpdatedAt = time.Unix(0, updated).UTC()
+		out = append(out, &st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("streak query: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,150 @@
+// Package streak keeps per-actor streaks: the number of consecutive calendar
+// days on which an actor sent at least one event of a given type. The engine
+// advances a streak for every event of a type the active rules read with
+// streak("type"), using the day in the actor's time zone.
+package streak
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// DayLayout is the format of Streak.LastDay.
+const DayLayout = "2006-01-02"
+
+// Streak is one actor's streak for one event type.
+type Streak struct {
+	ActorID   string    `json:"actor_id"`
+	EventType string    `json:"event_type"`
+	Current   int       `json:"current"`  // days in the streak ending on LastDay
+	Best      int       `json:"best"`     // longest streak seen
+	LastDay   string    `json:"last_day"` // YYYY-MM-DD of the latest counted event
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Day returns the calendar day of t in loc, as stored in LastDay.
+func Day(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(DayLayout)
+}
+
+// daysBetween returns the number of days from a to b, or false when either
+// is not a valid day.
+func daysBetween(a, b string) (int, bool) {
+	ta, err := time.Parse(DayLayout, a)
+	if err != nil {
+		return 0, false
+	}
+	tb, err := time.Parse(DayLayout, b)
+	if err != nil {
+		return 0, false
+	}
+	return int(tb.Sub(ta).Hours() / 24), true
+}
+
+// Advance returns s after an event on day. A second event on the same day
+// changes nothing, the next day extends the streak and a later one starts a
+// new streak of 1. Events dated before LastDay arrived late and are ignored.
+func (s Streak) Advance(day string) Streak {
+	gap, ok := daysBetween(s.LastDay, day)
+	switch {
+	case ok && gap <= 0:
+		return s
+	case ok && gap == 1 && s.Current > 0:
+		s.Current++
+	default:
+		s.Current = 1
+	}
+	s.LastDay = day
+	if s.Current > s.Best {
+		s.Best = s.Current
+	}
+	return s
+}
+
+// Length returns the streak as seen on day: Current while it is still alive,
+// that is while its last event was on day or the day before, and 0 once a day
+// has been missed.
+func (s Streak) Length(day string) int {
+	gap, ok := daysBetween(s.LastDay, day)
+	if !ok || gap > 1 {
+		return 0
+	}
+	return s.Current
+}
+
+// Store persists streaks.
+type Store interface {
+	// Get returns actorID's streak for eventType, or nil when there is none.
+	Get(ctx context.Context, actorID, eventType string) (*Streak, error)
+	// Save stores s, replacing the streak of the same actor and event type.
+	Save(ctx context.Context, s *Streak) error
+	// ForActor returns actorID's streaks ordered by event type.
+	ForActor(ctx context.Context, actorID string) ([]*Streak, error)
+	// Actors returns the IDs of actors with streaks.
+	Actors(ctx context.Context) ([]string, error)
+	// Purge deletes the streaks last updated before before and returns how
+	// many it deleted.
+	Purge(ctx context.Context, before time.Time) (int, error)
+}
+
+// Tracker advances streaks in a Store. Updates of one actor are serialized
+// within the process; replicas sharing a store can race on the same actor
+// and day, which at worst counts that day once instead of twice.
+type Tracker struct {
+	store Store
+	locks [64]sync.Mutex
+}
+
+// NewTracker creates a Tracker over store.
+func NewTracker(store Store) *Tracker {
+	return &Tracker{store: store}
+}
+
+// Store returns the store the tracker writes to.
+func (t *Tracker) Store() Store {
+	return t.store
+}
+
+func (t *Tracker) lock(actorID string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(actorID))
+	return &t.locks[h.Sum32()%uint32(len(t.locks))]
+}
+
+// Record advances actorID's streak for eventType to day and saves it when it
+// changed. It returns the streak after the update.
+func (t *Tracker) Record(ctx context.Context, actorID, eventType, day string, now time.Time) (Streak, error) {
+	mu := t.lock(actorID)
+	mu.Lock()
+	defer mu.Unlock()
+	cur, err := t.store.Get(ctx, actorID, eventType)
+	if err != nil {
+		return Streak{}, err
+	}
+	if cur == nil {
+		cur = &Streak{ActorID: actorID, EventType: eventType}
+	}
+	next := cur.Advance(day)
+	if next == *cur {
+		return next, nil
+	}
+	next.UpdatedAt = now.UTC()
+	if err := t.store.Save(ctx, &next); err != nil {
+		return Streak{}, err
+	}
+	return next, nil
+}
+
+// Get returns actorID's streak for eventType, zero when there is none.
+func (t *Tracker) Get(ctx context.Context, actorID, eventType string) (Streak, error) {
+	cur, err := t.store.Get(ctx, actorID, eventType)
+	if err != nil || cur == nil {
+		return Streak{ActorID: actorID, EventType: eventType}, err
+	}
+	return *cur, nil
+}
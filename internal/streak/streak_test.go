@@ -0,0 +1,109 @@
+package streak
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAdvance(t *testing.T) {
+	var s Streak
+	steps := []struct {
+		day           string
+		current, best int
+	}{
+		{"2026-03-01", 1, 1},
+		{"2026-03-01", 1, 1}, // same day
+		{"2026-03-02", 2, 2},
+		{"2026-03-03", 3, 3},
+		{"2026-03-02", 3, 3}, // late event
+		{"2026-03-05", 1, 3}, // missed a day
+		{"2026-03-06", 2, 3},
+		{"2026-03-31", 1, 3},
+		{"2026-04-01", 2, 3}, // across a month
+	}
+	for _, st := range steps {
+		s = s.Advance(st.day)
+		if s.Current != st.current || s.Best != st.best {
+			t.Fatalf("after %s: current %d best %d, want %d and %d", st.day, s.Current, s.Best, st.current, st.best)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	s := Streak{Current: 4, Best: 4, LastDay: "2026-03-10"}
+	for day, want := range map[string]int{
+		"2026-03-10": 4,
+		"2026-03-11": 4, // still alive until the day ends
+		"2026-03-12": 0,
+	} {
+		if got := s.Length(day); got != want {
+			t.Errorf("Length(%s) = %d, want %d", day, got, want)
+		}
+	}
+	if got := (Streak{}).Length("2026-03-10"); got != 0 {
+		t.Errorf("empty streak Length = %d, want 0", got)
+	}
+}
+
+func TestDay_UsesLocation(t *testing.T) {
+	at := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("tzdata not available")
+	}
+	if got := Day(at, time.UTC); got != "2026-03-01" {
+		t.Errorf("UTC day = %s", got)
+	}
+	if got := Day(at, kolkata); got != "2026-03-02" {
+		t.Errorf("Asia/Kolkata day = %s, want 2026-03-02", got)
+	}
+}
+
+func TestTracker_Record(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	tr := NewTracker(store)
+	now := time.Now()
+	for _, day := range []string{"2026-03-01", "2026-03-02", "2026-03-02"} {
+		if _, err := tr.Record(ctx, "u1", "login", day, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tr.Record(ctx, "u2", "purchase", "2026-03-02", now); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.Get(ctx, "u1", "login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Current != 2 || got.LastDay != "2026-03-02" {
+		t.Errorf("u1 login = %+v", got)
+	}
+	if none, _ := tr.Get(ctx, "u1", "purchase"); none.Current != 0 {
+		t.Errorf("u1 purchase = %+v, want zero", none)
+	}
+	actors, _ := store.Actors(ctx)
+	if len(actors) != 2 || actors[0] != "u1" || actors[1] != "u2" {
+		t.Errorf("Actors = %v", actors)
+	}
+}
+
+func TestMemoryStore_Purge(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	tr := NewTracker(store)
+	now := time.Now()
+	if _, err := tr.Record(ctx, "u1", "login", "2026-01-01", now.Add(-100*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tr.Record(ctx, "u2", "login", "2026-04-10", now); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := store.Purge(ctx, now.Add(-90*24*time.Hour)); n != 1 || err != nil {
+		t.Fatalf("Purge = %d, %v; want the stale streak purged", n, err)
+	}
+	if actors, _ := store.Actors(ctx); len(actors) != 1 || actors[0] != "u2" {
+		t.Errorf("Actors after Purge = %v, want [u2]", actors)
+	}
+}
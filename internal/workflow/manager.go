@@ -0,0 +1,357 @@
+// Package workflow runs lightweight sagas: ordered steps that each wait for a
+// later event from the same actor, with per-step timeouts and compensation
+// actions that undo completed steps when the saga cannot finish.
+package workflow
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+	"github.com/gyaneshwarpardhi/ifttt/internal/logctx"
+	"github.com/gyaneshwarpardhi/ifttt/internal/metrics"
+	"github.com/gyaneshwarpardhi/ifttt/pkg/condition"
+)
+
+// StartActionType is the action type that starts a workflow from a scenario.
+const StartActionType = config.StartWorkflowType
+
+const sweepInterval = time.Second
+
+// lockStripes is how many locks actors are hashed onto.
+const lockStripes = 64
+
+type step struct {
+	config.WorkflowStep
+	await   map[string]bool
+	cond    condition.Expr // nil = any awaited event
+	timeout time.Duration
+}
+
+type definition struct {
+	id    string
+	steps []step
+}
+
+// Manager starts, advances and times out workflow instances.
+type Manager struct {
+	store Store
+	reg   *action.Registry
+	defs  atomic.Pointer[map[string]*definition]
+	locks [lockStripes]sync.Mutex // serialise transitions per actor
+	now   func() time.Time
+}
+
+// NewManager creates a Manager that runs step actions through reg.
+func NewManager(store Store, reg *action.Registry) *Manager {
+	m := &Manager{store: store, reg: reg, now: time.Now}
+	m.defs.Store(&map[string]*definition{})
+	return m
+}
+
+// Load compiles defs and replaces the active definitions. Running instances
+// of a workflow that no longer exists are left untouched until it returns.
+func (m *Manager) Load(defs []config.WorkflowDef) error {
+	out := make(map[string]*definition, len(defs))
+	for _, wd := range defs {
+		d := &definition{id: wd.ID}
+		for _, ws := range wd.Steps {
+			if err := m.validateActions(ws); err != nil {
+				return fmt.Errorf("workflow %s step %s: %w", wd.ID, ws.ID, err)
+			}
+			st := step{WorkflowStep: ws, await: make(map[string]bool, len(ws.Await))}
+			for _, t := range ws.Await {
+				st.await[t] = true
+			}
+			if ws.Condition != "" {
+				expr, err := condition.Parse(ws.Condition)
+				if err != nil {
+					return fmt.Errorf("workflow %s step %s: %w", wd.ID, ws.ID, err)
+				}
+				st.cond = expr
+			}
+			st.timeout = ws.TimeoutMs.Duration()
+			d.steps = append(d.steps, st)
+		}
+		out[wd.ID] = d
+	}
+	m.defs.Store(&out)
+	return nil
+}
+
+// validateActions checks the params of a step's actions and compensations.
+// A step may not start a workflow: the step runs with its actor locked.
+func (m *Manager) validateActions(ws config.WorkflowStep) error {
+	for _, list := range [][]config.ActionDef{ws.Actions, ws.Compensate} {
+		for _, a := range list {
+			if a.Type == StartActionType {
+				return fmt.Errorf("action %s: %s is not allowed in a workflow step", a.ID, StartActionType)
+			}
+			exec, err := m.reg.Get(a.Type)
+			if err != nil {
+				return fmt.Errorf("action %s: %w", a.ID, err)
+			}
+			if err := exec.Validate(a.Params); err != nil {
+				return fmt.Errorf("action %s: %w", a.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Manager) definition(id string) *definition {
+	return (*m.defs.Load())[id]
+}
+
+// lock locks actorID's stripe and returns its unlock. Transitions of one
+// actor's instances are serialised; other actors' run in parallel.
+func (m *Manager) lock(actorID string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(actorID))
+	mu := &m.locks[h.Sum32()%lockStripes]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Start creates an instance of workflowID for the event's actor and runs
+// every leading step that does not await an event.
+func (m *Manager) Start(ctx context.Context, workflowID string, ev *event.Event) (*Instance, error) {
+	def := m.definition(workflowID)
+	if def == nil {
+		return nil, fmt.Errorf("workflow: unknown workflow %q", workflowID)
+	}
+	now := m.now()
+	inst := &Instance{
+		ID:         uuid.New().String(),
+		WorkflowID: workflowID,
+		ActorID:    ev.ActorID,
+		Step:       -1,
+		Status:     StatusRunning,
+		StartedAt:  now,
+		Event:      ev,
+	}
+	metrics.WorkflowTransitions.WithLabelValues(workflowID, string(StatusRunning)).Inc()
+
+	defer m.lock(ev.ActorID)()
+	m.advance(ctx, def, inst, ev)
+	return inst, m.store.Save(ctx, inst)
+}
+
+// Observe advances every running instance of ev's actor whose current step
+// awaits ev. It is intended to be registered as an engine event hook.
+func (m *Manager) Observe(ctx context.Context, ev *event.Event) {
+	if ev.ActorID == "" {
+		return
+	}
+	ctx = logctx.With(ctx, "event_id", ev.ID)
+	defer m.lock(ev.ActorID)()
+	running, err := m.store.Running(ctx, ev.ActorID)
+	if err != nil {
+		logctx.From(ctx).Warn("workflow lookup failed", "actor_id", ev.ActorID, "err", err)
+		return
+	}
+	for _, inst := range running {
+		// The event that started or last advanced the saga cannot satisfy the next step.
+		if inst.Event != nil && inst.Event.ID == ev.ID {
+			continue
+		}
+		def := m.definition(inst.WorkflowID)
+		if def == nil || inst.Step < 0 || inst.Step >= len(def.steps) || !def.steps[inst.Step].matches(ev) {
+			continue
+		}
+		inst.Event = ev
+		if m.runStep(ctx, def, inst, ev) {
+			m.advance(ctx, def, inst, ev)
+		}
+		if err := m.store.Save(ctx, inst); err != nil {
+			logctx.From(ctx).Warn("workflow save failed", "workflow_id", inst.WorkflowID, "instance_id", inst.ID, "err", err)
+		}
+	}
+}
+
+// Sweep times out instances whose current step deadline has passed.
+func (m *Manager) Sweep(ctx context.Context) {
+	due, err := m.store.Due(ctx, m.now())
+	if err != nil {
+		logctx.From(ctx).Warn("workflow sweep failed", "err", err)
+		return
+	}
+	for _, inst := range due {
+		m.timeout(ctx, inst)
+	}
+}
+
+// timeout compensates inst if, with its actor locked, it is still running
+// and past its deadline: an event may have advanced it since Due read it.
+func (m *Manager) timeout(ctx context.Context, inst *Instance) {
+	defer m.lock(inst.ActorID)()
+	running, err := m.store.Running(ctx, inst.ActorID)
+	if err != nil {
+		logctx.From(ctx).Warn("workflow sweep failed", "actor_id", inst.ActorID, "err", err)
+		return
+	}
+	idx := slices.IndexFunc(running, func(r *Instance) bool { return r.ID == inst.ID })
+	if idx < 0 {
+		return
+	}
+	inst = running[idx]
+	def := m.definition(inst.WorkflowID)
+	if def == nil || inst.Step >= len(def.steps) || inst.Deadline.IsZero() || inst.Deadline.After(m.now()) {
+		return
+	}
+	inst.Error = fmt.Sprintf("step %s timed out", def.steps[inst.Step].ID)
+	m.compensate(ctx, def, inst, StatusTimedOut)
+	if err := m.store.Save(ctx, inst); err != nil {
+		logctx.From(ctx).Warn("workflow save failed", "workflow_id", inst.WorkflowID, "instance_id", inst.ID, "err", err)
+	}
+}
+
+// Run sweeps for timed-out instances until ctx is cancelled.
+func (m *Manager) Run(ctx context.Context) {
+	t := time.NewTicker(sweepInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			m.Sweep(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// advance moves inst past its current step, running steps that await
+// nothing, until it reaches an awaiting step or the end.
+func (m *Manager) advance(ctx context.Context, def *definition, inst *Instance, ev *event.Event) {
+	for inst.Step++; inst.Step < len(def.steps); inst.Step++ {
+		st := def.steps[inst.Step]
+		if len(st.await) > 0 {
+			inst.Deadline = time.Time{}
+			if st.timeout > 0 {
+				inst.Deadline = m.now().Add(st.timeout)
+			}
+			inst.UpdatedAt = m.now()
+			return
+		}
+		if !m.runStep(ctx, def, inst, ev) {
+			return
+		}
+	}
+	inst.Status = StatusCompleted
+	inst.Deadline = time.Time{}
+	inst.UpdatedAt = m.now()
+	metrics.WorkflowTransitions.WithLabelValues(def.id, string(StatusCompleted)).Inc()
+}
+
+// runStep executes the current step's actions. On failure it compensates the
+// completed steps and reports false.
+func (m *Manager) runStep(ctx context.Context, def *definition, inst *Instance, ev *event.Event) bool {
+	st := def.steps[inst.Step]
+	if err := m.execute(ctx, st.Actions, ev); err != nil {
+		inst.Error = fmt.Sprintf("step %s: %v", st.ID, err)
+		m.compensate(ctx, def, inst, StatusFailed)
+		return false
+	}
+	return true
+}
+
+// compensate runs Compensate of the steps before inst.Step in reverse order
+// and finishes inst with status. The step that failed or timed out is not
+// compensated; its own actions are expected to be atomic.
+func (m *Manager) compensate(ctx context.Context, def *definition, inst *Instance, status Status) {
+	for i := inst.Step - 1; i >= 0; i-- {
+		if err := m.execute(ctx, def.steps[i].Compensate, inst.Event); err != nil {
+			logctx.From(ctx).Error("workflow compensation failed",
+				"workflow_id", def.id, "instance_id", inst.ID, "step", def.steps[i].ID, "err", err)
+		}
+	}
+	inst.Status = status
+	inst.Deadline = time.Time{}
+	inst.UpdatedAt = m.now()
+	metrics.WorkflowTransitions.WithLabelValues(def.id, string(status)).Inc()
+}
+
+// execute runs actions in order, stopping at the first failure.
+func (m *Manager) execute(ctx context.Context, actions []config.ActionDef, ev *event.Event) error {
+	evalCtx := &dag.EvalContext{Event: ev, Results: dag.NewResults(0)}
+	for _, a := range actions {
+		exec, err := m.reg.Get(a.Type)
+		if err != nil {
+			return err
+		}
+		res, err := exec.Execute(ctx, a.ID, a.Params, evalCtx)
+		if err != nil {
+			return fmt.Errorf("action %s: %w", a.ID, err)
+		}
+		if res != nil && !res.Success {
+			return fmt.Errorf("action %s: %s", a.ID, res.Message)
+		}
+	}
+	return nil
+}
+
+func (s step) matches(ev *event.Event) bool {
+	if !s.await[ev.Type] {
+		return false
+	}
+	if s.cond == nil {
+		return true
+	}
+	ok, err := condition.Evaluate(s.cond, &dag.EvalContext{Event: ev})
+	return err == nil && ok
+}
+
+// Executor returns the start_workflow action executor backed by m.
+//
+// Params:
+//   - workflow: id of the workflow to start for the event's actor
+func (m *Manager) Executor() action.Executor { return &startExecutor{m: m} }
+
+type startExecutor struct {
+	m *Manager
+}
+
+func (s *startExecutor) Type() string { return StartActionType }
+
+func (s *startExecutor) Validate(params map[string]interface{}) error {
+	id, _ := params["workflow"].(string)
+	if id == "" {
+		return fmt.Errorf("%s: workflow is required", StartActionType)
+	}
+	// Unknown ids are rejected by config.Validate, which sees the workflows
+	// of the same config; params are validated before they are loaded here.
+	return nil
+}
+
+func (s *startExecutor) Execute(
+	ctx context.Context,
+	actionID string,
+	params map[string]interface{},
+	evalCtx *dag.EvalContext,
+) (*action.ActionResult, error) {
+	id, _ := params["workflow"].(string)
+	inst, err := s.m.Start(ctx, id, evalCtx.Event)
+	if err != nil {
+		return &action.ActionResult{ActionID: actionID, Type: StartActionType, Success: false, Message: err.Error()}, err
+	}
+	evalCtx.Results.SetOutput(actionID, map[string]interface{}{
+		"instance_id": inst.ID,
+		"status":      string(inst.Status),
+	})
+	return &action.ActionResult{
+		ActionID: actionID,
+		Type:     StartActionType,
+		Success:  true,
+		Message:  fmt.Sprintf("workflow %s %s (%s)", id, inst.Status, inst.ID),
+	}, nil
+}
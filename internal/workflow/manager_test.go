@@ -0,0 +1,159 @@
+package workflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/action"
+	"github.com/gyaneshwarpardhi/ifttt/internal/config"
+	"github.com/gyaneshwarpardhi/ifttt/internal/dag"
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// recorder records the IDs of executed actions.
+type recorder struct{ ran []string }
+
+func (r *recorder) Type() string                                 { return "record" }
+func (r *recorder) Validate(params map[string]interface{}) error { return nil }
+func (r *recorder) Execute(ctx context.Context, id string, params map[string]interface{}, evalCtx *dag.EvalContext) (*action.ActionResult, error) {
+	r.ran = append(r.ran, id)
+	return &action.ActionResult{ActionID: id, Type: r.Type(), Success: true}, nil
+}
+
+func checkoutWorkflow() []config.WorkflowDef {
+	return []config.WorkflowDef{{
+		ID: "wf_checkout",
+		Steps: []config.WorkflowStep{
+			{
+				ID:         "reserve",
+				Actions:    []config.ActionDef{{ID: "act_reserve", Type: "record"}},
+				Compensate: []config.ActionDef{{ID: "act_release", Type: "record"}},
+			},
+			{
+				ID:        "pay",
+				Await:     []string{"payment"},
+				Condition: "payload.amount > 0",
+				TimeoutMs: config.Millis(time.Hour / time.Millisecond),
+				Actions:   []config.ActionDef{{ID: "act_confirm", Type: "record"}},
+			},
+		},
+	}}
+}
+
+func newTestManager(t *testing.T) (*Manager, *recorder) {
+	t.Helper()
+	rec := &recorder{}
+	reg := action.NewRegistry()
+	reg.Register(rec)
+	m := NewManager(NewMemoryStore(), reg)
+	if err := m.Load(checkoutWorkflow()); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return m, rec
+}
+
+func TestManager_CompletesOnAwaitedEvent(t *testing.T) {
+	m, rec := newTestManager(t)
+	ctx := context.Background()
+	start := &event.Event{ID: "e1", Type: "cart", ActorID: "u1"}
+	inst, err := m.Start(ctx, "wf_checkout", start)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if inst.Step != 1 || inst.Status != StatusRunning {
+		t.Fatalf("expected to await step 1, got step=%d status=%s", inst.Step, inst.Status)
+	}
+
+	m.Observe(ctx, &event.Event{ID: "e2", Type: "payment", ActorID: "u1", Payload: map[string]interface{}{"amount": float64(0)}})
+	m.Observe(ctx, &event.Event{ID: "e3", Type: "payment", ActorID: "u2", Payload: map[string]interface{}{"amount": float64(5)}})
+	m.Observe(ctx, &event.Event{ID: "e4", Type: "payment", ActorID: "u1", Payload: map[string]interface{}{"amount": float64(5)}})
+
+	if got := rec.ran; len(got) != 2 || got[0] != "act_reserve" || got[1] != "act_confirm" {
+		t.Errorf("expected [act_reserve act_confirm], got %v", got)
+	}
+	if running, _ := m.store.Running(ctx, "u1"); len(running) != 0 {
+		t.Errorf("expected no running instances after completion, got %d", len(running))
+	}
+}
+
+func TestManager_TimeoutCompensates(t *testing.T) {
+	m, rec := newTestManager(t)
+	ctx := context.Background()
+	if _, err := m.Start(ctx, "wf_checkout", &event.Event{ID: "e1", Type: "cart", ActorID: "u1"}); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	m.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	m.Sweep(ctx)
+
+	if got := rec.ran; len(got) != 2 || got[1] != "act_release" {
+		t.Errorf("expected reserve then release, got %v", got)
+	}
+	if running, _ := m.store.Running(ctx, "u1"); len(running) != 0 {
+		t.Errorf("timed-out instance should no longer be running, got %d", len(running))
+	}
+}
+
+func TestManager_LoadRejectsStepActions(t *testing.T) {
+	m, _ := newTestManager(t)
+	for name, a := range map[string]config.ActionDef{
+		"start_workflow": {ID: "act_nested", Type: StartActionType, Params: map[string]interface{}{"workflow": "wf_checkout"}},
+		"unknown type":   {ID: "act_unknown", Type: "missing"},
+	} {
+		defs := checkoutWorkflow()
+		defs[0].Steps[0].Actions = append(defs[0].Steps[0].Actions, a)
+		if err := m.Load(defs); err == nil {
+			t.Errorf("%s: Load accepted the step", name)
+		}
+	}
+}
+
+// blocker blocks actions of actor u1 until release is closed.
+type blocker struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blocker) Type() string                                 { return "block" }
+func (b *blocker) Validate(params map[string]interface{}) error { return nil }
+func (b *blocker) Execute(ctx context.Context, id string, params map[string]interface{}, evalCtx *dag.EvalContext) (*action.ActionResult, error) {
+	if evalCtx.Event.ActorID == "u1" {
+		close(b.started)
+		<-b.release
+	}
+	return &action.ActionResult{ActionID: id, Type: b.Type(), Success: true}, nil
+}
+
+func TestManager_ActorsDoNotBlockEachOther(t *testing.T) {
+	b := &blocker{started: make(chan struct{}), release: make(chan struct{})}
+	reg := action.NewRegistry()
+	reg.Register(b)
+	m := NewManager(NewMemoryStore(), reg)
+	err := m.Load([]config.WorkflowDef{{
+		ID:    "wf_slow",
+		Steps: []config.WorkflowStep{{ID: "call", Actions: []config.ActionDef{{ID: "act_call", Type: "block"}}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	go m.Start(ctx, "wf_slow", &event.Event{ID: "e1", ActorID: "u1"})
+	<-b.started
+	defer close(b.release)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := m.Start(ctx, "wf_slow", &event.Event{ID: "e2", ActorID: "u2"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("u2's workflow waited for u1's step action")
+	}
+}
@@ -0,0 +1,167 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/seal"
+)
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS workflows (
+	id          TEXT PRIMARY KEY,
+	actor_id    TEXT    NOT NULL,
+	status      TEXT    NOT NULL,
+	deadline    INTEGER NOT NULL DEFAULT 0,
+	body        BLOB    NOT NULL
+)`,
+	`CREATE INDEX IF NOT EXISTS workflows_running ON workflows (status, actor_id)`,
+}
+
+// SQLStore keeps instances in a single SQL table, including finished ones,
+// so saga outcomes survive restarts and can be inspected afterwards.
+type SQLStore struct {
+	db     *sql.DB
+	sealer *seal.Sealer
+}
+
+// OpenSQLStore opens (creating if needed) a store at dsn using a database/sql
+// driver registered under driverName, e.g. "sqlite3" from github.com/mattn/go-sqlite3.
+func OpenSQLStore(driverName, dsn string) (*SQLStore, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("workflow store open: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	for _, stmt := range schema {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("workflow store schema: %w", err)
+		}
+	}
+	return &SQLStore{db: db}, nil
+}
+
+// SetSealer encrypts new and updated instances with s (nil, the default,
+// stores them in the clear). Rows written in the clear are still read;
+// Reseal rewrites them.
+func (s *SQLStore) SetSealer(sl *seal.Sealer) {
+	s.sealer = sl
+}
+
+// Reseal rewrites instances not sealed under the primary key, and returns
+// how many it rewrote.
+func (s *SQLStore) Reseal(ctx context.Context) (int, error) {
+	return seal.ResealTable(ctx, s.db, s.sealer, "workflows")
+}
+
+// Close closes the underlying database.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) Save(ctx context.Context, inst *Instance) error {
+	body, err := json.Marshal(inst)
+	if err != nil {
+		return fmt.Errorf("workflow encode %s: %w", inst.ID, err)
+	}
+	if body, err = s.sealer.Seal(ctx, body); err != nil {
+		return fmt.Errorf("workflow seal %s: %w", inst.ID, err)
+	}
+	var deadline int64
+	if !inst.Deadline.IsZero() {
+		deadline = inst.Deadline.UnixNano()
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT OR REPLACE INTO workflows (id, actor_id, status, deadline, body) VALUES (?, ?, ?, ?, ?)`,
+		inst.ID, inst.ActorID, string(inst.Status), deadline, body)
+	if err != nil {
+		return fmt.Errorf("workflow save %s: %w", inst.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Running(ctx context.Context, actorID string) ([]*Instance, error) {
+	return s.query(ctx, `SELECT body FROM workflows WHERE status = ? AND actor_id = ?`,
+		string(StatusRunning), actorID)
+}
+
+func (s *SQLStore) Due(ctx context.Context, now time.Time) ([]*Instance, error) {
+	return s.query(ctx, `SELECT body FROM workflows WHERE status = ? AND deadline > 0 AND deadline <= ?`,
+		string(StatusRunning), now.UnixNano())
+}
+
+func (s *SQLStore) Actors(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT DISTINCT actor_id FROM workflows WHERE status = ?`, string(StatusRunning))
+	if err != nil {
+		return nil, fmt.Errorf("workflow actors: %w", err)
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("workflow actors: %w", err)
+		}
+		out = append(out, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("workflow actors: %w", err)
+	}
+	return out, nil
+}
+
+// PurgeFinished deletes finished instances of each workflow in before whose
+// last update is earlier than its cutoff, and returns how many it deleted.
+func (s *SQLStore) PurgeFinished(ctx context.Context, before map[string]time.Time) (int, error) {
+	if len(before) == 0 {
+		return 0, nil
+	}
+	finished, err := s.query(ctx, `SELECT body FROM workflows WHERE status != ?`, string(StatusRunning))
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, inst := range finished {
+		cutoff, ok := before[inst.WorkflowID]
+		if !ok || !inst.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM workflows WHERE id = ?`, inst.ID); err != nil {
+			return n, fmt.Errorf("workflow purge %s: %w", inst.ID, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *SQLStore) query(ctx context.Context, q string, args ...interface{}) ([]*Instance, error) {
+	rows, err := s.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("workflow query: %w", err)
+	}
+	defer rows.Close()
+	var out []*Instance
+	for rows.Next() {
+		var body []byte
+		if err := rows.Scan(&body); err != nil {
+			return nil, fmt.Errorf("workflow query: %w", err)
+		}
+		body, err := s.sealer.Open(ctx, body)
+		if err != nil {
+			return nil, fmt.Errorf("workflow open: %w", err)
+		}
+		var inst Instance
+		if err := json.Unmarshal(body, &inst); err != nil {
+			return nil, fmt.Errorf("workflow decode: %w", err)
+		}
+		out = append(out, &inst)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("workflow query: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,55 @@
+//go:build cgo
+
+package workflow
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+func TestSQLStore_RunningDuePurge(t *testing.T) {
+	st, err := OpenSQLStore("sqlite3", filepath.Join(t.TempDir(), "workflows.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	waiting := &Instance{ID: "w1", WorkflowID: "wf_checkout", ActorID: "u1", Step: 1, Status: StatusRunning,
+		Deadline: now.Add(time.Hour), Event: &event.Event{ID: "e1", Type: "cart", ActorID: "u1"}}
+	done := &Instance{ID: "w2", WorkflowID: "wf_checkout", ActorID: "u1", Status: StatusCompleted, UpdatedAt: now}
+	for _, inst := range []*Instance{waiting, done} {
+		if err := st.Save(ctx, inst); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	running, err := st.Running(ctx, "u1")
+	if err != nil || len(running) != 1 || running[0].ID != "w1" || running[0].Event.Type != "cart" {
+		t.Fatalf("Running = %+v, %v", running, err)
+	}
+	if due, _ := st.Due(ctx, now); len(due) != 0 {
+		t.Errorf("Due before the deadline = %d instances", len(due))
+	}
+	if due, _ := st.Due(ctx, now.Add(time.Hour)); len(due) != 1 {
+		t.Errorf("Due at the deadline = %d instances, want 1", len(due))
+	}
+	if actors, _ := st.Actors(ctx); len(actors) != 1 || actors[0] != "u1" {
+		t.Errorf("Actors = %v", actors)
+	}
+
+	n, err := st.PurgeFinished(ctx, map[string]time.Time{"wf_checkout": now.Add(time.Minute)})
+	if err != nil || n != 1 {
+		t.Fatalf("PurgeFinished = %d, %v; want 1", n, err)
+	}
+	if running, _ := st.Running(ctx, "u1"); len(running) != 1 {
+		t.Error("PurgeFinished deleted a running instance")
+	}
+}
@@ -0,0 +1,111 @@
+package workflow
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/event"
+)
+
+// Status is the lifecycle state of an Instance.
+type Status string
+
+const (
+	StatusRunning   Status = "running"
+	StatusCompleted Status = "completed"
+	StatusFailed    Status = "failed"    // a step's action failed; earlier steps compensated
+	StatusTimedOut  Status = "timed_out" // an awaited event never arrived; earlier steps compensated
+)
+
+// Instance is one running (or finished) saga for a single actor.
+type Instance struct {
+	ID         string       `json:"id"`
+	WorkflowID string       `json:"workflow_id"`
+	ActorID    string       `json:"actor_id"`
+	Step       int          `json:"step"` // index of the step being awaited
+	Status     Status       `json:"status"`
+	StartedAt  time.Time    `json:"started_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	Deadline   time.Time    `json:"deadline,omitempty"` // zero = no timeout for the current step
+	Event      *event.Event `json:"event"`              // last event that advanced the saga
+	Error      string       `json:"error,omitempty"`
+}
+
+// Store persists workflow instances.
+type Store interface {
+	// Save inserts or replaces inst.
+	Save(ctx context.Context, inst *Instance) error
+	// Running returns the running instances for actorID.
+	Running(ctx context.Context, actorID string) ([]*Instance, error)
+	// Due returns running instances whose deadline is at or before now.
+	Due(ctx context.Context, now time.Time) ([]*Instance, error)
+	// Actors returns the IDs of actors with running instances.
+	Actors(ctx context.Context) ([]string, error)
+}
+
+// MemoryStore is a process-local Store. Finished instances are dropped, so it
+// suits tests and deployments that can afford to lose in-flight sagas on restart.
+type MemoryStore struct {
+	mu      sync.Mutex
+	byActor map[string]map[string]*Instance
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{byActor: make(map[string]map[string]*Instance)}
+}
+
+func (s *MemoryStore) Save(_ context.Context, inst *Instance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.byActor[inst.ActorID]
+	if inst.Status != StatusRunning {
+		delete(m, inst.ID)
+		if len(m) == 0 {
+			delete(s.byActor, inst.ActorID)
+		}
+		return nil
+	}
+	if m == nil {
+		m = make(map[string]*Instance)
+		s.byActor[inst.ActorID] = m
+	}
+	cp := *inst
+	m[inst.ID] = &cp
+	return nil
+}
+
+func (s *MemoryStore) Running(_ context.Context, actorID string) ([]*Instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*Instance, 0, len(s.byActor[actorID]))
+	for _, inst := range s.byActor[actorID] {
+		cp := *inst
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Due(_ context.Context, now time.Time) ([]*Instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*Instance
+	for _, m := range s.byActor {
+		for _, inst := range m {
+			if !inst.Deadline.IsZero() && !inst.Deadline.After(now) {
+				cp := *inst
+				out = append(out, &cp)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) Actors(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Collect(maps.Keys(s.byActor)), nil
+}
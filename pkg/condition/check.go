@@ -0,0 +1,144 @@
+package condition
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FieldKind is the statically known type of an operand.
+type FieldKind int
+
+const (
+	KindUnknown FieldKind = iota // resolved at runtime (e.g. payload fields)
+	KindNumber
+	KindString
+	KindBool
+)
+
+func (k FieldKind) String() string {
+	switch k {
+	case KindNumber:
+		return "number"
+	case KindString:
+		return "string"
+	case KindBool:
+		return "bool"
+	}
+	return "unknown"
+}
+
+// Schema describes which field paths exist at build time.
+type Schema interface {
+	// Lookup returns the static kind of path, or an error if the path can never resolve.
+	Lookup(path []string) (FieldKind, error)
+}
+
+// Check validates every field path in expr against schema and rejects
+// comparisons whose operand kinds are statically incompatible, so that
+// mistakes surface when rules are built instead of as runtime errors.
+func Check(expr Expr, schema Schema) error {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		if err := Check(e.Left, schema); err != nil {
+			return err
+		}
+		return Check(e.Right, schema)
+	case *NotExpr:
+		return Check(e.Expr, schema)
+	case *ComparisonExpr:
+		return checkComparison(e, schema)
+	case *ConstExpr:
+		return nil
+	default:
+		return fmt.Errorf("unknown expr type %T", expr)
+	}
+}
+
+func checkComparison(e *ComparisonExpr, schema Schema) error {
+	lk, err := operandKind(e.Left, schema)
+	if err != nil {
+		return err
+	}
+	rk, err := operandKind(e.Right, schema)
+	if err != nil {
+		return err
+	}
+	switch e.Op {
+	case OpGt, OpGte, OpLt, OpLte:
+		for _, side := range []struct {
+			op   Operand
+			kind FieldKind
+		}{{e.Left, lk}, {e.Right, rk}} {
+			if side.kind != KindUnknown && side.kind != KindNumber {
+				return fmt.Errorf("%s %s %s: operator %s requires numeric operands, %s is %s",
+					OperandString(e.Left), e.Op, OperandString(e.Right), e.Op, OperandString(side.op), side.kind)
+			}
+		}
+	case OpContains, OpMatches:
+		if lk != KindUnknown && lk != KindString {
+			return fmt.Errorf("%s %s %s: left operand must be a string, %s is %s",
+				OperandString(e.Left), e.Op, OperandString(e.Right), OperandString(e.Left), lk)
+		}
+		if e.Op == OpMatches {
+			if lit, ok := e.Right.(*LiteralOperand); ok {
+				pattern, ok := lit.Value.(string)
+				if !ok {
+					return fmt.Errorf("matches: pattern must be a string, got %s", OperandString(e.Right))
+				}
+				if _, err := regexp.Compile(pattern); err != nil {
+					return fmt.Errorf("matches: invalid regex %q: %w", pattern, err)
+				}
+			}
+		}
+	case OpEq, OpNeq:
+	default:
+		return fmt.Errorf("unknown operator: %s", e.Op)
+	}
+	return nil
+}
+
+func operandKind(op Operand, schema Schema) (FieldKind, error) {
+	switch o := op.(type) {
+	case *LiteralOperand:
+		switch o.Value.(type) {
+		case float64:
+			return KindNumber, nil
+		case string:
+			return KindString, nil
+		case bool:
+			return KindBool, nil
+		}
+		return KindUnknown, nil
+	case *FieldOperand:
+		k, err := schema.Lookup(o.Path)
+		if err != nil {
+			return KindUnknown, fmt.Errorf("field %q: %w", strings.Join(o.Path, "."), err)
+		}
+		return k, nil
+	case *FuncOperand:
+		if _, err := operandKind(o.Arg, schema); err != nil {
+			return KindUnknown, err
+		}
+		fn, _ := o.function()
+		return fn.kind, nil
+	default:
+		return KindUnknown, fmt.Errorf("unknown operand type %T", op)
+	}
+}
+
+// OperandString renders an operand as it would appear in an expression.
+func OperandString(op Operand) string {
+	switch o := op.(type) {
+	case *FieldOperand:
+		return strings.Join(o.Path, ".")
+	case *LiteralOperand:
+		if s, ok := o.Value.(string); ok {
+			return fmt.Sprintf("%q", s)
+		}
+		return fmt.Sprintf("%v", o.Value)
+	case *FuncOperand:
+		return funcString(o)
+	}
+	return fmt.Sprintf("%v", op)
+}
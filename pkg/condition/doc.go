@@ -0,0 +1,59 @@
+// Package condition implements the boolean expression language used by
+// scenario conditions: a tokenizer and recursive-descent parser producing an
+// AST, a static checker, a constant folder, and an evaluator. It has no
+// dependency on the rules engine, so other services can evaluate the same
+// expressions against their own data.
+//
+// # Grammar
+//
+//	expr       = and_expr { "OR" and_expr }
+//	and_expr   = not_expr { "AND" not_expr }
+//	not_expr   = "NOT" not_expr | "(" expr ")" | comparison
+//	comparison = operand operator operand
+//	operator   = "==" | "!=" | ">" | ">=" | "<" | "<=" | "contains" | "matches"
+//	operand    = literal | field | name "(" operand ")"
+//	field      = ident { "." ident }
+//	literal    = string | number | duration | "true" | "false"
+//	string     = '"' … '"' | "'" … "'"     (\" \' and \\ escape)
+//	number     = [ "-" ] digits [ "." digits ]
+//	duration   = number unit { number unit }   (ns us ms s m h d w)
+//
+// Keywords, true/false and function names are case-insensitive; field paths
+// are not. AND binds tighter than OR and both short-circuit. A # starts a
+// comment running to the end of the line, and a backslash ending a line
+// joins it with the next.
+//
+// # Values
+//
+// Numbers of any Go numeric type compare by value as float64. Duration
+// literals are seconds, so age(event.occurred_at) < 1h reads naturally.
+// == and != never fail: booleans only equal booleans, and other mixed kinds
+// compare their formatted text. The ordering operators need numbers on both
+// sides, contains and matches a string on the left. matches takes an RE2
+// pattern.
+//
+// # Functions
+//
+// Every function takes one argument. The built-ins are age, hour, weekday,
+// day and month on timestamps (RFC 3339 strings, time.Time or epoch
+// seconds), and the casts int, float, string and time. The calendar
+// functions use the zone of an EvalContext that implements Locator, UTC
+// otherwise. Register adds functions; Functions lists them. A Funcs set adds
+// functions only to the expressions it parses.
+//
+// # Use
+//
+//	expr, err := condition.Parse(`payload.amount >= 100 AND payload.currency == "INR"`)
+//	if err != nil {
+//		return err
+//	}
+//	ok, err := condition.Evaluate(condition.Fold(expr), condition.Map{
+//		"payload": map[string]interface{}{"amount": 120.0, "currency": "INR"},
+//	})
+//
+// Parsed expressions are immutable and safe to evaluate concurrently.
+// Check validates field paths and operand kinds ahead of time against a
+// Schema. The exported API follows semantic versioning with the rest of the
+// repository: names are only added, never changed or removed, within a major
+// version.
+package condition
@@ -0,0 +1,141 @@
+package condition
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EvalContext provides data for expression evaluation. Resolve returns the
+// value at a field path, split on dots, and false when there is none.
+type EvalContext interface {
+	Resolve(path []string) (interface{}, bool)
+}
+
+// Map is an EvalContext over nested maps, for callers whose data is already
+// decoded JSON: Map{"payload": map[string]interface{}{"amount": 120.0}}
+// resolves payload.amount to 120.
+type Map map[string]interface{}
+
+// Resolve walks path through nested maps.
+func (m Map) Resolve(path []string) (interface{}, bool) {
+	var cur interface{} = map[string]interface{}(m)
+	for _, key := range path {
+		var ok bool
+		switch obj := cur.(type) {
+		case map[string]interface{}:
+			cur, ok = obj[key]
+		case Map:
+			cur, ok = obj[key]
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// MatchLimiter is optionally implemented by an EvalContext to cap the length
+// of strings fed to the matches operator. Zero means unlimited.
+type MatchLimiter interface {
+	MaxMatchInput() int
+}
+
+// Evaluate walks the AST and returns true/false or an error.
+func Evaluate(expr Expr, ctx EvalContext) (bool, error) {
+	switch e := expr.(type) {
+	case *BinaryExpr:
+		return evalBinary(e, ctx)
+	case *NotExpr:
+		v, err := Evaluate(e.Expr, ctx)
+		if err != nil {
+			return false, err
+		}
+		return !v, nil
+	case *ComparisonExpr:
+		return evalComparison(e, ctx)
+	case *ConstExpr:
+		return e.Value, nil
+	default:
+		return false, fmt.Errorf("unknown expr type %T", expr)
+	}
+}
+
+func evalBinary(e *BinaryExpr, ctx EvalContext) (bool, error) {
+	left, err := Evaluate(e.Left, ctx)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToUpper(e.Op) {
+	case "AND":
+		if !left {
+			return false, nil // short-circuit
+		}
+		return Evaluate(e.Right, ctx)
+	case "OR":
+		if left {
+			return true, nil // short-circuit
+		}
+		return Evaluate(e.Right, ctx)
+	default:
+		return false, fmt.Errorf("unknown binary op %q", e.Op)
+	}
+}
+
+func evalComparison(e *ComparisonExpr, ctx EvalContext) (bool, error) {
+	left, err := resolveOperand(e.Left, ctx)
+	if err != nil {
+		return false, err
+	}
+	right, err := resolveOperand(e.Right, ctx)
+	if err != nil {
+		return false, err
+	}
+	if e.Op == OpMatches {
+		if lim, ok := ctx.(MatchLimiter); ok {
+			if s, ok := left.(string); ok && lim.MaxMatchInput() > 0 && len(s) > lim.MaxMatchInput() {
+				return false, fmt.Errorf("%s: input of %d bytes exceeds matches limit %d",
+					OperandString(e.Left), len(s), lim.MaxMatchInput())
+			}
+		}
+	}
+	ok, err := compare(e.Op, left, right)
+	if err != nil {
+		// Name both sides: with field-vs-field comparisons the bare
+		// operand types alone don't say which field carried bad data.
+		return false, fmt.Errorf("%s %s %s: %w",
+			describeOperand(e.Left, left), e.Op, describeOperand(e.Right, right), err)
+	}
+	return ok, nil
+}
+
+// describeOperand renders a field operand with its runtime value and type.
+func describeOperand(op Operand, val interface{}) string {
+	if _, ok := op.(*FieldOperand); ok {
+		return fmt.Sprintf("%s (%T %v)", OperandString(op), val, val)
+	}
+	return OperandString(op)
+}
+
+// ResolveOperand returns the value of op in ctx, calling functions as
+// Evaluate would. It serves callers that use operands outside a comparison,
+// such as the terms of an arithmetic formula.
+func ResolveOperand(op Operand, ctx EvalContext) (interface{}, error) {
+	return resolveOperand(op, ctx)
+}
+
+func resolveOperand(op Operand, ctx EvalContext) (interface{}, error) {
+	switch o := op.(type) {
+	case *LiteralOperand:
+		return o.Value, nil
+	case *FieldOperand:
+		val, ok := ctx.Resolve(o.Path)
+		if !ok {
+			return nil, fmt.Errorf("field %q not found", strings.Join(o.Path, "."))
+		}
+		return val, nil
+	case *FuncOperand:
+		return resolveFunc(o, ctx)
+	default:
+		return nil, fmt.Errorf("unknown operand type %T", op)
+	}
+}
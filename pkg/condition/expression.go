@@ -5,6 +5,8 @@ import (
 	"strconv"
 	"strings"
 	"unicode"
+
+	"github.com/gyaneshwarpardhi/ifttt/internal/duration"
 )
 
 // -----------------------------------------------------------------------
@@ -71,11 +73,12 @@ func (*FieldOperand) operandNode() {}
 type tokenKind int
 
 const (
-	tokWord   tokenKind = iota // identifier or keyword
-	tokOp                      // ==, !=, >=, <=, >, <
-	tokString                  // "…" or '…'
-	tokNumber                  // 42 | 3.14
-	tokBool                    // true | false
+	tokWord     tokenKind = iota // identifier or keyword
+	tokOp                        // ==, !=, >=, <=, >, <
+	tokString                    // "…" or '…'
+	tokNumber                    // 42 | 3.14
+	tokDuration                  // 90s | 1h | 7d
+	tokBool                      // true | false
 	tokLParen
 	tokRParen
 	tokEOF
@@ -96,6 +99,25 @@ func tokenize(expr string) ([]token, error) {
 			i++
 			continue
 		}
+		// Comments run from # to the end of the line.
+		if ch == '#' {
+			for i < len(expr) && expr[i] != '\n' {
+				i++
+			}
+			continue
+		}
+		// A backslash ending a line continues the expression on the next one.
+		if ch == '\\' {
+			j := i + 1
+			for j < len(expr) && (expr[j] == ' ' || expr[j] == '\t' || expr[j] == '\r') {
+				j++
+			}
+			if j == len(expr) || expr[j] == '\n' {
+				i = j + 1
+				continue
+			}
+			return nil, fmt.Errorf("unexpected '\\' at position %d (a line continuation must end the line)", i)
+		}
 		// Parentheses.
 		if ch == '(' {
 			tokens = append(tokens, token{tokLParen, "("})
@@ -162,6 +184,15 @@ func tokenize(expr string) ([]token, error) {
 			for j < len(expr) && (unicode.IsDigit(rune(expr[j])) || expr[j] == '.') {
 				j++
 			}
+			// A unit suffix makes it a duration literal: 5m, 24h, 1h30m.
+			if j < len(expr) && unicode.IsLetter(rune(expr[j])) {
+				for j < len(expr) && (unicode.IsLetter(rune(expr[j])) || unicode.IsDigit(rune(expr[j])) || expr[j] == '.') {
+					j++
+				}
+				tokens = append(tokens, token{tokDuration, expr[i:j]})
+				i = j
+				continue
+			}
 			tokens = append(tokens, token{tokNumber, expr[i:j]})
 			i = j
 			continue
@@ -195,6 +226,7 @@ func tokenize(expr string) ([]token, error) {
 type parser struct {
 	tokens []token
 	pos    int
+	local  map[string]function // Funcs.Parse's functions
 }
 
 func (p *parser) peek() token {
@@ -216,11 +248,15 @@ func (p *parser) expect(kind tokenKind, val string) error {
 
 // Parse parses an expression string into an AST.
 func Parse(expr string) (Expr, error) {
+	return parse(expr, nil)
+}
+
+func parse(expr string, local map[string]function) (Expr, error) {
 	tokens, err := tokenize(expr)
 	if err != nil {
 		return nil, err
 	}
-	p := &parser{tokens: tokens}
+	p := &parser{tokens: tokens, local: local}
 	node, err := p.parseOr()
 	if err != nil {
 		return nil, err
@@ -319,7 +355,7 @@ func (p *parser) parseComparison() (Expr, error) {
 	return &ComparisonExpr{Left: left, Op: op, Right: right}, nil
 }
 
-// operand = field_path | literal
+// operand = field_path | literal | func "(" operand ")"
 func (p *parser) parseOperand() (Operand, error) {
 	t := p.peek()
 	switch t.kind {
@@ -340,14 +376,45 @@ func (p *parser) parseOperand() (Operand, error) {
 			return nil, fmt.Errorf("invalid integer %q", t.val)
 		}
 		return &LiteralOperand{Value: float64(n)}, nil
+	case tokDuration:
+		// Durations compare as seconds, matching what age() returns.
+		p.consume()
+		d, err := duration.Parse(t.val)
+		if err != nil {
+			return nil, err
+		}
+		return &LiteralOperand{Value: d.Seconds()}, nil
 	case tokBool:
 		p.consume()
 		return &LiteralOperand{Value: t.val == "true"}, nil
 	case tokWord:
 		p.consume()
+		if p.peek().kind == tokLParen {
+			return p.parseCall(t.val)
+		}
 		// Field path: split on '.' (already in token since tokenizer includes dots).
 		return &FieldOperand{Path: strings.Split(t.val, ".")}, nil
 	default:
 		return nil, fmt.Errorf("expected operand, got %q", t.val)
 	}
 }
+
+// parseCall parses the argument list of a function whose name was just consumed.
+func (p *parser) parseCall(name string) (Operand, error) {
+	name = strings.ToLower(name)
+	var local *function
+	if fn, ok := p.local[name]; ok {
+		local = &fn
+	} else if _, ok := funcs[name]; !ok {
+		return nil, fmt.Errorf("unknown function %q", name)
+	}
+	p.consume() // (
+	arg, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(tokRParen, ")"); err != nil {
+		return nil, err
+	}
+	return &FuncOperand{Name: name, Arg: arg, local: local}, nil
+}
@@ -0,0 +1,589 @@
+package condition
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockCtx implements EvalContext for tests.
+type mockCtx struct {
+	data map[string]interface{}
+}
+
+func (m *mockCtx) Resolve(path []string) (interface{}, bool) {
+	if len(path) == 0 {
+		return nil, false
+	}
+	v, ok := m.data[path[0]]
+	if !ok || len(path) == 1 {
+		return v, ok
+	}
+	sub, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return (&mockCtx{data: sub}).Resolve(path[1:])
+}
+
+func ctx(kv ...interface{}) *mockCtx {
+	m := &mockCtx{data: make(map[string]interface{})}
+	for i := 0; i < len(kv)-1; i += 2 {
+		m.data[kv[i].(string)] = kv[i+1]
+	}
+	return m
+}
+
+type evalCase struct {
+	name    string
+	expr    string
+	ctx     EvalContext
+	want    bool
+	wantErr bool
+}
+
+func TestEvaluate(t *testing.T) {
+	cases := []evalCase{
+		// Numeric comparisons
+		{
+			name: "gt true",
+			expr: "amount > 1000",
+			ctx:  ctx("amount", float64(1500)),
+			want: true,
+		},
+		{
+			name: "gt false",
+			expr: "amount > 1000",
+			ctx:  ctx("amount", float64(500)),
+			want: false,
+		},
+		{
+			name: "gte equal",
+			expr: "amount >= 1000",
+			ctx:  ctx("amount", float64(1000)),
+			want: true,
+		},
+		{
+			name: "lt true",
+			expr: "amount < 100",
+			ctx:  ctx("amount", float64(50)),
+			want: true,
+		},
+		// String equality
+		{
+			name: "eq string true",
+			expr: `category == "food"`,
+			ctx:  ctx("category", "food"),
+			want: true,
+		},
+		{
+			name: "eq string false",
+			expr: `category == "food"`,
+			ctx:  ctx("category", "electronics"),
+			want: false,
+		},
+		{
+			name: "neq string",
+			expr: `category != "food"`,
+			ctx:  ctx("category", "electronics"),
+			want: true,
+		},
+		// Boolean
+		{
+			name: "bool eq true",
+			expr: "is_first_login == true",
+			ctx:  ctx("is_first_login", true),
+			want: true,
+		},
+		{
+			name: "bool eq false literal",
+			expr: "is_first_login == false",
+			ctx:  ctx("is_first_login", true),
+			want: false,
+		},
+		// AND / OR
+		{
+			name: "AND both true",
+			expr: `category == "food" AND amount > 500`,
+			ctx:  ctx("category", "food", "amount", float64(1000)),
+			want: true,
+		},
+		{
+			name: "AND first false",
+			expr: `category == "food" AND amount > 500`,
+			ctx:  ctx("category", "clothing", "amount", float64(1000)),
+			want: false,
+		},
+		{
+			name: "OR first true",
+			expr: `category == "food" OR amount > 500`,
+			ctx:  ctx("category", "clothing", "amount", float64(1000)),
+			want: true,
+		},
+		{
+			name: "OR both false",
+			expr: `category == "food" OR amount > 500`,
+			ctx:  ctx("category", "clothing", "amount", float64(10)),
+			want: false,
+		},
+		// NOT
+		{
+			name: "NOT true",
+			expr: `NOT amount > 1000`,
+			ctx:  ctx("amount", float64(500)),
+			want: true,
+		},
+		// contains
+		{
+			name: "contains true",
+			expr: `tags contains "vip"`,
+			ctx:  ctx("tags", "vip-member"),
+			want: true,
+		},
+		{
+			name: "contains false",
+			expr: `tags contains "vip"`,
+			ctx:  ctx("tags", "regular"),
+			want: false,
+		},
+		// matches (regex)
+		{
+			name: "matches true",
+			expr: `email matches ".*@example\\.com"`,
+			ctx:  ctx("email", "user@example.com"),
+			want: true,
+		},
+		{
+			name: "matches false",
+			expr: `email matches ".*@example\\.com"`,
+			ctx:  ctx("email", "user@other.com"),
+			want: false,
+		},
+		// Comments and line continuations
+		{
+			name: "comments across lines",
+			expr: "# big spenders only\namount > 1000 # threshold from finance\nAND tier == \"gold\"  # not silver\n",
+			ctx:  ctx("amount", float64(1500), "tier", "gold"),
+			want: true,
+		},
+		{
+			name: "hash inside string is not a comment",
+			expr: `tag == "#vip"`,
+			ctx:  ctx("tag", "#vip"),
+			want: true,
+		},
+		{
+			name: "line continuation",
+			expr: "amount > 1000 AND \\  \n  tier == \"gold\"",
+			ctx:  ctx("amount", float64(1500), "tier", "silver"),
+			want: false,
+		},
+		// Nested field (handled by Resolve in real ctx; mock supports one level)
+		// Error cases
+		{
+			name:    "unknown field",
+			expr:    "missing > 10",
+			ctx:     ctx("amount", float64(100)),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ast, err := Parse(tc.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.expr, err)
+			}
+			got, err := Evaluate(ast, tc.ctx)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil (result=%v)", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tc.expr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	cases := []string{
+		`"unterminated`,
+		`amount 1000`, // missing operator
+		``,            // empty (will fail at comparison level)
+		`# only a comment`,
+		`amount > \ 1000`, // backslash not at end of line
+	}
+	for _, expr := range cases {
+		t.Run(expr, func(t *testing.T) {
+			_, err := Parse(expr)
+			if err == nil {
+				t.Errorf("expected parse error for %q, got nil", expr)
+			}
+		})
+	}
+}
+
+func TestEvaluate_FieldVsField(t *testing.T) {
+	cases := []evalCase{
+		{name: "numeric gt", expr: "spent > budget", ctx: ctx("spent", float64(120), "budget", float64(100)), want: true},
+		{name: "numeric lte", expr: "spent <= budget", ctx: ctx("spent", float64(120), "budget", float64(100)), want: false},
+		{name: "int vs float", expr: "spent == budget", ctx: ctx("spent", 100, "budget", float64(100)), want: true},
+		{name: "string eq", expr: "home == current", ctx: ctx("home", "IN", "current", "IN"), want: true},
+		{name: "string neq", expr: "home != current", ctx: ctx("home", "IN", "current", "US"), want: true},
+		{name: "mixed types ordering", expr: "spent > budget", ctx: ctx("spent", "lots", "budget", float64(100)), wantErr: true},
+		{name: "missing right field", expr: "spent > budget", ctx: ctx("spent", float64(1)), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ast, err := Parse(tc.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.expr, err)
+			}
+			got, err := Evaluate(ast, tc.ctx)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got nil (result=%v)", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tc.expr, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate_MixedTypeErrorNamesFields(t *testing.T) {
+	ast, _ := Parse("spent > budget")
+	_, err := Evaluate(ast, ctx("spent", "lots", "budget", float64(100)))
+	if err == nil || !contains(err.Error(), "spent (string lots)") || !contains(err.Error(), "budget (float64 100)") {
+		t.Errorf("expected error naming both fields, got %v", err)
+	}
+}
+
+// staticSchema treats "n.*" as numbers, "s.*" as strings and rejects everything else.
+type staticSchema struct{}
+
+func (staticSchema) Lookup(path []string) (FieldKind, error) {
+	switch path[0] {
+	case "n":
+		return KindNumber, nil
+	case "s":
+		return KindString, nil
+	case "u":
+		return KindUnknown, nil
+	}
+	return KindUnknown, errors.New("unknown namespace")
+}
+
+func TestCheck(t *testing.T) {
+	cases := []struct {
+		expr    string
+		wantErr bool
+	}{
+		{"n.spent > n.budget", false},
+		{"u.spent > n.budget", false},
+		{"s.name == n.count", false},
+		{"s.name > n.budget", true},
+		{"n.count > \"ten\"", true},
+		{"n.count contains \"1\"", true},
+		{"s.email matches \"[\"", true},
+		{"bad.field == 1", true},
+		{"n.a > 1 AND NOT bad.b == 2", true},
+		{"age(u.at) < 1h", false},
+		{"age(bad.at) < 1h", true},
+		{"int(s.count) > 3", false},
+		{"string(n.code) contains \"0\"", false},
+		{"string(n.code) > 3", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.expr, func(t *testing.T) {
+			ast, err := Parse(tc.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.expr, err)
+			}
+			err = Check(ast, staticSchema{})
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Check(%q) error = %v, wantErr %v", tc.expr, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluate_DurationsAndAge(t *testing.T) {
+	fixed := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return fixed }
+	defer func() { now = time.Now }()
+
+	cases := []evalCase{
+		{name: "duration literal in seconds", expr: "wait == 1h30m", ctx: ctx("wait", float64(5400)), want: true},
+		{name: "days", expr: "wait < 7d", ctx: ctx("wait", float64(6*86400)), want: true},
+		{name: "age of time.Time", expr: "age(at) < 1h", ctx: ctx("at", fixed.Add(-30*time.Minute)), want: true},
+		{name: "age of RFC 3339", expr: "age(at) >= 24h", ctx: ctx("at", "2026-02-28T11:00:00Z"), want: true},
+		{name: "age of epoch seconds", expr: "age(at) > 5m", ctx: ctx("at", float64(fixed.Add(-time.Minute).Unix())), want: false},
+		{name: "age of non-timestamp", expr: "age(at) < 1h", ctx: ctx("at", "yesterday"), wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ast, err := Parse(tc.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tc.expr, err)
+			}
+			got, err := Evaluate(ast, tc.ctx)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Evaluate(%q) error = %v, wantErr %v", tc.expr, err, tc.wantErr)
+			}
+			if err == nil && got != tc.want {
+				t.Errorf("Evaluate(%q) = %v, want %v", tc.expr, got, tc.want)
+			}
+		})
+	}
+
+	for _, expr := range []string{"wait < 5x", "nope(at) < 1h", "age(at < 1h"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("expected parse error for %q", expr)
+		}
+	}
+}
+
+// zonedCtx adds a time zone to mockCtx.
+type zonedCtx struct {
+	*mockCtx
+	loc *time.Location
+}
+
+func (z zonedCtx) Location() *time.Location { return z.loc }
+
+func TestEvaluate_CalendarFunctionsUseLocation(t *testing.T) {
+	ist, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("tzdata unavailable")
+	}
+	at := time.Date(2026, 3, 1, 13, 0, 0, 0, time.UTC) // 18:30 in Kolkata, a Sunday
+
+	ast, _ := Parse("hour(at) >= 18 AND weekday(at) == 0")
+	if ok, err := Evaluate(ast, ctx("at", at)); err != nil || ok {
+		t.Errorf("in UTC 13:00 is not evening: ok=%v err=%v", ok, err)
+	}
+	if ok, err := Evaluate(ast, zonedCtx{ctx("at", at), ist}); err != nil || !ok {
+		t.Errorf("in Asia/Kolkata 18:30 is evening: ok=%v err=%v", ok, err)
+	}
+}
+
+func TestEvaluate_Casts(t *testing.T) {
+	c := ctx("count", "12.7", "code", float64(7), "flag", true, "ts", "1772366400", "at", "2026-03-01T12:00:00Z", "bad", "n/a")
+	for _, expr := range []string{
+		`int(count) == 12`,
+		`float(count) > 12.5`,
+		`string(code) == "7"`,
+		`int(flag) == 1`,
+		`time(ts) == time(at)`,
+		`hour(time(ts)) == 12`,
+	} {
+		ast, err := Parse(expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) error: %v", expr, err)
+		}
+		if ok, err := Evaluate(ast, c); err != nil || !ok {
+			t.Errorf("%s: ok=%v err=%v", expr, ok, err)
+		}
+	}
+
+	ast, _ := Parse("int(bad) > 1")
+	if _, err := Evaluate(ast, c); err == nil || !strings.Contains(err.Error(), `int(bad): cannot convert "n/a" to a number`) {
+		t.Errorf("expected cast error naming the operand, got %v", err)
+	}
+}
+
+func TestFold(t *testing.T) {
+	cases := []struct {
+		expr string
+		want string // "true", "false", or "kept" when not fully decided
+	}{
+		{`1 == 1`, "true"},
+		{`"off" == "on" AND payload.amount > 10`, "false"},
+		{`"on" == "on" OR payload.amount > 10`, "true"},
+		{`NOT ("beta" == "beta")`, "false"},
+		{`payload.amount > 10 AND 2 > 1`, "kept"},
+		{`age("2020-01-01T00:00:00Z") > 1`, "kept"}, // depends on when it runs
+	}
+	for _, c := range cases {
+		ast, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", c.expr, err)
+		}
+		folded := Fold(ast)
+		got := "kept"
+		if k, ok := folded.(*ConstExpr); ok {
+			got = fmt.Sprint(k.Value)
+		}
+		if got != c.want {
+			t.Errorf("Fold(%s) = %s, want %s", c.expr, got, c.want)
+		}
+	}
+
+	// A partially constant AND keeps only the live side and evaluates the same.
+	ast, _ := Parse(`payload.amount > 10 AND 2 > 1`)
+	folded := Fold(ast)
+	if _, ok := folded.(*ComparisonExpr); !ok {
+		t.Errorf("expected the constant side to drop out, got %T", folded)
+	}
+	if ok, err := Evaluate(folded, ctx("payload", map[string]interface{}{"amount": float64(20)})); err != nil || !ok {
+		t.Errorf("folded expression evaluated to %v, %v", ok, err)
+	}
+}
+
+func TestMap_Resolve(t *testing.T) {
+	m := Map{
+		"payload": map[string]interface{}{"amount": 120.0, "tags": []interface{}{"a"}},
+		"meta":    Map{"region": "in"},
+	}
+	ok, err := Evaluate(mustParse(t, `payload.amount > 100 AND meta.region == "in"`), m)
+	if err != nil || !ok {
+		t.Fatalf("Evaluate = %v, %v", ok, err)
+	}
+	for _, path := range []string{"payload.missing", "payload.amount.x", "payload.tags.0", "nope"} {
+		if v, ok := m.Resolve(strings.Split(path, ".")); ok {
+			t.Errorf("Resolve(%s) = %v, want not found", path, v)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("Len", KindNumber, func(arg interface{}, _ EvalContext) (interface{}, error) {
+		s, ok := arg.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot take the length of %T", arg)
+		}
+		return float64(len(s)), nil
+	})
+	defer delete(funcs, "len")
+
+	ok, err := Evaluate(mustParse(t, `len(payload.code) == 4`), Map{"payload": map[string]interface{}{"code": "ABCD"}})
+	if err != nil || !ok {
+		t.Fatalf("Evaluate = %v, %v", ok, err)
+	}
+	found := false
+	for _, name := range Functions() {
+		found = found || name == "len"
+	}
+	if !found {
+		t.Errorf("Functions() = %v, want len listed", Functions())
+	}
+
+	for _, name := range []string{"len", "age", "", "a.b", "9x"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", name)
+				}
+			}()
+			Register(name, KindNumber, ageFunc)
+		}()
+	}
+}
+
+func TestFuncs(t *testing.T) {
+	fs := NewFuncs()
+	fs.Register("Double", KindNumber, func(arg interface{}, _ EvalContext) (interface{}, error) {
+		f, _ := toFloat64(arg)
+		return 2 * f, nil
+	})
+
+	ast, err := fs.Parse(`double(payload.n) == 8 AND age(payload.at) > 0`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	// Check knows the result kind of the set's functions.
+	if bad, _ := fs.Parse(`double(u.x) contains "1"`); Check(bad, staticSchema{}) == nil {
+		t.Error("Check accepted contains on a number")
+	}
+	ok, err := Evaluate(Fold(ast), Map{"payload": map[string]interface{}{"n": 4.0, "at": "2020-01-01T00:00:00Z"}})
+	if err != nil || !ok {
+		t.Errorf("Evaluate = %v, %v", ok, err)
+	}
+
+	// The set's functions stay out of the package registry.
+	if _, err := Parse(`double(payload.n) == 8`); err == nil || !strings.Contains(err.Error(), "unknown function") {
+		t.Errorf("Parse = %v, want an unknown function", err)
+	}
+	if names := fs.Names(); !slices.Contains(names, "double") || !slices.Contains(names, "age") {
+		t.Errorf("Names() = %v, want double and the registered functions", names)
+	}
+	if slices.Contains(Functions(), "double") {
+		t.Errorf("Functions() = %v lists a Funcs function", Functions())
+	}
+
+	for _, name := range []string{"double", "age", "", "a.b"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Funcs.Register(%q) did not panic", name)
+				}
+			}()
+			fs.Register(name, KindNumber, ageFunc)
+		}()
+	}
+}
+
+func mustParse(t *testing.T, expr string) Expr {
+	t.Helper()
+	ast, err := Parse(expr)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", expr, err)
+	}
+	return ast
+}
+
+// benchExpr is a typical rule condition: a few comparisons, a function call
+// and a duration literal.
+const benchExpr = `payload.category == "food" AND (payload.amount > 1000 OR meta.tier == "gold") AND NOT payload.note contains "test" AND float(payload.tax) < 50 AND 90s < 2h`
+
+func BenchmarkTokenize(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchExpr)))
+	for i := 0; i < b.N; i++ {
+		if _, err := tokenize(benchExpr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(benchExpr)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(benchExpr); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEvaluate(b *testing.B) {
+	ast, err := Parse(benchExpr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := &mockCtx{data: map[string]interface{}{
+		"payload": map[string]interface{}{"category": "food", "amount": 1500.0, "note": "", "tax": "12.5"},
+		"meta":    map[string]interface{}{"tier": "silver"},
+	}}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if ok, err := Evaluate(ast, ctx); err != nil || !ok {
+			b.Fatalf("Evaluate = %v, %v", ok, err)
+		}
+	}
+}
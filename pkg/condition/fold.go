@@ -0,0 +1,66 @@
+package condition
+
+import "strings"
+
+// ConstExpr is an expression whose value Fold decided when the rules were
+// built.
+type ConstExpr struct {
+	Value bool
+}
+
+func (*ConstExpr) exprNode() {}
+
+// Fold simplifies expr by evaluating comparisons between two literals and
+// propagating the results through NOT, AND and OR, so generated rules with
+// constant feature-flag checks (`"off" == "on" AND …`) cost nothing at
+// runtime. It returns a *ConstExpr when the whole expression is decided.
+//
+// Field and function operands are never folded, since age() and the
+// calendar functions depend on when they run. A literal comparison that
+// fails to evaluate is kept so the error still surfaces at runtime. expr is
+// not modified; shared subtrees are reused where nothing changed.
+func Fold(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *ComparisonExpr:
+		l, lok := e.Left.(*LiteralOperand)
+		r, rok := e.Right.(*LiteralOperand)
+		if lok && rok {
+			if v, err := compare(e.Op, l.Value, r.Value); err == nil {
+				return &ConstExpr{Value: v}
+			}
+		}
+		return e
+	case *NotExpr:
+		inner := Fold(e.Expr)
+		if c, ok := inner.(*ConstExpr); ok {
+			return &ConstExpr{Value: !c.Value}
+		}
+		if inner == e.Expr {
+			return e
+		}
+		return &NotExpr{Expr: inner}
+	case *BinaryExpr:
+		and := strings.EqualFold(e.Op, "AND")
+		if !and && !strings.EqualFold(e.Op, "OR") {
+			return e
+		}
+		left, right := Fold(e.Left), Fold(e.Right)
+		// AND is decided by a false side and OR by a true one; the
+		// other constant is the identity and drops out.
+		decisive := !and
+		lc, lok := left.(*ConstExpr)
+		rc, rok := right.(*ConstExpr)
+		switch {
+		case lok && lc.Value == decisive, rok && rc.Value == decisive:
+			return &ConstExpr{Value: decisive}
+		case lok:
+			return right
+		case rok:
+			return left
+		case left == e.Left && right == e.Right:
+			return e
+		}
+		return &BinaryExpr{Op: e.Op, Left: left, Right: right}
+	}
+	return expr
+}
@@ -0,0 +1,276 @@
+package condition
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// now is the clock used by time functions; tests may replace it.
+var now = time.Now
+
+// FuncOperand is a function call on a field, e.g. age(event.occurred_at).
+type FuncOperand struct {
+	Name string
+	Arg  Operand
+
+	local *function // set when Name came from a Funcs set
+}
+
+func (*FuncOperand) operandNode() {}
+
+// Locator is optionally implemented by an EvalContext to supply the time
+// zone used by calendar functions such as hour(). UTC is used otherwise.
+type Locator interface {
+	Location() *time.Location
+}
+
+// Func is a function callable in expressions. It receives its single
+// argument already resolved, and the context the expression is evaluated
+// against.
+type Func func(arg interface{}, ctx EvalContext) (interface{}, error)
+
+// function is a registered callable.
+type function struct {
+	kind FieldKind // static result kind, for Check
+	call Func
+}
+
+// funcs maps function names to their implementations.
+var funcs = map[string]function{
+	"age":     {KindNumber, ageFunc},
+	"hour":    {KindNumber, calendar(func(t time.Time) int { return t.Hour() })},
+	"weekday": {KindNumber, calendar(func(t time.Time) int { return int(t.Weekday()) })}, // 0 = Sunday
+	"day":     {KindNumber, calendar(func(t time.Time) int { return t.Day() })},
+	"month":   {KindNumber, calendar(func(t time.Time) int { return int(t.Month()) })},
+
+	// Casts, for producers that send numbers as strings and the like.
+	"int":    {KindNumber, intFunc},
+	"float":  {KindNumber, floatFunc},
+	"string": {KindString, stringFunc},
+	"time":   {KindNumber, timeFunc},
+}
+
+// Register makes fn callable in expressions as name(arg). kind is the
+// result kind Check assumes; use KindUnknown when it varies. Names are
+// case-insensitive. Register is meant to be called from init: it is not safe
+// to call while expressions are being parsed or evaluated, and it panics if
+// name is empty, not an identifier, or already registered.
+func Register(name string, kind FieldKind, fn Func) {
+	name = strings.ToLower(name)
+	if fn == nil || !isIdent(name) {
+		panic(fmt.Sprintf("condition: invalid function registration %q", name))
+	}
+	if _, dup := funcs[name]; dup {
+		panic(fmt.Sprintf("condition: function %q already registered", name))
+	}
+	funcs[name] = function{kind, fn}
+}
+
+// Functions returns the names of the registered functions, sorted.
+func Functions() []string {
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Funcs is a private set of functions on top of the registered ones, for
+// functions that only work against one application's EvalContext. Only
+// expressions parsed with its Parse can call them, so they stay out of other
+// users of the package. Like Register, its Register is meant for setup
+// before any Parse.
+type Funcs struct {
+	funcs map[string]function
+}
+
+// NewFuncs creates an empty function set.
+func NewFuncs() *Funcs {
+	return &Funcs{funcs: make(map[string]function)}
+}
+
+// Register adds fn to s as name(arg), as the package-level Register does.
+// It also panics if name is a registered function.
+func (s *Funcs) Register(name string, kind FieldKind, fn Func) {
+	name = strings.ToLower(name)
+	if fn == nil || !isIdent(name) {
+		panic(fmt.Sprintf("condition: invalid function registration %q", name))
+	}
+	_, global := funcs[name]
+	if _, dup := s.funcs[name]; dup || global {
+		panic(fmt.Sprintf("condition: function %q already registered", name))
+	}
+	s.funcs[name] = function{kind, fn}
+}
+
+// Parse is the package-level Parse with s's functions callable.
+func (s *Funcs) Parse(expr string) (Expr, error) {
+	return parse(expr, s.funcs)
+}
+
+// Names returns the names of the registered functions and of s's, sorted.
+func (s *Funcs) Names() []string {
+	names := Functions()
+	for name := range s.funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// function returns what f calls: its Funcs entry, else the registered one.
+func (f *FuncOperand) function() (function, bool) {
+	if f.local != nil {
+		return *f.local, true
+	}
+	fn, ok := funcs[f.Name]
+	return fn, ok
+}
+
+// isIdent reports whether s tokenizes as a single word without dots.
+func isIdent(s string) bool {
+	for i, r := range s {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// ageFunc returns the seconds elapsed since a timestamp, so it compares
+// directly with duration literals: age(event.occurred_at) < 1h.
+func ageFunc(arg interface{}, _ EvalContext) (interface{}, error) {
+	t, err := toTime(arg)
+	if err != nil {
+		return nil, err
+	}
+	return now().Sub(t).Seconds(), nil
+}
+
+// calendar builds a function returning a field of the timestamp in the
+// context's time zone, so hour(event.occurred_at) >= 18 means local evening.
+func calendar(field func(time.Time) int) func(interface{}, EvalContext) (interface{}, error) {
+	return func(arg interface{}, ctx EvalContext) (interface{}, error) {
+		t, err := toTime(arg)
+		if err != nil {
+			return nil, err
+		}
+		loc := time.UTC
+		if l, ok := ctx.(Locator); ok {
+			if tz := l.Location(); tz != nil {
+				loc = tz
+			}
+		}
+		return float64(field(t.In(loc))), nil
+	}
+}
+
+// floatFunc converts a number, numeric string or bool (1/0) to a number.
+func floatFunc(arg interface{}, _ EvalContext) (interface{}, error) {
+	switch v := arg.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("cannot convert %q to a number", v)
+		}
+		return f, nil
+	case bool:
+		if v {
+			return float64(1), nil
+		}
+		return float64(0), nil
+	}
+	if f, ok := toFloat64(arg); ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to a number", arg)
+}
+
+// intFunc is floatFunc truncated toward zero: int("12.7") == 12.
+func intFunc(arg interface{}, ctx EvalContext) (interface{}, error) {
+	f, err := floatFunc(arg, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return math.Trunc(f.(float64)), nil
+}
+
+// stringFunc formats scalars the way producers usually write them: numbers
+// without exponent or trailing zeros (7, 0.5), timestamps as RFC 3339.
+func stringFunc(arg interface{}, _ EvalContext) (interface{}, error) {
+	switch v := arg.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	if f, ok := toFloat64(arg); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to a string", arg)
+}
+
+// timeFunc returns a timestamp as epoch seconds, so timestamps compare with
+// each other and still feed age() and the calendar functions. It also
+// accepts epoch seconds sent as a string.
+func timeFunc(arg interface{}, _ EvalContext) (interface{}, error) {
+	if s, ok := arg.(string); ok {
+		if secs, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+			arg = secs
+		}
+	}
+	t, err := toTime(arg)
+	if err != nil {
+		return nil, err
+	}
+	return float64(t.UnixNano()) / float64(time.Second), nil
+}
+
+// toTime accepts a time.Time, an RFC 3339 string, or epoch seconds.
+func toTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		if t.IsZero() {
+			return time.Time{}, fmt.Errorf("timestamp is not set")
+		}
+		return t, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp %q is not RFC 3339", t)
+		}
+		return parsed, nil
+	}
+	if secs, ok := toFloat64(v); ok {
+		return time.Unix(0, int64(secs*float64(time.Second))), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot use %T as a timestamp", v)
+}
+
+func resolveFunc(f *FuncOperand, ctx EvalContext) (interface{}, error) {
+	fn, ok := f.function()
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", f.Name)
+	}
+	arg, err := resolveOperand(f.Arg, ctx)
+	if err != nil {
+		return nil, err
+	}
+	v, err := fn.call(arg, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", OperandString(f), err)
+	}
+	return v, nil
+}
+
+func funcString(f *FuncOperand) string {
+	return fmt.Sprintf("%s(%s)", strings.ToLower(f.Name), OperandString(f.Arg))
+}
@@ -20,6 +20,11 @@ const (
 	OpMatches  Operator = "matches"
 )
 
+// Operators returns every comparison operator.
+func Operators() []Operator {
+	return []Operator{OpEq, OpNeq, OpGt, OpGte, OpLt, OpLte, OpContains, OpMatches}
+}
+
 // toFloat64 coerces a numeric value to float64.
 func toFloat64(v interface{}) (float64, bool) {
 	switch n := v.(type) {
@@ -71,6 +76,12 @@ func compare(op Operator, left, right interface{}) (bool, error) {
 
 // equal does deep-ish equality: numeric types are compared by value.
 func equal(left, right interface{}) bool {
+	// Fast path for the common string == string case (including field vs field).
+	if ls, ok := left.(string); ok {
+		if rs, ok := right.(string); ok {
+			return ls == rs
+		}
+	}
 	lf, lok := toFloat64(left)
 	rf, rok := toFloat64(right)
 	if lok && rok {